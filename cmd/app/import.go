@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	servicepkg "prsrv/internal/domain"
+	"prsrv/internal/importer"
+	repopg "prsrv/internal/repo"
+)
+
+const importUsage = "usage: app import --format github-json|gitlab-json [--team name] <file>"
+
+// runImport backfills the PRs of a code host export into the database.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "export format: github-json or gitlab-json")
+	team := fs.String("team", "imported", "team of the users that do not exist yet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *format == "" || *team == "" {
+		return errors.New(importUsage)
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	prs, err := importer.Parse(*format, f)
+	if err != nil {
+		return err
+	}
+
+	cfg := loadConfig()
+	db, err := openDB(cfg.DatabaseURL, 0)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	defer db.Close()
+	if err := repopg.RunMigrations(db, cfg.MigrationsDir); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	pii, err := repopg.ParseFieldKeys(cfg.PIIKeys)
+	if err != nil {
+		return err
+	}
+	res, err := servicepkg.NewService(repopg.NewPostgresRepo(db).EncryptPII(pii)).ImportPRs(context.Background(), *team, prs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d PRs, skipped %d existing; created %d users and %d teams\n", res.PRs, res.Skipped, res.Users, res.Teams)
+	return nil
+}
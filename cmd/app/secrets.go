@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// secrets resolves sensitive settings. For a variable K the first of these
+// wins: the K environment variable, the file named by K_FILE, the field K of
+// the Vault secret at VAULT_SECRET_PATH, and finally the built-in default.
+// Lookup errors are collected and reported by config.validate.
+type secrets struct {
+	vault     *vaultSecret
+	vaultRead bool
+	errs      []error
+}
+
+type vaultSecret struct {
+	Addr   string
+	Token  string
+	Path   string
+	Client *http.Client
+	fields map[string]string
+}
+
+func newSecrets() *secrets {
+	s := &secrets{}
+	addr, path := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_SECRET_PATH")
+	if addr == "" || path == "" {
+		return s
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if f := os.Getenv("VAULT_TOKEN_FILE"); f != "" {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				s.errs = append(s.errs, fmt.Errorf("VAULT_TOKEN_FILE: %w", err))
+				return s
+			}
+			token = strings.TrimSpace(string(b))
+		}
+	}
+	if token == "" {
+		s.errs = append(s.errs, errors.New("VAULT_TOKEN or VAULT_TOKEN_FILE must be set with VAULT_ADDR"))
+		return s
+	}
+	s.vault = &vaultSecret{
+		Addr:   strings.TrimSuffix(addr, "/"),
+		Token:  token,
+		Path:   strings.Trim(path, "/"),
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+	return s
+}
+
+func (s *secrets) get(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	if f := os.Getenv(k + "_FILE"); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			s.errs = append(s.errs, fmt.Errorf("%s_FILE: %w", k, err))
+			return ""
+		}
+		return strings.TrimRight(string(b), "\r\n")
+	}
+	if s.vault != nil {
+		if !s.vaultRead {
+			s.vaultRead = true
+			if err := s.vault.fetch(); err != nil {
+				s.errs = append(s.errs, fmt.Errorf("vault %s: %w", s.vault.Path, err))
+			}
+		}
+		if v, ok := s.vault.fields[k]; ok {
+			return v
+		}
+	}
+	return def
+}
+
+func (s *secrets) err() error {
+	return errors.Join(s.errs...)
+}
+
+// fetch reads the secret through the Vault HTTP API. Both KV v1 and KV v2
+// (paths like secret/data/prsrv) layouts are understood.
+func (v *vaultSecret) fetch() error {
+	req, err := http.NewRequest(http.MethodGet, v.Addr+"/v1/"+v.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	data := body.Data
+	if nested, ok := data["data"]; ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = nil
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return err
+			}
+		}
+	}
+	v.fields = make(map[string]string, len(data))
+	for k, raw := range data {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			v.fields[k] = s
+		}
+	}
+	return nil
+}
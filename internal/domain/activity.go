@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"context"
+	"slices"
+)
+
+// Activity item types: the part of a PR's life an item belongs to.
+const (
+	ActivityStatus    = "status"    // created, merged, closed, reopened, author changed
+	ActivityReviewers = "reviewers" // assigned, replaced, removed, delivered, escalated
+	ActivityApprovals = "approvals" // acknowledged, approved, changes requested
+	ActivityComments  = "comments"  // commented
+)
+
+// ActivityCommented is the kind of the activity items of comments; they are
+// not PR events and so not in the timeline.
+const ActivityCommented = "commented"
+
+// Sort orders for the activity feed; ties keep the order things happened in.
+const (
+	ActivitySortOldest = "at"
+	ActivitySortNewest = "-at"
+)
+
+var activityTypes = map[string]string{
+	PREventCreated:          ActivityStatus,
+	PREventMerged:           ActivityStatus,
+	PREventClosed:           ActivityStatus,
+	PREventReopened:         ActivityStatus,
+	PREventAuthorChanged:    ActivityStatus,
+	PREventAssigned:         ActivityReviewers,
+	PREventReplaced:         ActivityReviewers,
+	PREventRemoved:          ActivityReviewers,
+	PREventDelivered:        ActivityReviewers,
+	PREventEscalated:        ActivityReviewers,
+	PREventAcknowledged:     ActivityApprovals,
+	PREventApproved:         ActivityApprovals,
+	PREventChangesRequested: ActivityApprovals,
+}
+
+// ActivityItem is one entry of a PR's activity feed. Comment is set for
+// comments, whose author is UserID.
+type ActivityItem struct {
+	Type string `json:"type"`
+	PREvent
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// PRActivity is one page of a PR's activity feed.
+type PRActivity struct {
+	PRID   string         `json:"pull_request_id"`
+	Status PRStatus       `json:"status"`
+	Items  []ActivityItem `json:"items"`
+	Page   PageInfo       `json:"page"`
+}
+
+// PRActivity returns one page of everything that happened to a PR, events
+// and comments merged by time, the single feed behind its detail page.
+func (s *Service) PRActivity(ctx context.Context, prID string, p PageQuery) (*PRActivity, error) {
+	if err := p.resolve(MaxPageLimit, ActivitySortOldest, ActivitySortNewest); err != nil {
+		return nil, err
+	}
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.ListPREvents(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.repo.ListPRComments(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ActivityItem, 0, len(events)+len(comments))
+	for len(events) > 0 || len(comments) > 0 {
+		if len(comments) == 0 || len(events) > 0 && !comments[0].CreatedAt.Before(events[0].At) {
+			items = append(items, ActivityItem{Type: activityTypes[events[0].Kind], PREvent: events[0]})
+			events = events[1:]
+			continue
+		}
+		c := comments[0]
+		items = append(items, ActivityItem{
+			Type:    ActivityComments,
+			PREvent: PREvent{PRID: prID, Kind: ActivityCommented, UserID: c.AuthorID, At: c.CreatedAt},
+			Comment: &c,
+		})
+		comments = comments[1:]
+	}
+	if p.Sort == ActivitySortNewest {
+		slices.Reverse(items)
+	}
+	a := &PRActivity{PRID: pr.ID, Status: pr.Status}
+	a.Items, a.Page = paginate(items, p)
+	return a, nil
+}
@@ -0,0 +1,227 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// maxReviewers is how many reviewers a PR gets, automatically or manually.
+const maxReviewers = 2
+
+// MaxPreferredReviewers caps the reviewers an author may suggest on create.
+const MaxPreferredReviewers = 10
+
+// Reasons for rejecting a preferred reviewer.
+const (
+	RejectNotFound  = "not_found"
+	RejectInactive  = "inactive"
+	RejectAuthor    = "author"
+	RejectOtherTeam = "other_team"
+	RejectDuplicate = "duplicate"
+	RejectNoSlot    = "no_slot" // maxReviewers valid suggestions came first
+)
+
+// RejectedReviewer is a preferred reviewer CreatePRPreferring did not assign.
+type RejectedReviewer struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+// preferredReviewers splits the reviewers suggested by author into those to
+// assign, active members of the author's team, at most maxReviewers, and
+// the rejected rest.
+func (s *Service) preferredReviewers(ctx context.Context, author *User, preferred []string) ([]string, []RejectedReviewer, error) {
+	if len(preferred) > MaxPreferredReviewers {
+		return nil, nil, NewError(ErrInvalid, "at most "+strconv.Itoa(MaxPreferredReviewers)+" preferred reviewers")
+	}
+	var ok []string
+	var rejected []RejectedReviewer
+	for i, id := range preferred {
+		if slices.Contains(preferred[:i], id) {
+			rejected = append(rejected, RejectedReviewer{UserID: id, Reason: RejectDuplicate})
+			continue
+		}
+		reason := ""
+		u, err := s.repo.GetUser(ctx, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			reason = RejectNotFound
+		case err != nil:
+			return nil, nil, err
+		case id == author.UserID:
+			reason = RejectAuthor
+		case u.TeamName != author.TeamName:
+			reason = RejectOtherTeam
+		case !u.IsActive:
+			reason = RejectInactive
+		case len(ok) == maxReviewers:
+			reason = RejectNoSlot
+		default:
+			ok = append(ok, id)
+			continue
+		}
+		rejected = append(rejected, RejectedReviewer{UserID: id, Reason: reason})
+	}
+	return ok, rejected, nil
+}
+
+// SetTeamManualAssignment turns automatic reviewer selection of the team
+// off (manual) or back on. PRs of manual teams are created without
+// reviewers and admins attach them with AddReviewer; deactivating a member
+// removes their assignments without replacing them.
+func (s *Service) SetTeamManualAssignment(ctx context.Context, team string, manual bool) error {
+	return s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		exists, err := s.repo.TeamExists(ctx, tx, team)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NewError(ErrNotFound, "team not found")
+		}
+		return s.repo.SetTeamManualAssignment(ctx, tx, team, manual)
+	})
+}
+
+// AddReviewer assigns an active user other than the author to an open PR
+// that has fewer than maxReviewers reviewers. The reviewer may be from any
+// team.
+func (s *Service) AddReviewer(ctx context.Context, prID, userID, ifMatch string) (*PullRequest, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.repo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if pr.AssignedReviewers, err = s.repo.GetAssignedReviewers(ctx, prID); err != nil {
+			return err
+		}
+		if err := checkIfMatch(ifMatch, pr); err != nil {
+			return err
+		}
+		if pr.Status == StatusMERGED {
+			return NewError(ErrPRMerged, "cannot add a reviewer to a merged PR")
+		}
+		if pr.Status == StatusCLOSED {
+			return NewError(ErrPRClosed, "cannot add a reviewer to a closed PR")
+		}
+		u, err := s.repo.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case u.UserID == pr.AuthorID:
+			return NewError(ErrInvalid, "the author cannot review their own PR")
+		case !u.IsActive:
+			return NewError(ErrInvalid, "reviewer must be active")
+		}
+		for _, id := range pr.AssignedReviewers {
+			if id == userID {
+				return NewError(ErrInvalid, "user is already a reviewer of this PR")
+			}
+		}
+		if len(pr.AssignedReviewers) >= maxReviewers {
+			return NewError(ErrInvalid, "PR already has 2 reviewers")
+		}
+		if err := s.repo.AssignReviewers(ctx, tx, prID, []string{userID}); err != nil {
+			return err
+		}
+		if err := s.deferOutsideHours(ctx, tx, prID, userID); err != nil {
+			return err
+		}
+		return s.repo.AddPREvents(ctx, tx, []PREvent{{PRID: prID, Kind: PREventAssigned, UserID: userID, Reason: ReasonManual}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	assignmentsTotal.Inc()
+	s.publish(ctx, []PREvent{{PRID: prID, Kind: PREventAssigned, UserID: userID, Reason: ReasonManual}})
+	return s.GetPR(ctx, prID)
+}
+
+// TransferAuthor hands an open PR over to another active user. If the new
+// author reviews the PR, that assignment is replaced with another member of
+// their team, or removed when the team has manual assignment or nobody is
+// left; neither author is picked as the replacement.
+func (s *Service) TransferAuthor(ctx context.Context, prID, authorID, ifMatch string) (*PullRequest, error) {
+	var noCandidate *NoCandidateEvent
+	var events []PREvent
+	replaced := false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.repo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if pr.AssignedReviewers, err = s.repo.GetAssignedReviewers(ctx, prID); err != nil {
+			return err
+		}
+		if err := checkIfMatch(ifMatch, pr); err != nil {
+			return err
+		}
+		if pr.Status == StatusMERGED {
+			return NewError(ErrPRMerged, "cannot transfer a merged PR")
+		}
+		if pr.Status == StatusCLOSED {
+			return NewError(ErrPRClosed, "cannot transfer a closed PR")
+		}
+		if pr.AuthorID == authorID {
+			return nil
+		}
+		author, err := s.repo.GetUser(ctx, authorID)
+		if err != nil {
+			return err
+		}
+		if !author.IsActive {
+			return NewError(ErrInvalid, "new author must be active")
+		}
+		if err := s.repo.SetPRAuthor(ctx, tx, prID, authorID); err != nil {
+			return err
+		}
+		events = []PREvent{{PRID: prID, Kind: PREventAuthorChanged, UserID: pr.AuthorID, ReplacedBy: authorID}}
+		if slices.Contains(pr.AssignedReviewers, authorID) {
+			manual, err := s.repo.TeamManualAssignment(ctx, author.TeamName)
+			if err != nil {
+				return err
+			}
+			var cands []string
+			if !manual {
+				excl := append(append([]string{}, pr.AssignedReviewers...), pr.AuthorID)
+				if cands, err = s.pickReviewers(ctx, prID, author.TeamName, authorID, excl, 1); err != nil {
+					return err
+				}
+			}
+			if len(cands) > 0 {
+				if err := s.repo.ReplaceReviewer(ctx, tx, prID, authorID, cands[0]); err != nil {
+					return err
+				}
+				if err := s.deferOutsideHours(ctx, tx, prID, cands[0]); err != nil {
+					return err
+				}
+				events = append(events, PREvent{PRID: prID, Kind: PREventReplaced, UserID: authorID, ReplacedBy: cands[0], Reason: ReasonAuthorTransfer})
+				replaced = true
+			} else {
+				if err := s.repo.DeleteReviewer(ctx, tx, prID, authorID); err != nil {
+					return err
+				}
+				events = append(events, PREvent{PRID: prID, Kind: PREventRemoved, UserID: authorID, Reason: ReasonAuthorTransfer})
+				if !manual {
+					noCandidate = &NoCandidateEvent{Op: OpTransfer, TeamName: author.TeamName, PRID: prID, UserID: authorID}
+				}
+			}
+		}
+		return s.repo.AddPREvents(ctx, tx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replaced {
+		assignmentsTotal.Inc()
+		reassignmentsTotal.Inc(ReasonAuthorTransfer)
+	}
+	if noCandidate != nil {
+		s.recordNoCandidate(ctx, *noCandidate)
+	}
+	s.publish(ctx, events)
+	return s.GetPR(ctx, prID)
+}
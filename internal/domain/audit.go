@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+	AuthOutcomeDenied  = "denied"
+)
+
+// AuthEvent is one entry of the security audit trail.
+type AuthEvent struct {
+	ID      int64     `json:"id,omitempty"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Outcome string    `json:"outcome"`
+	TokenID string    `json:"token_id,omitempty"`
+	UserID  string    `json:"user_id,omitempty"`
+	Role    string    `json:"role,omitempty"`
+	Method  string    `json:"method,omitempty"`
+	Route   string    `json:"route"`
+	IP      string    `json:"ip"`
+}
+
+type AuthEventFilter struct {
+	Since   *time.Time
+	Until   *time.Time
+	Outcome string
+	TokenID string
+	UserID  string
+	Limit   int
+}
+
+func (s *Service) RecordAuthEvents(ctx context.Context, events []AuthEvent) error {
+	return s.repo.InsertAuthEvents(ctx, events)
+}
+
+func (s *Service) ListAuthEvents(ctx context.Context, f AuthEventFilter) ([]AuthEvent, error) {
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 100
+	}
+	return s.repo.ListAuthEvents(ctx, f)
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"log"
+)
+
+// CapacityWarning says a team with automatic assignment has fewer active
+// members than a PR needs, its author and maxReviewers reviewers. Its PRs
+// get fewer reviewers than usual and reassignments may find no candidate.
+type CapacityWarning struct {
+	TeamName      string `json:"team_name"`
+	ActiveMembers int    `json:"active_members"`
+	Required      int    `json:"required"`
+}
+
+// WithCapacityNotifier makes the service hand a warning to notify whenever
+// a team change (a new team, a move to another team, deactivation or
+// anonymization) leaves a team short of reviewers.
+func (s *Service) WithCapacityNotifier(notify func(CapacityWarning)) *Service {
+	s.capacityNotify = notify
+	return s
+}
+
+// capacityWarning returns the warning for a team with members, nil when it
+// has enough active ones or assigns reviewers manually.
+func capacityWarning(team string, members []TeamMember, manual bool) *CapacityWarning {
+	if manual {
+		return nil
+	}
+	active := 0
+	for _, m := range members {
+		if m.IsActive {
+			active++
+		}
+	}
+	if active > maxReviewers {
+		return nil
+	}
+	return &CapacityWarning{TeamName: team, ActiveMembers: active, Required: maxReviewers + 1}
+}
+
+// TeamCapacity returns the capacity warning of the team, nil when there is
+// none.
+func (s *Service) TeamCapacity(ctx context.Context, team string) (*CapacityWarning, error) {
+	members, err := s.repo.GetTeamMembers(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	manual, err := s.repo.TeamManualAssignment(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	return capacityWarning(team, members, manual), nil
+}
+
+// checkCapacity notifies about each of the teams, just left with fewer
+// active members, that is now short of reviewers.
+func (s *Service) checkCapacity(ctx context.Context, teams ...string) {
+	seen := map[string]bool{}
+	for _, team := range teams {
+		if seen[team] {
+			continue
+		}
+		seen[team] = true
+		w, err := s.TeamCapacity(ctx, team)
+		if err != nil {
+			log.Printf("capacity of %s: %v", team, err)
+			continue
+		}
+		if w == nil {
+			continue
+		}
+		log.Printf("capacity: team %s has %d active members, needs %d", w.TeamName, w.ActiveMembers, w.Required)
+		if s.capacityNotify != nil {
+			s.capacityNotify(*w)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCommentLength caps the text of a comment, in characters.
+const maxCommentLength = 4000
+
+// Sort orders for the comments of a PR; ties are broken by id.
+const (
+	CommentSortOldest = "created_at"
+	CommentSortNewest = "-created_at"
+)
+
+// Comment is a note left on a PR, e.g. "reviewed offline, LGTM" when no
+// code host is integrated. ReplyTo is the comment it answers, if any.
+type Comment struct {
+	ID        int64     `json:"comment_id"`
+	PRID      string    `json:"pull_request_id"`
+	AuthorID  string    `json:"author_id"`
+	Text      string    `json:"text"`
+	ReplyTo   *int64    `json:"reply_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddComment leaves a comment by authorID on a PR, open or merged; replyTo
+// must be a comment on the same PR.
+func (s *Service) AddComment(ctx context.Context, prID, authorID, text string, replyTo *int64) (*Comment, error) {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return nil, NewError(ErrInvalid, "comment text must not be empty")
+	case utf8.RuneCountInString(text) > maxCommentLength:
+		return nil, NewError(ErrInvalid, "comment text must be at most "+strconv.Itoa(maxCommentLength)+" characters")
+	}
+	c := &Comment{PRID: prID, AuthorID: authorID, Text: text, ReplyTo: replyTo}
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.repo.GetPR(ctx, prID); err != nil {
+			return err
+		}
+		if _, err := s.repo.GetUser(ctx, authorID); err != nil {
+			return err
+		}
+		if replyTo != nil {
+			comments, err := s.repo.ListPRComments(ctx, prID)
+			if err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(comments, func(c Comment) bool { return c.ID == *replyTo }) {
+				return NewError(ErrNotFound, "comment "+strconv.FormatInt(*replyTo, 10)+" not found on this PR")
+			}
+		}
+		return s.repo.AddPRComment(ctx, tx, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListComments returns one page of the comments on a PR.
+func (s *Service) ListComments(ctx context.Context, prID string, p PageQuery) ([]Comment, PageInfo, error) {
+	if err := p.resolve(MaxPageLimit, CommentSortOldest, CommentSortNewest); err != nil {
+		return nil, PageInfo{}, err
+	}
+	if _, err := s.repo.GetPR(ctx, prID); err != nil {
+		return nil, PageInfo{}, err
+	}
+	comments, err := s.repo.ListPRComments(ctx, prID)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	if p.Sort == CommentSortNewest {
+		slices.Reverse(comments)
+	}
+	page, info := paginate(comments, p)
+	return page, info, nil
+}
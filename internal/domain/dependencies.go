@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strconv"
+)
+
+// maxDependencies caps the blocked_by links of one PR.
+const maxDependencies = 20
+
+// AddPRDependency marks prID as blocked by blockedBy until blockedBy is
+// merged. Blocked PRs come last in their reviewers' lists and are not
+// escalated. Links may not form a cycle.
+func (s *Service) AddPRDependency(ctx context.Context, prID, blockedBy, ifMatch string) (*PullRequest, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.checkedPR(ctx, prID, ifMatch)
+		if err != nil {
+			return err
+		}
+		if pr.Status == StatusMERGED {
+			return NewError(ErrPRMerged, "cannot add a dependency to a merged PR")
+		}
+		if blockedBy == prID {
+			return NewError(ErrInvalid, "a PR cannot be blocked by itself")
+		}
+		if _, err := s.repo.GetPR(ctx, blockedBy); err != nil {
+			return err
+		}
+		deps, err := s.repo.ListPRDependencies(ctx, prID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case slices.Contains(deps, blockedBy):
+			return NewError(ErrInvalid, "PR is already blocked by "+blockedBy)
+		case len(deps) >= maxDependencies:
+			return NewError(ErrInvalid, "PR already has "+strconv.Itoa(maxDependencies)+" dependencies")
+		}
+		cycle, err := s.dependsOn(ctx, blockedBy, prID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return NewError(ErrInvalid, "dependency cycle: "+blockedBy+" already depends on "+prID)
+		}
+		return s.repo.AddPRDependency(ctx, tx, prID, blockedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPR(ctx, prID)
+}
+
+// RemovePRDependency drops the blocked_by link from prID to blockedBy.
+func (s *Service) RemovePRDependency(ctx context.Context, prID, blockedBy, ifMatch string) (*PullRequest, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.checkedPR(ctx, prID, ifMatch); err != nil {
+			return err
+		}
+		deps, err := s.repo.ListPRDependencies(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(deps, blockedBy) {
+			return NewError(ErrNotFound, "PR is not blocked by "+blockedBy)
+		}
+		return s.repo.DeletePRDependency(ctx, tx, prID, blockedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPR(ctx, prID)
+}
+
+// checkedPR loads the PR with its reviewers and checks it against ifMatch.
+func (s *Service) checkedPR(ctx context.Context, prID, ifMatch string) (*PullRequest, error) {
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if pr.AssignedReviewers, err = s.repo.GetAssignedReviewers(ctx, prID); err != nil {
+		return nil, err
+	}
+	return pr, checkIfMatch(ifMatch, pr)
+}
+
+// dependsOn reports whether prID is blocked by target, directly or through
+// other PRs.
+func (s *Service) dependsOn(ctx context.Context, prID, target string) (bool, error) {
+	seen := map[string]bool{prID: true}
+	queue := []string{prID}
+	for len(queue) > 0 {
+		deps, err := s.repo.ListPRDependencies(ctx, queue[0])
+		if err != nil {
+			return false, err
+		}
+		queue = queue[1:]
+		for _, d := range deps {
+			if d == target {
+				return true, nil
+			}
+			if !seen[d] {
+				seen[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,54 @@
+package domain
+
+import "errors"
+
+// Error is a failure reported to API clients: a stable code, a message safe
+// to show and, optionally, the underlying cause.
+//
+// errors.Is(err, ErrNotFound) matches any Error with that code, however deep
+// it is wrapped.
+type Error struct {
+	Code ErrorCode
+	Msg  string
+	Err  error
+}
+
+func NewError(code ErrorCode, msg string) error {
+	return &Error{Code: code, Msg: msg}
+}
+
+// WrapError is NewError keeping cause for errors.Is/As and logs; clients
+// only see msg.
+func WrapError(code ErrorCode, msg string, cause error) error {
+	return &Error{Code: code, Msg: msg, Err: cause}
+}
+
+func (e *Error) Error() string {
+	s := string(e.Code) + ": " + e.Msg
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorCode:
+		return t == e.Code
+	case *Error:
+		return t.Code == e.Code && (t.Msg == "" || t.Msg == e.Msg)
+	}
+	return false
+}
+
+// Error makes codes usable as errors.Is targets.
+func (c ErrorCode) Error() string { return string(c) }
+
+// AsError returns the domain error in err's chain, if any.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"slices"
+	"time"
+)
+
+// Escalation steps, also the reasons of their "escalated" PR events.
+const (
+	EscalationNotifyLead  = "notify_lead"
+	EscalationAddReviewer = "add_reviewer"
+)
+
+// EscalationPolicy escalates the open PRs of a team (the author's team) on
+// which no reviewer has acted: NotifyLeadDays after creation the team lead is
+// notified, AddReviewerDays after creation another reviewer is added. Team
+// holidays do not count. Zero turns a step off.
+type EscalationPolicy struct {
+	NotifyLeadDays  int `json:"notify_lead_after_days,omitempty"`
+	AddReviewerDays int `json:"add_reviewer_after_days,omitempty"`
+}
+
+func (p EscalationPolicy) IsZero() bool { return p == EscalationPolicy{} }
+
+// StalledPR is an open PR of a team with an escalation policy that none of
+// its reviewers has acknowledged or approved and no unmerged PR blocks. Done lists the escalation
+// steps already taken on it.
+type StalledPR struct {
+	PRID      string
+	AuthorID  string
+	TeamName  string
+	CreatedAt time.Time
+	Policy    EscalationPolicy
+	Done      []string
+}
+
+// Escalation is a step taken on a stalled PR. LeadUserID is the lead to
+// notify, empty when the team has none; ReviewerID the reviewer added, empty
+// when nobody could be.
+type Escalation struct {
+	Step       string    `json:"step"`
+	PRID       string    `json:"pull_request_id"`
+	TeamName   string    `json:"team_name"`
+	LeadUserID string    `json:"lead_user_id,omitempty"`
+	ReviewerID string    `json:"reviewer_id,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// SetTeamEscalation replaces the escalation policy of the team; a zero
+// policy turns escalation off.
+func (s *Service) SetTeamEscalation(ctx context.Context, team string, p EscalationPolicy) error {
+	switch {
+	case p.NotifyLeadDays < 0 || p.AddReviewerDays < 0:
+		return NewError(ErrInvalid, "escalation days must not be negative")
+	case p.NotifyLeadDays > 0 && p.AddReviewerDays > 0 && p.AddReviewerDays < p.NotifyLeadDays:
+		return NewError(ErrInvalid, "add_reviewer_after_days must not be less than notify_lead_after_days")
+	}
+	return s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		exists, err := s.repo.TeamExists(ctx, tx, team)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NewError(ErrNotFound, "team not found")
+		}
+		return s.repo.SetTeamEscalation(ctx, tx, team, p)
+	})
+}
+
+// RunEscalations takes the escalation steps that are due on stalled PRs,
+// each once per PR, and records them in the PR history as "escalated"
+// events. It returns the steps taken.
+func (s *Service) RunEscalations(ctx context.Context) ([]Escalation, error) {
+	stalled, err := s.repo.ListStalledPRs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	var out []Escalation
+	type calendar struct {
+		loc      *time.Location
+		holidays []Holiday
+	}
+	calendars := map[string]calendar{}
+	for _, p := range stalled {
+		cal, ok := calendars[p.TeamName]
+		if !ok {
+			w, err := s.repo.TeamWorkingHours(ctx, p.TeamName)
+			if err != nil {
+				return out, err
+			}
+			if cal.holidays, err = s.repo.ListTeamHolidays(ctx, p.TeamName); err != nil {
+				return out, err
+			}
+			cal.loc = w.Location()
+			calendars[p.TeamName] = cal
+		}
+		age := now.Sub(p.CreatedAt) - HolidayTime(cal.holidays, cal.loc, p.CreatedAt, now)
+		for _, step := range []struct {
+			name string
+			days int
+			run  func(context.Context, StalledPR) (Escalation, error)
+		}{
+			{EscalationNotifyLead, p.Policy.NotifyLeadDays, s.escalateToLead},
+			{EscalationAddReviewer, p.Policy.AddReviewerDays, s.escalateAddReviewer},
+		} {
+			if step.days == 0 || age < time.Duration(step.days)*24*time.Hour || slices.Contains(p.Done, step.name) {
+				continue
+			}
+			e, err := step.run(ctx, p)
+			if err != nil {
+				return out, err
+			}
+			escalationsTotal.Inc(step.name)
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) escalateToLead(ctx context.Context, p StalledPR) (Escalation, error) {
+	e := Escalation{Step: EscalationNotifyLead, PRID: p.PRID, TeamName: p.TeamName, At: s.clock.Now()}
+	lead, err := s.repo.GetTeamLead(ctx, p.TeamName)
+	if err != nil {
+		return e, err
+	}
+	e.LeadUserID = lead
+	err = s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		return s.repo.AddPREvents(ctx, tx, []PREvent{{PRID: p.PRID, Kind: PREventEscalated, UserID: lead, Reason: EscalationNotifyLead}})
+	})
+	return e, err
+}
+
+// escalateAddReviewer assigns one more active member of the author's team,
+// beyond maxReviewers if need be. Teams with manual assignment get none.
+func (s *Service) escalateAddReviewer(ctx context.Context, p StalledPR) (Escalation, error) {
+	e := Escalation{Step: EscalationAddReviewer, PRID: p.PRID, TeamName: p.TeamName, At: s.clock.Now()}
+	manual := false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if manual, err = s.repo.TeamManualAssignment(ctx, p.TeamName); err != nil {
+			return err
+		}
+		events := []PREvent{}
+		if !manual {
+			assigned, err := s.repo.GetAssignedReviewers(ctx, p.PRID)
+			if err != nil {
+				return err
+			}
+			cands, err := s.pickReviewers(ctx, p.PRID, p.TeamName, p.AuthorID, append(assigned, p.AuthorID), 1)
+			if err != nil {
+				return err
+			}
+			if len(cands) > 0 {
+				if err := s.repo.AssignReviewers(ctx, tx, p.PRID, cands); err != nil {
+					return err
+				}
+				if err := s.deferOutsideHours(ctx, tx, p.PRID, cands...); err != nil {
+					return err
+				}
+				e.ReviewerID = cands[0]
+				events = append(events, PREvent{PRID: p.PRID, Kind: PREventAssigned, UserID: e.ReviewerID, Reason: ReasonEscalation})
+			}
+		}
+		events = append(events, PREvent{PRID: p.PRID, Kind: PREventEscalated, UserID: e.ReviewerID, Reason: EscalationAddReviewer})
+		return s.repo.AddPREvents(ctx, tx, events)
+	})
+	if err != nil {
+		return e, err
+	}
+	if e.ReviewerID != "" {
+		assignmentsTotal.Inc()
+		s.publish(ctx, []PREvent{{PRID: p.PRID, Kind: PREventAssigned, UserID: e.ReviewerID, Reason: ReasonEscalation}})
+	} else if !manual {
+		s.recordNoCandidate(ctx, NoCandidateEvent{Op: OpEscalate, TeamName: p.TeamName, PRID: p.PRID})
+	}
+	return e, nil
+}
+
+// StartEscalations runs the due escalation steps every interval until Close
+// and hands each step taken to notify.
+func (s *Service) StartEscalations(every time.Duration, notify func(Escalation)) *Job {
+	return startJob(every, false, func(ctx context.Context) {
+		taken, err := s.RunEscalations(ctx)
+		if err != nil {
+			log.Printf("escalations: %v", err)
+		}
+		for _, e := range taken {
+			log.Printf("escalation: %s on PR %s of team %s (lead %q, reviewer %q)",
+				e.Step, e.PRID, e.TeamName, e.LeadUserID, e.ReviewerID)
+			if notify != nil {
+				notify(e)
+			}
+		}
+	})
+}
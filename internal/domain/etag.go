@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETag is a strong entity tag of the PR's current state, including its
+// reviewers. Any change that a client could act on yields a new tag, so
+// clients send it back in If-Match to make sure they still see the PR
+// they decided on.
+func (pr *PullRequest) ETag() string {
+	h := sha256.New()
+	for _, s := range []string{pr.ID, pr.Name, pr.AuthorID, pr.Repository, string(pr.Status), etagTime(pr.CreatedAt), etagTime(pr.MergedAt)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	if pr.ClosedAt != nil {
+		// Only PRs that were closed hash it, so other tags stay as they were.
+		h.Write([]byte(etagTime(pr.ClosedAt)))
+		h.Write([]byte{0})
+	}
+	for _, r := range pr.AssignedReviewers {
+		h.Write([]byte(r))
+		h.Write([]byte{1})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+func etagTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+// MatchesETag reports whether an If-Match header value matches etag: "*",
+// or a list containing etag. Weak tags never match, as If-Match compares
+// strongly.
+func MatchesETag(ifMatch, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch fails with ErrPreconditionFailed unless the PR matches
+// ifMatch; an empty ifMatch always passes. The PR needs its reviewers.
+func checkIfMatch(ifMatch string, pr *PullRequest) error {
+	if ifMatch == "" || MatchesETag(ifMatch, pr.ETag()) {
+		return nil
+	}
+	return NewError(ErrPreconditionFailed, "pull request was modified; fetch it again")
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// PR event kinds, in the order they usually happen.
+const (
+	PREventCreated          = "created"
+	PREventAssigned         = "assigned"
+	PREventReplaced         = "replaced"
+	PREventRemoved          = "removed"
+	PREventAcknowledged     = "acknowledged"
+	PREventApproved         = "approved"
+	PREventChangesRequested = "changes_requested"
+	PREventMerged           = "merged"
+	PREventClosed           = "closed"
+	PREventReopened         = "reopened"
+	PREventAuthorChanged    = "author_changed"
+	PREventEscalated        = "escalated"
+	PREventDelivered        = "delivered"
+)
+
+// Reasons for assigning, replacing or removing a reviewer.
+const (
+	ReasonManual         = "manual"
+	ReasonDeactivation   = "deactivation"
+	ReasonAuthorTransfer = "author_transfer"
+	ReasonEscalation     = "escalation"
+	ReasonPreferred      = "preferred" // suggested by the author on create
+	ReasonImport         = "import"    // backfilled from a code host export
+	ReasonReopen         = "reopen"    // deactivated while the PR was closed
+)
+
+// PREvent is one entry of a PR's history. UserID is the author for
+// "created", the previous author for "author_changed" and the notified lead
+// or added reviewer for "escalated" (Reason is the escalation step),
+// otherwise the reviewer concerned, e.g. of an assignment queued outside
+// working hours for "delivered"; ReplacedBy is set for "replaced" and is
+// the new author for "author_changed". The log is append-only and written in the same transaction as
+// the change it describes.
+type PREvent struct {
+	PRID       string    `json:"-"`
+	Kind       string    `json:"kind"`
+	UserID     string    `json:"user_id,omitempty"`
+	ReplacedBy string    `json:"replaced_by,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+type PRTimeline struct {
+	PRID   string    `json:"pull_request_id"`
+	Status PRStatus  `json:"status"`
+	Events []PREvent `json:"events"`
+}
+
+// PRTimeline returns the PR's events in the order they happened.
+func (s *Service) PRTimeline(ctx context.Context, prID string) (*PRTimeline, error) {
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.ListPREvents(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	return &PRTimeline{PRID: pr.ID, Status: pr.Status, Events: events}, nil
+}
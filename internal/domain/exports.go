@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	ExportAssignmentsByUser = "assignments_by_user"
+	ExportAssignmentsByPR   = "assignments_by_pr"
+)
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// Export is a report generated in the background and stored until fetched
+// through a signed download URL.
+type Export struct {
+	ID         string     `json:"export_id"`
+	Kind       string     `json:"kind"`
+	Teams      []string   `json:"teams,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// StartExport records a pending export and builds it in the background.
+// teams limits the report like a team-scoped token would.
+func (s *Service) StartExport(ctx context.Context, kind string, teams []string, createdBy string) (*Export, error) {
+	if kind != ExportAssignmentsByUser && kind != ExportAssignmentsByPR {
+		return nil, NewError(ErrInvalid, "unknown export kind")
+	}
+	id, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	e := Export{ID: "exp_" + id, Kind: kind, Teams: teams, Status: ExportPending, CreatedBy: createdBy, CreatedAt: s.clock.Now().UTC()}
+	if err := s.repo.CreateExport(ctx, e); err != nil {
+		return nil, err
+	}
+	// The export outlives the request that started it.
+	go s.buildExport(context.WithoutCancel(ctx), e)
+	return &e, nil
+}
+
+func (s *Service) buildExport(ctx context.Context, e Export) {
+	content, err := s.exportCSV(ctx, e.Kind, e.Teams)
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	if err := s.repo.FinishExport(ctx, e.ID, content, msg); err != nil {
+		log.Printf("export %s: %v", e.ID, err)
+	}
+}
+
+func (s *Service) exportCSV(ctx context.Context, kind string, teams []string) ([]byte, error) {
+	var (
+		counts []AssignmentCount
+		header []string
+		err    error
+	)
+	q := AssignmentQuery{Teams: teams, Sort: SortByID}
+	switch kind {
+	case ExportAssignmentsByUser:
+		counts, _, err = s.repo.StatsAssignmentsByUser(ctx, q)
+		header = []string{"user_id", "assignments"}
+	default:
+		counts, _, err = s.repo.StatsAssignmentsByPR(ctx, q)
+		header = []string{"pull_request_id", "reviewers"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(header)
+	for _, c := range counts {
+		_ = w.Write([]string{c.UserID + c.PRID, strconv.Itoa(c.Count)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (s *Service) GetExport(ctx context.Context, id string) (*Export, error) {
+	return s.repo.GetExport(ctx, id)
+}
+
+// ExportContent returns the report body of a ready export.
+func (s *Service) ExportContent(ctx context.Context, id string) ([]byte, error) {
+	e, err := s.repo.GetExport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != ExportReady {
+		return nil, NewError(ErrNotFound, "export is not ready")
+	}
+	return s.repo.GetExportContent(ctx, id)
+}
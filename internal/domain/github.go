@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// GitHubLogin maps a GitHub account to a user, so GitHub webhook deliveries
+// can name the PR author.
+type GitHubLogin struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"github_login,omitempty"`
+}
+
+// SetGitHubLogin maps login to userID, replacing their previous login; an
+// empty login removes the mapping. GitHub logins are case-insensitive, so
+// they are stored in lower case. A login mapped to another user fails with
+// ErrInvalid.
+func (s *Service) SetGitHubLogin(ctx context.Context, userID, login string) (*GitHubLogin, error) {
+	login = strings.ToLower(login)
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.repo.GetUser(ctx, userID); err != nil {
+			return err
+		}
+		return s.repo.SetGitHubLogin(ctx, tx, userID, login)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GitHubLogin{UserID: userID, Login: login}, nil
+}
+
+// GitHubUser returns the id of the user login is mapped to, or ErrNotFound.
+func (s *Service) GitHubUser(ctx context.Context, login string) (string, error) {
+	return s.repo.GitHubLoginUser(ctx, strings.ToLower(login))
+}
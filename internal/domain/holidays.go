@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+const maxHolidays = 366
+
+// Holiday is a public holiday of a team: a whole day, in the time zone of
+// the team's working hours (UTC without them).
+type Holiday struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name,omitempty"`
+}
+
+// SetTeamHolidays replaces the holiday calendar of the team. Holidays defer
+// assignments like time outside working hours and do not count towards the
+// SLA waits and escalation deadlines of the team's PRs.
+func (s *Service) SetTeamHolidays(ctx context.Context, team string, holidays []Holiday) error {
+	if len(holidays) > maxHolidays {
+		return NewError(ErrInvalid, "too many holidays")
+	}
+	seen := map[string]bool{}
+	for _, h := range holidays {
+		if _, err := time.Parse(time.DateOnly, h.Date); err != nil {
+			return NewError(ErrInvalid, "holiday date must be YYYY-MM-DD: "+h.Date)
+		}
+		if seen[h.Date] {
+			return NewError(ErrInvalid, "duplicate holiday "+h.Date)
+		}
+		seen[h.Date] = true
+	}
+	return s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		exists, err := s.repo.TeamExists(ctx, tx, team)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NewError(ErrNotFound, "team not found")
+		}
+		return s.repo.SetTeamHolidays(ctx, tx, team, holidays)
+	})
+}
+
+// TeamHolidays returns the holiday calendar of the team by date.
+func (s *Service) TeamHolidays(ctx context.Context, team string) ([]Holiday, error) {
+	members, err := s.repo.GetTeamMembers(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, NewError(ErrNotFound, "team not found")
+	}
+	holidays, err := s.repo.ListTeamHolidays(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+	return holidays, nil
+}
+
+// HolidayTime returns how much of [from, to) falls on the holidays, whole
+// days in loc. Repos subtract it from waits, like holiday_seconds in SQL.
+func HolidayTime(holidays []Holiday, loc *time.Location, from, to time.Time) time.Duration {
+	var total time.Duration
+	for _, h := range holidays {
+		day, err := time.ParseInLocation(time.DateOnly, h.Date, loc)
+		if err != nil {
+			continue
+		}
+		start, end := day, day.AddDate(0, 0, 1)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// Location is the time zone of the working hours, UTC when unset.
+func (w WorkingHours) Location() *time.Location {
+	if loc, err := time.LoadLocation(w.TimeZone); err == nil && w.TimeZone != "" {
+		return loc
+	}
+	return time.UTC
+}
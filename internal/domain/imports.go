@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+)
+
+// ImportedReview is a reviewer of an imported PR; ApprovedAt is set when
+// they approved it.
+type ImportedReview struct {
+	UserID     string
+	ApprovedAt *time.Time
+}
+
+// ImportedPR is a PR read from a code host export, with its original
+// timestamps. MergedAt is nil for PRs still open.
+type ImportedPR struct {
+	ID         string
+	Name       string
+	AuthorID   string
+	Repository string
+	CreatedAt  time.Time
+	MergedAt   *time.Time
+	Reviewers  []ImportedReview
+}
+
+// ImportResult counts what an import created; Skipped are the PRs that
+// already existed.
+type ImportResult struct {
+	Teams   int `json:"teams"`
+	Users   int `json:"users"`
+	PRs     int `json:"prs"`
+	Skipped int `json:"skipped"`
+}
+
+// ImportPRs backfills PRs with their original timestamps, reviewers and
+// approvals, and writes their history as it happened, so stats cover them
+// from the start. Authors and reviewers that do not exist yet are created,
+// active, in team, which is created if need be; existing users keep their
+// team. PRs that already exist are skipped, so an export can be imported
+// again. Each PR is imported in its own transaction.
+func (s *Service) ImportPRs(ctx context.Context, team string, prs []ImportedPR) (*ImportResult, error) {
+	res := &ImportResult{}
+	for _, pr := range prs {
+		var created ImportResult
+		err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+			created = ImportResult{}
+			if _, err := s.repo.GetPR(ctx, pr.ID); err == nil {
+				created.Skipped++
+				return nil
+			} else if !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			users := []string{pr.AuthorID}
+			for _, r := range pr.Reviewers {
+				if !slices.Contains(users, r.UserID) {
+					users = append(users, r.UserID)
+				}
+			}
+			for _, id := range users {
+				if _, err := s.repo.GetUser(ctx, id); err == nil {
+					continue
+				} else if !errors.Is(err, ErrNotFound) {
+					return err
+				}
+				exists, err := s.repo.TeamExists(ctx, tx, team)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					if err := s.repo.CreateTeam(ctx, tx, team); err != nil {
+						return err
+					}
+					created.Teams++
+				}
+				if err := s.repo.UpsertUser(ctx, tx, User{UserID: id, Username: id, TeamName: team, IsActive: true}); err != nil {
+					return err
+				}
+				created.Users++
+			}
+			if err := s.repo.ImportPR(ctx, tx, pr); err != nil {
+				return err
+			}
+			created.PRs++
+			return s.repo.AddPREvents(ctx, tx, importedEvents(pr))
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Teams += created.Teams
+		res.Users += created.Users
+		res.PRs += created.PRs
+		res.Skipped += created.Skipped
+	}
+	return res, nil
+}
+
+// importedEvents is the history of an imported PR, oldest first.
+func importedEvents(pr ImportedPR) []PREvent {
+	events := []PREvent{{PRID: pr.ID, Kind: PREventCreated, UserID: pr.AuthorID, At: pr.CreatedAt}}
+	for _, r := range pr.Reviewers {
+		events = append(events, PREvent{PRID: pr.ID, Kind: PREventAssigned, UserID: r.UserID, Reason: ReasonImport, At: pr.CreatedAt})
+	}
+	for _, r := range pr.Reviewers {
+		if r.ApprovedAt != nil {
+			events = append(events, PREvent{PRID: pr.ID, Kind: PREventApproved, UserID: r.UserID, At: *r.ApprovedAt})
+		}
+	}
+	if pr.MergedAt != nil {
+		events = append(events, PREvent{PRID: pr.ID, Kind: PREventMerged, At: *pr.MergedAt})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	return events
+}
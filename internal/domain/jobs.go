@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Job is a background task run on a schedule until Close.
+type Job struct {
+	stop   chan struct{}
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// startJob runs fn every interval, and once right away when now is set. The
+// context fn gets is cancelled by Close.
+func startJob(every time.Duration, now bool, fn func(ctx context.Context)) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{stop: make(chan struct{}), done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(j.done)
+		t := time.NewTicker(every)
+		defer t.Stop()
+		if now {
+			fn(ctx)
+		}
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-t.C:
+				fn(ctx)
+			}
+		}
+	}()
+	return j
+}
+
+// Close stops the job, cancelling a running fn, and waits for it to finish.
+func (j *Job) Close() {
+	close(j.stop)
+	j.cancel()
+	<-j.done
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ClosePR closes an open PR without merging it. Its reviewers stay
+// assigned but leave the open load: open review batches, polling, overload
+// checks and escalation skip it, stats stop its clocks at closing, and
+// ListUserPRs keeps it with its CLOSED status. Closing a closed PR changes
+// nothing; a merged PR cannot be closed.
+func (s *Service) ClosePR(ctx context.Context, prID, ifMatch string) (*PullRequest, error) {
+	closed := false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.checkedPR(ctx, prID, ifMatch)
+		if err != nil {
+			return err
+		}
+		switch pr.Status {
+		case StatusMERGED:
+			return NewError(ErrPRMerged, "cannot close a merged PR")
+		case StatusCLOSED:
+			return nil
+		}
+		if _, err := s.repo.SetPRStatus(ctx, tx, prID, StatusCLOSED); err != nil {
+			return err
+		}
+		closed = true
+		return s.repo.AddPREvents(ctx, tx, []PREvent{{PRID: prID, Kind: PREventClosed}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if closed {
+		s.publish(ctx, []PREvent{{PRID: prID, Kind: PREventClosed}})
+	}
+	return s.GetPR(ctx, prID)
+}
+
+// ReopenPR opens a closed PR again. Reviewers deactivated while it was
+// closed are replaced with another active member of their team, or removed
+// when the team has manual assignment or nobody is left, as deactivation
+// does for open PRs. Reopening an open PR changes nothing; a merged PR
+// cannot be reopened.
+func (s *Service) ReopenPR(ctx context.Context, prID, ifMatch string) (*PullRequest, error) {
+	var events []PREvent
+	var noCandidates []NoCandidateEvent
+	replaced := 0
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.checkedPR(ctx, prID, ifMatch)
+		if err != nil {
+			return err
+		}
+		switch pr.Status {
+		case StatusMERGED:
+			return NewError(ErrPRMerged, "cannot reopen a merged PR")
+		case StatusOPEN:
+			return nil
+		}
+		if _, err := s.repo.SetPRStatus(ctx, tx, prID, StatusOPEN); err != nil {
+			return err
+		}
+		events = append(events, PREvent{PRID: prID, Kind: PREventReopened})
+		cur := pr.AssignedReviewers
+		for _, id := range pr.AssignedReviewers {
+			u, err := s.repo.GetUser(ctx, id)
+			if err != nil {
+				return err
+			}
+			if u.IsActive {
+				continue
+			}
+			manual, err := s.repo.TeamManualAssignment(ctx, u.TeamName)
+			if err != nil {
+				return err
+			}
+			var cands []string
+			if !manual {
+				if cands, err = s.pickReviewers(ctx, prID, u.TeamName, pr.AuthorID, append(cur, pr.AuthorID), 1); err != nil {
+					return err
+				}
+			}
+			var next []string
+			for _, r := range cur {
+				if r != id {
+					next = append(next, r)
+				}
+			}
+			if len(cands) > 0 {
+				if err := s.repo.ReplaceReviewer(ctx, tx, prID, id, cands[0]); err != nil {
+					return err
+				}
+				if err := s.deferOutsideHours(ctx, tx, prID, cands[0]); err != nil {
+					return err
+				}
+				events = append(events, PREvent{PRID: prID, Kind: PREventReplaced, UserID: id, ReplacedBy: cands[0], Reason: ReasonReopen})
+				next = append(next, cands[0])
+				replaced++
+			} else {
+				if err := s.repo.DeleteReviewer(ctx, tx, prID, id); err != nil {
+					return err
+				}
+				events = append(events, PREvent{PRID: prID, Kind: PREventRemoved, UserID: id, Reason: ReasonReopen})
+				if !manual {
+					noCandidates = append(noCandidates, NoCandidateEvent{Op: OpReopen, TeamName: u.TeamName, PRID: prID, UserID: id})
+				}
+			}
+			cur = next
+		}
+		return s.repo.AddPREvents(ctx, tx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for range replaced {
+		assignmentsTotal.Inc()
+		reassignmentsTotal.Inc("reopen")
+	}
+	for _, e := range noCandidates {
+		s.recordNoCandidate(ctx, e)
+	}
+	s.publish(ctx, events)
+	return s.GetPR(ctx, prID)
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// StartStatsRefresh switches per-user/per-team assignment counts and merge
+// latency to the materialized aggregates and refreshes them now and then
+// every interval until Close. Responses carry materialized_at so clients see
+// how stale the numbers may be.
+func (s *Service) StartStatsRefresh(every time.Duration) *Job {
+	s.materialized.Store(true)
+	return startJob(every, true, func(ctx context.Context) {
+		if _, err := s.RefreshStats(ctx); err != nil {
+			log.Printf("stats refresh: %v", err)
+		}
+	})
+}
+
+// RefreshStats rebuilds the materialized aggregates and returns the time
+// they reflect.
+func (s *Service) RefreshStats(ctx context.Context) (time.Time, error) {
+	return s.repo.RefreshStats(ctx)
+}
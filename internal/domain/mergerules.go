@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// Rules of MergeRules, as named in ErrMergeBlocked messages and, for
+// RuleMinApprovals, ErrNotApproved ones.
+const (
+	RuleMinAge             = "min_age"
+	RuleNoChangesRequested = "no_changes_requested"
+	RuleMinApprovals       = "min_approvals"
+	RuleLeadApproval       = "lead_approval"
+)
+
+// ReviewChangesRequested is the review state of a reviewer who asked for
+// changes; it blocks the merge under BlockOnChangesRequested.
+const ReviewChangesRequested = "CHANGES_REQUESTED"
+
+// MergeRules are the preconditions for merging PRs of a team (the author's
+// team). The zero value allows every merge.
+type MergeRules struct {
+	// MinApprovals is how many assigned reviewers must have approved.
+	MinApprovals int `json:"min_approvals,omitempty"`
+	// BlockOnChangesRequested blocks the merge while any assigned reviewer
+	// has requested changes.
+	BlockOnChangesRequested bool `json:"block_on_changes_requested,omitempty"`
+	// RequireLeadApproval requires the team lead to be an assigned reviewer
+	// who approved. PRs have no labels, so it applies to every PR of the team.
+	RequireLeadApproval bool `json:"require_lead_approval,omitempty"`
+	// MinAgeSeconds is how long after creation a PR can be merged.
+	MinAgeSeconds int64 `json:"min_age_seconds,omitempty"`
+}
+
+func (m MergeRules) IsZero() bool { return m == MergeRules{} }
+
+// SetTeamMergeRules replaces the merge rules of the team; zero rules turn
+// them off.
+func (s *Service) SetTeamMergeRules(ctx context.Context, team string, rules MergeRules) error {
+	switch {
+	case rules.MinApprovals < 0 || rules.MinApprovals > maxReviewers:
+		return NewError(ErrInvalid, "min_approvals must be between 0 and "+strconv.Itoa(maxReviewers))
+	case rules.MinAgeSeconds < 0:
+		return NewError(ErrInvalid, "min_age_seconds must not be negative")
+	}
+	return s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		exists, err := s.repo.TeamExists(ctx, tx, team)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NewError(ErrNotFound, "team not found")
+		}
+		return s.repo.SetTeamMergeRules(ctx, tx, team, rules)
+	})
+}
+
+// checkMergeRules fails with ErrMergeBlocked naming the first rule of the
+// author's team the PR breaks, checked in the order of the Rule constants;
+// too few approvals fail with ErrNotApproved instead.
+func (s *Service) checkMergeRules(ctx context.Context, pr *PullRequest) error {
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return err
+	}
+	rules, err := s.repo.TeamMergeRules(ctx, author.TeamName)
+	if err != nil || rules.IsZero() {
+		return err
+	}
+	if rules.MinAgeSeconds > 0 && pr.CreatedAt != nil {
+		minAge := time.Duration(rules.MinAgeSeconds) * time.Second
+		if age := s.clock.Now().Sub(*pr.CreatedAt); age < minAge {
+			return mergeBlocked(RuleMinAge, "PR can be merged "+strconv.FormatInt(rules.MinAgeSeconds, 10)+
+				" seconds after creation, "+strconv.FormatInt(int64((minAge-age+time.Second-1)/time.Second), 10)+" left")
+		}
+	}
+	states, err := s.repo.GetReviewStates(ctx, pr.ID)
+	if err != nil {
+		return err
+	}
+	approvals := 0
+	for _, id := range pr.AssignedReviewers {
+		switch states[id] {
+		case ReviewChangesRequested:
+			if rules.BlockOnChangesRequested {
+				return mergeBlocked(RuleNoChangesRequested, "reviewer "+id+" requested changes")
+			}
+		case ReviewApproved:
+			approvals++
+		}
+	}
+	if approvals < rules.MinApprovals {
+		return NewError(ErrNotApproved, RuleMinApprovals+": "+strconv.Itoa(approvals)+" of "+strconv.Itoa(rules.MinApprovals)+" required approvals")
+	}
+	if rules.RequireLeadApproval {
+		lead, err := s.repo.GetTeamLead(ctx, author.TeamName)
+		if err != nil {
+			return err
+		}
+		switch {
+		case lead == "":
+			return mergeBlocked(RuleLeadApproval, "team "+author.TeamName+" has no lead to approve")
+		case states[lead] != ReviewApproved:
+			return mergeBlocked(RuleLeadApproval, "team lead "+lead+" has not approved")
+		}
+	}
+	return nil
+}
+
+func mergeBlocked(rule, msg string) error {
+	return NewError(ErrMergeBlocked, rule+": "+msg)
+}
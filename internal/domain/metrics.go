@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"prsrv/internal/metrics"
+)
+
+// Business metrics, served on /metrics.
+var (
+	assignmentsTotal    = metrics.NewCounter("assignments_total", "Reviewers assigned to PRs, including replacements.")
+	reassignmentsTotal  = metrics.NewCounter("reassignments_total", "Reviewers replaced on open PRs, by reason.", "reason")
+	noCandidateTotal    = metrics.NewCounter("no_candidate_total", "Times no active reviewer candidate was found, by operation.", "op")
+	overloadAlertsTotal = metrics.NewCounter("overload_alerts_total", "Reviewer overload alerts raised, by reason.", "reason")
+	escalationsTotal    = metrics.NewCounter("escalations_total", "Escalation steps taken on stalled PRs, by step.", "step")
+	mergeDuration       = metrics.NewHistogram("merge_duration_seconds", "Time from PR creation to merge.",
+		[]float64{3600, 4 * 3600, 8 * 3600, 24 * 3600, 2 * 86400, 3 * 86400, 7 * 86400, 14 * 86400, 30 * 86400})
+)
+
+// RegisterOpenPRGauge exposes the open_prs gauge per author team; it is read
+// from the database on every scrape.
+func (s *Service) RegisterOpenPRGauge() {
+	metrics.NewGaugeFunc("open_prs", "Open PRs by author team.", "team", func() (map[string]float64, error) {
+		counts, err := s.repo.StatsPRStatus(context.Background(), nil, false)
+		if err != nil {
+			return nil, err
+		}
+		out := map[string]float64{}
+		for _, c := range counts {
+			if c.Status == StatusOPEN {
+				out[c.TeamName] += float64(c.Count)
+			}
+		}
+		return out, nil
+	})
+}
@@ -7,17 +7,30 @@ type PRStatus string
 const (
 	StatusOPEN   PRStatus = "OPEN"
 	StatusMERGED PRStatus = "MERGED"
+	// StatusCLOSED is a PR closed without merging; it can be reopened.
+	StatusCLOSED PRStatus = "CLOSED"
 )
 
 type ErrorCode string
 
 const (
-	ErrTeamExists  ErrorCode = "TEAM_EXISTS"
-	ErrPRExists    ErrorCode = "PR_EXISTS"
-	ErrPRMerged    ErrorCode = "PR_MERGED"
-	ErrNotAssigned ErrorCode = "NOT_ASSIGNED"
-	ErrNoCandidate ErrorCode = "NO_CANDIDATE"
-	ErrNotFound    ErrorCode = "NOT_FOUND"
+	ErrTeamExists         ErrorCode = "TEAM_EXISTS"
+	ErrPRExists           ErrorCode = "PR_EXISTS"
+	ErrPRMerged           ErrorCode = "PR_MERGED"
+	ErrPRClosed           ErrorCode = "PR_CLOSED"
+	ErrNotAssigned        ErrorCode = "NOT_ASSIGNED"
+	ErrNoCandidate        ErrorCode = "NO_CANDIDATE"
+	ErrPreconditionFailed ErrorCode = "PRECONDITION_FAILED"
+	ErrMergeBlocked       ErrorCode = "MERGE_BLOCKED"
+	ErrNotApproved        ErrorCode = "NOT_APPROVED"
+	ErrNotFound           ErrorCode = "NOT_FOUND"
+	ErrRateLimited        ErrorCode = "RATE_LIMITED"
+	ErrTimeout            ErrorCode = "TIMEOUT"
+	ErrUnavailable        ErrorCode = "UNAVAILABLE"
+	ErrForbidden          ErrorCode = "FORBIDDEN"
+	ErrUnauthorized       ErrorCode = "UNAUTHORIZED"
+	ErrInvalid            ErrorCode = "INVALID_ARGUMENT"
+	ErrInternal           ErrorCode = "INTERNAL"
 )
 
 type TeamMember struct {
@@ -27,8 +40,16 @@ type TeamMember struct {
 }
 
 type Team struct {
-	TeamName string       `json:"team_name"`
-	Members  []TeamMember `json:"members"`
+	TeamName   string `json:"team_name"`
+	LeadUserID string `json:"lead_user_id,omitempty"`
+	// ManualAssignment turns off automatic reviewer selection for the team.
+	ManualAssignment bool              `json:"manual_assignment,omitempty"`
+	MergeRules       *MergeRules       `json:"merge_rules,omitempty"`
+	Escalation       *EscalationPolicy `json:"escalation,omitempty"`
+	WorkingHours     *WorkingHours     `json:"working_hours,omitempty"`
+	// CapacityWarning is set while the team is short of reviewers.
+	CapacityWarning *CapacityWarning `json:"capacity_warning,omitempty"`
+	Members         []TeamMember     `json:"members"`
 }
 
 type User struct {
@@ -42,10 +63,15 @@ type PullRequest struct {
 	ID                string     `json:"pull_request_id"`
 	Name              string     `json:"pull_request_name"`
 	AuthorID          string     `json:"author_id"`
+	Repository        string     `json:"repository,omitempty"`
 	Status            PRStatus   `json:"status"`
 	AssignedReviewers []string   `json:"assigned_reviewers"`
 	CreatedAt         *time.Time `json:"createdAt,omitempty"`
 	MergedAt          *time.Time `json:"mergedAt,omitempty"`
+	ClosedAt          *time.Time `json:"closedAt,omitempty"`
+	BlockedBy         []string   `json:"blocked_by,omitempty"` // PRs this one depends on, merged or not
+	Watchers          []string   `json:"watchers,omitempty"`   // users subscribed to its events
+	Reviews           []Review   `json:"reviews,omitempty"`    // review state of each reviewer
 }
 
 type PullRequestShort struct {
@@ -53,4 +79,29 @@ type PullRequestShort struct {
 	Name     string   `json:"pull_request_name"`
 	AuthorID string   `json:"author_id"`
 	Status   PRStatus `json:"status"`
+	Blocked  bool     `json:"blocked,omitempty"` // open and depends on an unmerged PR
+}
+
+type APIToken struct {
+	ID        string     `json:"token_id"`
+	UserID    string     `json:"user_id"`
+	Role      string     `json:"role"`
+	Name      string     `json:"name"`
+	Teams     []string   `json:"teams,omitempty"`
+	Hash      string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (t APIToken) Valid(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+type IssuedToken struct {
+	APIToken
+	Token string `json:"token"`
 }
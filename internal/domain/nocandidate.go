@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// Operations in which reviewer selection can find no candidate.
+const (
+	OpCreate     = "create"
+	OpReassign   = "reassign"
+	OpDeactivate = "deactivate"
+	OpTransfer   = "transfer_author"
+	OpEscalate   = "escalate"
+	OpReopen     = "reopen"
+)
+
+// NoCandidateEvent records a reviewer selection that found no active
+// candidate in Team. UserID is the reviewer being replaced, if any.
+type NoCandidateEvent struct {
+	Op       string    `json:"op"`
+	TeamName string    `json:"team_name"`
+	PRID     string    `json:"pull_request_id,omitempty"`
+	UserID   string    `json:"user_id,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+type TeamNoCandidate struct {
+	TeamName string         `json:"team_name"`
+	Total    int            `json:"total"`
+	ByOp     map[string]int `json:"by_op"`
+	LastAt   time.Time      `json:"last_at"`
+}
+
+// NoCandidateReport summarizes failed reviewer selections in [From, To];
+// Recent lists the latest of them.
+type NoCandidateReport struct {
+	From   string             `json:"from"`
+	To     string             `json:"to"`
+	Total  int                `json:"total"`
+	ByOp   map[string]int     `json:"by_op"`
+	ByTeam []TeamNoCandidate  `json:"by_team"`
+	Recent []NoCandidateEvent `json:"recent"`
+}
+
+const noCandidateRecent = 50
+
+// recordNoCandidate counts and stores a failed selection. It runs outside the
+// operation's transaction, which may have been rolled back, and never fails
+// the operation.
+func (s *Service) recordNoCandidate(ctx context.Context, e NoCandidateEvent) {
+	noCandidateTotal.Inc(e.Op)
+	if err := s.repo.AddNoCandidateEvent(ctx, e); err != nil {
+		log.Printf("record no candidate: %v", err)
+	}
+}
+
+// NoCandidateStats reports failed reviewer selections in teams (all when
+// empty) on UTC days [from, to].
+func (s *Service) NoCandidateStats(ctx context.Context, teams []string, from, to time.Time) (*NoCandidateReport, error) {
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.ListNoCandidateEvents(ctx, teams, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	rep := &NoCandidateReport{
+		From: from.Format(time.DateOnly), To: to.Format(time.DateOnly),
+		Total: len(events), ByOp: map[string]int{}, ByTeam: []TeamNoCandidate{}, Recent: []NoCandidateEvent{},
+	}
+	byTeam := map[string]*TeamNoCandidate{}
+	for _, e := range events {
+		rep.ByOp[e.Op]++
+		t := byTeam[e.TeamName]
+		if t == nil {
+			t = &TeamNoCandidate{TeamName: e.TeamName, ByOp: map[string]int{}}
+			byTeam[e.TeamName] = t
+		}
+		t.Total++
+		t.ByOp[e.Op]++
+		if e.At.After(t.LastAt) {
+			t.LastAt = e.At
+		}
+	}
+	for _, t := range byTeam {
+		rep.ByTeam = append(rep.ByTeam, *t)
+	}
+	sort.Slice(rep.ByTeam, func(i, j int) bool {
+		a, b := rep.ByTeam[i], rep.ByTeam[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.TeamName < b.TeamName
+	})
+	// events come oldest first
+	for i := len(events) - 1; i >= 0 && len(rep.Recent) < noCandidateRecent; i-- {
+		rep.Recent = append(rep.Recent, events[i])
+	}
+	return rep, nil
+}
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// Reasons an overload alert is raised.
+const (
+	OverloadThreshold = "threshold" // more open assignments than OverloadPolicy.MaxOpen
+	OverloadMedian    = "median"    // far above the team median
+)
+
+// minTeamForMedian is the number of active members below which a team median
+// says too little to compare against.
+const minTeamForMedian = 3
+
+// OverloadPolicy says when a reviewer's open-assignment count is anomalous:
+// above MaxOpen, or above MedianFactor times the team median (a median below
+// one counts as one). Zero disables the respective check.
+type OverloadPolicy struct {
+	MaxOpen      int
+	MedianFactor int
+}
+
+// ReviewerLoad is the number of open PRs an active reviewer is assigned to.
+type ReviewerLoad struct {
+	UserID   string
+	TeamName string
+	Open     int
+}
+
+// OverloadAlert is raised once per overload episode of a reviewer and
+// addressed to the team lead, if the team has one.
+type OverloadAlert struct {
+	ID         int64      `json:"alert_id"`
+	TeamName   string     `json:"team_name"`
+	UserID     string     `json:"user_id"`
+	LeadUserID string     `json:"lead_user_id,omitempty"`
+	Reason     string     `json:"reason"`
+	OpenCount  int        `json:"open_count"`
+	TeamMedian float64    `json:"team_median"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// DetectOverload returns an alert candidate for every reviewer whose load is
+// anomalous under p.
+func DetectOverload(loads []ReviewerLoad, p OverloadPolicy) []OverloadAlert {
+	byTeam := map[string][]int{}
+	for _, l := range loads {
+		byTeam[l.TeamName] = append(byTeam[l.TeamName], l.Open)
+	}
+	medians := map[string]float64{}
+	for team, counts := range byTeam {
+		sort.Ints(counts)
+		n := len(counts)
+		if n%2 == 1 {
+			medians[team] = float64(counts[n/2])
+		} else {
+			medians[team] = float64(counts[n/2-1]+counts[n/2]) / 2
+		}
+	}
+	var out []OverloadAlert
+	for _, l := range loads {
+		median := medians[l.TeamName]
+		a := OverloadAlert{TeamName: l.TeamName, UserID: l.UserID, OpenCount: l.Open, TeamMedian: median}
+		switch {
+		case p.MaxOpen > 0 && l.Open > p.MaxOpen:
+			a.Reason = OverloadThreshold
+		case p.MedianFactor > 0 && len(byTeam[l.TeamName]) >= minTeamForMedian &&
+			float64(l.Open) > float64(p.MedianFactor)*max(median, 1):
+			a.Reason = OverloadMedian
+		default:
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// CheckOverload compares current reviewer loads against p, opens alerts for
+// newly overloaded reviewers and resolves those whose load went back to
+// normal. It returns the alerts opened by this check.
+func (s *Service) CheckOverload(ctx context.Context, p OverloadPolicy) ([]OverloadAlert, error) {
+	loads, err := s.repo.ListReviewerLoads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := s.repo.SyncOverloadAlerts(ctx, DetectOverload(loads, p))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range opened {
+		overloadAlertsTotal.Inc(a.Reason)
+	}
+	return opened, nil
+}
+
+// ListOverloadAlerts returns alerts of the given teams (all when empty),
+// newest first; resolved ones only when includeResolved is set.
+func (s *Service) ListOverloadAlerts(ctx context.Context, teams []string, includeResolved bool) ([]OverloadAlert, error) {
+	return s.repo.ListOverloadAlerts(ctx, teams, includeResolved)
+}
+
+// SetTeamLead makes userID, who must be a member of the team, its lead; an
+// empty userID clears it.
+func (s *Service) SetTeamLead(ctx context.Context, team, userID string) error {
+	if userID != "" {
+		u, err := s.repo.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if u.TeamName != team {
+			return NewError(ErrInvalid, "team lead must be a member of the team")
+		}
+	}
+	return s.repo.SetTeamLead(ctx, team, userID)
+}
+
+// StartOverloadCheck checks reviewer loads every interval until Close and
+// hands each newly opened alert to notify.
+func (s *Service) StartOverloadCheck(p OverloadPolicy, every time.Duration, notify func(OverloadAlert)) *Job {
+	return startJob(every, false, func(ctx context.Context) {
+		opened, err := s.CheckOverload(ctx, p)
+		if err != nil {
+			log.Printf("overload check: %v", err)
+			return
+		}
+		for _, a := range opened {
+			log.Printf("overload alert: %s in team %s has %d open reviews (team median %g, lead %q)",
+				a.UserID, a.TeamName, a.OpenCount, a.TeamMedian, a.LeadUserID)
+			if notify != nil {
+				notify(a)
+			}
+		}
+	})
+}
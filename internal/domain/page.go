@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 500
+)
+
+// PageQuery selects one page of a list. Sort is one of the orders the list
+// supports, empty for its default; Cursor is the next_cursor of the previous
+// page. Repositories read Sort, Limit and Offset, which Service decodes from
+// the cursor, and return up to Limit+1 rows so that Service can tell whether
+// another page follows.
+type PageQuery struct {
+	Sort   string
+	Limit  int
+	Cursor string
+	Offset int
+}
+
+// PageInfo describes a returned page; NextCursor is empty on the last one.
+type PageInfo struct {
+	Sort       string `json:"sort"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// pageCursor is the content of an opaque cursor. It remembers the sort it
+// was issued for, since an offset means nothing under another order.
+type pageCursor struct {
+	Sort   string `json:"s"`
+	Offset int    `json:"o"`
+}
+
+func encodeCursor(sort string, offset int) string {
+	b, _ := json.Marshal(pageCursor{Sort: sort, Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// resolve fills in the defaults, sorts[0] being the default order, and
+// decodes the cursor into Offset.
+func (q *PageQuery) resolve(maxLimit int, sorts ...string) error {
+	if q.Sort == "" {
+		q.Sort = sorts[0]
+	}
+	if !slices.Contains(sorts, q.Sort) {
+		return NewError(ErrInvalid, "sort must be one of "+strings.Join(sorts, ", "))
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultPageLimit
+	}
+	if q.Limit < 0 || q.Limit > maxLimit {
+		return NewError(ErrInvalid, fmt.Sprintf("limit must be 1..%d", maxLimit))
+	}
+	if q.Cursor == "" {
+		return nil
+	}
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.Offset < 0 {
+		return NewError(ErrInvalid, "invalid cursor")
+	}
+	if c.Sort != q.Sort {
+		return NewError(ErrInvalid, "cursor was issued for sort "+c.Sort)
+	}
+	q.Offset = c.Offset
+	return nil
+}
+
+// info describes the page of q; more tells whether rows follow it.
+func (q PageQuery) info(more bool) PageInfo {
+	p := PageInfo{Sort: q.Sort, Limit: q.Limit}
+	if more {
+		p.NextCursor = encodeCursor(q.Sort, q.Offset+q.Limit)
+	}
+	return p
+}
+
+// paginate returns the page of items q selects; items are already in q.Sort
+// order.
+func paginate[T any](items []T, q PageQuery) ([]T, PageInfo) {
+	from := min(q.Offset, len(items))
+	to := min(from+q.Limit, len(items))
+	return items[from:to], q.info(to < len(items))
+}
+
+// trimPage cuts the extra row a repository fetched past q.Limit to learn
+// whether another page follows.
+func trimPage[T any](rows []T, q PageQuery) ([]T, PageInfo) {
+	if len(rows) > q.Limit {
+		return rows[:q.Limit], q.info(true)
+	}
+	return rows, q.info(false)
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const pairingTopPairs = 20
+
+// Pairing counts the assignments of a reviewer to the PRs of an author,
+// replacements included. TeamName is the author's team.
+type Pairing struct {
+	AuthorID    string `json:"author_id"`
+	ReviewerID  string `json:"reviewer_id"`
+	TeamName    string `json:"team_name"`
+	Assignments int    `json:"assignments"`
+}
+
+// AuthorPairings shows how the assignments to an author's PRs spread over
+// reviewers; TopShare is the share of the most frequent one.
+type AuthorPairings struct {
+	AuthorID      string  `json:"author_id"`
+	TeamName      string  `json:"team_name"`
+	Assignments   int     `json:"assignments"`
+	Reviewers     int     `json:"reviewers"`
+	TopReviewerID string  `json:"top_reviewer_id"`
+	TopShare      float64 `json:"top_share"`
+}
+
+// PairingReport covers assignments made in [From, To]. MaxRepeats and
+// WindowSeconds describe the pairing limit in force, if any.
+type PairingReport struct {
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	Assignments   int              `json:"assignments"`
+	MaxRepeats    int              `json:"max_repeats,omitempty"`
+	WindowSeconds float64          `json:"window_seconds,omitempty"`
+	ByAuthor      []AuthorPairings `json:"by_author"`
+	TopPairs      []Pairing        `json:"top_pairs"`
+}
+
+// WithPairingLimit makes automatic picks pass over reviewers already
+// assigned to maxRepeats PRs of the same author within window, so that
+// review cliques do not form. Like the review cooldown, the limit gives way
+// when nobody else is left. Zero maxRepeats turns it off.
+func (s *Service) WithPairingLimit(maxRepeats int, window time.Duration) *Service {
+	s.pairMax, s.pairWindow = maxRepeats, window
+	return s
+}
+
+// PairingStats reports author-reviewer pairings of assignments made in
+// [from, to] to PRs by authors of the given teams (all when empty).
+func (s *Service) PairingStats(ctx context.Context, teams []string, from, to time.Time) (*PairingReport, error) {
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := s.repo.StatsPairings(ctx, teams, from, to)
+	if err != nil {
+		return nil, err
+	}
+	rep := &PairingReport{
+		From: from.Format(time.DateOnly), To: to.Format(time.DateOnly),
+		ByAuthor: []AuthorPairings{}, TopPairs: []Pairing{},
+	}
+	if s.pairMax > 0 {
+		rep.MaxRepeats, rep.WindowSeconds = s.pairMax, s.pairWindow.Seconds()
+	}
+	authors := map[string]*AuthorPairings{}
+	top := map[string]int{}
+	for _, p := range pairs {
+		rep.Assignments += p.Assignments
+		a := authors[p.AuthorID]
+		if a == nil {
+			a = &AuthorPairings{AuthorID: p.AuthorID, TeamName: p.TeamName}
+			authors[p.AuthorID] = a
+		}
+		a.Assignments += p.Assignments
+		a.Reviewers++
+		if n := top[p.AuthorID]; p.Assignments > n || p.Assignments == n && p.ReviewerID < a.TopReviewerID {
+			a.TopReviewerID, top[p.AuthorID] = p.ReviewerID, p.Assignments
+		}
+	}
+	for id, a := range authors {
+		a.TopShare = float64(top[id]) / float64(a.Assignments)
+		rep.ByAuthor = append(rep.ByAuthor, *a)
+	}
+	sort.Slice(rep.ByAuthor, func(i, j int) bool {
+		a, b := rep.ByAuthor[i], rep.ByAuthor[j]
+		if a.TopShare != b.TopShare {
+			return a.TopShare > b.TopShare
+		}
+		return a.AuthorID < b.AuthorID
+	})
+	rep.TopPairs = append(rep.TopPairs, pairs...)
+	sort.Slice(rep.TopPairs, func(i, j int) bool {
+		a, b := rep.TopPairs[i], rep.TopPairs[j]
+		if a.Assignments != b.Assignments {
+			return a.Assignments > b.Assignments
+		}
+		if a.AuthorID != b.AuthorID {
+			return a.AuthorID < b.AuthorID
+		}
+		return a.ReviewerID < b.ReviewerID
+	})
+	if len(rep.TopPairs) > pairingTopPairs {
+		rep.TopPairs = rep.TopPairs[:pairingTopPairs]
+	}
+	return rep, nil
+}
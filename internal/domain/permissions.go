@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+type Permission string
+
+const (
+	PermPublic    Permission = ""
+	PermAll       Permission = "*"
+	PermTeamRead  Permission = "team:read"
+	PermTeamWrite Permission = "team:write"
+	PermUserWrite Permission = "user:write"
+	PermPRRead    Permission = "pr:read"
+	PermPRCreate  Permission = "pr:create"
+	PermPRMerge   Permission = "pr:merge"
+	PermPRAssign  Permission = "pr:reassign"
+	PermStatsRead Permission = "stats:read"
+	PermAuthAdmin Permission = "auth:admin"
+	// PermPRReview lets a reviewer act on their own review assignments.
+	PermPRReview Permission = "pr:review"
+	// PermExport allows generating report exports and handing out their URLs.
+	PermExport Permission = "export:create"
+	// PermAnyUser lets a caller bound to one user act on other users' data.
+	PermAnyUser Permission = "user:any"
+	// PermAuthenticated admits any caller with a valid credential. It is a
+	// route requirement only and cannot be granted to a role.
+	PermAuthenticated Permission = "authenticated"
+)
+
+var KnownPermissions = []Permission{
+	PermAll, PermTeamRead, PermTeamWrite, PermUserWrite, PermPRRead, PermPRCreate,
+	PermPRMerge, PermPRAssign, PermPRReview, PermStatsRead, PermAuthAdmin, PermAnyUser, PermExport,
+}
+
+// DefaultRolePermissions mirrors the seed data and is used until the role
+// table has been read.
+var DefaultRolePermissions = map[string][]Permission{
+	"admin": {PermAll},
+	"user":  {PermTeamRead, PermPRRead, PermPRReview, PermStatsRead},
+}
+
+// Privileged reports whether the permission guards a state-changing or
+// administrative endpoint, as opposed to read-only access or the everyday
+// actions of reviewers (pr:review). Exports stay privileged: their signed
+// links hand out data of every team.
+func (p Permission) Privileged() bool {
+	switch p {
+	case PermPublic, PermAuthenticated, PermTeamRead, PermPRRead, PermStatsRead, PermPRReview:
+		return false
+	}
+	return true
+}
+
+type RolePermissions struct {
+	Role        string       `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+func (rp RolePermissions) Has(p Permission) bool {
+	for _, have := range rp.Permissions {
+		if have == PermAll || have == p {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownPermission(p Permission) bool {
+	for _, k := range KnownPermissions {
+		if k == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) ListRoles(ctx context.Context) ([]RolePermissions, error) {
+	return s.repo.ListRolePermissions(ctx)
+}
+
+func (s *Service) SetRolePermissions(ctx context.Context, role string, perms []Permission) (*RolePermissions, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		return nil, NewError(ErrInvalid, "role is required")
+	}
+	if role == "admin" {
+		return nil, NewError(ErrInvalid, "admin role is built in and cannot be changed")
+	}
+	seen := map[Permission]bool{}
+	clean := []Permission{}
+	for _, p := range perms {
+		if !isKnownPermission(p) {
+			return nil, NewError(ErrInvalid, "unknown permission "+string(p))
+		}
+		if !seen[p] {
+			seen[p] = true
+			clean = append(clean, p)
+		}
+	}
+	sort.Slice(clean, func(i, j int) bool { return clean[i] < clean[j] })
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		return s.repo.SetRolePermissions(ctx, tx, role, clean)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RolePermissions{Role: role, Permissions: clean}, nil
+}
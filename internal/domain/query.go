@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Report dimensions and measures accepted by QueryReport. For assignments
+// the user is the reviewer and the week is that of the assignment; for
+// merges and latency the user is the author and the week is that of the
+// merge. The team is the user's team, the status and repository those of the
+// PR.
+var (
+	ReportDimensions = []string{"user", "team", "status", "week", "repository"}
+	ReportMeasures   = []string{"assignments", "merges", "latency"}
+)
+
+const (
+	defaultReportRows = 1000
+	maxReportRows     = 10000
+)
+
+// ReportQuery is a constrained, self-service stats query: group the facts by
+// Dimensions and compute Measures over those matching Filters.
+type ReportQuery struct {
+	Dimensions []string      `json:"dimensions"`
+	Measures   []string      `json:"measures"`
+	Filters    ReportFilters `json:"filters"`
+	Limit      int           `json:"limit"`
+}
+
+// ReportFilters narrow the facts; Since/Until apply to the event time
+// (assignment or merge).
+type ReportFilters struct {
+	Teams  []string   `json:"teams,omitempty"`
+	Users  []string   `json:"users,omitempty"`
+	Status []PRStatus `json:"status,omitempty"`
+	Since  *time.Time `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+type ReportResult struct {
+	Dimensions []string         `json:"dimensions"`
+	Measures   []string         `json:"measures"`
+	Rows       []map[string]any `json:"rows"`
+	// Truncated is set when more groups matched than Limit.
+	Truncated bool `json:"truncated"`
+}
+
+// QueryReport validates q against the whitelists and runs it. Rows are
+// ordered by the dimensions.
+func (s *Service) QueryReport(ctx context.Context, q ReportQuery) (*ReportResult, error) {
+	if len(q.Measures) == 0 {
+		return nil, NewError(ErrInvalid, "at least one measure is required")
+	}
+	if err := checkReportNames("dimension", q.Dimensions, ReportDimensions); err != nil {
+		return nil, err
+	}
+	if err := checkReportNames("measure", q.Measures, ReportMeasures); err != nil {
+		return nil, err
+	}
+	for _, st := range q.Filters.Status {
+		if st != StatusOPEN && st != StatusMERGED && st != StatusCLOSED {
+			return nil, NewError(ErrInvalid, fmt.Sprintf("unknown status %q", st))
+		}
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultReportRows
+	}
+	if q.Limit < 0 || q.Limit > maxReportRows {
+		return nil, NewError(ErrInvalid, fmt.Sprintf("limit must be 1..%d", maxReportRows))
+	}
+	rows, err := s.repo.QueryReport(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	res := &ReportResult{Dimensions: q.Dimensions, Measures: q.Measures, Rows: rows}
+	if len(rows) > q.Limit {
+		res.Rows, res.Truncated = rows[:q.Limit], true
+	}
+	if res.Dimensions == nil {
+		res.Dimensions = []string{}
+	}
+	return res, nil
+}
+
+func checkReportNames(kind string, names, allowed []string) error {
+	seen := map[string]bool{}
+	for _, n := range names {
+		if seen[n] {
+			return NewError(ErrInvalid, fmt.Sprintf("duplicate %s %q", kind, n))
+		}
+		seen[n] = true
+		known := false
+		for _, a := range allowed {
+			known = known || a == n
+		}
+		if !known {
+			return NewError(ErrInvalid, fmt.Sprintf("unknown %s %q", kind, n))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Rebalancing suggestion kinds.
+const (
+	RebalanceMove = "move" // move UserID from FromTeam to ToTeam
+	RebalanceAdd  = "add"  // bring Count more people into ToTeam
+)
+
+// Why a team needs more people.
+const (
+	RebalanceCapacity = "capacity" // too few active members to assign reviewers
+	RebalanceLoad     = "load"     // open reviews per member well above average
+)
+
+// rebalanceMinGap is how many open reviews per active member a team must be
+// above the average to count as overloaded.
+const rebalanceMinGap = 1.0
+
+// TeamLoad is the review load of a team: open review assignments of its
+// active members per active member.
+type TeamLoad struct {
+	TeamName      string  `json:"team_name"`
+	ActiveMembers int     `json:"active_members"`
+	OpenReviews   int     `json:"open_reviews"`
+	Load          float64 `json:"load"`
+}
+
+// RebalanceSuggestion is one way to even out review load. LoadBefore and
+// LoadAfter are the load of ToTeam now and after the change, assuming its
+// open reviews are shared among its new members too.
+type RebalanceSuggestion struct {
+	Rank       int     `json:"rank"`
+	Kind       string  `json:"kind"`
+	Reason     string  `json:"reason"`
+	ToTeam     string  `json:"to_team"`
+	FromTeam   string  `json:"from_team,omitempty"`
+	UserID     string  `json:"user_id,omitempty"`
+	Count      int     `json:"count"`
+	LoadBefore float64 `json:"load_before"`
+	LoadAfter  float64 `json:"load_after"`
+}
+
+// RebalanceReport lists the load of each team and the suggested changes,
+// most pressing first. Nothing is changed.
+type RebalanceReport struct {
+	AverageLoad float64               `json:"average_load"`
+	Teams       []TeamLoad            `json:"teams"`
+	Suggestions []RebalanceSuggestion `json:"suggestions"`
+}
+
+// Rebalancing suggests moves between the given teams (all when empty), and
+// additions to them, that even out review load. Teams short of reviewers
+// come first, then the overloaded ones by how much their load drops. A team
+// gives away at most one member and only while it keeps enough to assign
+// reviewers; the one with the fewest open reviews is picked.
+func (s *Service) Rebalancing(ctx context.Context, teams []string) (*RebalanceReport, error) {
+	loads, err := s.repo.ListReviewerLoads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byTeam := map[string]*TeamLoad{}
+	idlest := map[string]ReviewerLoad{}
+	total, active := 0, 0
+	for _, l := range loads {
+		if len(teams) > 0 && !slices.Contains(teams, l.TeamName) {
+			continue
+		}
+		t := byTeam[l.TeamName]
+		if t == nil {
+			t = &TeamLoad{TeamName: l.TeamName}
+			byTeam[l.TeamName] = t
+		}
+		t.ActiveMembers++
+		t.OpenReviews += l.Open
+		total += l.Open
+		active++
+		if cur, ok := idlest[l.TeamName]; !ok || l.Open < cur.Open {
+			idlest[l.TeamName] = l
+		}
+	}
+	report := &RebalanceReport{Teams: []TeamLoad{}, Suggestions: []RebalanceSuggestion{}}
+	if active > 0 {
+		report.AverageLoad = float64(total) / float64(active)
+	}
+	manual := map[string]bool{}
+	for name, t := range byTeam {
+		t.Load = float64(t.OpenReviews) / float64(t.ActiveMembers)
+		report.Teams = append(report.Teams, *t)
+		if manual[name], err = s.repo.TeamManualAssignment(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(report.Teams, func(i, j int) bool { return report.Teams[i].TeamName < report.Teams[j].TeamName })
+
+	short := func(t TeamLoad) bool { return !manual[t.TeamName] && t.ActiveMembers <= maxReviewers }
+	var needy, donors []TeamLoad
+	for _, t := range report.Teams {
+		switch {
+		case short(t), t.Load-report.AverageLoad >= rebalanceMinGap:
+			needy = append(needy, t)
+		case t.Load < report.AverageLoad && t.ActiveMembers > maxReviewers+1:
+			donors = append(donors, t)
+		}
+	}
+	// Teams short of reviewers get the donors first, then the busiest.
+	sort.SliceStable(needy, func(i, j int) bool {
+		if short(needy[i]) != short(needy[j]) {
+			return short(needy[i])
+		}
+		return needy[i].Load > needy[j].Load
+	})
+	used := map[string]bool{}
+	for _, t := range needy {
+		sg := RebalanceSuggestion{Reason: RebalanceLoad, ToTeam: t.TeamName, LoadBefore: t.Load}
+		if short(t) {
+			sg.Reason = RebalanceCapacity
+		}
+		moved := float64(t.OpenReviews) / float64(t.ActiveMembers+1)
+		best, bestLoad := "", math.Inf(1)
+		for _, d := range donors {
+			after := float64(d.OpenReviews) / float64(d.ActiveMembers-1)
+			if used[d.TeamName] || sg.Reason == RebalanceLoad && after > moved || after >= bestLoad {
+				continue
+			}
+			best, bestLoad = d.TeamName, after
+		}
+		if best != "" {
+			used[best] = true
+			sg.Kind, sg.FromTeam, sg.UserID, sg.Count = RebalanceMove, best, idlest[best].UserID, 1
+			sg.LoadAfter = moved
+			report.Suggestions = append(report.Suggestions, sg)
+			continue
+		}
+		sg.Kind, sg.Count = RebalanceAdd, 1
+		if sg.Reason == RebalanceCapacity {
+			sg.Count = maxReviewers + 1 - t.ActiveMembers
+		} else if report.AverageLoad > 0 {
+			sg.Count = max(1, int(math.Ceil(float64(t.OpenReviews)/report.AverageLoad))-t.ActiveMembers)
+		}
+		sg.LoadAfter = float64(t.OpenReviews) / float64(t.ActiveMembers+sg.Count)
+		report.Suggestions = append(report.Suggestions, sg)
+	}
+	sort.SliceStable(report.Suggestions, func(i, j int) bool {
+		a, b := report.Suggestions[i], report.Suggestions[j]
+		if (a.Reason == RebalanceCapacity) != (b.Reason == RebalanceCapacity) {
+			return a.Reason == RebalanceCapacity
+		}
+		if da, db := a.LoadBefore-a.LoadAfter, b.LoadBefore-b.LoadAfter; da != db {
+			return da > db
+		}
+		return a.ToTeam < b.ToTeam
+	})
+	for i := range report.Suggestions {
+		report.Suggestions[i].Rank = i + 1
+	}
+	return report, nil
+}
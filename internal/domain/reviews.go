@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// AcknowledgeReview records that the reviewer has seen the assignment. Only
+// the first action is kept, so repeated calls return the original time.
+func (s *Service) AcknowledgeReview(ctx context.Context, prID, userID string) (time.Time, error) {
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if pr.Status == StatusMERGED {
+		return time.Time{}, NewError(ErrPRMerged, "cannot acknowledge a merged PR")
+	}
+	if pr.Status == StatusCLOSED {
+		return time.Time{}, NewError(ErrPRClosed, "cannot acknowledge a closed PR")
+	}
+	return s.repo.AcknowledgeReview(ctx, prID, userID)
+}
+
+// OpenReviewsState returns the open PRs userID reviews and a cursor of that
+// list. The cursor changes when an assignment is added or removed or one of
+// the PRs is renamed, merged or (un)blocked, so long-polling clients compare it to see
+// whether there is anything new.
+func (s *Service) OpenReviewsState(ctx context.Context, userID string) ([]PullRequestShort, string, error) {
+	byUser, err := s.ListOpenReviews(ctx, []string{userID})
+	if err != nil {
+		return nil, "", err
+	}
+	prs := byUser[userID]
+	h := sha256.New()
+	for _, pr := range prs {
+		for _, f := range []string{pr.ID, pr.Name, string(pr.Status)} {
+			h.Write([]byte(f))
+			h.Write([]byte{0})
+		}
+		if pr.Blocked {
+			h.Write([]byte{1})
+		}
+	}
+	return prs, hex.EncodeToString(h.Sum(nil)[:12]), nil
+}
+
+// Review is the review state of an assigned reviewer: ReviewPending until
+// they approve or request changes, which they may change their mind about.
+type Review struct {
+	UserID    string     `json:"user_id"`
+	State     string     `json:"state"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// ApproveReview records that userID, an assigned reviewer, approves the PR.
+// Approvals count towards the min_approvals merge rule of the author's team.
+func (s *Service) ApproveReview(ctx context.Context, prID, userID string) (*PullRequest, error) {
+	return s.decideReview(ctx, prID, userID, ReviewApproved, PREventApproved)
+}
+
+// RequestChanges records that userID, an assigned reviewer, asks for
+// changes, which withdraws their approval.
+func (s *Service) RequestChanges(ctx context.Context, prID, userID string) (*PullRequest, error) {
+	return s.decideReview(ctx, prID, userID, ReviewChangesRequested, PREventChangesRequested)
+}
+
+// decideReview sets the reviewer's state; repeating the current decision
+// changes nothing and keeps its time.
+func (s *Service) decideReview(ctx context.Context, prID, userID, state, kind string) (*PullRequest, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.repo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		switch pr.Status {
+		case StatusMERGED:
+			return NewError(ErrPRMerged, "cannot review a merged PR")
+		case StatusCLOSED:
+			return NewError(ErrPRClosed, "cannot review a closed PR")
+		}
+		changed, err := s.repo.DecideReview(ctx, tx, prID, userID, state)
+		if err != nil || !changed {
+			return err
+		}
+		return s.repo.AddPREvents(ctx, tx, []PREvent{{PRID: prID, Kind: kind, UserID: userID}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPR(ctx, prID)
+}
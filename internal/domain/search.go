@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Types of search results, in the order they are listed on equal rank.
+const (
+	SearchTeam = "team"
+	SearchUser = "user"
+	SearchPR   = "pull_request"
+)
+
+var searchTypes = []string{SearchTeam, SearchUser, SearchPR}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchText      = 100
+)
+
+// SearchQuery looks Text up in team names, user ids and names, and PR ids
+// and names, case-insensitively. Types limits the kinds of results (all when
+// empty) and Teams the teams they belong to: a user's team, a PR's author's
+// team.
+type SearchQuery struct {
+	Text  string
+	Types []string
+	Teams []string
+	Limit int
+}
+
+// SearchResult is one match. TeamName is set for users, Status for PRs.
+type SearchResult struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	TeamName string   `json:"team_name,omitempty"`
+	Status   PRStatus `json:"status,omitempty"`
+}
+
+// Search returns up to q.Limit matches: exact matches first, then prefix and
+// substring matches, each by type and name.
+func (s *Service) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	switch n := utf8.RuneCountInString(q.Text); {
+	case n == 0:
+		return nil, NewError(ErrInvalid, "q is required")
+	case n > maxSearchText:
+		return nil, NewError(ErrInvalid, "q is too long")
+	}
+	for _, t := range q.Types {
+		if searchTypeOrder(t) < 0 {
+			return nil, NewError(ErrInvalid, "unknown type "+t+"; use team, user or pull_request")
+		}
+	}
+	if len(q.Types) == 0 {
+		q.Types = searchTypes
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = defaultSearchLimit
+	case q.Limit < 0 || q.Limit > maxSearchLimit:
+		return nil, NewError(ErrInvalid, "limit must be between 1 and 100")
+	}
+	// the repo returns the best q.Limit matches of each type
+	found, err := s.repo.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ToLower(q.Text)
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if ra, rb := SearchRank(a, text), SearchRank(b, text); ra != rb {
+			return ra < rb
+		}
+		if a.Type != b.Type {
+			return searchTypeOrder(a.Type) < searchTypeOrder(b.Type)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found, nil
+}
+
+// SearchRank is 0 when the lowercased text equals the result's id or name,
+// 1 when either starts with it and 2 otherwise. Repos order their matches of
+// a type by it.
+func SearchRank(r SearchResult, text string) int {
+	id, name := strings.ToLower(r.ID), strings.ToLower(r.Name)
+	switch {
+	case id == text || name == text:
+		return 0
+	case strings.HasPrefix(id, text) || strings.HasPrefix(name, text):
+		return 1
+	}
+	return 2
+}
+
+func searchTypeOrder(t string) int {
+	for i, s := range searchTypes {
+		if s == t {
+			return i
+		}
+	}
+	return -1
+}
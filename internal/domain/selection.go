@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// WithReviewCooldown makes automatic picks pass over reviewers whose review
+// of a PR by the same author ended, by a decision or the merge, less than d
+// ago, so that authors get fresh eyes. They are still picked when nobody
+// else is left. Zero turns the cooldown off.
+func (s *Service) WithReviewCooldown(d time.Duration) *Service {
+	s.cooldown = d
+	return s
+}
+
+// pickReviewers is PickReviewersFromTeam for a PR of authorID that passes
+// over the reviewers in cooldown or over the pairing limit for the author,
+// unless nobody else is left.
+func (s *Service) pickReviewers(ctx context.Context, prID, team, authorID string, exclude []string, limit int) ([]string, error) {
+	avoid, err := s.avoidedReviewers(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+	if len(avoid) == 0 {
+		return s.repo.PickReviewersFromTeam(ctx, prID, team, exclude, limit)
+	}
+	picked, err := s.repo.PickReviewersFromTeam(ctx, prID, team, append(slices.Clone(exclude), avoid...), limit)
+	if err != nil || len(picked) == limit {
+		return picked, err
+	}
+	more, err := s.repo.PickReviewersFromTeam(ctx, prID, team, append(slices.Clone(exclude), picked...), limit-len(picked))
+	if err != nil {
+		return nil, err
+	}
+	return append(picked, more...), nil
+}
+
+// avoidedReviewers lists the reviewers automatic picks for authorID should
+// pass over.
+func (s *Service) avoidedReviewers(ctx context.Context, authorID string) ([]string, error) {
+	var avoid []string
+	now := s.clock.Now()
+	if s.cooldown > 0 {
+		recent, err := s.repo.ListRecentReviewers(ctx, authorID, now.Add(-s.cooldown))
+		if err != nil {
+			return nil, err
+		}
+		avoid = append(avoid, recent...)
+	}
+	if s.pairMax > 0 {
+		counts, err := s.repo.CountPairings(ctx, authorID, now.Add(-s.pairWindow))
+		if err != nil {
+			return nil, err
+		}
+		for id, n := range counts {
+			if n >= s.pairMax && !slices.Contains(avoid, id) {
+				avoid = append(avoid, id)
+			}
+		}
+	}
+	return avoid, nil
+}
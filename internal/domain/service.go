@@ -1,46 +1,242 @@
 package domain
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
-	"errors"
+	"slices"
 	"sort"
+	"sync/atomic"
+	"time"
 )
 
 type Repo interface {
-	CreateTeam(tx *sql.Tx, teamName string) error
-	TeamExists(tx *sql.Tx, teamName string) (bool, error)
-	UpsertUser(tx *sql.Tx, u User) error
-	GetTeamMembers(teamName string) ([]TeamMember, error)
+	CreateTeam(ctx context.Context, tx *sql.Tx, teamName string) error
+	TeamExists(ctx context.Context, tx *sql.Tx, teamName string) (bool, error)
+	SetTeamManualAssignment(ctx context.Context, tx *sql.Tx, teamName string, manual bool) error
+	TeamManualAssignment(ctx context.Context, teamName string) (bool, error)
+	SetTeamMergeRules(ctx context.Context, tx *sql.Tx, teamName string, rules MergeRules) error
+	TeamMergeRules(ctx context.Context, teamName string) (MergeRules, error)
+	SetTeamEscalation(ctx context.Context, tx *sql.Tx, teamName string, p EscalationPolicy) error
+	TeamEscalation(ctx context.Context, teamName string) (EscalationPolicy, error)
+	// ListStalledPRs leaves out PRs blocked by an unmerged dependency.
+	ListStalledPRs(ctx context.Context) ([]StalledPR, error)
+	SetTeamWorkingHours(ctx context.Context, tx *sql.Tx, teamName string, w WorkingHours) error
+	TeamWorkingHours(ctx context.Context, teamName string) (WorkingHours, error)
+	SetTeamHolidays(ctx context.Context, tx *sql.Tx, teamName string, holidays []Holiday) error
+	ListTeamHolidays(ctx context.Context, teamName string) ([]Holiday, error)
+	DeferReview(ctx context.Context, tx *sql.Tx, prID, userID string, until time.Time) error
+	ListDueDeferredReviews(ctx context.Context, now time.Time) ([]DeferredReview, error)
+	ClearDeferredReview(ctx context.Context, tx *sql.Tx, prID, userID string) error
+	UpsertUser(ctx context.Context, tx *sql.Tx, u User) error
+	GetTeamMembers(ctx context.Context, teamName string) ([]TeamMember, error)
 
-	SetUserActive(uID string, active bool) (*User, error)
-	GetUser(uID string) (*User, error)
+	SetUserActive(ctx context.Context, uID string, active bool) (*User, error)
+	AnonymizeUser(ctx context.Context, tx *sql.Tx, uID, placeholder string) error
+	SetUsername(ctx context.Context, tx *sql.Tx, uID, username string) error
+	GetUser(ctx context.Context, uID string) (*User, error)
+	// SetGitHubLogin maps login to uID, replacing the user's previous
+	// login; an empty login only drops it. A login mapped to another user
+	// fails with ErrInvalid; the check is atomic with the insert.
+	SetGitHubLogin(ctx context.Context, tx *sql.Tx, uID, login string) error
+	// GitHubLoginUser returns the user login is mapped to, or ErrNotFound.
+	GitHubLoginUser(ctx context.Context, login string) (string, error)
 
-	CreatePR(tx *sql.Tx, pr PullRequest) error
-	GetPR(prID string) (*PullRequest, error)
-	SetPRMerged(tx *sql.Tx, prID string) (*PullRequest, error)
+	CreatePR(ctx context.Context, tx *sql.Tx, pr PullRequest) error
+	SetPRAuthor(ctx context.Context, tx *sql.Tx, prID, authorID string) error
+	GetPR(ctx context.Context, prID string) (*PullRequest, error)
+	SetPRMerged(ctx context.Context, tx *sql.Tx, prID string) (*PullRequest, error)
+	// SetPRStatus closes or reopens a PR, setting or clearing ClosedAt.
+	SetPRStatus(ctx context.Context, tx *sql.Tx, prID string, status PRStatus) (*PullRequest, error)
+	// ImportPR stores a PR with its original timestamps and reviewers,
+	// assigned when it was created.
+	ImportPR(ctx context.Context, tx *sql.Tx, pr ImportedPR) error
 
-	GetAuthorTeam(authorID string) (string, error)
-	PickReviewersFromTeam(prID, team string, exclude []string, limit int) ([]string, error)
+	GetAuthorTeam(ctx context.Context, authorID string) (string, error)
+	PickReviewersFromTeam(ctx context.Context, prID, team string, exclude []string, limit int) ([]string, error)
+	// ListRecentReviewers lists the reviewers of authorID's PRs whose
+	// review was decided, or whose PR was merged, at or after since.
+	ListRecentReviewers(ctx context.Context, authorID string, since time.Time) ([]string, error)
+	// CountPairings counts the assignments of each reviewer to authorID's
+	// PRs at or after since, replacements included.
+	CountPairings(ctx context.Context, authorID string, since time.Time) (map[string]int, error)
+	StatsPairings(ctx context.Context, teams []string, from, to time.Time) ([]Pairing, error)
+	// ListPRHistory lists the PRs created on UTC dates from..to by authors
+	// of teams (all when empty), oldest first.
+	ListPRHistory(ctx context.Context, teams []string, from, to time.Time) ([]HistoricalPR, error)
 
-	GetAssignedReviewers(prID string) ([]string, error)
-	AssignReviewers(tx *sql.Tx, prID string, userIDs []string) error
-	ReplaceReviewer(tx *sql.Tx, prID, oldUser, newUser string) error
-	DeleteReviewer(tx *sql.Tx, prID, userID string) error
+	GetAssignedReviewers(ctx context.Context, prID string) ([]string, error)
+	GetReviewStates(ctx context.Context, prID string) (map[string]string, error)
+	// ListReviews returns the review state of each assigned reviewer, by
+	// user id.
+	ListReviews(ctx context.Context, prID string) ([]Review, error)
+	// DecideReview sets the review state of an assigned reviewer and
+	// reports whether it changed; it fails with ErrNotAssigned for others.
+	DecideReview(ctx context.Context, tx *sql.Tx, prID, userID, state string) (bool, error)
+	AssignReviewers(ctx context.Context, tx *sql.Tx, prID string, userIDs []string) error
+	ReplaceReviewer(ctx context.Context, tx *sql.Tx, prID, oldUser, newUser string) error
+	DeleteReviewer(ctx context.Context, tx *sql.Tx, prID, userID string) error
 
-	ListUserPRs(uID string) ([]PullRequestShort, error)
+	AddPRDependency(ctx context.Context, tx *sql.Tx, prID, blockedBy string) error
+	DeletePRDependency(ctx context.Context, tx *sql.Tx, prID, blockedBy string) error
+	ListPRDependencies(ctx context.Context, prID string) ([]string, error)
+	AddPRWatcher(ctx context.Context, tx *sql.Tx, prID, userID string) error
+	DeletePRWatcher(ctx context.Context, tx *sql.Tx, prID, userID string) error
+	ListPRWatchers(ctx context.Context, prID string) ([]string, error)
+	// AddPRComment sets the ID and CreatedAt of c.
+	AddPRComment(ctx context.Context, tx *sql.Tx, c *Comment) error
+	ListPRComments(ctx context.Context, prID string) ([]Comment, error)
 
-	StatsAssignmentsByUser() (map[string]int, error)
-	StatsAssignmentsByPR() (map[string]int, error)
+	// ListUserPRs and ListOpenReviews set Blocked; ListUserPRs orders
+	// blocked PRs last.
+	ListUserPRs(ctx context.Context, uID string, p PageQuery) ([]PullRequestShort, error)
+	ListOpenReviews(ctx context.Context, userIDs []string) (map[string][]PullRequestShort, error)
+	ListPRs(ctx context.Context, f PRFilter, p PageQuery) ([]PullRequestShort, error)
 
-	BulkDeactivateUsers(team string, userIDs []string) ([]string, error)
-	ListOpenAssignmentsByUsers(userIDs []string) ([]OpenAssignment, error)
+	StatsAssignmentsByUser(ctx context.Context, q AssignmentQuery) ([]AssignmentCount, int, error)
+	StatsAssignmentsByPR(ctx context.Context, q AssignmentQuery) ([]AssignmentCount, int, error)
+	StatsTimeToFirstApproval(ctx context.Context, teams []string) (byTeam, byReviewer []DurationStats, err error)
+	ListSLABreaches(ctx context.Context, f SLAFilter) ([]SLABreach, error)
+	StatsMergeTime(ctx context.Context, teams []string, since, until *time.Time) (overall DurationStats, byTeam []DurationStats, err error)
+	StatsPRStatus(ctx context.Context, teams []string, weekly bool) ([]PRStatusCount, error)
+	StatsOpenPRAge(ctx context.Context, teams []string) ([]PRAgeCount, error)
+	StatsDailyActivity(ctx context.Context, teams []string, from, to time.Time) ([]DayCount, error)
 
-	WithTx(fn func(tx *sql.Tx) error) error
+	BulkDeactivateUsers(ctx context.Context, team string, userIDs []string) ([]string, error)
+	ListOpenAssignmentsByUsers(ctx context.Context, userIDs []string) ([]OpenAssignment, error)
+
+	CreateAPIToken(ctx context.Context, tx *sql.Tx, t APIToken) (*APIToken, error)
+	GetAPIToken(ctx context.Context, tokenID string) (*APIToken, error)
+	GetAPITokenByHash(ctx context.Context, hash string) (*APIToken, error)
+	SetAPITokenExpiry(ctx context.Context, tx *sql.Tx, tokenID string, expiresAt time.Time) (*APIToken, error)
+	RevokeAPIToken(ctx context.Context, tokenID string) (*APIToken, error)
+	RevokeUserAPITokens(ctx context.Context, tx *sql.Tx, userID string) ([]string, error)
+	ListAPITokens(ctx context.Context, userID string) ([]APIToken, error)
+
+	ListRolePermissions(ctx context.Context) ([]RolePermissions, error)
+	SetRolePermissions(ctx context.Context, tx *sql.Tx, role string, perms []Permission) error
+	RoleExists(ctx context.Context, role string) (bool, error)
+
+	InsertAuthEvents(ctx context.Context, events []AuthEvent) error
+	ListAuthEvents(ctx context.Context, f AuthEventFilter) ([]AuthEvent, error)
+
+	AddTokenUsage(ctx context.Context, usage []TokenUsage) error
+	CreateWebhook(ctx context.Context, w Webhook) error
+	// ListWebhooks returns the webhooks, oldest first.
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	CreateExport(ctx context.Context, e Export) error
+	FinishExport(ctx context.Context, id string, content []byte, errMsg string) error
+	GetExport(ctx context.Context, id string) (*Export, error)
+	GetExportContent(ctx context.Context, id string) ([]byte, error)
+	ListTokenUsage(ctx context.Context, idleSince *time.Time) ([]TokenUsage, error)
+	AcknowledgeReview(ctx context.Context, prID, userID string) (time.Time, error)
+	StatsReviewerResponsiveness(ctx context.Context, teams []string, sla time.Duration) ([]ReviewerResponsiveness, error)
+	ListIdleReviewers(ctx context.Context, teams []string, since time.Time) ([]IdleReviewer, error)
+	AddPREvents(ctx context.Context, tx *sql.Tx, events []PREvent) error
+	ListPREvents(ctx context.Context, prID string) ([]PREvent, error)
+	StatsTeamComparison(ctx context.Context, teams []string) ([]TeamComparison, error)
+	StatsOpenPRsByDay(ctx context.Context, teams []string, from, to time.Time) ([]TeamDayCount, error)
+	StatsReassignments(ctx context.Context, teams []string, from, to time.Time) (int, []Reassignment, error)
+	TakeStatsSnapshot(ctx context.Context, day time.Time) (time.Time, error)
+	GetStatsSnapshot(ctx context.Context, day time.Time, teams []string) (*StatsSnapshot, error)
+	StatsAuthorMergeRates(ctx context.Context, teams []string, from, to time.Time, mergeSLA time.Duration) ([]AuthorMergeRate, error)
+	AddNoCandidateEvent(ctx context.Context, e NoCandidateEvent) error
+	ListNoCandidateEvents(ctx context.Context, teams []string, from, until time.Time) ([]NoCandidateEvent, error)
+	QueryReport(ctx context.Context, q ReportQuery) ([]map[string]any, error)
+	ListReviewerLoads(ctx context.Context) ([]ReviewerLoad, error)
+	SyncOverloadAlerts(ctx context.Context, current []OverloadAlert) ([]OverloadAlert, error)
+	ListOverloadAlerts(ctx context.Context, teams []string, includeResolved bool) ([]OverloadAlert, error)
+	SetTeamLead(ctx context.Context, team, userID string) error
+	GetTeamLead(ctx context.Context, team string) (string, error)
+	StatsAssignmentsByTeam(ctx context.Context, q AssignmentQuery) ([]AssignmentCount, int, error)
+	StatsAssignmentsByRepository(ctx context.Context, q AssignmentQuery) ([]AssignmentCount, int, error)
+	StatsMergeTimeByRepository(ctx context.Context, teams []string, since, until *time.Time) ([]DurationStats, error)
+	StatsMergeTimeMaterialized(ctx context.Context) (DurationStats, []DurationStats, error)
+	RefreshStats(ctx context.Context) (time.Time, error)
+	StatsRefreshedAt(ctx context.Context) (time.Time, error)
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
+}
+
+// Sort orders for assignment stats.
+const (
+	SortByCount = "count" // most assignments first
+	SortByID    = "id"
+)
+
+const (
+	DefaultStatsLimit = DefaultPageLimit
+	MaxStatsLimit     = 1000
+)
+
+// Sort orders for team members.
+const (
+	MemberSortUserID   = "user_id"
+	MemberSortUsername = "username"
+)
+
+// Sort orders for PR lists; ties are broken by id.
+const (
+	PRSortID      = "pull_request_id"
+	PRSortCreated = "created_at"
+	PRSortNewest  = "-created_at"
+)
+
+// PRFilter selects PRs for ListPRs; zero fields match everything. Teams
+// matches the author's team, or with IncludeReviewing a reviewer's team too.
+type PRFilter struct {
+	Teams            []string
+	AuthorID         string
+	Status           PRStatus
+	IncludeReviewing bool
 }
 
+// AssignmentQuery selects a page of assignment counts. Limit 0 returns all
+// rows (used by exports). A non-empty UserIDs counts only assignments of
+// those reviewers. Materialized reads per-user and per-team counts from the
+// materialized aggregates instead of the live tables.
+type AssignmentQuery struct {
+	Teams        []string
+	UserIDs      []string
+	Sort         string
+	Limit        int
+	Offset       int
+	Materialized bool
+	// Cursor, when set, replaces Offset.
+	Cursor string
+}
+
+// AssignmentCount is the number of reviewer assignments of one user, PR,
+// team or repository; exactly one of the keys is set.
+type AssignmentCount struct {
+	UserID     string `json:"user_id,omitempty"`
+	PRID       string `json:"pull_request_id,omitempty"`
+	TeamName   string `json:"team_name,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Count      int    `json:"count"`
+}
+
+// AssignmentStats holds one page per grouping; the totals count all rows so
+// clients can page through them.
 type AssignmentStats struct {
-	ByUser map[string]int `json:"by_user,omitempty"`
-	ByPR   map[string]int `json:"by_pr,omitempty"`
+	ByUser     []AssignmentCount `json:"by_user,omitempty"`
+	ByPR       []AssignmentCount `json:"by_pr,omitempty"`
+	ByTeam     []AssignmentCount `json:"by_team,omitempty"`
+	ByRepo     []AssignmentCount `json:"by_repository,omitempty"`
+	TotalUsers *int              `json:"total_users,omitempty"`
+	TotalPRs   *int              `json:"total_prs,omitempty"`
+	TotalTeams *int              `json:"total_teams,omitempty"`
+	TotalRepos *int              `json:"total_repositories,omitempty"`
+	// MaterializedAt is set when per-user and per-team counts come from the
+	// materialized aggregates and tells how fresh they are.
+	MaterializedAt *time.Time `json:"materialized_at,omitempty"`
+	Sort           string     `json:"sort"`
+	Limit          int        `json:"limit"`
+	Offset         int        `json:"offset"`
+	// Page continues after the longest of the returned lists.
+	Page PageInfo `json:"page"`
 }
 
 type OpenAssignment struct {
@@ -54,6 +250,8 @@ type BulkDeactivateResult struct {
 	Team          string                `json:"team_name"`
 	Deactivated   []string              `json:"deactivated_user_ids"`
 	Reassignments []BulkReassignOutcome `json:"reassignments"`
+	// DryRun is set when nothing was changed (PlanBulkDeactivation).
+	DryRun bool `json:"dry_run,omitempty"`
 }
 type BulkReassignOutcome struct {
 	PRID       string  `json:"pr_id"`
@@ -64,25 +262,91 @@ type BulkReassignOutcome struct {
 
 type Service struct {
 	repo Repo
+	// materialized switches assignment and merge-time stats to the
+	// aggregates kept fresh by StartStatsRefresh.
+	materialized atomic.Bool
+	clock        Clock
+	// watchNotify, when set, receives the events of watched PRs.
+	watchNotify func(WatchNotification)
+	// cooldown is the review cooldown of WithReviewCooldown, pairMax and
+	// pairWindow the limit of WithPairingLimit.
+	cooldown   time.Duration
+	pairMax    int
+	pairWindow time.Duration
+	// capacityNotify, when set, receives the teams left short of reviewers.
+	capacityNotify func(CapacityWarning)
+	// webhookDispatch, when set, sends events to outgoing webhooks.
+	webhookDispatch func(WebhookDelivery)
+}
+
+func NewService(r Repo) *Service { return &Service{repo: r, clock: SystemClock} }
+
+// WithClock makes the service read the current time from c.
+func (s *Service) WithClock(c Clock) *Service {
+	s.clock = c
+	return s
 }
 
-func NewService(r Repo) *Service { return &Service{repo: r} }
+func (s *Service) AddTeam(ctx context.Context, team Team) (*Team, error) {
+	t, _, err := s.addTeam(ctx, team, false)
+	return t, err
+}
 
-func (s *Service) AddTeam(team Team) (*Team, error) {
-	returnTeam := &Team{TeamName: team.TeamName}
-	err := s.repo.WithTx(func(tx *sql.Tx) error {
-		exists, err := s.repo.TeamExists(tx, team.TeamName)
+// UpsertTeam adds the team like AddTeam, or merges the members into it when
+// it exists: listed members are added or updated, the others stay, and the
+// team's settings, manual_assignment included, are left alone. A member
+// listed twice takes the last entry. created reports whether the team is
+// new.
+func (s *Service) UpsertTeam(ctx context.Context, team Team) (t *Team, created bool, err error) {
+	team.Members = DedupeMembers(team.Members)
+	return s.addTeam(ctx, team, true)
+}
+
+// DedupeMembers keeps one entry per user id, with the data of the last one
+// at the place of the first.
+func DedupeMembers(members []TeamMember) []TeamMember {
+	at := make(map[string]int, len(members))
+	out := make([]TeamMember, 0, len(members))
+	for _, m := range members {
+		if i, ok := at[m.UserID]; ok {
+			out[i] = m
+			continue
+		}
+		at[m.UserID] = len(out)
+		out = append(out, m)
+	}
+	return out
+}
+
+func (s *Service) addTeam(ctx context.Context, team Team, upsert bool) (*Team, bool, error) {
+	returnTeam := &Team{TeamName: team.TeamName, ManualAssignment: team.ManualAssignment}
+	// left are the teams members move out of, and the new one.
+	left := []string{team.TeamName}
+	created := false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		exists, err := s.repo.TeamExists(ctx, tx, team.TeamName)
 		if err != nil {
 			return err
 		}
-		if exists {
-			return wrapCode(ErrTeamExists, "team_name already exists")
+		if exists && !upsert {
+			return NewError(ErrTeamExists, "team_name already exists")
 		}
-		if err := s.repo.CreateTeam(tx, team.TeamName); err != nil {
-			return err
+		if !exists {
+			if err := s.repo.CreateTeam(ctx, tx, team.TeamName); err != nil {
+				return err
+			}
+			if team.ManualAssignment {
+				if err := s.repo.SetTeamManualAssignment(ctx, tx, team.TeamName, true); err != nil {
+					return err
+				}
+			}
+			created = true
 		}
 		for _, m := range team.Members {
-			if err := s.repo.UpsertUser(tx, User{
+			if old, err := s.repo.GetUser(ctx, m.UserID); err == nil && old.IsActive {
+				left = append(left, old.TeamName)
+			}
+			if err := s.repo.UpsertUser(ctx, tx, User{
 				UserID:   m.UserID,
 				Username: m.Username,
 				TeamName: team.TeamName,
@@ -94,114 +358,334 @@ func (s *Service) AddTeam(team Team) (*Team, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	members, err := s.repo.GetTeamMembers(team.TeamName)
+	if !created {
+		if returnTeam.ManualAssignment, err = s.repo.TeamManualAssignment(ctx, team.TeamName); err != nil {
+			return nil, false, err
+		}
+		if returnTeam.LeadUserID, err = s.repo.GetTeamLead(ctx, team.TeamName); err != nil {
+			return nil, false, err
+		}
+	}
+	members, err := s.repo.GetTeamMembers(ctx, team.TeamName)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if members == nil {
+		members = []TeamMember{}
 	}
 	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
 	returnTeam.Members = members
-	return returnTeam, nil
+	returnTeam.CapacityWarning = capacityWarning(team.TeamName, members, returnTeam.ManualAssignment)
+	s.checkCapacity(ctx, left...)
+	return returnTeam, created, nil
 }
 
-func (s *Service) GetTeam(teamName string) (*Team, error) {
-	members, err := s.repo.GetTeamMembers(teamName)
+func (s *Service) GetTeam(ctx context.Context, teamName string) (*Team, error) {
+	members, err := s.repo.GetTeamMembers(ctx, teamName)
 	if err != nil {
 		return nil, err
 	}
 	if len(members) == 0 {
-		return nil, wrapCode(ErrNotFound, "team not found")
+		return nil, NewError(ErrNotFound, "team not found")
+	}
+	lead, err := s.repo.GetTeamLead(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	manual, err := s.repo.TeamManualAssignment(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	team := &Team{
+		TeamName: teamName, LeadUserID: lead, ManualAssignment: manual, Members: members,
+		CapacityWarning: capacityWarning(teamName, members, manual),
+	}
+	rules, err := s.repo.TeamMergeRules(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !rules.IsZero() {
+		team.MergeRules = &rules
+	}
+	esc, err := s.repo.TeamEscalation(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !esc.IsZero() {
+		team.Escalation = &esc
+	}
+	hours, err := s.repo.TeamWorkingHours(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !hours.IsZero() {
+		team.WorkingHours = &hours
 	}
-	return &Team{TeamName: teamName, Members: members}, nil
+	return team, nil
 }
 
-func (s *Service) SetIsActive(userID string, active bool) (*User, error) {
-	u, err := s.repo.SetUserActive(userID, active)
+func (s *Service) SetIsActive(ctx context.Context, userID string, active bool) (*User, error) {
+	was, err := s.repo.GetUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	u, err := s.repo.SetUserActive(ctx, userID, active)
+	if err != nil {
+		return nil, err
+	}
+	if was.IsActive && !active {
+		s.checkCapacity(ctx, u.TeamName)
+	}
 	return u, nil
 }
 
-func (s *Service) CreatePR(prID, name, authorID string) (*PullRequest, error) {
+// UserUpdate holds the profile fields UpdateUser changes; nil fields keep
+// their value.
+type UserUpdate struct {
+	Username *string `json:"username,omitempty"`
+}
+
+// UpdateUser changes profile fields of an existing user, without
+// resubmitting their team. Membership and activity have their own calls.
+func (s *Service) UpdateUser(ctx context.Context, userID string, upd UserUpdate) (*User, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if upd.Username != nil {
+			return s.repo.SetUsername(ctx, tx, userID, *upd.Username)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetUser(ctx, userID)
+}
+
+// AnonymizedUsername replaces the username of anonymized users.
+const AnonymizedUsername = "anonymized user"
+
+// AnonymizeUser erases the user's personal data for a deletion request. The
+// user_id is kept so PRs, reviewer assignments and stats stay consistent; the
+// user is deactivated, their API tokens are revoked and their GitHub login is
+// forgotten. It returns the ids of the revoked tokens.
+func (s *Service) AnonymizeUser(ctx context.Context, userID string) (*User, []string, error) {
+	var revoked []string
+	wasActive := false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if u, err := s.repo.GetUser(ctx, userID); err == nil {
+			wasActive = u.IsActive
+		}
+		if err := s.repo.AnonymizeUser(ctx, tx, userID, AnonymizedUsername); err != nil {
+			return err
+		}
+		if err := s.repo.SetGitHubLogin(ctx, tx, userID, ""); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = s.repo.RevokeUserAPITokens(ctx, tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wasActive {
+		s.checkCapacity(ctx, u.TeamName)
+	}
+	return u, revoked, nil
+}
+
+// CreatePR opens a PR and assigns up to two reviewers from the author's team.
+// An empty prID is replaced with a generated UUIDv7; repository is optional
+// and only used to group stats.
+func (s *Service) CreatePR(ctx context.Context, prID, name, authorID, repository string) (*PullRequest, error) {
+	pr, _, err := s.CreatePRPreferring(ctx, prID, name, authorID, repository, nil)
+	return pr, err
+}
+
+// CreatePRPreferring is CreatePR that assigns the valid preferred reviewers
+// suggested by the author first, in order, and only then fills the
+// remaining slots automatically. It returns the suggestions it rejected and
+// why. Teams with manual assignment get the valid suggestions only.
+func (s *Service) CreatePRPreferring(ctx context.Context, prID, name, authorID, repository string, preferred []string) (*PullRequest, []RejectedReviewer, error) {
+	if prID == "" {
+		var err error
+		if prID, err = NewUUIDv7(s.clock.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
 	var out *PullRequest
-	err := s.repo.WithTx(func(tx *sql.Tx) error {
-		if _, err := s.repo.GetPR(prID); err == nil {
-			return wrapCode(ErrPRExists, "PR id already exists")
+	var rejected []RejectedReviewer
+	var events []PREvent
+	assigned, team, manual := 0, "", false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.repo.GetPR(ctx, prID); err == nil {
+			return NewError(ErrPRExists, "PR id already exists")
 		}
-		author, err := s.repo.GetUser(authorID)
+		author, err := s.repo.GetUser(ctx, authorID)
 		if err != nil {
 			return err
 		}
-		team := author.TeamName
-		pr := PullRequest{ID: prID, Name: name, AuthorID: authorID, Status: StatusOPEN}
-		if err := s.repo.CreatePR(tx, pr); err != nil {
+		team = author.TeamName
+		pr := PullRequest{ID: prID, Name: name, AuthorID: authorID, Repository: repository, Status: StatusOPEN}
+		if err := s.repo.CreatePR(ctx, tx, pr); err != nil {
 			return err
 		}
-		cands, err := s.repo.PickReviewersFromTeam(prID, team, []string{authorID}, 2)
-		if err != nil {
+		if manual, err = s.repo.TeamManualAssignment(ctx, team); err != nil {
+			return err
+		}
+		var cands []string
+		if cands, rejected, err = s.preferredReviewers(ctx, author, preferred); err != nil {
 			return err
 		}
-		if err := s.repo.AssignReviewers(tx, prID, cands); err != nil {
+		if !manual && len(cands) < maxReviewers {
+			picked, err := s.pickReviewers(ctx, prID, team, authorID, append([]string{authorID}, cands...), maxReviewers-len(cands))
+			if err != nil {
+				return err
+			}
+			cands = append(cands, picked...)
+		}
+		if err := s.repo.AssignReviewers(ctx, tx, prID, cands); err != nil {
 			return err
 		}
+		if err := s.deferOutsideHours(ctx, tx, prID, cands...); err != nil {
+			return err
+		}
+		events = []PREvent{{PRID: prID, Kind: PREventCreated, UserID: authorID}}
+		for _, c := range cands {
+			e := PREvent{PRID: prID, Kind: PREventAssigned, UserID: c}
+			if slices.Contains(preferred, c) {
+				e.Reason = ReasonPreferred
+			}
+			events = append(events, e)
+		}
+		if err := s.repo.AddPREvents(ctx, tx, events); err != nil {
+			return err
+		}
+		assigned = len(cands)
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	pr, err := s.repo.GetPR(prID)
+	assignmentsTotal.Add(float64(assigned))
+	if assigned == 0 && !manual {
+		s.recordNoCandidate(ctx, NoCandidateEvent{Op: OpCreate, TeamName: team, PRID: prID})
+	}
+	s.publish(ctx, events)
+	pr, err := s.repo.GetPR(ctx, prID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	revs, _ := s.repo.GetAssignedReviewers(prID)
+	revs, _ := s.repo.GetAssignedReviewers(ctx, prID)
 	pr.AssignedReviewers = revs
 	out = pr
-	return out, nil
+	return out, rejected, nil
+}
+
+func (s *Service) MergePR(ctx context.Context, prID string) (*PullRequest, error) {
+	return s.MergePRIfMatch(ctx, prID, "")
+}
+
+// MergePRIfMatch is MergePR that fails with ErrPreconditionFailed when the
+// PR no longer matches the If-Match value ifMatch (see PullRequest.ETag).
+// Open PRs that break the merge rules of the author's team fail with
+// ErrMergeBlocked.
+func (s *Service) MergePRIfMatch(ctx context.Context, prID, ifMatch string) (*PullRequest, error) {
+	return s.mergePR(ctx, prID, ifMatch, true)
 }
 
-func (s *Service) MergePR(prID string) (*PullRequest, error) {
+// RecordExternalMerge marks an open PR merged because its repository host
+// already merged it, so the merge rules of the author's team are not
+// checked: refusing would only leave the PR open here.
+func (s *Service) RecordExternalMerge(ctx context.Context, prID string) (*PullRequest, error) {
+	return s.mergePR(ctx, prID, "", false)
+}
+
+func (s *Service) mergePR(ctx context.Context, prID, ifMatch string, checkRules bool) (*PullRequest, error) {
 	var out *PullRequest
-	err := s.repo.WithTx(func(tx *sql.Tx) error {
-		pr, err := s.repo.GetPR(prID)
+	merged := false
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.repo.GetPR(ctx, prID)
 		if err != nil {
 			return err
 		}
+		if pr.AssignedReviewers, err = s.repo.GetAssignedReviewers(ctx, prID); err != nil {
+			return err
+		}
+		if err := checkIfMatch(ifMatch, pr); err != nil {
+			return err
+		}
 		if pr.Status == StatusMERGED {
 			out = pr
 			return nil
 		}
-		pr, err = s.repo.SetPRMerged(tx, prID)
+		if pr.Status == StatusCLOSED {
+			return NewError(ErrPRClosed, "cannot merge a closed PR; reopen it first")
+		}
+		if checkRules {
+			if err := s.checkMergeRules(ctx, pr); err != nil {
+				return err
+			}
+		}
+		pr, err = s.repo.SetPRMerged(ctx, tx, prID)
 		if err != nil {
 			return err
 		}
-		out = pr
+		if err := s.repo.AddPREvents(ctx, tx, []PREvent{{PRID: prID, Kind: PREventMerged}}); err != nil {
+			return err
+		}
+		out, merged = pr, true
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	revs, _ := s.repo.GetAssignedReviewers(prID)
+	if merged && out.CreatedAt != nil && out.MergedAt != nil {
+		mergeDuration.Observe(out.MergedAt.Sub(*out.CreatedAt).Seconds())
+	}
+	if merged {
+		s.publish(ctx, []PREvent{{PRID: prID, Kind: PREventMerged}})
+	}
+	revs, _ := s.repo.GetAssignedReviewers(ctx, prID)
 	out.AssignedReviewers = revs
 	return out, nil
 }
 
-func (s *Service) Reassign(prID, oldUserID string) (*PullRequest, string, error) {
+func (s *Service) Reassign(ctx context.Context, prID, oldUserID string) (*PullRequest, string, error) {
+	return s.ReassignIfMatch(ctx, prID, oldUserID, "")
+}
+
+// ReassignIfMatch is Reassign that fails with ErrPreconditionFailed when
+// the PR no longer matches the If-Match value ifMatch.
+func (s *Service) ReassignIfMatch(ctx context.Context, prID, oldUserID, ifMatch string) (*PullRequest, string, error) {
 	var out *PullRequest
 	var replacedBy string
-	err := s.repo.WithTx(func(tx *sql.Tx) error {
-		pr, err := s.repo.GetPR(prID)
+	var event PREvent
+	var noCandidate *NoCandidateEvent
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.repo.GetPR(ctx, prID)
 		if err != nil {
 			return err
 		}
-		if pr.Status == StatusMERGED {
-			return wrapCode(ErrPRMerged, "cannot reassign on merged PR")
-		}
-		assigned, err := s.repo.GetAssignedReviewers(prID)
+		assigned, err := s.repo.GetAssignedReviewers(ctx, prID)
 		if err != nil {
 			return err
 		}
+		pr.AssignedReviewers = assigned
+		if err := checkIfMatch(ifMatch, pr); err != nil {
+			return err
+		}
+		if pr.Status == StatusMERGED {
+			return NewError(ErrPRMerged, "cannot reassign on merged PR")
+		}
+		if pr.Status == StatusCLOSED {
+			return NewError(ErrPRClosed, "cannot reassign on closed PR")
+		}
 		found := false
 		for _, a := range assigned {
 			if a == oldUserID {
@@ -210,139 +694,377 @@ func (s *Service) Reassign(prID, oldUserID string) (*PullRequest, string, error)
 			}
 		}
 		if !found {
-			return wrapCode(ErrNotAssigned, "reviewer is not assigned to this PR")
+			return NewError(ErrNotAssigned, "reviewer is not assigned to this PR")
 		}
-		oldUser, err := s.repo.GetUser(oldUserID)
+		oldUser, err := s.repo.GetUser(ctx, oldUserID)
 		if err != nil {
 			return err
 		}
 		excl := append(assigned, pr.AuthorID)
-		cands, err := s.repo.PickReviewersFromTeam(prID, oldUser.TeamName, excl, 1)
+		cands, err := s.pickReviewers(ctx, prID, oldUser.TeamName, pr.AuthorID, excl, 1)
 		if err != nil {
 			return err
 		}
 		if len(cands) == 0 {
-			return wrapCode(ErrNoCandidate, "no active replacement candidate in team")
+			noCandidate = &NoCandidateEvent{Op: OpReassign, TeamName: oldUser.TeamName, PRID: prID, UserID: oldUserID}
+			return NewError(ErrNoCandidate, "no active replacement candidate in team")
 		}
-		if err := s.repo.ReplaceReviewer(tx, prID, oldUserID, cands[0]); err != nil {
+		if err := s.repo.ReplaceReviewer(ctx, tx, prID, oldUserID, cands[0]); err != nil {
+			return err
+		}
+		if err := s.deferOutsideHours(ctx, tx, prID, cands[0]); err != nil {
+			return err
+		}
+		event = PREvent{PRID: prID, Kind: PREventReplaced, UserID: oldUserID, ReplacedBy: cands[0], Reason: ReasonManual}
+		if err := s.repo.AddPREvents(ctx, tx, []PREvent{event}); err != nil {
 			return err
 		}
 		replacedBy = cands[0]
 		return nil
 	})
+	if noCandidate != nil {
+		s.recordNoCandidate(ctx, *noCandidate)
+	}
 	if err != nil {
 		return nil, "", err
 	}
-	pr, err := s.repo.GetPR(prID)
+	assignmentsTotal.Inc()
+	reassignmentsTotal.Inc("manual")
+	s.publish(ctx, []PREvent{event})
+	pr, err := s.repo.GetPR(ctx, prID)
 	if err != nil {
 		return nil, "", err
 	}
-	revs, _ := s.repo.GetAssignedReviewers(prID)
+	revs, _ := s.repo.GetAssignedReviewers(ctx, prID)
 	pr.AssignedReviewers = revs
 	out = pr
 	return out, replacedBy, nil
 }
 
-func (s *Service) ListUserPRs(userID string) ([]PullRequestShort, error) {
-	return s.repo.ListUserPRs(userID)
+func (s *Service) UserTeam(ctx context.Context, userID string) (string, error) {
+	u, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return u.TeamName, nil
+}
+
+// GetPR returns the PR with its reviewers.
+func (s *Service) GetPR(ctx context.Context, prID string) (*PullRequest, error) {
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if pr.AssignedReviewers, err = s.repo.GetAssignedReviewers(ctx, prID); err != nil {
+		return nil, err
+	}
+	if pr.BlockedBy, err = s.repo.ListPRDependencies(ctx, prID); err != nil {
+		return nil, err
+	}
+	if pr.Watchers, err = s.repo.ListPRWatchers(ctx, prID); err != nil {
+		return nil, err
+	}
+	if pr.Reviews, err = s.repo.ListReviews(ctx, prID); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// PRTeam returns the team of the PR's author.
+func (s *Service) PRTeam(ctx context.Context, prID string) (string, error) {
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return "", err
+	}
+	return s.UserTeam(ctx, pr.AuthorID)
+}
+
+// GetTeamPage is GetTeam with one page of the members. Usernames may be
+// encrypted at rest, so members are sorted here rather than in the query.
+func (s *Service) GetTeamPage(ctx context.Context, teamName string, p PageQuery) (*Team, PageInfo, error) {
+	if err := p.resolve(MaxPageLimit, MemberSortUserID, MemberSortUsername); err != nil {
+		return nil, PageInfo{}, err
+	}
+	team, err := s.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	if p.Sort == MemberSortUsername {
+		slices.SortStableFunc(team.Members, func(a, b TeamMember) int {
+			return cmp.Or(cmp.Compare(a.Username, b.Username), cmp.Compare(a.UserID, b.UserID))
+		})
+	}
+	var info PageInfo
+	team.Members, info = paginate(team.Members, p)
+	return team, info, nil
+}
+
+// ListUserPRs lists the PRs userID reviews, blocked ones last.
+func (s *Service) ListUserPRs(ctx context.Context, userID string, p PageQuery) ([]PullRequestShort, PageInfo, error) {
+	if err := p.resolve(MaxPageLimit, PRSortID, PRSortCreated, PRSortNewest); err != nil {
+		return nil, PageInfo{}, err
+	}
+	rows, err := s.repo.ListUserPRs(ctx, userID, p)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	page, info := trimPage(rows, p)
+	return page, info, nil
+}
+
+// ListOpenReviews returns the open PRs each user reviews, blocked ones
+// last, with an empty list for users without any.
+func (s *Service) ListOpenReviews(ctx context.Context, userIDs []string) (map[string][]PullRequestShort, error) {
+	byUser, err := s.repo.ListOpenReviews(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		if byUser[id] == nil {
+			byUser[id] = []PullRequestShort{}
+		}
+		slices.SortStableFunc(byUser[id], func(a, b PullRequestShort) int {
+			switch {
+			case a.Blocked == b.Blocked:
+				return 0
+			case a.Blocked:
+				return 1
+			}
+			return -1
+		})
+	}
+	return byUser, nil
+}
+
+func (s *Service) ListPRs(ctx context.Context, f PRFilter, p PageQuery) ([]PullRequestShort, PageInfo, error) {
+	if f.Status != "" && f.Status != StatusOPEN && f.Status != StatusMERGED && f.Status != StatusCLOSED {
+		return nil, PageInfo{}, NewError(ErrInvalid, "status must be OPEN, MERGED or CLOSED")
+	}
+	if err := p.resolve(MaxPageLimit, PRSortID, PRSortCreated, PRSortNewest); err != nil {
+		return nil, PageInfo{}, err
+	}
+	rows, err := s.repo.ListPRs(ctx, f, p)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	page, info := trimPage(rows, p)
+	return page, info, nil
+}
+
+// ListTeamPRs lists the PRs authored by members of the team, and with
+// includeReviewing also those they review, for per-team review boards.
+func (s *Service) ListTeamPRs(ctx context.Context, teamName string, includeReviewing bool, status PRStatus, p PageQuery) ([]PullRequestShort, PageInfo, error) {
+	members, err := s.repo.GetTeamMembers(ctx, teamName)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	if len(members) == 0 {
+		return nil, PageInfo{}, NewError(ErrNotFound, "team not found")
+	}
+	return s.ListPRs(ctx, PRFilter{Teams: []string{teamName}, Status: status, IncludeReviewing: includeReviewing}, p)
 }
 
-func (s *Service) StatsAssignments(groupBy string) (*AssignmentStats, error) {
-	stats := &AssignmentStats{}
-	switch groupBy {
-	case "user":
-		m, err := s.repo.StatsAssignmentsByUser()
+// StatsAssignments counts reviewer assignments; a non-empty q.Teams limits
+// the result to reviewers (by user) or authors (by PR) from those teams.
+// Results are sorted by q.Sort (ties by id) and paginated.
+func (s *Service) StatsAssignments(ctx context.Context, groupBy string, q AssignmentQuery) (*AssignmentStats, error) {
+	if q.Offset < 0 {
+		return nil, NewError(ErrInvalid, "offset must not be negative")
+	}
+	p := PageQuery{Sort: q.Sort, Limit: q.Limit, Cursor: q.Cursor, Offset: q.Offset}
+	if err := p.resolve(MaxStatsLimit, SortByCount, SortByID); err != nil {
+		return nil, err
+	}
+	q.Sort, q.Limit, q.Offset = p.Sort, p.Limit, p.Offset
+	stats := &AssignmentStats{Sort: q.Sort, Limit: q.Limit, Offset: q.Offset}
+	byUser, byPR, byTeam, byRepo := groupBy == "user", groupBy == "pr", groupBy == "team", groupBy == "repository"
+	if !byUser && !byPR && !byTeam && !byRepo {
+		byUser, byPR = true, true
+	}
+	if s.materialized.Load() && (byUser || byTeam) {
+		at, err := s.repo.StatsRefreshedAt(ctx)
 		if err != nil {
 			return nil, err
 		}
-		stats.ByUser = m
-	case "pr":
-		m, err := s.repo.StatsAssignmentsByPR()
+		q.Materialized, stats.MaterializedAt = true, &at
+	}
+	if byUser {
+		page, total, err := s.repo.StatsAssignmentsByUser(ctx, q)
 		if err != nil {
 			return nil, err
 		}
-		stats.ByPR = m
-	default:
-		mu, err := s.repo.StatsAssignmentsByUser()
+		stats.ByUser, stats.TotalUsers = page, &total
+	}
+	if byPR {
+		page, total, err := s.repo.StatsAssignmentsByPR(ctx, q)
 		if err != nil {
 			return nil, err
 		}
-		mp, err := s.repo.StatsAssignmentsByPR()
+		stats.ByPR, stats.TotalPRs = page, &total
+	}
+	if byTeam {
+		page, total, err := s.repo.StatsAssignmentsByTeam(ctx, q)
 		if err != nil {
 			return nil, err
 		}
-		stats.ByUser, stats.ByPR = mu, mp
+		stats.ByTeam, stats.TotalTeams = page, &total
 	}
+	if byRepo {
+		page, total, err := s.repo.StatsAssignmentsByRepository(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		stats.ByRepo, stats.TotalRepos = page, &total
+	}
+	longest := 0
+	for _, t := range []*int{stats.TotalUsers, stats.TotalPRs, stats.TotalTeams, stats.TotalRepos} {
+		if t != nil {
+			longest = max(longest, *t)
+		}
+	}
+	stats.Page = p.info(q.Offset+q.Limit < longest)
 	return stats, nil
 }
 
-func (s *Service) BulkDeactivateAndReassign(team string, userIDs []string) (*BulkDeactivateResult, error) {
-	res := &BulkDeactivateResult{Team: team}
+func (s *Service) BulkDeactivateAndReassign(ctx context.Context, team string, userIDs []string) (*BulkDeactivateResult, error) {
+	var res *BulkDeactivateResult
+	var noCandidates []NoCandidateEvent
+	var events []PREvent
 
-	err := s.repo.WithTx(func(tx *sql.Tx) error {
-		deactivated, err := s.repo.BulkDeactivateUsers(team, userIDs)
-		if err != nil {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if res, noCandidates, err = s.planBulkDeactivation(ctx, team, userIDs); err != nil {
 			return err
 		}
-		res.Deactivated = deactivated
-		if len(deactivated) == 0 {
+		if len(res.Deactivated) == 0 {
 			return nil
 		}
-
-		open, err := s.repo.ListOpenAssignmentsByUsers(deactivated)
-		if err != nil {
+		if _, err := s.repo.BulkDeactivateUsers(ctx, team, res.Deactivated); err != nil {
 			return err
 		}
-
-		for _, item := range open {
-			assigned, err := s.repo.GetAssignedReviewers(item.PRID)
-			if err != nil {
-				return err
-			}
-			excl := append(append([]string{}, assigned...), item.AuthorID)
-			cands, err := s.repo.PickReviewersFromTeam(item.PRID, item.OldUserTeam, excl, 1)
-			if err != nil {
-				return err
-			}
-			if len(cands) > 0 {
-				if err := s.repo.ReplaceReviewer(tx, item.PRID, item.OldUserID, cands[0]); err != nil {
+		for _, o := range res.Reassignments {
+			if o.ReplacedBy != nil {
+				if err := s.repo.ReplaceReviewer(ctx, tx, o.PRID, o.OldUserID, *o.ReplacedBy); err != nil {
+					return err
+				}
+				if err := s.deferOutsideHours(ctx, tx, o.PRID, *o.ReplacedBy); err != nil {
 					return err
 				}
-				r := cands[0]
-				res.Reassignments = append(res.Reassignments, BulkReassignOutcome{
-					PRID: item.PRID, OldUserID: item.OldUserID, Action: "replaced", ReplacedBy: &r,
+				events = append(events, PREvent{
+					PRID: o.PRID, Kind: PREventReplaced, UserID: o.OldUserID, ReplacedBy: *o.ReplacedBy, Reason: ReasonDeactivation,
 				})
 			} else {
-				if err := s.repo.DeleteReviewer(tx, item.PRID, item.OldUserID); err != nil {
+				if err := s.repo.DeleteReviewer(ctx, tx, o.PRID, o.OldUserID); err != nil {
 					return err
 				}
-				res.Reassignments = append(res.Reassignments, BulkReassignOutcome{
-					PRID: item.PRID, OldUserID: item.OldUserID, Action: "removed", ReplacedBy: nil,
+				events = append(events, PREvent{
+					PRID: o.PRID, Kind: PREventRemoved, UserID: o.OldUserID, Reason: ReasonDeactivation,
 				})
 			}
 		}
-		return nil
+		return s.repo.AddPREvents(ctx, tx, events)
 	})
 	if err != nil {
 		return nil, err
 	}
+	for _, o := range res.Reassignments {
+		if o.ReplacedBy != nil {
+			assignmentsTotal.Inc()
+			reassignmentsTotal.Inc("deactivation")
+		}
+	}
+	for _, e := range noCandidates {
+		s.recordNoCandidate(ctx, e)
+	}
+	s.publish(ctx, events)
+	if len(res.Deactivated) > 0 {
+		s.checkCapacity(ctx, team)
+	}
 	return res, nil
 }
 
-func wrapCode(code ErrorCode, msg string) error {
-	return errors.New(string(code) + ":" + msg)
+// PlanBulkDeactivation returns what BulkDeactivateAndReassign would do,
+// changing nothing: the same users and the same replacements, since both
+// pick the replacements here.
+func (s *Service) PlanBulkDeactivation(ctx context.Context, team string, userIDs []string) (*BulkDeactivateResult, error) {
+	res, _, err := s.planBulkDeactivation(ctx, team, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	res.DryRun = true
+	return res, nil
 }
 
-func ParseErrorCode(err error) (ErrorCode, string) {
-	if err == nil {
-		return "", ""
+// planBulkDeactivation only reads. The users to deactivate are excluded
+// from the candidates as if they were inactive already, and replacements
+// made for one PR are seen by the next item of the same PR. Teams with
+// manual assignment get no replacements.
+func (s *Service) planBulkDeactivation(ctx context.Context, team string, userIDs []string) (*BulkDeactivateResult, []NoCandidateEvent, error) {
+	res := &BulkDeactivateResult{Team: team, Deactivated: []string{}, Reassignments: []BulkReassignOutcome{}}
+	members, err := s.repo.GetTeamMembers(ctx, team)
+	if err != nil {
+		return nil, nil, err
+	}
+	wanted := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	for _, m := range members {
+		if wanted[m.UserID] {
+			res.Deactivated = append(res.Deactivated, m.UserID)
+		}
+	}
+	if len(res.Deactivated) == 0 {
+		return res, nil, nil
+	}
+	manual, err := s.repo.TeamManualAssignment(ctx, team)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	open, err := s.repo.ListOpenAssignmentsByUsers(ctx, res.Deactivated)
+	if err != nil {
+		return nil, nil, err
 	}
-	s := err.Error()
-	for _, c := range []ErrorCode{ErrTeamExists, ErrPRExists, ErrPRMerged, ErrNotAssigned, ErrNoCandidate, ErrNotFound} {
-		prefix := string(c) + ":"
-		if len(s) >= len(prefix) && s[:len(prefix)] == prefix {
-			return c, s[len(prefix):]
+	var noCandidates []NoCandidateEvent
+	assigned := map[string][]string{}
+	for _, item := range open {
+		cur, ok := assigned[item.PRID]
+		if !ok {
+			if cur, err = s.repo.GetAssignedReviewers(ctx, item.PRID); err != nil {
+				return nil, nil, err
+			}
+		}
+		var cands []string
+		if !manual {
+			excl := append(append(append([]string{}, cur...), res.Deactivated...), item.AuthorID)
+			if cands, err = s.pickReviewers(ctx, item.PRID, item.OldUserTeam, item.AuthorID, excl, 1); err != nil {
+				return nil, nil, err
+			}
+		}
+		var next []string
+		for _, id := range cur {
+			if id != item.OldUserID {
+				next = append(next, id)
+			}
+		}
+		if len(cands) > 0 {
+			r := cands[0]
+			next = append(next, r)
+			res.Reassignments = append(res.Reassignments, BulkReassignOutcome{
+				PRID: item.PRID, OldUserID: item.OldUserID, Action: "replaced", ReplacedBy: &r,
+			})
+		} else {
+			res.Reassignments = append(res.Reassignments, BulkReassignOutcome{
+				PRID: item.PRID, OldUserID: item.OldUserID, Action: "removed", ReplacedBy: nil,
+			})
+			if !manual {
+				noCandidates = append(noCandidates, NoCandidateEvent{
+					Op: OpDeactivate, TeamName: item.OldUserTeam, PRID: item.PRID, UserID: item.OldUserID,
+				})
+			}
 		}
+		assigned[item.PRID] = next
 	}
-	return "", s
+	return res, noCandidates, nil
 }
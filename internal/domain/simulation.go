@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Reviewer selection strategies the simulation replays.
+const (
+	StrategyCurrent     = "current"      // the service's own: md5(PR id + user id) order
+	StrategyLeastLoaded = "least_loaded" // fewest open reviews at the time, then the current order
+	StrategyRoundRobin  = "round_robin"  // the members of a team in turn, by user id
+)
+
+var strategies = []string{StrategyCurrent, StrategyLeastLoaded, StrategyRoundRobin}
+
+// HistoricalPR is a PR as the simulation replays it: who wrote it in which
+// team, when it was open and who reviewed it.
+type HistoricalPR struct {
+	PRID      string
+	AuthorID  string
+	TeamName  string
+	CreatedAt time.Time
+	MergedAt  *time.Time
+	Reviewers []string
+}
+
+// ReviewerShare is what one reviewer got in a replay. PeakOpen is the most
+// reviews they had open at once.
+type ReviewerShare struct {
+	UserID      string `json:"user_id"`
+	Assignments int    `json:"assignments"`
+	PeakOpen    int    `json:"peak_open"`
+}
+
+// SimulationOutcome sums up one replay of the PRs. Concentration is the
+// Herfindahl index of assignments over reviewers, as in /stats/teams.
+// MedianResponseSeconds estimates the time to the first response of a PR
+// as the lowest median response time among its reviewers; it is null when
+// none of them has responded to anything yet.
+type SimulationOutcome struct {
+	Assignments           int             `json:"assignments"`
+	Reviewers             int             `json:"reviewers"`
+	MaxAssignments        int             `json:"max_assignments"`
+	Concentration         float64         `json:"assignment_concentration"`
+	PeakOpen              int             `json:"peak_open"`
+	MedianResponseSeconds *float64        `json:"median_response_seconds"`
+	ByReviewer            []ReviewerShare `json:"by_reviewer"`
+}
+
+// SimulationReport compares what PRs created in [From, To] actually got
+// with what Strategy would have given them. PRs of teams with manual
+// assignment are skipped.
+type SimulationReport struct {
+	Strategy  string            `json:"strategy"`
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	PRs       int               `json:"prs"`
+	Skipped   int               `json:"skipped"`
+	Actual    SimulationOutcome `json:"actual"`
+	Simulated SimulationOutcome `json:"simulated"`
+}
+
+// Simulate replays the creation of the PRs of teams (all when empty)
+// created on UTC dates from..to against strategy and reports how the
+// distribution of reviews and the response time would have differed. It
+// changes nothing. Candidates are the team's active members of today, and
+// the cooldown and pairing limit are left out; a reviewer's review stays
+// open until the PR was merged.
+func (s *Service) Simulate(ctx context.Context, teams []string, strategy string, from, to time.Time) (*SimulationReport, error) {
+	if !slices.Contains(strategies, strategy) {
+		return nil, NewError(ErrInvalid, "strategy must be one of current, least_loaded, round_robin")
+	}
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	prs, err := s.repo.ListPRHistory(ctx, teams, from, to)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.repo.StatsReviewerResponsiveness(ctx, nil, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	medians := map[string]float64{}
+	for _, r := range resp {
+		if r.Responded > 0 {
+			medians[r.UserID] = r.MedianSeconds
+		}
+	}
+	rep := &SimulationReport{Strategy: strategy, From: from.Format(time.DateOnly), To: to.Format(time.DateOnly)}
+	actual, simulated := newReplay(medians), newReplay(medians)
+	members := map[string][]string{}
+	manual := map[string]bool{}
+	next := map[string]int{}
+	for _, pr := range prs {
+		cands, ok := members[pr.TeamName]
+		if !ok {
+			if manual[pr.TeamName], err = s.repo.TeamManualAssignment(ctx, pr.TeamName); err != nil {
+				return nil, err
+			}
+			list, err := s.repo.GetTeamMembers(ctx, pr.TeamName)
+			if err != nil {
+				return nil, err
+			}
+			cands = []string{}
+			for _, m := range list {
+				if m.IsActive {
+					cands = append(cands, m.UserID)
+				}
+			}
+			slices.Sort(cands)
+			members[pr.TeamName] = cands
+		}
+		if manual[pr.TeamName] {
+			rep.Skipped++
+			continue
+		}
+		rep.PRs++
+		actual.add(pr, pr.Reviewers)
+		var picked []string
+		switch strategy {
+		case StrategyCurrent:
+			picked = hashOrder(pr.PRID, without(cands, pr.AuthorID))
+		case StrategyLeastLoaded:
+			picked = hashOrder(pr.PRID, without(cands, pr.AuthorID))
+			open := map[string]int{}
+			for _, id := range picked {
+				open[id] = simulated.openAt(id, pr.CreatedAt)
+			}
+			sort.SliceStable(picked, func(i, j int) bool { return open[picked[i]] < open[picked[j]] })
+		case StrategyRoundRobin:
+			start := next[pr.TeamName]
+			for i := 0; i < len(cands) && len(picked) < maxReviewers; i++ {
+				if j := (start + i) % len(cands); cands[j] != pr.AuthorID {
+					picked = append(picked, cands[j])
+					next[pr.TeamName] = (j + 1) % len(cands)
+				}
+			}
+		}
+		simulated.add(pr, picked[:min(maxReviewers, len(picked))])
+	}
+	rep.Actual, rep.Simulated = actual.outcome(), simulated.outcome()
+	return rep, nil
+}
+
+// hashOrder orders ids the way PickReviewersFromTeam does for prID.
+func hashOrder(prID string, ids []string) []string {
+	keys := map[string]string{}
+	for _, id := range ids {
+		sum := md5.Sum([]byte(prID + id))
+		keys[id] = hex.EncodeToString(sum[:])
+	}
+	sort.Slice(ids, func(i, j int) bool { return keys[ids[i]] < keys[ids[j]] })
+	return ids
+}
+
+// without returns a copy of ids without id.
+func without(ids []string, id string) []string {
+	return slices.DeleteFunc(slices.Clone(ids), func(s string) bool { return s == id })
+}
+
+// replay tallies the reviews handed out while PRs are replayed in order of
+// creation.
+type replay struct {
+	medians  map[string]float64
+	shares   map[string]*ReviewerShare
+	open     map[string][]*time.Time // merge times of the open reviews, nil while open
+	response []float64
+}
+
+func newReplay(medians map[string]float64) *replay {
+	return &replay{medians: medians, shares: map[string]*ReviewerShare{}, open: map[string][]*time.Time{}}
+}
+
+// openAt returns how many reviews userID has open at t, dropping the ones
+// merged by then.
+func (r *replay) openAt(userID string, t time.Time) int {
+	r.open[userID] = slices.DeleteFunc(r.open[userID], func(m *time.Time) bool { return m != nil && !m.After(t) })
+	return len(r.open[userID])
+}
+
+func (r *replay) add(pr HistoricalPR, reviewers []string) {
+	fastest, known := 0.0, false
+	for _, id := range reviewers {
+		sh := r.shares[id]
+		if sh == nil {
+			sh = &ReviewerShare{UserID: id}
+			r.shares[id] = sh
+		}
+		sh.Assignments++
+		r.openAt(id, pr.CreatedAt)
+		r.open[id] = append(r.open[id], pr.MergedAt)
+		sh.PeakOpen = max(sh.PeakOpen, len(r.open[id]))
+		if m, ok := r.medians[id]; ok && (!known || m < fastest) {
+			fastest, known = m, true
+		}
+	}
+	if known {
+		r.response = append(r.response, fastest)
+	}
+}
+
+func (r *replay) outcome() SimulationOutcome {
+	o := SimulationOutcome{ByReviewer: []ReviewerShare{}}
+	for _, sh := range r.shares {
+		o.Assignments += sh.Assignments
+		o.MaxAssignments = max(o.MaxAssignments, sh.Assignments)
+		o.PeakOpen = max(o.PeakOpen, sh.PeakOpen)
+		o.ByReviewer = append(o.ByReviewer, *sh)
+	}
+	o.Reviewers = len(o.ByReviewer)
+	for _, sh := range o.ByReviewer {
+		share := float64(sh.Assignments) / float64(o.Assignments)
+		o.Concentration += share * share
+	}
+	sort.Slice(o.ByReviewer, func(i, j int) bool { return o.ByReviewer[i].UserID < o.ByReviewer[j].UserID })
+	if n := len(r.response); n > 0 {
+		slices.Sort(r.response)
+		m := r.response[n/2]
+		if n%2 == 0 {
+			m = (r.response[n/2-1] + r.response[n/2]) / 2
+		}
+		o.MedianResponseSeconds = &m
+	}
+	return o
+}
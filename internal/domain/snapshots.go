@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type UserSnapshot struct {
+	UserID           string `json:"user_id"`
+	IsActive         bool   `json:"is_active"`
+	OpenAssignments  int    `json:"open_assignments"`
+	TotalAssignments int    `json:"total_assignments"`
+}
+
+// TeamSnapshot is a team's workload at the time of a snapshot. PR counts are
+// by author team, assignment counts by reviewer team.
+type TeamSnapshot struct {
+	TeamName        string         `json:"team_name"`
+	ActiveMembers   int            `json:"active_members"`
+	OpenPRs         int            `json:"open_prs"`
+	MergedPRs       int            `json:"merged_prs"`
+	OpenAssignments int            `json:"open_assignments"`
+	Users           []UserSnapshot `json:"users"`
+}
+
+// StatsSnapshot is the workload as it was at the end of Date (UTC), or at
+// TakenAt for the current day.
+type StatsSnapshot struct {
+	Date    string         `json:"date"`
+	TakenAt time.Time      `json:"taken_at"`
+	Teams   []TeamSnapshot `json:"teams"`
+}
+
+// TakeStatsSnapshot stores the current workload as today's (UTC) snapshot,
+// replacing an earlier one of the same day.
+func (s *Service) TakeStatsSnapshot(ctx context.Context) (time.Time, error) {
+	return s.repo.TakeStatsSnapshot(ctx, s.clock.Now().UTC().Truncate(24*time.Hour))
+}
+
+// StatsSnapshot returns the snapshot of day limited to teams (all when
+// empty).
+func (s *Service) StatsSnapshot(ctx context.Context, day time.Time, teams []string) (*StatsSnapshot, error) {
+	day = day.UTC().Truncate(24 * time.Hour)
+	snap, err := s.repo.GetStatsSnapshot(ctx, day, teams)
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, NewError(ErrNotFound, "no snapshot for "+day.Format(time.DateOnly))
+	}
+	return snap, nil
+}
+
+// StartStatsSnapshots takes a snapshot now and then every interval until
+// Close; the last one of a day is what history shows for it.
+func (s *Service) StartStatsSnapshots(every time.Duration) *Job {
+	return startJob(every, true, func(ctx context.Context) {
+		if _, err := s.TakeStatsSnapshot(ctx); err != nil {
+			log.Printf("stats snapshot: %v", err)
+		}
+	})
+}
@@ -0,0 +1,601 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Review states of an assigned reviewer; ReviewChangesRequested is with the
+// merge rules.
+const (
+	ReviewPending  = "PENDING"
+	ReviewApproved = "APPROVED"
+)
+
+// DurationStats summarizes a set of durations for one group (team, reviewer…).
+// Percentiles are interpolated (percentile_cont); the distributions have long
+// tails, so averages alone are misleading.
+type DurationStats struct {
+	Key           string  `json:"key"`
+	Count         int     `json:"count"`
+	AvgSeconds    float64 `json:"avg_seconds"`
+	MedianSeconds float64 `json:"median_seconds"`
+	P90Seconds    float64 `json:"p90_seconds"`
+	P99Seconds    float64 `json:"p99_seconds"`
+}
+
+type ApprovalStats struct {
+	ByTeam     []DurationStats `json:"by_team"`
+	ByReviewer []DurationStats `json:"by_reviewer"`
+}
+
+// TimeToFirstApproval measures, per PR, the time from assigning the reviewer
+// who approved first until that approval, grouped by the author's team and by
+// that reviewer. PRs without approvals are not counted.
+func (s *Service) TimeToFirstApproval(ctx context.Context, teams []string) (*ApprovalStats, error) {
+	byTeam, byReviewer, err := s.repo.StatsTimeToFirstApproval(ctx, teams)
+	if err != nil {
+		return nil, err
+	}
+	return &ApprovalStats{ByTeam: byTeam, ByReviewer: byReviewer}, nil
+}
+
+type MergeTimeStats struct {
+	Overall DurationStats   `json:"overall"`
+	ByTeam  []DurationStats `json:"by_team"`
+	// ByRepository is only filled on request; PRs without a repository are
+	// left out.
+	ByRepository []DurationStats `json:"by_repository,omitempty"`
+	// MaterializedAt is set when the figures come from the materialized
+	// aggregates.
+	MaterializedAt *time.Time `json:"materialized_at,omitempty"`
+}
+
+// MergeTime summarizes creation-to-merge durations of PRs merged in the window,
+// also per repository when byRepository is set. Unfiltered, unscoped requests
+// are served from the materialized aggregates when they are enabled.
+func (s *Service) MergeTime(ctx context.Context, teams []string, since, until *time.Time, byRepository bool) (*MergeTimeStats, error) {
+	if s.materialized.Load() && len(teams) == 0 && since == nil && until == nil && !byRepository {
+		at, err := s.repo.StatsRefreshedAt(ctx)
+		if err != nil {
+			return nil, err
+		}
+		overall, byTeam, err := s.repo.StatsMergeTimeMaterialized(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &MergeTimeStats{Overall: overall, ByTeam: byTeam, MaterializedAt: &at}, nil
+	}
+	overall, byTeam, err := s.repo.StatsMergeTime(ctx, teams, since, until)
+	if err != nil {
+		return nil, err
+	}
+	stats := &MergeTimeStats{Overall: overall, ByTeam: byTeam}
+	if byRepository {
+		if stats.ByRepository, err = s.repo.StatsMergeTimeByRepository(ctx, teams, since, until); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// SLA sets how long a PR may wait for its first approval and for merge, and
+// how long a reviewer may take to first act on an assignment.
+type SLA struct {
+	Review   time.Duration
+	Merge    time.Duration
+	Response time.Duration
+}
+
+type SLAFilter struct {
+	SLA
+	Since *time.Time
+	Until *time.Time
+	Teams []string
+	// Worst is how many offenders to list per team.
+	Worst int
+}
+
+// SLABreach is a PR that waited longer than allowed. Waits are measured from
+// PR creation to the first approval and to merge; for open PRs the wait so far
+// counts.
+type SLABreach struct {
+	PRID          string   `json:"pull_request_id"`
+	AuthorID      string   `json:"author_id"`
+	TeamName      string   `json:"team_name"`
+	Status        PRStatus `json:"status"`
+	ReviewSeconds *float64 `json:"review_seconds,omitempty"`
+	MergeSeconds  float64  `json:"merge_seconds"`
+	ReviewBreach  bool     `json:"review_breach"`
+	MergeBreach   bool     `json:"merge_breach"`
+}
+
+type TeamSLABreaches struct {
+	TeamName       string      `json:"team_name"`
+	ReviewBreaches int         `json:"review_breaches"`
+	MergeBreaches  int         `json:"merge_breaches"`
+	Worst          []SLABreach `json:"worst"`
+}
+
+type SLAReport struct {
+	ReviewSLASeconds float64           `json:"review_sla_seconds"`
+	MergeSLASeconds  float64           `json:"merge_sla_seconds"`
+	Teams            []TeamSLABreaches `json:"teams"`
+}
+
+// SLABreaches lists PRs created in the window that broke either SLA, grouped
+// by the author's team, worst (longest overdue) first.
+func (s *Service) SLABreaches(ctx context.Context, f SLAFilter) (*SLAReport, error) {
+	if f.Review <= 0 || f.Merge <= 0 {
+		return nil, NewError(ErrInvalid, "review and merge SLA must be positive")
+	}
+	if f.Worst <= 0 {
+		f.Worst = 5
+	}
+	list, err := s.repo.ListSLABreaches(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	overdue := func(b SLABreach) float64 {
+		o := b.MergeSeconds / f.Merge.Seconds()
+		if b.ReviewSeconds != nil {
+			o = max(o, *b.ReviewSeconds/f.Review.Seconds())
+		}
+		return o
+	}
+	sort.SliceStable(list, func(i, j int) bool { return overdue(list[i]) > overdue(list[j]) })
+
+	report := &SLAReport{ReviewSLASeconds: f.Review.Seconds(), MergeSLASeconds: f.Merge.Seconds(), Teams: []TeamSLABreaches{}}
+	idx := map[string]int{}
+	for _, b := range list {
+		i, ok := idx[b.TeamName]
+		if !ok {
+			i = len(report.Teams)
+			idx[b.TeamName] = i
+			report.Teams = append(report.Teams, TeamSLABreaches{TeamName: b.TeamName, Worst: []SLABreach{}})
+		}
+		t := &report.Teams[i]
+		if b.ReviewBreach {
+			t.ReviewBreaches++
+		}
+		if b.MergeBreach {
+			t.MergeBreaches++
+		}
+		if len(t.Worst) < f.Worst {
+			t.Worst = append(t.Worst, b)
+		}
+	}
+	sort.Slice(report.Teams, func(i, j int) bool { return report.Teams[i].TeamName < report.Teams[j].TeamName })
+	return report, nil
+}
+
+// PRStatusCount is one (week, team, status) cell of /stats/prStatus; Week is
+// nil unless weekly buckets were requested.
+type PRStatusCount struct {
+	Week     *time.Time
+	TeamName string
+	Status   PRStatus
+	Count    int
+}
+
+type StatusCounts map[PRStatus]int
+
+type PRStatusStats struct {
+	Overall StatusCounts            `json:"overall"`
+	ByTeam  map[string]StatusCounts `json:"by_team"`
+	Weeks   []PRStatusWeek          `json:"weeks,omitempty"`
+}
+
+type PRStatusWeek struct {
+	WeekStart string                  `json:"week_start"`
+	Overall   StatusCounts            `json:"overall"`
+	ByTeam    map[string]StatusCounts `json:"by_team"`
+}
+
+func newStatusCounts() StatusCounts {
+	return StatusCounts{StatusOPEN: 0, StatusMERGED: 0, StatusCLOSED: 0}
+}
+
+// PRStatusStats counts PRs by status per author team and overall. With
+// weekly set the counts are also split by the ISO week the PR was created in.
+func (s *Service) PRStatusStats(ctx context.Context, teams []string, weekly bool) (*PRStatusStats, error) {
+	rows, err := s.repo.StatsPRStatus(ctx, teams, weekly)
+	if err != nil {
+		return nil, err
+	}
+	out := &PRStatusStats{Overall: newStatusCounts(), ByTeam: map[string]StatusCounts{}}
+	weeks := map[string]*PRStatusWeek{}
+	add := func(overall StatusCounts, byTeam map[string]StatusCounts, c PRStatusCount) {
+		overall[c.Status] += c.Count
+		if byTeam[c.TeamName] == nil {
+			byTeam[c.TeamName] = newStatusCounts()
+		}
+		byTeam[c.TeamName][c.Status] += c.Count
+	}
+	for _, c := range rows {
+		add(out.Overall, out.ByTeam, c)
+		if c.Week == nil {
+			continue
+		}
+		key := c.Week.Format("2006-01-02")
+		w, ok := weeks[key]
+		if !ok {
+			w = &PRStatusWeek{WeekStart: key, Overall: newStatusCounts(), ByTeam: map[string]StatusCounts{}}
+			weeks[key] = w
+		}
+		add(w.Overall, w.ByTeam, c)
+	}
+	for _, w := range weeks {
+		out.Weeks = append(out.Weeks, *w)
+	}
+	sort.Slice(out.Weeks, func(i, j int) bool { return out.Weeks[i].WeekStart < out.Weeks[j].WeekStart })
+	return out, nil
+}
+
+// PRAgeBuckets are the waiting-time buckets of /stats/prAge, shortest first.
+var PRAgeBuckets = []string{"<1d", "1-3d", "3-7d", ">7d"}
+
+type PRAgeCount struct {
+	TeamName string
+	Bucket   string
+	Count    int
+}
+
+type PRAgeHistogram struct {
+	Buckets []string                  `json:"buckets"`
+	Overall map[string]int            `json:"overall"`
+	ByTeam  map[string]map[string]int `json:"by_team"`
+}
+
+func newAgeCounts() map[string]int {
+	m := make(map[string]int, len(PRAgeBuckets))
+	for _, b := range PRAgeBuckets {
+		m[b] = 0
+	}
+	return m
+}
+
+// PRAge buckets currently open PRs by how long they have been open.
+func (s *Service) PRAge(ctx context.Context, teams []string) (*PRAgeHistogram, error) {
+	rows, err := s.repo.StatsOpenPRAge(ctx, teams)
+	if err != nil {
+		return nil, err
+	}
+	out := &PRAgeHistogram{Buckets: PRAgeBuckets, Overall: newAgeCounts(), ByTeam: map[string]map[string]int{}}
+	for _, c := range rows {
+		out.Overall[c.Bucket] += c.Count
+		if out.ByTeam[c.TeamName] == nil {
+			out.ByTeam[c.TeamName] = newAgeCounts()
+		}
+		out.ByTeam[c.TeamName][c.Bucket] += c.Count
+	}
+	return out, nil
+}
+
+// DayCount is one point of the assignment trend series.
+type DayCount struct {
+	Date        string `json:"date"`
+	Assignments int    `json:"assignments"`
+	Merges      int    `json:"merges"`
+}
+
+const maxSeriesDays = 366
+
+// AssignmentTimeseries returns per-day (UTC) assignment and merge counts for
+// every day in [from, to], including days without activity.
+func (s *Service) AssignmentTimeseries(ctx context.Context, teams []string, from, to time.Time) ([]DayCount, error) {
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.StatsDailyActivity(ctx, teams, from, to)
+}
+
+// seriesRange truncates a day range to UTC dates and checks its bounds.
+func seriesRange(from, to time.Time) (time.Time, time.Time, error) {
+	from, to = from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour)
+	if to.Before(from) {
+		return from, to, NewError(ErrInvalid, "to must not be before from")
+	}
+	if to.Sub(from) > maxSeriesDays*24*time.Hour {
+		return from, to, NewError(ErrInvalid, "range is limited to 366 days")
+	}
+	return from, to, nil
+}
+
+// TeamDayCount is a per-team count for one UTC date (YYYY-MM-DD).
+type TeamDayCount struct {
+	Date     string
+	TeamName string
+	Count    int
+}
+
+// BurndownDay is how many PRs were still open at the end of a UTC day.
+type BurndownDay struct {
+	Date   string         `json:"date"`
+	Open   int            `json:"open"`
+	ByTeam map[string]int `json:"by_team"`
+}
+
+// OpenPRBurndown returns, for every day in [from, to], the number of PRs
+// open at the end of that day overall and per author team.
+func (s *Service) OpenPRBurndown(ctx context.Context, teams []string, from, to time.Time) ([]BurndownDay, error) {
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.StatsOpenPRsByDay(ctx, teams, from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := []BurndownDay{}
+	idx := map[string]int{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		idx[d.Format(time.DateOnly)] = len(out)
+		out = append(out, BurndownDay{Date: d.Format(time.DateOnly), ByTeam: map[string]int{}})
+	}
+	for _, c := range counts {
+		if i, ok := idx[c.Date]; ok {
+			out[i].ByTeam[c.TeamName] = c.Count
+			out[i].Open += c.Count
+		}
+	}
+	return out, nil
+}
+
+// ReviewerResponsiveness measures how quickly a reviewer first acts on an
+// assignment (acknowledge, approve or decline). Durations cover assignments
+// with an action. Compliance is the share of acted-on assignments answered
+// within the response SLA among those acted on or already overdue; it is
+// null when there is nothing to judge yet.
+type ReviewerResponsiveness struct {
+	UserID        string   `json:"user_id"`
+	Responded     int      `json:"responded"`
+	Pending       int      `json:"pending"`
+	AvgSeconds    float64  `json:"avg_seconds"`
+	MedianSeconds float64  `json:"median_seconds"`
+	P90Seconds    float64  `json:"p90_seconds"`
+	WithinSLA     int      `json:"within_sla"`
+	Overdue       int      `json:"overdue"`
+	Compliance    *float64 `json:"compliance"`
+}
+
+type ResponsivenessReport struct {
+	ResponseSLASeconds float64                  `json:"response_sla_seconds"`
+	Reviewers          []ReviewerResponsiveness `json:"reviewers"`
+}
+
+// ReviewerResponsiveness reports per-reviewer response times for reviewers
+// from teams (all when empty).
+func (s *Service) ReviewerResponsiveness(ctx context.Context, teams []string, sla time.Duration) (*ResponsivenessReport, error) {
+	if sla <= 0 {
+		return nil, NewError(ErrInvalid, "response SLA must be positive")
+	}
+	list, err := s.repo.StatsReviewerResponsiveness(ctx, teams, sla)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if judged := list[i].WithinSLA + list[i].Overdue; judged > 0 {
+			c := float64(list[i].WithinSLA) / float64(judged)
+			list[i].Compliance = &c
+		}
+	}
+	return &ResponsivenessReport{ResponseSLASeconds: sla.Seconds(), Reviewers: list}, nil
+}
+
+// IdleReviewer is an active user who got no review assignments in the
+// report window. LastAssignedAt is nil if they were never assigned.
+type IdleReviewer struct {
+	TeamName       string     `json:"-"`
+	UserID         string     `json:"user_id"`
+	Username       string     `json:"username"`
+	LastAssignedAt *time.Time `json:"last_assigned_at"`
+}
+
+type TeamIdleReviewers struct {
+	TeamName string         `json:"team_name"`
+	Users    []IdleReviewer `json:"users"`
+}
+
+type IdleReviewersReport struct {
+	Days  int                 `json:"days"`
+	Teams []TeamIdleReviewers `json:"teams"`
+}
+
+// IdleReviewers lists active users of teams (all when empty) without
+// assignments in the last days days, grouped by team.
+func (s *Service) IdleReviewers(ctx context.Context, teams []string, days int) (*IdleReviewersReport, error) {
+	if days <= 0 || days > 366 {
+		return nil, NewError(ErrInvalid, "days must be 1..366")
+	}
+	list, err := s.repo.ListIdleReviewers(ctx, teams, s.clock.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+	report := &IdleReviewersReport{Days: days, Teams: []TeamIdleReviewers{}}
+	for _, u := range list {
+		n := len(report.Teams)
+		if n == 0 || report.Teams[n-1].TeamName != u.TeamName {
+			report.Teams = append(report.Teams, TeamIdleReviewers{TeamName: u.TeamName})
+			n++
+		}
+		report.Teams[n-1].Users = append(report.Teams[n-1].Users, u)
+	}
+	return report, nil
+}
+
+// TeamComparison is one row of the cross-team report. PR figures are by the
+// author's team; AssignmentConcentration is the Herfindahl index of review
+// assignments over the team's reviewers: 1 when one person gets all of them,
+// 1/n when n reviewers share them evenly, 0 without assignments.
+type TeamComparison struct {
+	TeamName                string  `json:"team_name"`
+	Members                 int     `json:"members"`
+	OpenPRs                 int     `json:"open_prs"`
+	AvgReviewersPerPR       float64 `json:"avg_reviewers_per_pr"`
+	MedianMergeSeconds      float64 `json:"median_merge_seconds"`
+	AssignmentConcentration float64 `json:"assignment_concentration"`
+}
+
+func (s *Service) TeamComparison(ctx context.Context, teams []string) ([]TeamComparison, error) {
+	return s.repo.StatsTeamComparison(ctx, teams)
+}
+
+// Reassignment is one reviewer replaced on or removed from a PR.
+type Reassignment struct {
+	PRID     string
+	UserID   string
+	TeamName string
+	Reason   string
+}
+
+type UserChurn struct {
+	UserID        string         `json:"user_id"`
+	TeamName      string         `json:"team_name"`
+	Reassignments int            `json:"reassignments"`
+	ByReason      map[string]int `json:"by_reason"`
+}
+
+type PRChurn struct {
+	PRID          string `json:"pull_request_id"`
+	Reassignments int    `json:"reassignments"`
+}
+
+// ChurnReport covers PRs created in [From, To]: how many of them had a
+// reviewer replaced or removed, why, and whose assignments were moved most.
+// ChurnRate is the share of PRs with at least one reassignment, null without
+// PRs.
+type ChurnReport struct {
+	From          string         `json:"from"`
+	To            string         `json:"to"`
+	PRs           int            `json:"prs"`
+	ReassignedPRs int            `json:"reassigned_prs"`
+	Reassignments int            `json:"reassignments"`
+	ChurnRate     *float64       `json:"churn_rate"`
+	ByReason      map[string]int `json:"by_reason"`
+	ByUser        []UserChurn    `json:"by_user"`
+	TopPRs        []PRChurn      `json:"top_prs"`
+}
+
+const churnTopPRs = 10
+
+// ReassignmentChurn reports reassignments of PRs created in [from, to] by
+// authors of the given teams (all when empty).
+func (s *Service) ReassignmentChurn(ctx context.Context, teams []string, from, to time.Time) (*ChurnReport, error) {
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	prs, moves, err := s.repo.StatsReassignments(ctx, teams, from, to)
+	if err != nil {
+		return nil, err
+	}
+	rep := &ChurnReport{
+		From: from.Format(time.DateOnly), To: to.Format(time.DateOnly),
+		PRs: prs, Reassignments: len(moves),
+		ByReason: map[string]int{}, ByUser: []UserChurn{}, TopPRs: []PRChurn{},
+	}
+	users := map[string]*UserChurn{}
+	perPR := map[string]int{}
+	for _, m := range moves {
+		rep.ByReason[m.Reason]++
+		perPR[m.PRID]++
+		u := users[m.UserID]
+		if u == nil {
+			u = &UserChurn{UserID: m.UserID, TeamName: m.TeamName, ByReason: map[string]int{}}
+			users[m.UserID] = u
+		}
+		u.Reassignments++
+		u.ByReason[m.Reason]++
+	}
+	for _, u := range users {
+		rep.ByUser = append(rep.ByUser, *u)
+	}
+	sort.Slice(rep.ByUser, func(i, j int) bool {
+		a, b := rep.ByUser[i], rep.ByUser[j]
+		if a.Reassignments != b.Reassignments {
+			return a.Reassignments > b.Reassignments
+		}
+		return a.UserID < b.UserID
+	})
+	for id, n := range perPR {
+		rep.TopPRs = append(rep.TopPRs, PRChurn{PRID: id, Reassignments: n})
+	}
+	sort.Slice(rep.TopPRs, func(i, j int) bool {
+		a, b := rep.TopPRs[i], rep.TopPRs[j]
+		if a.Reassignments != b.Reassignments {
+			return a.Reassignments > b.Reassignments
+		}
+		return a.PRID < b.PRID
+	})
+	rep.ReassignedPRs = len(rep.TopPRs)
+	if len(rep.TopPRs) > churnTopPRs {
+		rep.TopPRs = rep.TopPRs[:churnTopPRs]
+	}
+	if prs > 0 {
+		rate := float64(rep.ReassignedPRs) / float64(prs)
+		rep.ChurnRate = &rate
+	}
+	return rep, nil
+}
+
+// AuthorMergeRate compares PRs an author opened in the report period with how
+// many of them got merged. Open and LongOpen count the author's PRs open now,
+// LongOpen those open longer than the merge SLA. MergeRate is null without
+// PRs in the period.
+type AuthorMergeRate struct {
+	UserID               string   `json:"user_id"`
+	TeamName             string   `json:"team_name"`
+	Created              int      `json:"created"`
+	Merged               int      `json:"merged"`
+	MergeRate            *float64 `json:"merge_rate"`
+	Open                 int      `json:"open"`
+	LongOpen             int      `json:"long_open"`
+	OldestOpenAgeSeconds float64  `json:"oldest_open_age_seconds"`
+}
+
+type AuthorMergeReport struct {
+	From            string            `json:"from"`
+	To              string            `json:"to"`
+	MergeSLASeconds float64           `json:"merge_sla_seconds"`
+	Authors         []AuthorMergeRate `json:"authors"`
+}
+
+// AuthorMergeRates reports, per author of the given teams (all when empty),
+// PRs created in [from, to] vs merged, and their PRs open now. Authors with
+// the most long-open PRs come first.
+func (s *Service) AuthorMergeRates(ctx context.Context, teams []string, from, to time.Time, mergeSLA time.Duration) (*AuthorMergeReport, error) {
+	if mergeSLA <= 0 {
+		return nil, NewError(ErrInvalid, "merge SLA must be positive")
+	}
+	from, to, err := seriesRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.repo.StatsAuthorMergeRates(ctx, teams, from, to, mergeSLA)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Created > 0 {
+			rate := float64(list[i].Merged) / float64(list[i].Created)
+			list[i].MergeRate = &rate
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.LongOpen != b.LongOpen {
+			return a.LongOpen > b.LongOpen
+		}
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		return a.UserID < b.UserID
+	})
+	return &AuthorMergeReport{
+		From: from.Format(time.DateOnly), To: to.Format(time.DateOnly),
+		MergeSLASeconds: mergeSLA.Seconds(), Authors: list,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const TokenPrefix = "prt_"
+
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IssueToken creates a token for userID. A non-empty teams list restricts
+// the token to data of those teams.
+func (s *Service) IssueToken(ctx context.Context, userID, role, name string, teams []string, ttl time.Duration) (*IssuedToken, error) {
+	if _, err := s.repo.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	exists, err := s.repo.RoleExists(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "role not found")
+	}
+	var out *IssuedToken
+	err = s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		for _, team := range teams {
+			exists, err := s.repo.TeamExists(ctx, tx, team)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return NewError(ErrNotFound, "team "+team+" not found")
+			}
+		}
+		var err error
+		out, err = s.issueToken(ctx, tx, APIToken{UserID: userID, Role: role, Name: name, Teams: teams}, ttl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RotateToken issues a replacement for tokenID with the same owner, role,
+// name and team scope, and shortens the old token's lifetime to grace so clients can switch over.
+func (s *Service) RotateToken(ctx context.Context, tokenID string, ttl, grace time.Duration) (*IssuedToken, *APIToken, error) {
+	var fresh *IssuedToken
+	var old *APIToken
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		cur, err := s.repo.GetAPIToken(ctx, tokenID)
+		if err != nil {
+			return err
+		}
+		if !cur.Valid(s.clock.Now()) {
+			return NewError(ErrNotFound, "token is revoked or expired")
+		}
+		fresh, err = s.issueToken(ctx, tx, APIToken{UserID: cur.UserID, Role: cur.Role, Name: cur.Name, Teams: cur.Teams}, ttl)
+		if err != nil {
+			return err
+		}
+		exp := s.clock.Now().Add(grace).UTC()
+		if cur.ExpiresAt != nil && cur.ExpiresAt.Before(exp) {
+			exp = *cur.ExpiresAt
+		}
+		old, err = s.repo.SetAPITokenExpiry(ctx, tx, tokenID, exp)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return fresh, old, nil
+}
+
+func (s *Service) issueToken(ctx context.Context, tx *sql.Tx, t APIToken, ttl time.Duration) (*IssuedToken, error) {
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	idPart := make([]byte, 8)
+	if _, err := rand.Read(idPart); err != nil {
+		return nil, err
+	}
+	plain := TokenPrefix + secret
+	t.ID = "tok_" + hex.EncodeToString(idPart)
+	t.Hash = HashToken(plain)
+	if ttl > 0 {
+		exp := s.clock.Now().Add(ttl).UTC()
+		t.ExpiresAt = &exp
+	}
+	created, err := s.repo.CreateAPIToken(ctx, tx, t)
+	if err != nil {
+		return nil, err
+	}
+	return &IssuedToken{APIToken: *created, Token: plain}, nil
+}
+
+func (s *Service) RevokeToken(ctx context.Context, tokenID string) (*APIToken, error) {
+	return s.repo.RevokeAPIToken(ctx, tokenID)
+}
+
+func (s *Service) ListTokens(ctx context.Context, userID string) ([]APIToken, error) {
+	return s.repo.ListAPITokens(ctx, userID)
+}
+
+func (s *Service) LookupToken(ctx context.Context, plain string) (*APIToken, error) {
+	return s.repo.GetAPITokenByHash(ctx, HashToken(plain))
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TokenUsage aggregates the requests made with one credential. Tokens issued
+// but never used are listed with zero counts and no timestamps.
+type TokenUsage struct {
+	TokenID     string     `json:"token_id"`
+	Method      string     `json:"method,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	Role        string     `json:"role,omitempty"`
+	Requests    int64      `json:"requests"`
+	Errors      int64      `json:"errors"`
+	ErrorRate   float64    `json:"error_rate"`
+	FirstUsedAt *time.Time `json:"first_used_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	LastStatus  int        `json:"last_status,omitempty"`
+}
+
+func (s *Service) RecordTokenUsage(ctx context.Context, usage []TokenUsage) error {
+	return s.repo.AddTokenUsage(ctx, usage)
+}
+
+// ListTokenUsage returns usage per credential, least recently used first.
+// With idleSince only credentials unused since then are returned.
+func (s *Service) ListTokenUsage(ctx context.Context, idleSince *time.Time) ([]TokenUsage, error) {
+	list, err := s.repo.ListTokenUsage(ctx, idleSince)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Requests > 0 {
+			list[i].ErrorRate = float64(list[i].Errors) / float64(list[i].Requests)
+		}
+	}
+	return list, nil
+}
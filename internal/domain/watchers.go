@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"slices"
+)
+
+// watchedEvents are the PR events watchers are notified of.
+var watchedEvents = map[string]bool{
+	PREventAssigned:      true,
+	PREventReplaced:      true,
+	PREventRemoved:       true,
+	PREventAuthorChanged: true,
+	PREventMerged:        true,
+	PREventClosed:        true,
+	PREventReopened:      true,
+}
+
+// WatchNotification tells the watchers of a PR about one of its events.
+type WatchNotification struct {
+	PRID     string   `json:"pull_request_id"`
+	Event    PREvent  `json:"pr_event"`
+	Watchers []string `json:"watchers"`
+}
+
+// WithWatchNotifier makes the service hand each reviewer change and merge
+// of a watched PR to notify, after it is committed.
+func (s *Service) WithWatchNotifier(notify func(WatchNotification)) *Service {
+	s.watchNotify = notify
+	return s
+}
+
+// WatchPR subscribes userID to the events of a PR they do not review.
+// Watching twice changes nothing.
+func (s *Service) WatchPR(ctx context.Context, prID, userID string) (*PullRequest, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		pr, err := s.repo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if pr.Status == StatusMERGED {
+			return NewError(ErrPRMerged, "cannot watch a merged PR")
+		}
+		if _, err := s.repo.GetUser(ctx, userID); err != nil {
+			return err
+		}
+		assigned, err := s.repo.GetAssignedReviewers(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(assigned, userID) {
+			return NewError(ErrInvalid, "user reviews this PR")
+		}
+		return s.repo.AddPRWatcher(ctx, tx, prID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPR(ctx, prID)
+}
+
+// UnwatchPR drops the subscription of userID, if any.
+func (s *Service) UnwatchPR(ctx context.Context, prID, userID string) (*PullRequest, error) {
+	err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.repo.GetPR(ctx, prID); err != nil {
+			return err
+		}
+		return s.repo.DeletePRWatcher(ctx, tx, prID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPR(ctx, prID)
+}
+
+// notifyWatchers hands the watched events among events to the notifier,
+// one notification per event of a PR with watchers.
+func (s *Service) notifyWatchers(ctx context.Context, events []PREvent) {
+	if s.watchNotify == nil {
+		return
+	}
+	watchers := map[string][]string{}
+	for _, e := range events {
+		if !watchedEvents[e.Kind] {
+			continue
+		}
+		ids, ok := watchers[e.PRID]
+		if !ok {
+			var err error
+			if ids, err = s.repo.ListPRWatchers(ctx, e.PRID); err != nil {
+				log.Printf("watchers of %s: %v", e.PRID, err)
+				continue
+			}
+			watchers[e.PRID] = ids
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if e.At.IsZero() {
+			e.At = s.clock.Now().UTC()
+		}
+		s.watchNotify(WatchNotification{PRID: e.PRID, Event: e, Watchers: ids})
+	}
+}
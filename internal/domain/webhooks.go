@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Events of outgoing webhooks.
+const (
+	WebhookReviewerAssigned = "reviewer_assigned"
+	WebhookReviewerReplaced = "reviewer_replaced"
+	WebhookPRMerged         = "pr_merged"
+)
+
+// WebhookEvents are the events a webhook can subscribe to.
+var WebhookEvents = []string{WebhookReviewerAssigned, WebhookReviewerReplaced, WebhookPRMerged}
+
+// webhookEvents names the PR events sent to webhooks.
+var webhookEvents = map[string]string{
+	PREventAssigned: WebhookReviewerAssigned,
+	PREventReplaced: WebhookReviewerReplaced,
+	PREventMerged:   WebhookPRMerged,
+}
+
+// Webhook is an outgoing webhook: the events it subscribes to are POSTed to
+// URL, signed with Secret.
+type Webhook struct {
+	ID        string    `json:"webhook_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddedWebhook is a new webhook with its signing secret, which is shown
+// only once.
+type AddedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookPayload is the body POSTed to a webhook. DeliveryID stays the same
+// across retries, so receivers can drop duplicates.
+type WebhookPayload struct {
+	DeliveryID string  `json:"delivery_id"`
+	Event      string  `json:"event"`
+	PRID       string  `json:"pull_request_id"`
+	PREvent    PREvent `json:"pr_event"`
+}
+
+// WebhookDelivery is a payload on its way to a webhook.
+type WebhookDelivery struct {
+	Webhook Webhook
+	Payload WebhookPayload
+}
+
+// WithWebhookDispatcher makes the service hand each delivery to an outgoing
+// webhook to dispatch, after the change is committed. dispatch must not
+// block.
+func (s *Service) WithWebhookDispatcher(dispatch func(WebhookDelivery)) *Service {
+	s.webhookDispatch = dispatch
+	return s
+}
+
+// AddWebhook registers an http(s) URL for events, every event when empty.
+func (s *Service) AddWebhook(ctx context.Context, rawURL string, events []string) (*AddedWebhook, error) {
+	if u, err := url.Parse(rawURL); err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return nil, NewError(ErrInvalid, "url must be an http(s) url")
+	}
+	if len(events) == 0 {
+		events = slices.Clone(WebhookEvents)
+	}
+	for _, e := range events {
+		if !slices.Contains(WebhookEvents, e) {
+			return nil, NewError(ErrInvalid, "unknown event "+e)
+		}
+	}
+	slices.Sort(events)
+	events = slices.Compact(events)
+	id, err := randomString(12)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	w := Webhook{ID: "wh_" + id, URL: rawURL, Events: events, Secret: secret, CreatedAt: s.clock.Now().UTC()}
+	if err := s.repo.CreateWebhook(ctx, w); err != nil {
+		return nil, err
+	}
+	return &AddedWebhook{Webhook: w, Secret: secret}, nil
+}
+
+func (s *Service) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	return s.repo.ListWebhooks(ctx)
+}
+
+// RemoveWebhook unregisters a webhook; deliveries already queued are still
+// attempted.
+func (s *Service) RemoveWebhook(ctx context.Context, id string) error {
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+// dispatchWebhooks hands the events among events to the dispatcher, once
+// for each webhook subscribed to them.
+func (s *Service) dispatchWebhooks(ctx context.Context, events []PREvent) {
+	if s.webhookDispatch == nil {
+		return
+	}
+	var hooks []Webhook
+	loaded := false
+	for _, e := range events {
+		name, ok := webhookEvents[e.Kind]
+		if !ok {
+			continue
+		}
+		if !loaded {
+			var err error
+			if hooks, err = s.repo.ListWebhooks(ctx); err != nil {
+				log.Printf("webhooks: %v", err)
+				return
+			}
+			loaded = true
+		}
+		if e.At.IsZero() {
+			e.At = s.clock.Now().UTC()
+		}
+		for _, w := range hooks {
+			if !slices.Contains(w.Events, name) {
+				continue
+			}
+			id, err := NewUUIDv7(s.clock.Now())
+			if err != nil {
+				log.Printf("webhook %s: %v", w.ID, err)
+				continue
+			}
+			s.webhookDispatch(WebhookDelivery{Webhook: w, Payload: WebhookPayload{DeliveryID: id, Event: name, PRID: e.PRID, PREvent: e}})
+		}
+	}
+}
+
+// publish tells watchers and webhooks about events once they are committed.
+func (s *Service) publish(ctx context.Context, events []PREvent) {
+	s.notifyWatchers(ctx, events)
+	s.dispatchWebhooks(ctx, events)
+}
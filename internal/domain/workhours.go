@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+	_ "time/tzdata" // the alpine image has no zoneinfo
+)
+
+// WorkingHours is the working window of a team's reviewers: StartHour to
+// EndHour, Monday to Friday, in TimeZone (an IANA name such as
+// Europe/Moscow). The zero value means no window: assignments are delivered
+// at once.
+type WorkingHours struct {
+	TimeZone  string `json:"time_zone,omitempty"`
+	StartHour int    `json:"start_hour,omitempty"`
+	EndHour   int    `json:"end_hour,omitempty"`
+}
+
+func (w WorkingHours) IsZero() bool { return w == WorkingHours{} }
+
+func (w WorkingHours) validate() error {
+	if _, err := time.LoadLocation(w.TimeZone); err != nil || w.TimeZone == "" {
+		return NewError(ErrInvalid, "time_zone must be an IANA time zone, e.g. Europe/Moscow")
+	}
+	if w.StartHour < 0 || w.EndHour > 24 || w.StartHour >= w.EndHour {
+		return NewError(ErrInvalid, "working hours must satisfy 0 <= start_hour < end_hour <= 24")
+	}
+	return nil
+}
+
+// NextStart returns t when it falls within the working window, otherwise
+// the start of the next one; holidays are skipped. Zero hours are a window
+// of every whole day.
+func (w WorkingHours) NextStart(t time.Time, holidays []Holiday) time.Time {
+	loc, from, to, weekends := w.Location(), w.StartHour, w.EndHour, true
+	if w.IsZero() {
+		to, weekends = 24, false
+	}
+	off := map[string]bool{}
+	for _, h := range holidays {
+		off[h.Date] = true
+	}
+	local := t.In(loc)
+	for day := 0; day <= 7+len(holidays); day++ {
+		d := local.AddDate(0, 0, day)
+		if wd := d.Weekday(); weekends && (wd == time.Saturday || wd == time.Sunday) || off[d.Format(time.DateOnly)] {
+			continue
+		}
+		start := time.Date(d.Year(), d.Month(), d.Day(), from, 0, 0, 0, loc)
+		end := time.Date(d.Year(), d.Month(), d.Day(), to, 0, 0, 0, loc)
+		switch {
+		case t.Before(start):
+			return start.UTC()
+		case t.Before(end):
+			return t
+		}
+	}
+	return t
+}
+
+// DeferredReview is an assignment queued until its reviewer's next workday.
+type DeferredReview struct {
+	PRID      string    `json:"pull_request_id"`
+	UserID    string    `json:"user_id"`
+	DeliverAt time.Time `json:"deliver_at"`
+}
+
+// SetTeamWorkingHours replaces the working hours of the team; zero hours
+// turn deferred delivery off.
+func (s *Service) SetTeamWorkingHours(ctx context.Context, team string, w WorkingHours) error {
+	if !w.IsZero() {
+		if err := w.validate(); err != nil {
+			return err
+		}
+	}
+	return s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		exists, err := s.repo.TeamExists(ctx, tx, team)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NewError(ErrNotFound, "team not found")
+		}
+		return s.repo.SetTeamWorkingHours(ctx, tx, team, w)
+	})
+}
+
+// deferOutsideHours queues the new assignments of userIDs to prID whose
+// reviewers are outside the working hours of their team or on its holiday.
+// A queued assignment stays on the PR but is left out of the reviewer's
+// open reviews until DeliverAt.
+func (s *Service) deferOutsideHours(ctx context.Context, tx *sql.Tx, prID string, userIDs ...string) error {
+	now := s.clock.Now()
+	for _, id := range userIDs {
+		u, err := s.repo.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		w, err := s.repo.TeamWorkingHours(ctx, u.TeamName)
+		if err != nil {
+			return err
+		}
+		holidays, err := s.repo.ListTeamHolidays(ctx, u.TeamName)
+		if err != nil {
+			return err
+		}
+		if w.IsZero() && len(holidays) == 0 {
+			continue
+		}
+		if at := w.NextStart(now, holidays); at.After(now) {
+			if err := s.repo.DeferReview(ctx, tx, prID, id, at); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeliverDeferredReviews marks the queued assignments that are due as
+// delivered, with a "delivered" PR event, and returns them so reviewers can
+// be notified.
+func (s *Service) DeliverDeferredReviews(ctx context.Context) ([]DeferredReview, error) {
+	due, err := s.repo.ListDueDeferredReviews(ctx, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	var out []DeferredReview
+	for _, d := range due {
+		err := s.repo.WithTx(ctx, func(tx *sql.Tx) error {
+			if err := s.repo.ClearDeferredReview(ctx, tx, d.PRID, d.UserID); err != nil {
+				return err
+			}
+			return s.repo.AddPREvents(ctx, tx, []PREvent{{PRID: d.PRID, Kind: PREventDelivered, UserID: d.UserID, At: d.DeliverAt}})
+		})
+		if err != nil {
+			return out, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// StartDeferredDelivery delivers due queued assignments every interval until
+// Close and hands each to notify.
+func (s *Service) StartDeferredDelivery(every time.Duration, notify func(DeferredReview)) *Job {
+	return startJob(every, true, func(ctx context.Context) {
+		delivered, err := s.DeliverDeferredReviews(ctx)
+		if err != nil {
+			log.Printf("deferred delivery: %v", err)
+		}
+		for _, d := range delivered {
+			if notify != nil {
+				notify(d)
+			}
+		}
+	})
+}
@@ -0,0 +1,515 @@
+package http
+
+import (
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// apiDocs documents every path Register mounts; /openapi.json is built from
+// it, so a new route needs an entry here too.
+var apiDocs = map[string]apiDoc{
+	"/health": {Tag: "Health", Summary: "Liveness check", Response: struct {
+		Status string `json:"status"`
+	}{}},
+	"/openapi.json": {Tag: "Health", Summary: "This OpenAPI document", Response: map[string]any{}},
+	"/docs":         {Tag: "Health", Summary: "Swagger UI for this API", Produces: "text/html"},
+
+	"/team/add": {Tag: "Teams", Summary: "Create a team with its members", Status: 201,
+		Body: domain.Team{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+	"/team/upsert": {Tag: "Teams", Summary: "Merge members into a team, creating it with 201 if need be", AlsoStatus: 201,
+		Body: domain.Team{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+	"/team/get": {Tag: "Teams", Summary: "Get a team with a page of its members",
+		Query: pageParams("user_id or username", apiParam{Name: "team_name", Required: true}), Response: struct {
+			domain.Team
+			Page domain.PageInfo `json:"page"`
+		}{}},
+	"/team/setManualAssignment": {Tag: "Teams", Summary: "Turn automatic reviewer selection of a team off or back on",
+		Body: struct {
+			TeamName         string `json:"team_name"`
+			ManualAssignment bool   `json:"manual_assignment"`
+		}{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+	"/team/setMergeRules": {Tag: "Teams", Summary: "Replace the preconditions for merging PRs of a team; zero values turn a rule off",
+		Body: struct {
+			TeamName string `json:"team_name"`
+			domain.MergeRules
+		}{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+	"/team/setEscalation": {Tag: "Teams", Summary: "Replace the escalation policy for open PRs of a team no reviewer has acted on; zero turns a step off",
+		Body: struct {
+			TeamName string `json:"team_name"`
+			domain.EscalationPolicy
+		}{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+	"/team/setWorkingHours": {Tag: "Teams", Summary: "Set the working hours of a team; assignments outside them are queued until the next workday",
+		Body: struct {
+			TeamName string `json:"team_name"`
+			domain.WorkingHours
+		}{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+	"/team/setHolidays": {Tag: "Teams", Summary: "Replace the holiday calendar of a team; holidays queue assignments and do not count towards SLA waits and escalations",
+		Body: struct {
+			TeamName string           `json:"team_name"`
+			Holidays []domain.Holiday `json:"holidays"`
+		}{}, Response: teamHolidays{}},
+	"/team/holidays": {Tag: "Teams", Summary: "Get the holiday calendar of a team",
+		Query: []apiParam{{Name: "team_name", Required: true}}, Response: teamHolidays{}},
+	"/team/setLead": {Tag: "Teams", Summary: "Set the team lead who receives overload alerts; an empty user_id clears it",
+		Body: struct {
+			TeamName string `json:"team_name"`
+			UserID   string `json:"user_id"`
+		}{}, Response: struct {
+			Team *domain.Team `json:"team"`
+		}{}},
+
+	"/users/setIsActive": {Tag: "Users", Summary: "Activate or deactivate a user",
+		Body: struct {
+			UserID   string `json:"user_id"`
+			IsActive bool   `json:"is_active"`
+		}{}, Response: struct {
+			User *domain.User `json:"user"`
+		}{}},
+	"/users/getReview": {Tag: "Users", Summary: "PRs the user is assigned to review",
+		Query: pageParams(prSorts, apiParam{Name: "user_id", Description: "defaults to the caller; required for credentials without a user"}), Response: struct {
+			UserID string                    `json:"user_id"`
+			PRs    []domain.PullRequestShort `json:"pull_requests"`
+			Page   domain.PageInfo           `json:"page"`
+		}{}},
+	"/users/getReviewBatch": {Tag: "Users", Summary: "Open PRs each of several users is assigned to review, in one request",
+		Query: []apiParam{{Name: "user_ids", Required: true, Description: "comma-separated, up to 500"}}, Response: struct {
+			Reviews map[string][]domain.PullRequestShort `json:"reviews"`
+		}{}},
+	"/users/pollAssignments": {Tag: "Users", Summary: "Wait until the user's open review assignments change (long polling)",
+		Query: []apiParam{
+			{Name: "user_id", Description: "defaults to the caller"},
+			{Name: "cursor", Description: "cursor of the last response; without it the call returns at once"},
+			{Name: "timeout", Type: "integer", Description: "seconds to wait, 0 to 60, default 25"},
+		}, Response: struct {
+			UserID  string                    `json:"user_id"`
+			Changed bool                      `json:"changed"`
+			Cursor  string                    `json:"cursor"`
+			PRs     []domain.PullRequestShort `json:"pull_requests"`
+		}{}},
+	"/users/update": {Tag: "Users", Summary: "Change profile fields of a user; fields left out keep their value",
+		Body: struct {
+			UserID string `json:"user_id"`
+			domain.UserUpdate
+		}{}, Response: struct {
+			User *domain.User `json:"user"`
+		}{}},
+	"/users/bulkDeactivate": {Tag: "Users", Summary: "Deactivate team members and reassign their open reviews; dry_run only reports what would change",
+		Body: struct {
+			TeamName string   `json:"team_name"`
+			UserIDs  []string `json:"user_ids"`
+			DryRun   bool     `json:"dry_run,omitempty"`
+		}{}, Response: domain.BulkDeactivateResult{}},
+	"/users/anonymize": {Tag: "Users", Summary: "Erase a user's personal data and revoke their tokens",
+		Body: struct {
+			UserID string `json:"user_id"`
+		}{}, Response: struct {
+			User    *domain.User `json:"user"`
+			Revoked []string     `json:"revoked_tokens"`
+		}{}},
+
+	"/pullRequest/create": {Tag: "PullRequests", Summary: "Create a PR and assign up to two reviewers, valid preferred_reviewers first; a UUIDv7 id is generated when pull_request_id is omitted", Status: 201,
+		Body: struct {
+			ID         string   `json:"pull_request_id,omitempty"`
+			Name       string   `json:"pull_request_name"`
+			AuthorID   string   `json:"author_id"`
+			Repository string   `json:"repository,omitempty"`
+			Preferred  []string `json:"preferred_reviewers,omitempty"`
+		}{}, Response: struct {
+			PR       *domain.PullRequest       `json:"pr"`
+			Rejected []domain.RejectedReviewer `json:"rejected_reviewers,omitempty"`
+			Capacity *domain.CapacityWarning   `json:"capacity_warning,omitempty"`
+		}{}},
+	"/pullRequest/get": {Tag: "PullRequests", Summary: "Get a PR with its reviewers; the ETag header goes into If-Match of merge and reassign",
+		Query: []apiParam{{Name: "pull_request_id", Required: true}, ifNoneMatch}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/merge": {Tag: "PullRequests", Summary: "Merge a PR (idempotent); 409 MERGE_BLOCKED names the team merge rule it breaks",
+		Query: []apiParam{ifMatch},
+		Body: struct {
+			PRID string `json:"pull_request_id"`
+		}{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/close": {Tag: "PullRequests", Summary: "Close an open PR without merging (idempotent); its reviewers leave the open load",
+		Query: []apiParam{ifMatch},
+		Body: struct {
+			PRID string `json:"pull_request_id"`
+		}{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/reopen": {Tag: "PullRequests", Summary: "Reopen a closed PR (idempotent); reviewers deactivated meanwhile are replaced or removed",
+		Query: []apiParam{ifMatch},
+		Body: struct {
+			PRID string `json:"pull_request_id"`
+		}{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/reassign": {Tag: "PullRequests", Summary: "Replace a reviewer with another active member of their team",
+		Query: []apiParam{ifMatch},
+		Body: struct {
+			PRID      string `json:"pull_request_id"`
+			OldUserID string `json:"old_user_id"`
+		}{}, Response: struct {
+			PR         *domain.PullRequest `json:"pr"`
+			ReplacedBy string              `json:"replaced_by"`
+		}{}},
+	"/pullRequest/addReviewer": {Tag: "PullRequests", Summary: "Attach a reviewer by hand, e.g. on teams with manual assignment",
+		Query: []apiParam{ifMatch},
+		Body: struct {
+			PRID   string `json:"pull_request_id"`
+			UserID string `json:"user_id"`
+		}{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/transferAuthor": {Tag: "PullRequests", Summary: "Hand an open PR over to a new author, replacing them as a reviewer if needed",
+		Query: []apiParam{ifMatch},
+		Body: struct {
+			PRID     string `json:"pull_request_id"`
+			AuthorID string `json:"author_id"`
+		}{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/addDependency": {Tag: "PullRequests", Summary: "Mark a PR as blocked by another until that one is merged; blocked PRs come last in reviewers' lists and are not escalated",
+		Query: []apiParam{ifMatch},
+		Body:  prDependency{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/removeDependency": {Tag: "PullRequests", Summary: "Drop a blocked_by link of a PR",
+		Query: []apiParam{ifMatch},
+		Body:  prDependency{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/listByTeam": {Tag: "PullRequests", Summary: "PRs authored by a team's members, optionally with those they review",
+		Query: pageParams(prSorts,
+			apiParam{Name: "team_name", Required: true},
+			apiParam{Name: "include_reviewing", Type: "boolean", Description: "also PRs a member of the team reviews"},
+			apiParam{Name: "status", Description: "OPEN, MERGED or CLOSED"},
+		), Response: struct {
+			TeamName string                    `json:"team_name"`
+			PRs      []domain.PullRequestShort `json:"pull_requests"`
+			Page     domain.PageInfo           `json:"page"`
+		}{}},
+	"/pullRequest/list": {Tag: "PullRequests", Summary: "List PRs by author team, author and status",
+		Query: pageParams(prSorts,
+			apiParam{Name: "team_name", Description: "author's team"},
+			apiParam{Name: "author_id"},
+			apiParam{Name: "status", Description: "OPEN, MERGED or CLOSED"},
+		), Response: struct {
+			PRs  []domain.PullRequestShort `json:"pull_requests"`
+			Page domain.PageInfo           `json:"page"`
+		}{}},
+	"/pullRequest/timeline": {Tag: "PullRequests", Summary: "Event history of a PR",
+		Query: []apiParam{{Name: "pull_request_id", Required: true}}, Response: domain.PRTimeline{}},
+	"/pullRequest/activity": {Tag: "PullRequests", Summary: "Paginated feed of status changes, reviewer changes, approvals and comments of a PR",
+		Query: pageParams(activitySorts, apiParam{Name: "pull_request_id", Required: true}), Response: domain.PRActivity{}},
+	"/pullRequest/comment": {Tag: "PullRequests", Summary: "Leave a comment on a PR; author_id defaults to the caller", Status: 201,
+		Body: struct {
+			PRID     string `json:"pull_request_id"`
+			AuthorID string `json:"author_id,omitempty"`
+			Text     string `json:"text"`
+			ReplyTo  *int64 `json:"reply_to,omitempty"`
+		}{}, Response: struct {
+			Comment *domain.Comment `json:"comment"`
+		}{}},
+	"/pullRequest/comments": {Tag: "PullRequests", Summary: "Comments on a PR",
+		Query: pageParams(commentSorts, apiParam{Name: "pull_request_id", Required: true}), Response: struct {
+			PRID     string           `json:"pull_request_id"`
+			Comments []domain.Comment `json:"comments"`
+			Page     domain.PageInfo  `json:"page"`
+		}{}},
+	"/pullRequest/watch": {Tag: "PullRequests", Summary: "Subscribe a user who does not review a PR to its reviewer changes and merge",
+		Body: prWatch{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/unwatch": {Tag: "PullRequests", Summary: "Drop a user's subscription to a PR",
+		Body: prWatch{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/acknowledge": {Tag: "PullRequests", Summary: "Mark that a reviewer started the review",
+		Body: struct {
+			PRID   string `json:"pull_request_id"`
+			UserID string `json:"user_id,omitempty"`
+		}{}, Response: struct {
+			PRID           string    `json:"pull_request_id"`
+			UserID         string    `json:"user_id"`
+			AcknowledgedAt time.Time `json:"acknowledged_at"`
+		}{}},
+	"/pullRequest/approve": {Tag: "PullRequests", Summary: "Approve a PR as one of its reviewers; approvals count towards the team's min_approvals",
+		Body: prWatch{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+	"/pullRequest/requestChanges": {Tag: "PullRequests", Summary: "Request changes on a PR as one of its reviewers, withdrawing their approval",
+		Body: prWatch{}, Response: struct {
+			PR *domain.PullRequest `json:"pr"`
+		}{}},
+
+	"/stats/assignments": {Tag: "Stats", Summary: "Assignment counts per user, PR, team or repository", Report: true,
+		Query: []apiParam{
+			{Name: "group_by", Description: "user, pr, team or repository; user and pr when empty"},
+			{Name: "team_name"},
+			{Name: "user_ids", Description: "comma-separated"},
+			{Name: "sort", Description: "count or id"},
+			{Name: "limit", Type: "integer"},
+			{Name: "cursor", Description: "page.next_cursor of the previous page"},
+			{Name: "offset", Type: "integer", Description: "deprecated, use cursor"},
+		}, Response: domain.AssignmentStats{}},
+	"/stats/assignments/timeseries": {Tag: "Stats", Summary: "Assignments per day", Report: true,
+		Query: dateRangeParams, Response: struct {
+			Days []domain.DayCount `json:"days"`
+		}{}},
+	"/stats/prBurndown": {Tag: "Stats", Summary: "Open PRs at the end of each day", Report: true,
+		Query: dateRangeParams, Response: struct {
+			Days []domain.BurndownDay `json:"days"`
+		}{}},
+	"/stats/timeToFirstApproval": {Tag: "Stats", Summary: "Time from PR creation to the first approval", Report: true,
+		Response: domain.ApprovalStats{}},
+	"/stats/mergeTime": {Tag: "Stats", Summary: "Time from PR creation to merge", Report: true,
+		Query: []apiParam{
+			{Name: "since", Type: "date-time"},
+			{Name: "until", Type: "date-time"},
+			{Name: "group_by", Description: "repository"},
+		}, Response: domain.MergeTimeStats{}},
+	"/stats/slaBreaches": {Tag: "Stats", Summary: "PRs over the review or merge SLA", Report: true,
+		Query: []apiParam{
+			{Name: "since", Type: "date-time"},
+			{Name: "until", Type: "date-time"},
+			{Name: "review_sla", Type: "duration"},
+			{Name: "merge_sla", Type: "duration"},
+			{Name: "worst", Type: "integer", Description: "number of worst PRs to list"},
+		}, Response: domain.SLAReport{}},
+	"/stats/prStatus": {Tag: "Stats", Summary: "PR counts per status", Report: true,
+		Query: []apiParam{{Name: "bucket", Description: "week for a weekly breakdown"}}, Response: domain.PRStatusStats{}},
+	"/stats/prAge": {Tag: "Stats", Summary: "Age histogram of open PRs", Report: true,
+		Response: domain.PRAgeHistogram{}},
+	"/stats/authors": {Tag: "Stats", Summary: "Merge rate and long-open PRs per author", Report: true,
+		Query:    []apiParam{dateRangeParams[0], dateRangeParams[1], {Name: "merge_sla", Type: "duration"}},
+		Response: domain.AuthorMergeReport{}},
+	"/stats/noCandidate": {Tag: "Stats", Summary: "Operations that found no reviewer candidate", Report: true,
+		Query: dateRangeParams, Response: domain.NoCandidateReport{}},
+	"/stats/reassignments": {Tag: "Stats", Summary: "Reviewer churn per reason, user and PR", Report: true,
+		Query: dateRangeParams, Response: domain.ChurnReport{}},
+	"/stats/pairings": {Tag: "Stats", Summary: "How assignments spread over author-reviewer pairs", Report: true,
+		Query: dateRangeParams, Response: domain.PairingReport{}},
+	"/stats/teams": {Tag: "Stats", Summary: "Side-by-side team metrics", Report: true,
+		Response: struct {
+			Teams []domain.TeamComparison `json:"teams"`
+		}{}},
+	"/stats/rebalancing": {Tag: "Stats", Summary: "Ranked moves and additions of people that would even out review load; changes nothing", Report: true,
+		Response: domain.RebalanceReport{}},
+	"/stats/simulate": {Tag: "Stats", Summary: "Replay the PRs created in a period against another reviewer selection strategy and compare the outcome; changes nothing",
+		Query:    append([]apiParam{{Name: "strategy", Required: true, Description: "current, least_loaded or round_robin"}}, dateRangeParams...),
+		Response: domain.SimulationReport{}},
+	"/stats/idleReviewers": {Tag: "Stats", Summary: "Active users without recent assignments", Report: true,
+		Query: []apiParam{{Name: "days", Type: "integer"}}, Response: domain.IdleReviewersReport{}},
+	"/stats/snapshot": {Tag: "Stats", Summary: "Workload snapshot of a day", Report: true,
+		Query: []apiParam{
+			{Name: "date", Type: "date", Description: "defaults to today"},
+			{Name: "team_name"},
+		}, Response: domain.StatsSnapshot{}},
+	"/stats/snapshot/take": {Tag: "Stats", Summary: "Take today's workload snapshot now",
+		Response: struct {
+			Date    string    `json:"date"`
+			TakenAt time.Time `json:"taken_at"`
+		}{}},
+	"/stats/query": {Tag: "Stats", Summary: "Ad-hoc report over chosen dimensions and measures",
+		Body: domain.ReportQuery{}, Response: domain.ReportResult{}},
+	"/stats/refresh": {Tag: "Stats", Summary: "Refresh materialized statistics",
+		Response: struct {
+			RefreshedAt time.Time `json:"refreshed_at"`
+		}{}},
+	"/stats/reviewerResponsiveness": {Tag: "Stats", Summary: "Time reviewers take to acknowledge assignments", Report: true,
+		Query: []apiParam{{Name: "response_sla", Type: "duration"}}, Response: domain.ResponsivenessReport{}},
+
+	"/search": {Tag: "Search", Summary: "Find teams, users and PRs by a part of their id or name",
+		Query: []apiParam{
+			{Name: "q", Required: true, Description: "case-insensitive substring, up to 100 characters"},
+			{Name: "types", Description: "comma-separated team, user, pull_request; all by default"},
+			{Name: "limit", Type: "integer", Description: "default 20, at most 100"},
+		}, Response: struct {
+			Query   string                `json:"query"`
+			Results []domain.SearchResult `json:"results"`
+		}{}},
+	"/alerts/overload": {Tag: "Alerts", Summary: "Reviewer overload alerts",
+		Query: []apiParam{
+			{Name: "team_name"},
+			{Name: "include_resolved", Type: "boolean"},
+		}, Response: struct {
+			Alerts []domain.OverloadAlert `json:"alerts"`
+		}{}},
+	"/alerts/overload/check": {Tag: "Alerts", Summary: "Run the overload check now",
+		Response: struct {
+			Opened []domain.OverloadAlert `json:"opened"`
+		}{}},
+	"/alerts/escalations/run": {Tag: "Alerts", Summary: "Take the due escalation steps on stalled PRs now",
+		Response: struct {
+			Escalations []domain.Escalation `json:"escalations"`
+		}{}},
+
+	"/exports/create": {Tag: "Exports", Summary: "Start building a CSV export", Status: 202,
+		Body: struct {
+			Kind string `json:"kind"`
+		}{}, Response: struct {
+			Export *domain.Export `json:"export"`
+		}{}},
+	"/exports/get": {Tag: "Exports", Summary: "Export status and, once ready, a signed download link",
+		Query: []apiParam{{Name: "export_id", Required: true}}, Response: struct {
+			Export            *domain.Export `json:"export"`
+			DownloadURL       string         `json:"download_url,omitempty"`
+			DownloadExpiresAt *time.Time     `json:"download_expires_at,omitempty"`
+		}{}},
+
+	"/integrations/github/setLogin": {Tag: "Integrations", Summary: "Map a GitHub login to a user for the GitHub webhook; an empty login removes it",
+		Body: domain.GitHubLogin{}, Response: domain.GitHubLogin{}},
+	"/integrations/github/webhook": {Tag: "Integrations", Summary: "Receive GitHub pull_request deliveries signed with the github WEBHOOK_SECRETS source: opened creates the PR, closed merges or closes it, reopened reopens it",
+		Body: githubPullRequestEvent{}, Response: githubWebhookResult{}},
+
+	"/webhooks/add": {Tag: "Webhooks", Summary: "Register a URL for signed POSTs of reviewer_assigned, reviewer_replaced and pr_merged events; no events means all of them", Status: 201,
+		Body: struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events,omitempty"`
+		}{}, Response: struct {
+			Webhook *domain.AddedWebhook `json:"webhook"`
+		}{}},
+	"/webhooks/list": {Tag: "Webhooks", Summary: "List outgoing webhooks, oldest first",
+		Response: struct {
+			Webhooks []domain.Webhook `json:"webhooks"`
+		}{}},
+	"/webhooks/remove": {Tag: "Webhooks", Summary: "Remove an outgoing webhook",
+		Body: struct {
+			WebhookID string `json:"webhook_id"`
+		}{}, Response: struct {
+			WebhookID string `json:"webhook_id"`
+		}{}},
+
+	exportDownloadPath: {Tag: "Exports", Summary: "Download an export through a signed link", Produces: "text/csv",
+		Query: []apiParam{
+			{Name: "id", Required: true},
+			{Name: "expires", Type: "integer", Required: true},
+			{Name: "signature", Required: true},
+		}},
+
+	"/auth/whoami": {Tag: "Auth", Summary: "Describe the caller's credential",
+		Response: struct {
+			Role        string              `json:"role"`
+			UserID      string              `json:"user_id"`
+			TokenID     string              `json:"token_id"`
+			Method      string              `json:"method"`
+			ExpiresAt   *time.Time          `json:"expires_at"`
+			Teams       []string            `json:"teams"`
+			Permissions []domain.Permission `json:"permissions"`
+		}{}},
+	"/auth/tokens/issue": {Tag: "Auth", Summary: "Issue a personal API token", Status: 201,
+		Body: struct {
+			UserID     string   `json:"user_id"`
+			Role       string   `json:"role"`
+			Name       string   `json:"name,omitempty"`
+			Teams      []string `json:"teams,omitempty"`
+			TTLSeconds int64    `json:"ttl_seconds,omitempty"`
+		}{}, Response: struct {
+			Token *domain.IssuedToken `json:"token"`
+		}{}},
+	"/auth/tokens/revoke": {Tag: "Auth", Summary: "Revoke an API token",
+		Body: struct {
+			TokenID string `json:"token_id"`
+		}{}, Response: struct {
+			Token *domain.APIToken `json:"token"`
+		}{}},
+	"/auth/tokens/rotate": {Tag: "Auth", Summary: "Replace an API token, keeping the old one valid for a grace period", Status: 201,
+		Body: struct {
+			TokenID      string `json:"token_id"`
+			TTLSeconds   int64  `json:"ttl_seconds,omitempty"`
+			GraceSeconds *int64 `json:"grace_seconds,omitempty"`
+		}{}, Response: struct {
+			Token    *domain.IssuedToken `json:"token"`
+			Previous *domain.APIToken    `json:"previous"`
+		}{}},
+	"/auth/tokens/list": {Tag: "Auth", Summary: "List API tokens",
+		Query: []apiParam{{Name: "user_id"}}, Response: struct {
+			Tokens []domain.APIToken `json:"tokens"`
+		}{}},
+	"/auth/tokens/usage": {Tag: "Auth", Summary: "Token usage, optionally only tokens idle since a time",
+		Query: []apiParam{{Name: "idle_since", Type: "date-time"}}, Response: struct {
+			Usage []domain.TokenUsage `json:"usage"`
+		}{}},
+	"/auth/roles/list": {Tag: "Auth", Summary: "Roles with their permissions",
+		Response: struct {
+			Roles []domain.RolePermissions `json:"roles"`
+			Known []domain.Permission      `json:"known_permissions"`
+		}{}},
+	"/auth/roles/set": {Tag: "Auth", Summary: "Replace the permissions of a role",
+		Body: domain.RolePermissions{}, Response: struct {
+			Role *domain.RolePermissions `json:"role"`
+		}{}},
+	"/auth/events": {Tag: "Auth", Summary: "Authentication audit log",
+		Query: []apiParam{
+			{Name: "since", Type: "date-time"},
+			{Name: "until", Type: "date-time"},
+			{Name: "outcome"},
+			{Name: "token_id"},
+			{Name: "user_id"},
+			{Name: "limit", Type: "integer"},
+		}, Response: struct {
+			Events []domain.AuthEvent `json:"events"`
+		}{}},
+
+	"/debug/vars": {Tag: "Health", Summary: "Runtime counters (expvar)", Response: map[string]any{}},
+	"/metrics":    {Tag: "Health", Summary: "Prometheus metrics", Produces: "text/plain"},
+}
+
+// Conditional request headers of the PR routes.
+var (
+	ifMatch     = apiParam{In: "header", Name: "If-Match", Description: "ETag of /pullRequest/get; 412 PRECONDITION_FAILED when the PR has changed since"}
+	ifNoneMatch = apiParam{In: "header", Name: "If-None-Match", Description: "304 when the PR still has this ETag"}
+)
+
+var dateRangeParams = []apiParam{
+	{Name: "from", Type: "date", Description: "first day, 29 days before to by default"},
+	{Name: "to", Type: "date", Description: "last day, today by default"},
+}
+
+// prDependency is the body of the dependency routes.
+type prDependency struct {
+	PRID      string `json:"pull_request_id"`
+	BlockedBy string `json:"blocked_by"`
+}
+
+// prWatch is the body of the watch and review routes; user_id defaults to
+// the caller.
+type prWatch struct {
+	PRID   string `json:"pull_request_id"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// teamHolidays is the response of the holiday calendar routes.
+type teamHolidays struct {
+	TeamName string           `json:"team_name"`
+	Holidays []domain.Holiday `json:"holidays"`
+}
+
+const prSorts = "pull_request_id, created_at or -created_at (newest first)"
+
+const activitySorts = "at or -at (newest first)"
+
+const commentSorts = "created_at or -created_at (newest first)"
+
+// pageParams appends the pagination parameters of list endpoints to params.
+func pageParams(sorts string, params ...apiParam) []apiParam {
+	return append(params,
+		apiParam{Name: "sort", Description: sorts},
+		apiParam{Name: "limit", Type: "integer", Description: "1..500, 100 by default"},
+		apiParam{Name: "cursor", Description: "page.next_cursor of the previous page"},
+	)
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+const auditBatchSize = 100
+
+func logSecurityEvent(e domain.AuthEvent) {
+	log.Printf("security_event type=%s outcome=%s ip=%s route=%s token_id=%s role=%s", e.Type, e.Outcome, e.IP, e.Route, e.TokenID, e.Role)
+}
+
+// AuditLog persists auth events in the background so recording them never
+// blocks a request. Events are dropped (and counted) when the buffer is full.
+type AuditLog struct {
+	store   func(context.Context, []domain.AuthEvent) error
+	events  chan domain.AuthEvent
+	dropped atomic.Int64
+	done    chan struct{}
+}
+
+func NewAuditLog(store func(context.Context, []domain.AuthEvent) error, buffer int) *AuditLog {
+	a := &AuditLog{
+		store:  store,
+		events: make(chan domain.AuthEvent, buffer),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Record logs non-successful events immediately and queues every event for storage.
+func (a *AuditLog) Record(e domain.AuthEvent) {
+	if e.Outcome != domain.AuthOutcomeSuccess {
+		logSecurityEvent(e)
+	}
+	select {
+	case a.events <- e:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// Close flushes queued events and stops the writer.
+func (a *AuditLog) Close() {
+	close(a.events)
+	<-a.done
+}
+
+func (a *AuditLog) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	batch := make([]domain.AuthEvent, 0, auditBatchSize)
+	flush := func() {
+		if n := a.dropped.Swap(0); n > 0 {
+			log.Printf("audit log: dropped %d events, buffer full", n)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.store(context.Background(), batch); err != nil {
+			log.Printf("audit log: store %d events: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case e, ok := <-a.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= auditBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"sync"
+	"time"
+)
+
+// FailureTracker counts failed authentications per source and blocks a
+// source for BlockFor once it reaches MaxFailures within Window.
+type FailureTracker struct {
+	MaxFailures int
+	Window      time.Duration
+	BlockFor    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*failureEntry
+	calls   int
+}
+
+type failureEntry struct {
+	count        int
+	windowStart  time.Time
+	blockedUntil time.Time
+}
+
+func NewFailureTracker(maxFailures int, window, blockFor time.Duration) *FailureTracker {
+	return &FailureTracker{MaxFailures: maxFailures, Window: window, BlockFor: blockFor, entries: make(map[string]*failureEntry)}
+}
+
+func (t *FailureTracker) Blocked(key string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[key]
+	if !ok {
+		return false, 0
+	}
+	if wait := time.Until(e.blockedUntil); wait > 0 {
+		return true, wait
+	}
+	return false, 0
+}
+
+// Fail records a failed attempt and reports whether it triggered a block.
+func (t *FailureTracker) Fail(key string) bool {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	if t.calls%1024 == 0 {
+		t.sweepLocked(now)
+	}
+	e, ok := t.entries[key]
+	if !ok {
+		e = &failureEntry{windowStart: now}
+		t.entries[key] = e
+	} else if now.Sub(e.windowStart) > t.Window {
+		e.count, e.windowStart = 0, now
+	}
+	e.count++
+	if e.count >= t.MaxFailures && now.After(e.blockedUntil) {
+		e.blockedUntil = now.Add(t.BlockFor)
+		e.count = 0
+		e.windowStart = now
+		return true
+	}
+	return false
+}
+
+func (t *FailureTracker) Succeed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.entries[key]; ok && time.Now().After(e.blockedUntil) {
+		delete(t.entries, key)
+	}
+}
+
+func (t *FailureTracker) sweepLocked(now time.Time) {
+	for k, e := range t.entries {
+		if now.Sub(e.windowStart) > t.Window && now.After(e.blockedUntil) {
+			delete(t.entries, k)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const responseCacheMaxEntries = 1024
+
+// ResponseCache keeps successful GET responses for a short TTL and answers
+// conditional requests (If-None-Match / If-Modified-Since) with 304. Entries
+// are keyed by path, query and the caller's team scope, since that is all
+// the cached handlers depend on. The ETag is the handler's own when it sets
+// one, a hash of the body otherwise.
+type ResponseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+type cachedResponse struct {
+	header   http.Header
+	body     []byte
+	etag     string
+	modified time.Time
+	expires  time.Time
+}
+
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &ResponseCache{ttl: ttl, entries: make(map[string]*cachedResponse)}
+}
+
+// Wrap caches next; a nil cache returns next unchanged.
+func (c *ResponseCache) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+		key := c.key(r)
+		now := time.Now()
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		c.mu.Unlock()
+		if !ok || now.After(e.expires) {
+			rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+			next(rec, r)
+			if rec.status != http.StatusOK {
+				rec.flush(w)
+				return
+			}
+			etag := rec.header.Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(rec.body.Bytes())
+				etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			}
+			e = &cachedResponse{
+				header:   rec.header,
+				body:     rec.body.Bytes(),
+				etag:     etag,
+				modified: now.UTC().Truncate(time.Second),
+				expires:  now.Add(c.ttl),
+			}
+			c.store(key, e, now)
+		}
+		for k, v := range e.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", e.etag)
+		w.Header().Set("Last-Modified", e.modified.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(c.ttl.Seconds())))
+		if notModified(r, e) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write(e.body)
+	}
+}
+
+// Invalidate drops all entries, so that the next read after a write sees
+// it. A nil cache does nothing.
+func (c *ResponseCache) Invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+func notModified(r *http.Request, e *cachedResponse) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			if tag = strings.TrimSpace(tag); tag == e.etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !e.modified.After(t)
+	}
+	return false
+}
+
+func (c *ResponseCache) key(r *http.Request) string {
+	teams := append([]string(nil), IdentityFrom(r.Context()).Teams...)
+	sort.Strings(teams)
+	return r.URL.Path + "?" + r.URL.Query().Encode() + "#" + strings.Join(teams, ",")
+}
+
+func (c *ResponseCache) store(key string, e *cachedResponse, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= responseCacheMaxEntries {
+		for k, old := range c.entries {
+			if now.After(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= responseCacheMaxEntries {
+			c.entries = make(map[string]*cachedResponse)
+		}
+	}
+	c.entries[key] = e
+}
+
+// bufferedResponse captures a handler's response so it can be cached.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(code int)        { b.status = code }
+
+func (b *bufferedResponse) flush(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(b.status)
+	_, _ = w.Write(b.body.Bytes())
+}
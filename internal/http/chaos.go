@@ -0,0 +1,69 @@
+package http
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// chaosExempt are the operational routes faults are never injected into,
+// so probes and scrapers keep working while the API misbehaves.
+var chaosExempt = map[string]bool{
+	"/health": true, "/metrics": true, "/debug/vars": true, "/openapi.json": true, "/docs": true,
+}
+
+// Chaos injects faults into a share of requests, to rehearse how clients
+// cope with slow and failing responses before an incident does. It is meant
+// for dev and staging. Injected faults carry an X-Chaos header naming them.
+type Chaos struct {
+	Latency     time.Duration
+	LatencyRate float64 // share of requests delayed by Latency
+	ErrorRate   float64 // share of requests answered ErrorStatus without running
+	ErrorStatus int     // 503 when zero
+	// Rand returns numbers in [0, 1); rand.Float64 when nil.
+	Rand func() float64
+}
+
+func (c *Chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if c.Rand != nil {
+		return c.Rand() < rate
+	}
+	return rand.Float64() < rate
+}
+
+// wrap delays or fails fn on a share of its requests.
+func (c *Chaos) wrap(path string, fn http.HandlerFunc) http.HandlerFunc {
+	if c == nil || chaosExempt[path] {
+		return fn
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.roll(c.LatencyRate) {
+			w.Header().Add("X-Chaos", "latency")
+			t := time.NewTimer(c.Latency)
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			}
+		}
+		if c.roll(c.ErrorRate) {
+			status := c.ErrorStatus
+			if status == 0 {
+				status = http.StatusServiceUnavailable
+			}
+			log.Printf("chaos: %s %s answered %d", r.Method, r.URL.Path, status)
+			w.Header().Add("X-Chaos", "error")
+			writeError(w, status, string(domain.ErrInternal), "injected fault, status "+strconv.Itoa(status))
+			return
+		}
+		fn(w, r)
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+// envelopeKeys are the single-key wrappers v1 puts around a resource, as in
+// {"team": ...} or {"days": [...]}; v2 returns the resource itself as data.
+var envelopeKeys = map[string]bool{
+	"team": true, "user": true, "pr": true, "export": true, "token": true, "role": true,
+	"days": true, "teams": true, "alerts": true, "opened": true, "tokens": true, "usage": true, "events": true,
+	"pull_requests": true,
+}
+
+// envelopeMeta is sent with every v2 response; Page is the "page" of list
+// responses, moved out of data.
+type envelopeMeta struct {
+	APIVersion int             `json:"api_version"`
+	Page       json.RawMessage `json:"page,omitempty"`
+}
+
+// envelope rewrites JSON responses of next as {"data": ..., "meta": ...} or
+// {"error": ..., "meta": ...}. Other content (CSV, XLSX) passes unchanged.
+func envelope(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next(rec, r)
+
+		var body map[string]json.RawMessage
+		if !isJSONResponse(rec.header) || json.Unmarshal(rec.body.Bytes(), &body) != nil {
+			rec.flush(w)
+			return
+		}
+		meta := envelopeMeta{APIVersion: 2}
+		out := map[string]any{"meta": &meta}
+		if e, ok := body["error"]; ok && rec.status >= 400 {
+			out["error"] = e
+		} else {
+			meta.Page = body["page"]
+			delete(body, "page")
+			out["data"] = body
+			for k, v := range body {
+				if len(body) == 1 && envelopeKeys[k] {
+					out["data"] = v
+				}
+			}
+		}
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.status)
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+// isJSONResponse reports whether a handler wrote JSON; an unset
+// Content-Type counts as JSON.
+func isJSONResponse(h http.Header) bool {
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && mt == "application/json"
+}
@@ -1,39 +1,227 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	domain "prsrv/internal/domain"
+	"prsrv/internal/metrics"
 )
 
 type Handlers struct {
 	Svc  *domain.Service
 	Auth Auth
+	// Webhooks verifies inbound webhook deliveries; wrap webhook endpoints
+	// with h.Webhooks.Require.
+	Webhooks *Webhooks
+	// Exports signs download links for generated reports; nil disables them.
+	Exports *URLSigner
+	// StatsCache caches /stats/* responses; nil disables caching.
+	StatsCache *ResponseCache
+	// ReadCache caches /team/get and /pullRequest/get; nil disables caching.
+	ReadCache *ResponseCache
+	// SLA holds the default SLAs for /stats/slaBreaches and
+	// /stats/reviewerResponsiveness.
+	SLA domain.SLA
+	// IdleReviewerDays is the default window of /stats/idleReviewers.
+	IdleReviewerDays int
+	// Overload is the policy of manual overload checks; AlertNotify, when
+	// set, receives the alerts they open.
+	Overload    domain.OverloadPolicy
+	AlertNotify func(domain.OverloadAlert)
+	// EscalationNotify, when set, receives the escalation steps taken by
+	// manual escalation runs.
+	EscalationNotify func(domain.Escalation)
+	// RoutePermissions overrides the permission Register assigns to a path.
+	RoutePermissions map[string]domain.Permission
+	// LegacySunset is announced in the Sunset header of the unversioned
+	// paths; zero leaves it out.
+	LegacySunset time.Time
+	// RequestTimeout bounds how long a request may take before it is
+	// answered 503 TIMEOUT; zero disables it.
+	RequestTimeout time.Duration
+	// DBTimeout bounds the database work of a request: its context is
+	// cancelled then, so queries still running fail with 503 TIMEOUT and
+	// transactions roll back. Zero disables it.
+	DBTimeout time.Duration
+	// Chaos injects faults into requests for resilience testing; nil
+	// disables it.
+	Chaos *Chaos
+
+	routes  []route
+	changes changeSignal
 }
 
 func NewHandlers(s *domain.Service, admin, user string) *Handlers {
+	var static []StaticToken
+	if admin != "" {
+		static = append(static, NewStaticToken(admin, RoleAdmin))
+	}
+	if user != "" {
+		static = append(static, NewStaticToken(user, RoleUser))
+	}
 	return &Handlers{
-		Svc:  s,
-		Auth: Auth{AdminToken: admin, UserToken: user},
+		Svc: s,
+		Auth: Auth{
+			Static: static,
+			Tokens: NewTokenCache(s.LookupToken, 30*time.Second),
+			// Reloads serve every request, so none of them may cancel one.
+			Permissions: NewPermissionCache(func() ([]domain.RolePermissions, error) { return s.ListRoles(context.Background()) }, 30*time.Second),
+		},
+		SLA:              domain.SLA{Review: 24 * time.Hour, Merge: 72 * time.Hour, Response: 4 * time.Hour},
+		IdleReviewerDays: 14,
+		Overload:         domain.OverloadPolicy{MaxOpen: 10, MedianFactor: 3},
 	}
 }
 
 func (h *Handlers) Register(mux *http.ServeMux) {
-	mux.HandleFunc("/health", Require(RoleNone, h.Auth, h.handleHealth))
+	h.handle(mux, http.MethodGet, "/health", domain.PermPublic, h.handleHealth)
+	h.handle(mux, http.MethodGet, "/openapi.json", domain.PermPublic, h.handleOpenAPI)
+	h.handle(mux, http.MethodGet, "/docs", domain.PermPublic, h.handleDocs)
+
+	h.handle(mux, http.MethodPost, "/team/add", domain.PermTeamWrite, h.handleTeamAdd)
+	h.handle(mux, http.MethodPost, "/team/upsert", domain.PermTeamWrite, h.handleTeamUpsert)
+	h.handle(mux, http.MethodGet, "/team/get", domain.PermTeamRead, h.ReadCache.Wrap(h.handleTeamGet))
+	h.handle(mux, http.MethodPost, "/team/setLead", domain.PermTeamWrite, h.handleTeamSetLead)
+	h.handle(mux, http.MethodPost, "/team/setManualAssignment", domain.PermTeamWrite, h.handleTeamSetManualAssignment)
+	h.handle(mux, http.MethodPost, "/team/setMergeRules", domain.PermTeamWrite, h.handleTeamSetMergeRules)
+	h.handle(mux, http.MethodPost, "/team/setEscalation", domain.PermTeamWrite, h.handleTeamSetEscalation)
+	h.handle(mux, http.MethodPost, "/team/setWorkingHours", domain.PermTeamWrite, h.handleTeamSetWorkingHours)
+	h.handle(mux, http.MethodPost, "/team/setHolidays", domain.PermTeamWrite, h.handleTeamSetHolidays)
+	h.handle(mux, http.MethodGet, "/team/holidays", domain.PermTeamRead, h.handleTeamHolidays)
+
+	h.handle(mux, http.MethodPost, "/users/setIsActive", domain.PermUserWrite, h.handleSetIsActive)
+	h.handle(mux, http.MethodGet, "/users/getReview", domain.PermPRRead, h.handleUsersGetReview)
+	h.handle(mux, http.MethodGet, "/users/getReviewBatch", domain.PermPRRead, h.handleUsersGetReviewBatch)
+	h.handle(mux, http.MethodGet, "/users/pollAssignments", domain.PermPRRead, h.handlePollAssignments)
+	h.handle(mux, http.MethodPost, "/users/bulkDeactivate", domain.PermUserWrite, h.handleUsersBulkDeactivate)
+	h.handle(mux, http.MethodPost, "/users/update", domain.PermUserWrite, h.handleUsersUpdate)
+	h.handle(mux, http.MethodPost, "/users/anonymize", domain.PermUserWrite, h.handleUsersAnonymize)
+
+	h.handle(mux, http.MethodPost, "/pullRequest/create", domain.PermPRCreate, h.handlePRCreate)
+	h.handle(mux, http.MethodGet, "/pullRequest/get", domain.PermPRRead, h.ReadCache.Wrap(h.handlePRGet))
+	h.handle(mux, http.MethodPost, "/pullRequest/merge", domain.PermPRMerge, h.handlePRMerge)
+	h.handle(mux, http.MethodPost, "/pullRequest/close", domain.PermPRMerge, h.handlePRStatus((*domain.Service).ClosePR))
+	h.handle(mux, http.MethodPost, "/pullRequest/reopen", domain.PermPRMerge, h.handlePRStatus((*domain.Service).ReopenPR))
+	h.handle(mux, http.MethodPost, "/pullRequest/reassign", domain.PermPRAssign, h.handlePRReassign)
+	h.handle(mux, http.MethodPost, "/pullRequest/addReviewer", domain.PermPRAssign, h.handlePRAddReviewer)
+	h.handle(mux, http.MethodPost, "/pullRequest/transferAuthor", domain.PermPRAssign, h.handlePRTransferAuthor)
+	h.handle(mux, http.MethodPost, "/pullRequest/addDependency", domain.PermPRCreate, h.handlePRDependency((*domain.Service).AddPRDependency))
+	h.handle(mux, http.MethodPost, "/pullRequest/removeDependency", domain.PermPRCreate, h.handlePRDependency((*domain.Service).RemovePRDependency))
+	h.handle(mux, http.MethodGet, "/pullRequest/listByTeam", domain.PermPRRead, h.handlePRListByTeam)
+	h.handle(mux, http.MethodGet, "/pullRequest/list", domain.PermPRRead, h.handlePRList)
+	h.handle(mux, http.MethodGet, "/pullRequest/timeline", domain.PermPRRead, h.handlePRTimeline)
+	h.handle(mux, http.MethodGet, "/pullRequest/activity", domain.PermPRRead, h.handlePRActivity)
+	h.handle(mux, http.MethodPost, "/pullRequest/comment", domain.PermPRReview, h.handlePRComment)
+	h.handle(mux, http.MethodGet, "/pullRequest/comments", domain.PermPRRead, h.handlePRComments)
+	h.handle(mux, http.MethodPost, "/pullRequest/acknowledge", domain.PermPRReview, h.handlePRAcknowledge)
+	h.handle(mux, http.MethodPost, "/pullRequest/approve", domain.PermPRReview, h.handlePRForUser((*domain.Service).ApproveReview, h.canDecideFor))
+	h.handle(mux, http.MethodPost, "/pullRequest/requestChanges", domain.PermPRReview, h.handlePRForUser((*domain.Service).RequestChanges, h.canDecideFor))
+	h.handle(mux, http.MethodPost, "/pullRequest/watch", domain.PermPRReview, h.handlePRForUser((*domain.Service).WatchPR, h.canDecideFor))
+	h.handle(mux, http.MethodPost, "/pullRequest/unwatch", domain.PermPRReview, h.handlePRForUser((*domain.Service).UnwatchPR, h.canDecideFor))
+
+	h.handle(mux, http.MethodGet, "/stats/assignments", domain.PermStatsRead, h.report(h.handleStatsAssignments))
+	h.handle(mux, http.MethodGet, "/stats/assignments/timeseries", domain.PermStatsRead, h.report(h.handleStatsTimeseries))
+	h.handle(mux, http.MethodGet, "/stats/prBurndown", domain.PermStatsRead, h.report(h.handleStatsBurndown))
+	h.handle(mux, http.MethodGet, "/stats/timeToFirstApproval", domain.PermStatsRead, h.report(h.handleStatsTimeToFirstApproval))
+	h.handle(mux, http.MethodGet, "/stats/mergeTime", domain.PermStatsRead, h.report(h.handleStatsMergeTime))
+	h.handle(mux, http.MethodGet, "/stats/slaBreaches", domain.PermStatsRead, h.report(h.handleStatsSLABreaches))
+	h.handle(mux, http.MethodGet, "/stats/prStatus", domain.PermStatsRead, h.report(h.handleStatsPRStatus))
+	h.handle(mux, http.MethodGet, "/stats/prAge", domain.PermStatsRead, h.report(h.handleStatsPRAge))
+	h.handle(mux, http.MethodGet, "/stats/authors", domain.PermStatsRead, h.report(h.handleStatsAuthors))
+	h.handle(mux, http.MethodGet, "/stats/noCandidate", domain.PermStatsRead, h.report(h.handleStatsNoCandidate))
+	h.handle(mux, http.MethodGet, "/stats/reassignments", domain.PermStatsRead, h.report(h.handleStatsReassignments))
+	h.handle(mux, http.MethodGet, "/stats/pairings", domain.PermStatsRead, h.report(h.handleStatsPairings))
+	h.handle(mux, http.MethodGet, "/stats/teams", domain.PermStatsRead, h.report(h.handleStatsTeams))
+	h.handle(mux, http.MethodGet, "/stats/rebalancing", domain.PermStatsRead, h.report(h.handleStatsRebalancing))
+	h.handle(mux, http.MethodGet, "/stats/simulate", domain.PermAuthAdmin, h.handleStatsSimulate)
+	h.handle(mux, http.MethodGet, "/stats/idleReviewers", domain.PermStatsRead, h.report(h.handleStatsIdleReviewers))
+	h.handle(mux, http.MethodGet, "/stats/snapshot", domain.PermStatsRead, h.report(h.handleStatsSnapshot))
+	h.handle(mux, http.MethodPost, "/stats/snapshot/take", domain.PermAuthAdmin, h.handleStatsSnapshotTake)
+	h.handle(mux, http.MethodPost, "/stats/query", domain.PermStatsRead, h.handleStatsQuery)
+	h.handle(mux, http.MethodPost, "/stats/refresh", domain.PermAuthAdmin, h.handleStatsRefresh)
+	h.handle(mux, http.MethodGet, "/stats/reviewerResponsiveness", domain.PermStatsRead, h.report(h.handleStatsReviewerResponsiveness))
+
+	h.handle(mux, http.MethodGet, "/search", domain.PermTeamRead, h.handleSearch)
+
+	h.handle(mux, http.MethodGet, "/alerts/overload", domain.PermStatsRead, h.handleOverloadAlerts)
+	h.handle(mux, http.MethodPost, "/alerts/overload/check", domain.PermAuthAdmin, h.handleOverloadCheck)
+	h.handle(mux, http.MethodPost, "/alerts/escalations/run", domain.PermAuthAdmin, h.handleEscalationsRun)
 
-	mux.HandleFunc("/team/add", Require(RoleAdmin, h.Auth, h.handleTeamAdd))
-	mux.HandleFunc("/team/get", Require(RoleUser, h.Auth, h.handleTeamGet))
+	h.handle(mux, http.MethodPost, "/exports/create", domain.PermExport, h.handleExportCreate)
+	h.handle(mux, http.MethodGet, "/exports/get", domain.PermExport, h.handleExportGet)
+	// the signed link is the credential here
+	h.handle(mux, http.MethodGet, exportDownloadPath, domain.PermPublic, h.handleExportDownload)
+
+	h.handle(mux, http.MethodPost, "/integrations/github/setLogin", domain.PermUserWrite, h.handleGitHubSetLogin)
+	// the delivery signature is the credential here
+	h.handle(mux, http.MethodPost, "/integrations/github/webhook", domain.PermPublic, h.Webhooks.Require(githubSource, h.handleGitHubWebhook))
+
+	h.handle(mux, http.MethodPost, "/webhooks/add", domain.PermAuthAdmin, h.handleWebhookAdd)
+	h.handle(mux, http.MethodGet, "/webhooks/list", domain.PermAuthAdmin, h.handleWebhookList)
+	h.handle(mux, http.MethodPost, "/webhooks/remove", domain.PermAuthAdmin, h.handleWebhookRemove)
+
+	h.handle(mux, http.MethodGet, "/auth/whoami", domain.PermAuthenticated, h.handleWhoami)
+	h.handle(mux, http.MethodPost, "/auth/tokens/issue", domain.PermAuthAdmin, h.handleTokenIssue)
+	h.handle(mux, http.MethodPost, "/auth/tokens/revoke", domain.PermAuthAdmin, h.handleTokenRevoke)
+	h.handle(mux, http.MethodPost, "/auth/tokens/rotate", domain.PermAuthAdmin, h.handleTokenRotate)
+	h.handle(mux, http.MethodGet, "/auth/tokens/list", domain.PermAuthAdmin, h.handleTokenList)
+	h.handle(mux, http.MethodGet, "/auth/tokens/usage", domain.PermAuthAdmin, h.handleTokenUsage)
+
+	h.handle(mux, http.MethodGet, "/auth/roles/list", domain.PermAuthAdmin, h.handleRoleList)
+	h.handle(mux, http.MethodPost, "/auth/roles/set", domain.PermAuthAdmin, h.handleRoleSet)
+	h.handle(mux, http.MethodGet, "/auth/events", domain.PermAuthAdmin, h.handleAuthEvents)
+
+	h.handle(mux, http.MethodGet, "/debug/vars", domain.PermAuthAdmin, expvar.Handler().ServeHTTP)
+	h.handle(mux, http.MethodGet, "/metrics", domain.PermStatsRead, metrics.Default.Handler())
+}
+
+// report serves a /stats/* endpoint: cached, and available as XLSX.
+func (h *Handlers) report(fn http.HandlerFunc) http.HandlerFunc {
+	return h.StatsCache.Wrap(reportFormat(fn))
+}
 
-	mux.HandleFunc("/users/setIsActive", Require(RoleAdmin, h.Auth, h.handleSetIsActive))
-	mux.HandleFunc("/users/getReview", Require(RoleUser, h.Auth, h.handleUsersGetReview))
-	mux.HandleFunc("/users/bulkDeactivate", Require(RoleAdmin, h.Auth, h.handleUsersBulkDeactivate))
+// handle mounts fn at path for method behind perm, unless RoutePermissions
+// says otherwise.
+func (h *Handlers) handle(mux *http.ServeMux, method, path string, perm domain.Permission, fn http.HandlerFunc) {
+	if p, ok := h.RoutePermissions[path]; ok {
+		log.Printf("route %s requires %q instead of %q", path, p, perm)
+		perm = p
+	}
+	h.routes = append(h.routes, route{method: method, path: path, perm: perm})
+	if method == http.MethodPost && !readOnlyPosts[path] {
+		fn = h.invalidating(fn)
+	}
+	fn = h.Chaos.wrap(path, fn)
+	if h.DBTimeout > 0 && !untimedRoutes[path] {
+		fn = withDeadline(h.DBTimeout, fn)
+	}
+	if h.RequestTimeout > 0 && !untimedRoutes[path] {
+		fn = withTimeout(h.RequestTimeout, fn)
+	}
+	h.mountVersions(mux, path, localizeErrors(allowMethod(method, Require(perm, h.Auth, msgpackRequest(jsonContent(fn))))))
+}
 
-	mux.HandleFunc("/pullRequest/create", Require(RoleAdmin, h.Auth, h.handlePRCreate))
-	mux.HandleFunc("/pullRequest/merge", Require(RoleAdmin, h.Auth, h.handlePRMerge))
-	mux.HandleFunc("/pullRequest/reassign", Require(RoleAdmin, h.Auth, h.handlePRReassign))
+// readOnlyPosts are POST routes that change nothing a cached read shows.
+var readOnlyPosts = map[string]bool{"/stats/query": true}
 
-	mux.HandleFunc("/stats/assignments", Require(RoleUser, h.Auth, h.handleStatsAssignments))
+// invalidating clears the response caches once a write is done and wakes
+// the long polls. Other replicas keep serving their entries until the TTL
+// runs out, and their polls notice the write on their next recheck.
+func (h *Handlers) invalidating(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn(w, r)
+		h.StatsCache.Invalidate()
+		h.ReadCache.Invalidate()
+		h.changes.notify()
+	}
 }
 
 func (h *Handlers) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -42,45 +230,73 @@ func (h *Handlers) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) handleTeamAdd(w http.ResponseWriter, r *http.Request) {
 	var req domain.Team
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, string(domain.ErrNotFound), "invalid json")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	validateTeam(&v, req)
+	if !v.ok(w) {
 		return
 	}
-	if req.TeamName == "" {
-		writeError(w, http.StatusBadRequest, string(domain.ErrNotFound), "team_name is required")
+	if !h.scopeTeam(w, r, req.TeamName) {
 		return
 	}
-	team, err := h.Svc.AddTeam(req)
+	team, err := h.Svc.AddTeam(r.Context(), req)
 	if err != nil {
-		code, msg := domain.ParseErrorCode(err)
-		if code == domain.ErrTeamExists {
-			writeError(w, http.StatusBadRequest, string(code), msg)
-			return
-		}
-		writeError(w, http.StatusInternalServerError, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
 }
 
+// handleTeamUpsert answers 201 when it created the team and 200 when it
+// merged the members into an existing one.
+func (h *Handlers) handleTeamUpsert(w http.ResponseWriter, r *http.Request) {
+	var req domain.Team
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Members = domain.DedupeMembers(req.Members)
+	var v validator
+	validateTeam(&v, req)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	team, created, err := h.Svc.UpsertTeam(r.Context(), req)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
+}
+
 func (h *Handlers) handleTeamGet(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("team_name")
-	if name == "" {
-		writeError(w, 400, string(domain.ErrNotFound), "team_name is required")
+	var v validator
+	v.id("team_name", name)
+	page := v.page(r.URL.Query())
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, name) {
 		return
 	}
-	team, err := h.Svc.GetTeam(name)
+	team, info, err := h.Svc.GetTeamPage(r.Context(), name, page)
 	if err != nil {
-		code, msg := domain.ParseErrorCode(err)
-		if code == domain.ErrNotFound {
-			writeError(w, 404, string(code), msg)
-			return
-		}
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(team)
+	_ = json.NewEncoder(w).Encode(struct {
+		*domain.Team
+		Page domain.PageInfo `json:"page"`
+	}{team, info})
 }
 
 func (h *Handlers) handleSetIsActive(w http.ResponseWriter, r *http.Request) {
@@ -88,52 +304,299 @@ func (h *Handlers) handleSetIsActive(w http.ResponseWriter, r *http.Request) {
 		UserID   string `json:"user_id"`
 		IsActive bool   `json:"is_active"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, 400, string(domain.ErrNotFound), "invalid json")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("user_id", req.UserID)
+	if !v.ok(w) {
 		return
 	}
-	u, err := h.Svc.SetIsActive(req.UserID, req.IsActive)
+	if !h.scopeUser(w, r, req.UserID) {
+		return
+	}
+	u, err := h.Svc.SetIsActive(r.Context(), req.UserID, req.IsActive)
 	if err != nil {
-		code, msg := domain.ParseErrorCode(err)
-		if code == domain.ErrNotFound {
-			writeError(w, 404, string(code), msg)
-			return
-		}
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
 	_ = json.NewEncoder(w).Encode(map[string]any{"user": u})
 }
 
+// handleUsersUpdate changes the fields present in the body and keeps the
+// others.
+func (h *Handlers) handleUsersUpdate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string `json:"user_id"`
+		domain.UserUpdate
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("user_id", req.UserID)
+	if req.Username != nil {
+		v.name("username", *req.Username, true)
+	} else {
+		v.add("username", "is required: there is nothing to update")
+	}
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeUser(w, r, req.UserID) {
+		return
+	}
+	u, err := h.Svc.UpdateUser(r.Context(), req.UserID, req.UserUpdate)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"user": u})
+}
+
+func (h *Handlers) handleUsersAnonymize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("user_id", req.UserID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeUser(w, r, req.UserID) {
+		return
+	}
+	u, revoked, err := h.Svc.AnonymizeUser(r.Context(), req.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if h.Auth.Tokens != nil {
+		for _, id := range revoked {
+			h.Auth.Tokens.Invalidate(id)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"user": u, "revoked_tokens": revoked})
+}
+
 func (h *Handlers) handleUsersGetReview(w http.ResponseWriter, r *http.Request) {
 	uid := r.URL.Query().Get("user_id")
-	prs, err := h.Svc.ListUserPRs(uid)
+	if uid == "" {
+		uid = IdentityFrom(r.Context()).UserID
+	}
+	var v validator
+	v.id("user_id", uid)
+	page := v.page(r.URL.Query())
+	if !v.ok(w) {
+		return
+	}
+	if !h.canActFor(r, uid) {
+		writeCode(w, domain.ErrForbidden, "cannot access another user's data")
+		return
+	}
+	if !h.scopeUser(w, r, uid) {
+		return
+	}
+	prs, info, err := h.Svc.ListUserPRs(r.Context(), uid, page)
 	if err != nil {
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"user_id":       uid,
 		"pull_requests": prs,
+		"page":          info,
 	})
 }
 
+// handleUsersGetReviewBatch returns the open PRs of several reviewers in
+// one query, for bots that would otherwise ask once per team member.
+func (h *Handlers) handleUsersGetReviewBatch(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	seen := map[string]bool{}
+	for _, v := range r.URL.Query()["user_ids"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	var v validator
+	v.count("user_ids", len(ids), maxBatch)
+	if len(ids) <= maxBatch {
+		for i, id := range ids {
+			v.id("user_ids["+strconv.Itoa(i)+"]", id)
+		}
+	}
+	if !v.ok(w) {
+		return
+	}
+	for _, id := range ids {
+		if !h.canActFor(r, id) {
+			writeCode(w, domain.ErrForbidden, "cannot access another user's data")
+			return
+		}
+		if !h.scopeUser(w, r, id) {
+			return
+		}
+	}
+	byUser, err := h.Svc.ListOpenReviews(r.Context(), ids)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"reviews": byUser})
+}
+
+// handlePRList lists PRs by author team, author and status; team-scoped
+// callers only see their teams.
+func (h *Handlers) handlePRList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := domain.PRFilter{Teams: IdentityFrom(r.Context()).Teams, AuthorID: q.Get("author_id"), Status: domain.PRStatus(q.Get("status"))}
+	var v validator
+	v.optionalID("team_name", q.Get("team_name"))
+	v.optionalID("author_id", f.AuthorID)
+	page := v.page(q)
+	if !v.ok(w) {
+		return
+	}
+	if team := q.Get("team_name"); team != "" {
+		if !h.scopeTeam(w, r, team) {
+			return
+		}
+		f.Teams = []string{team}
+	}
+	prs, info, err := h.Svc.ListPRs(r.Context(), f, page)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"pull_requests": prs, "page": info})
+}
+
+// handlePRListByTeam lists the PRs of one team's authors, optionally with the
+// PRs its members review.
+func (h *Handlers) handlePRListByTeam(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	team := q.Get("team_name")
+	var v validator
+	v.id("team_name", team)
+	page := v.page(q)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, team) {
+		return
+	}
+	prs, info, err := h.Svc.ListTeamPRs(r.Context(), team, q.Get("include_reviewing") == "true", domain.PRStatus(q.Get("status")), page)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team_name": team, "pull_requests": prs, "page": info})
+}
+
+// canActFor reports whether the caller may read userID's data. Credentials
+// bound to a user are limited to that user unless their role grants
+// user:any; shared static tokens carry no user and are not scoped.
+func (h *Handlers) canActFor(r *http.Request, userID string) bool {
+	id := IdentityFrom(r.Context())
+	if id.UserID == "" || id.UserID == userID {
+		return true
+	}
+	return h.Auth.Allowed(id, domain.PermAnyUser)
+}
+
+// canDecideFor is canActFor for writes recorded under userID's name, such as
+// review decisions, acknowledgements, comments and watches: shared
+// credentials without a user need user:any as well.
+func (h *Handlers) canDecideFor(r *http.Request, userID string) bool {
+	id := IdentityFrom(r.Context())
+	return id.UserID != "" && id.UserID == userID || h.Auth.Allowed(id, domain.PermAnyUser)
+}
+
+// teamAllowed reports whether a team-scoped caller covers team.
+func teamAllowed(r *http.Request, team string) bool {
+	id := IdentityFrom(r.Context())
+	return len(id.Teams) == 0 || containsString(id.Teams, team)
+}
+
+// scopeTeam, scopeUser and scopePR write 403 and return false when the
+// caller's team scope doesn't cover the target. Missing entities are left
+// to the handler so they still produce the usual 404; other lookup failures
+// are written as errors rather than let through.
+func (h *Handlers) scopeTeam(w http.ResponseWriter, r *http.Request, team string) bool {
+	if teamAllowed(r, team) {
+		return true
+	}
+	writeCode(w, domain.ErrForbidden, "outside of token team scope")
+	return false
+}
+
+func (h *Handlers) scopeUser(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if len(IdentityFrom(r.Context()).Teams) == 0 {
+		return true
+	}
+	team, err := h.Svc.UserTeam(r.Context(), userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return true
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return false
+	}
+	return h.scopeTeam(w, r, team)
+}
+
+func (h *Handlers) scopePR(w http.ResponseWriter, r *http.Request, prID string) bool {
+	if len(IdentityFrom(r.Context()).Teams) == 0 {
+		return true
+	}
+	team, err := h.Svc.PRTeam(r.Context(), prID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return true
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return false
+	}
+	return h.scopeTeam(w, r, team)
+}
+
 func (h *Handlers) handleUsersBulkDeactivate(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		TeamName string   `json:"team_name"`
 		UserIDs  []string `json:"user_ids"`
+		DryRun   bool     `json:"dry_run"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	v.count("user_ids", len(req.UserIDs), maxBatch)
+	if len(req.UserIDs) <= maxBatch {
+		for i, id := range req.UserIDs {
+			v.id("user_ids["+strconv.Itoa(i)+"]", id)
+		}
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, 400, string(domain.ErrNotFound), "invalid json")
+	if !v.ok(w) {
 		return
 	}
-	if req.TeamName == "" || len(req.UserIDs) == 0 {
-		writeError(w, 400, string(domain.ErrNotFound), "team_name and user_ids are required")
+	if !h.scopeTeam(w, r, req.TeamName) {
 		return
 	}
-	res, err := h.Svc.BulkDeactivateAndReassign(req.TeamName, req.UserIDs)
+	deactivate := h.Svc.BulkDeactivateAndReassign
+	if req.DryRun {
+		deactivate = h.Svc.PlanBulkDeactivation
+	}
+	res, err := deactivate(r.Context(), req.TeamName, req.UserIDs)
 	if err != nil {
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
 	_ = json.NewEncoder(w).Encode(res)
@@ -141,57 +604,105 @@ func (h *Handlers) handleUsersBulkDeactivate(w http.ResponseWriter, r *http.Requ
 
 func (h *Handlers) handlePRCreate(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID       string `json:"pull_request_id"`
-		Name     string `json:"pull_request_name"`
-		AuthorID string `json:"author_id"`
+		ID         string   `json:"pull_request_id"`
+		Name       string   `json:"pull_request_name"`
+		AuthorID   string   `json:"author_id"`
+		Repository string   `json:"repository"`
+		Preferred  []string `json:"preferred_reviewers"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, 400, string(domain.ErrNotFound), "invalid json")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	pr, err := h.Svc.CreatePR(req.ID, req.Name, req.AuthorID)
-	if err != nil {
-		code, msg := domain.ParseErrorCode(err)
-		if code == domain.ErrPRExists {
-			writeError(w, 409, string(code), msg)
-			return
-		}
-		if code == domain.ErrNotFound {
-			writeError(w, 404, string(code), msg)
-			return
+	var v validator
+	v.optionalID("pull_request_id", req.ID)
+	v.name("pull_request_name", req.Name, true)
+	v.id("author_id", req.AuthorID)
+	v.name("repository", req.Repository, false)
+	if len(req.Preferred) > domain.MaxPreferredReviewers {
+		v.add("preferred_reviewers", "must have at most "+strconv.Itoa(domain.MaxPreferredReviewers)+" items")
+	} else {
+		for i, id := range req.Preferred {
+			v.id("preferred_reviewers["+strconv.Itoa(i)+"]", id)
 		}
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+	}
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeUser(w, r, req.AuthorID) {
+		return
+	}
+	pr, rejected, err := h.Svc.CreatePRPreferring(r.Context(), req.ID, req.Name, req.AuthorID, req.Repository, req.Preferred)
+	if err != nil {
+		writeDomainError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+	out := map[string]any{"pr": pr}
+	if len(rejected) > 0 {
+		out["rejected_reviewers"] = rejected
+	}
+	if team, err := h.Svc.UserTeam(r.Context(), req.AuthorID); err == nil {
+		if warning, err := h.Svc.TeamCapacity(r.Context(), team); err == nil && warning != nil {
+			out["capacity_warning"] = warning
+		}
+	}
+	_ = json.NewEncoder(w).Encode(out)
 }
 
 func (h *Handlers) handlePRMerge(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ID string `json:"pull_request_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, 400, string(domain.ErrNotFound), "invalid json")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("pull_request_id", req.ID)
+	if !v.ok(w) {
 		return
 	}
-	pr, err := h.Svc.MergePR(req.ID)
+	if !h.scopePR(w, r, req.ID) {
+		return
+	}
+	pr, err := h.Svc.MergePRIfMatch(r.Context(), req.ID, r.Header.Get("If-Match"))
 	if err != nil {
-		code, msg := domain.ParseErrorCode(err)
-		if code == domain.ErrNotFound {
-			writeError(w, 404, string(code), msg)
-			return
-		}
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
+	w.Header().Set("ETag", pr.ETag())
 	_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
 }
 
+// handlePRStatus closes or reopens a PR with change.
+func (h *Handlers) handlePRStatus(change func(s *domain.Service, ctx context.Context, prID, ifMatch string) (*domain.PullRequest, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID string `json:"pull_request_id"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var v validator
+		v.id("pull_request_id", req.ID)
+		if !v.ok(w) {
+			return
+		}
+		if !h.scopePR(w, r, req.ID) {
+			return
+		}
+		pr, err := change(h.Svc, r.Context(), req.ID, r.Header.Get("If-Match"))
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		w.Header().Set("ETag", pr.ETag())
+		_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+	}
+}
+
 func (h *Handlers) handlePRReassign(w http.ResponseWriter, r *http.Request) {
 	var raw map[string]any
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		writeError(w, 400, string(domain.ErrNotFound), "invalid json")
+	if !decodeJSON(w, r, &raw) {
 		return
 	}
 	prID, _ := raw["pull_request_id"].(string)
@@ -199,30 +710,209 @@ func (h *Handlers) handlePRReassign(w http.ResponseWriter, r *http.Request) {
 	if old == "" {
 		old, _ = raw["old_reviewer_id"].(string)
 	}
-	pr, replacedBy, err := h.Svc.Reassign(prID, old)
+	var v validator
+	v.id("pull_request_id", prID)
+	v.id("old_user_id", old)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, prID) {
+		return
+	}
+	pr, replacedBy, err := h.Svc.ReassignIfMatch(r.Context(), prID, old, r.Header.Get("If-Match"))
 	if err != nil {
-		code, msg := domain.ParseErrorCode(err)
-		switch code {
-		case domain.ErrPRMerged, domain.ErrNotAssigned, domain.ErrNoCandidate:
-			writeError(w, 409, string(code), msg)
-		case domain.ErrNotFound:
-			writeError(w, 404, string(code), msg)
-		default:
-			writeError(w, 500, string(domain.ErrNotFound), err.Error())
-		}
+		writeDomainError(w, err)
 		return
 	}
+	w.Header().Set("ETag", pr.ETag())
 	_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr, "replaced_by": replacedBy})
 }
 
+// handlePRAddReviewer attaches a reviewer by hand, mainly to PRs of teams
+// with manual assignment.
+func (h *Handlers) handlePRAddReviewer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PRID   string `json:"pull_request_id"`
+		UserID string `json:"user_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("pull_request_id", req.PRID)
+	v.id("user_id", req.UserID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, req.PRID) {
+		return
+	}
+	pr, err := h.Svc.AddReviewer(r.Context(), req.PRID, req.UserID, r.Header.Get("If-Match"))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.Header().Set("ETag", pr.ETag())
+	_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+}
+
+// handlePRTransferAuthor hands a PR over to a new author; both the PR and
+// the new author must be within a team-scoped caller's teams.
+func (h *Handlers) handlePRTransferAuthor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PRID     string `json:"pull_request_id"`
+		AuthorID string `json:"author_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("pull_request_id", req.PRID)
+	v.id("author_id", req.AuthorID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, req.PRID) || !h.scopeUser(w, r, req.AuthorID) {
+		return
+	}
+	pr, err := h.Svc.TransferAuthor(r.Context(), req.PRID, req.AuthorID, r.Header.Get("If-Match"))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.Header().Set("ETag", pr.ETag())
+	_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+}
+
+// handlePRDependency adds or removes a blocked_by link with change; both
+// PRs must be within a team-scoped caller's teams.
+func (h *Handlers) handlePRDependency(change func(s *domain.Service, ctx context.Context, prID, blockedBy, ifMatch string) (*domain.PullRequest, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PRID      string `json:"pull_request_id"`
+			BlockedBy string `json:"blocked_by"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var v validator
+		v.id("pull_request_id", req.PRID)
+		v.id("blocked_by", req.BlockedBy)
+		if !v.ok(w) {
+			return
+		}
+		if !h.scopePR(w, r, req.PRID) || !h.scopePR(w, r, req.BlockedBy) {
+			return
+		}
+		pr, err := change(h.Svc, r.Context(), req.PRID, req.BlockedBy, r.Header.Get("If-Match"))
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		w.Header().Set("ETag", pr.ETag())
+		_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+	}
+}
+
+// handlePRGet serves a PR with its ETag, which merge and reassign accept in
+// If-Match to refuse changing a PR the client has an outdated view of.
+func (h *Handlers) handlePRGet(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	var v validator
+	v.id("pull_request_id", prID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, prID) {
+		return
+	}
+	pr, err := h.Svc.GetPR(r.Context(), prID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	etag := pr.ETag()
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && domain.MatchesETag(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+}
+
+func (h *Handlers) handlePRTimeline(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	var v validator
+	v.id("pull_request_id", prID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, prID) {
+		return
+	}
+	tl, err := h.Svc.PRTimeline(r.Context(), prID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(tl)
+}
+
+func (h *Handlers) handlePRActivity(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	var v validator
+	v.id("pull_request_id", prID)
+	page := v.page(r.URL.Query())
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, prID) {
+		return
+	}
+	a, err := h.Svc.PRActivity(r.Context(), prID, page)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(a)
+}
+
 func (h *Handlers) handleStatsAssignments(w http.ResponseWriter, r *http.Request) {
-	group := r.URL.Query().Get("group_by")
+	q := r.URL.Query()
+	group := q.Get("group_by")
 	if group == "" {
 		group = "all"
 	}
-	stats, err := h.Svc.StatsAssignments(group)
+	aq := domain.AssignmentQuery{Teams: IdentityFrom(r.Context()).Teams, Sort: q.Get("sort"), Cursor: q.Get("cursor")}
+	if team := q.Get("team_name"); team != "" {
+		if !h.scopeTeam(w, r, team) {
+			return
+		}
+		aq.Teams = []string{team}
+	}
+	for _, v := range q["user_ids"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				aq.UserIDs = append(aq.UserIDs, id)
+			}
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &aq.Limit}, {"offset", &aq.Offset}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeCode(w, domain.ErrInvalid, p.name+" must be a number")
+				return
+			}
+			*p.dst = n
+		}
+	}
+	stats, err := h.Svc.StatsAssignments(r.Context(), group, aq)
 	if err != nil {
-		writeError(w, 500, string(domain.ErrNotFound), err.Error())
+		writeDomainError(w, err)
 		return
 	}
 	_ = json.NewEncoder(w).Encode(stats)
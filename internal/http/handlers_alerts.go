@@ -0,0 +1,248 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	domain "prsrv/internal/domain"
+)
+
+func (h *Handlers) handleTeamSetLead(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName string `json:"team_name"`
+		UserID   string `json:"user_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	v.optionalID("user_id", req.UserID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	if err := h.Svc.SetTeamLead(r.Context(), req.TeamName, req.UserID); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	team, err := h.Svc.GetTeam(r.Context(), req.TeamName)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
+}
+
+func (h *Handlers) handleTeamSetManualAssignment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName         string `json:"team_name"`
+		ManualAssignment bool   `json:"manual_assignment"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	if err := h.Svc.SetTeamManualAssignment(r.Context(), req.TeamName, req.ManualAssignment); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	team, err := h.Svc.GetTeam(r.Context(), req.TeamName)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
+}
+
+func (h *Handlers) handleTeamSetMergeRules(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName string `json:"team_name"`
+		domain.MergeRules
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	if err := h.Svc.SetTeamMergeRules(r.Context(), req.TeamName, req.MergeRules); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	team, err := h.Svc.GetTeam(r.Context(), req.TeamName)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
+}
+
+func (h *Handlers) handleTeamSetEscalation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName string `json:"team_name"`
+		domain.EscalationPolicy
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	if err := h.Svc.SetTeamEscalation(r.Context(), req.TeamName, req.EscalationPolicy); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	team, err := h.Svc.GetTeam(r.Context(), req.TeamName)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
+}
+
+func (h *Handlers) handleTeamSetWorkingHours(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName string `json:"team_name"`
+		domain.WorkingHours
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	if err := h.Svc.SetTeamWorkingHours(r.Context(), req.TeamName, req.WorkingHours); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	team, err := h.Svc.GetTeam(r.Context(), req.TeamName)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team": team})
+}
+
+func (h *Handlers) handleTeamSetHolidays(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName string           `json:"team_name"`
+		Holidays []domain.Holiday `json:"holidays"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("team_name", req.TeamName)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, req.TeamName) {
+		return
+	}
+	if err := h.Svc.SetTeamHolidays(r.Context(), req.TeamName, req.Holidays); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	h.writeTeamHolidays(w, r, req.TeamName)
+}
+
+func (h *Handlers) handleTeamHolidays(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("team_name")
+	var v validator
+	v.id("team_name", name)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeTeam(w, r, name) {
+		return
+	}
+	h.writeTeamHolidays(w, r, name)
+}
+
+func (h *Handlers) writeTeamHolidays(w http.ResponseWriter, r *http.Request, team string) {
+	holidays, err := h.Svc.TeamHolidays(r.Context(), team)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"team_name": team, "holidays": holidays})
+}
+
+func (h *Handlers) handleOverloadAlerts(w http.ResponseWriter, r *http.Request) {
+	teams := IdentityFrom(r.Context()).Teams
+	if name := r.URL.Query().Get("team_name"); name != "" {
+		if !h.scopeTeam(w, r, name) {
+			return
+		}
+		teams = []string{name}
+	}
+	alerts, err := h.Svc.ListOverloadAlerts(r.Context(), teams, r.URL.Query().Get("include_resolved") == "true")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"alerts": alerts})
+}
+
+// handleOverloadCheck runs the overload check now instead of waiting for the
+// next scheduled one.
+func (h *Handlers) handleOverloadCheck(w http.ResponseWriter, r *http.Request) {
+	opened, err := h.Svc.CheckOverload(r.Context(), h.Overload)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if h.AlertNotify != nil {
+		for _, a := range opened {
+			h.AlertNotify(a)
+		}
+	}
+	if opened == nil {
+		opened = []domain.OverloadAlert{}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"opened": opened})
+}
+
+// handleEscalationsRun takes the due escalation steps now instead of waiting
+// for the next scheduled run.
+func (h *Handlers) handleEscalationsRun(w http.ResponseWriter, r *http.Request) {
+	taken, err := h.Svc.RunEscalations(r.Context())
+	if h.EscalationNotify != nil {
+		for _, e := range taken {
+			h.EscalationNotify(e)
+		}
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if taken == nil {
+		taken = []domain.Escalation{}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"escalations": taken})
+}
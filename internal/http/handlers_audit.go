@@ -0,0 +1,46 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+func (h *Handlers) handleAuthEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := domain.AuthEventFilter{
+		Outcome: q.Get("outcome"),
+		TokenID: q.Get("token_id"),
+		UserID:  q.Get("user_id"),
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeCode(w, domain.ErrInvalid, p.name+" must be RFC3339")
+				return
+			}
+			*p.dst = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeCode(w, domain.ErrInvalid, "limit must be a number")
+			return
+		}
+		f.Limit = n
+	}
+	events, err := h.Svc.ListAuthEvents(r.Context(), f)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"events": events})
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	domain "prsrv/internal/domain"
+)
+
+// handlePRComment leaves a comment on a PR; author_id defaults to the
+// caller.
+func (h *Handlers) handlePRComment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PRID     string `json:"pull_request_id"`
+		AuthorID string `json:"author_id"`
+		Text     string `json:"text"`
+		ReplyTo  *int64 `json:"reply_to"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.AuthorID == "" {
+		req.AuthorID = IdentityFrom(r.Context()).UserID
+	}
+	var v validator
+	v.id("pull_request_id", req.PRID)
+	v.id("author_id", req.AuthorID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.canDecideFor(r, req.AuthorID) {
+		writeCode(w, domain.ErrForbidden, "cannot act for another user")
+		return
+	}
+	if !h.scopePR(w, r, req.PRID) {
+		return
+	}
+	c, err := h.Svc.AddComment(r.Context(), req.PRID, req.AuthorID, req.Text, req.ReplyTo)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"comment": c})
+}
+
+func (h *Handlers) handlePRComments(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	var v validator
+	v.id("pull_request_id", prID)
+	page := v.page(r.URL.Query())
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopePR(w, r, prID) {
+		return
+	}
+	comments, info, err := h.Svc.ListComments(r.Context(), prID, page)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"pull_request_id": prID, "comments": comments, "page": info})
+}
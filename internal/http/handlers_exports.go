@@ -0,0 +1,64 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	domain "prsrv/internal/domain"
+)
+
+const exportDownloadPath = "/exports/download"
+
+func (h *Handlers) handleExportCreate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind string `json:"kind"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	id := IdentityFrom(r.Context())
+	e, err := h.Svc.StartExport(r.Context(), req.Kind, id.Teams, id.Key())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]any{"export": e})
+}
+
+// handleExportGet reports the export status and, once it is ready, a signed
+// download link that can be passed on to people without a token.
+func (h *Handlers) handleExportGet(w http.ResponseWriter, r *http.Request) {
+	e, err := h.Svc.GetExport(r.Context(), r.URL.Query().Get("export_id"))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	resp := map[string]any{"export": e}
+	if e.Status == domain.ExportReady && h.Exports != nil {
+		link, exp := h.Exports.Sign(exportDownloadPath, e.ID)
+		resp["download_url"] = currentPrefix + link
+		resp["download_expires_at"] = exp
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handlers) handleExportDownload(w http.ResponseWriter, r *http.Request) {
+	if h.Exports == nil {
+		writeCode(w, domain.ErrNotFound, "exports are disabled")
+		return
+	}
+	id, ok := h.Exports.Verify(exportDownloadPath, r.URL.Query())
+	if !ok {
+		writeCode(w, domain.ErrForbidden, "invalid or expired link")
+		return
+	}
+	content, err := h.Svc.ExportContent(r.Context(), id)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.csv"`)
+	_, _ = w.Write(content)
+}
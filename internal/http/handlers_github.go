@@ -0,0 +1,137 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	domain "prsrv/internal/domain"
+	"prsrv/internal/importer"
+)
+
+// githubSource is the WEBHOOK_SECRETS source GitHub deliveries are
+// verified with.
+const githubSource = "github"
+
+// Actions of a GitHub webhook delivery.
+const (
+	githubCreated  = "created"
+	githubMerged   = "merged"
+	githubClosed   = "closed"
+	githubReopened = "reopened"
+	githubIgnored  = "ignored" // other events, or a PR that already exists
+)
+
+// githubPullRequestEvent is the part of a pull_request delivery the
+// receiver reads; see
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads#pull_request.
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title  string `json:"title"`
+		Merged bool   `json:"merged"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// githubWebhookResult tells what a delivery did to the PR, if anything.
+type githubWebhookResult struct {
+	Action string              `json:"action"`
+	PR     *domain.PullRequest `json:"pr,omitempty"`
+}
+
+// handleGitHubWebhook applies pull_request deliveries: opened creates the
+// PR with the author mapped from their GitHub login, closed records the
+// merge GitHub already made, skipping the team's merge rules, or closes
+// the PR when GitHub did not merge it, and reopened reopens it. PR ids are
+// those of the importer, so imported PRs are picked up. Other events are
+// acknowledged and ignored.
+func (h *Handlers) handleGitHubWebhook(w http.ResponseWriter, r *http.Request, body []byte) {
+	if r.Header.Get("X-GitHub-Event") != "pull_request" {
+		_ = json.NewEncoder(w).Encode(githubWebhookResult{Action: githubIgnored})
+		return
+	}
+	var ev githubPullRequestEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		writeCode(w, domain.ErrInvalid, "invalid json")
+		return
+	}
+	prID := importer.PRID(ev.Repository.FullName, ev.Number)
+	var v validator
+	if ev.Repository.FullName == "" || ev.Number <= 0 {
+		v.add("repository.full_name", "and number are required")
+	} else {
+		v.id("pull_request_id", prID)
+	}
+	if ev.Action == "opened" {
+		v.name("pull_request.title", ev.PullRequest.Title, true)
+		v.id("pull_request.user.login", ev.PullRequest.User.Login)
+	}
+	if !v.ok(w) {
+		return
+	}
+
+	ctx := r.Context()
+	res := githubWebhookResult{}
+	var err error
+	switch {
+	case ev.Action == "opened":
+		var author string
+		if author, err = h.Svc.GitHubUser(ctx, ev.PullRequest.User.Login); err != nil {
+			break
+		}
+		res.Action = githubCreated
+		res.PR, err = h.Svc.CreatePR(ctx, prID, ev.PullRequest.Title, author, ev.Repository.FullName)
+		if errors.Is(err, domain.ErrPRExists) {
+			// GitHub redelivers events; the first delivery created it.
+			res.Action = githubIgnored
+			res.PR, err = h.Svc.GetPR(ctx, prID)
+		}
+	case ev.Action == "closed" && ev.PullRequest.Merged:
+		res.Action = githubMerged
+		res.PR, err = h.Svc.RecordExternalMerge(ctx, prID)
+	case ev.Action == "closed":
+		res.Action = githubClosed
+		res.PR, err = h.Svc.ClosePR(ctx, prID, "")
+	case ev.Action == "reopened":
+		res.Action = githubReopened
+		res.PR, err = h.Svc.ReopenPR(ctx, prID, "")
+	default:
+		res.Action = githubIgnored
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// handleGitHubSetLogin maps a GitHub login to a user for the webhook; an
+// empty login removes the mapping.
+func (h *Handlers) handleGitHubSetLogin(w http.ResponseWriter, r *http.Request) {
+	var req domain.GitHubLogin
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("user_id", req.UserID)
+	v.optionalID("github_login", req.Login)
+	if !v.ok(w) {
+		return
+	}
+	if !h.scopeUser(w, r, req.UserID) {
+		return
+	}
+	m, err := h.Svc.SetGitHubLogin(r.Context(), req.UserID, req.Login)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	domain "prsrv/internal/domain"
+)
+
+func (h *Handlers) handlePRAcknowledge(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PRID   string `json:"pull_request_id"`
+		UserID string `json:"user_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.UserID == "" {
+		req.UserID = IdentityFrom(r.Context()).UserID
+	}
+	var v validator
+	v.id("pull_request_id", req.PRID)
+	v.id("user_id", req.UserID)
+	if !v.ok(w) {
+		return
+	}
+	if !h.canDecideFor(r, req.UserID) {
+		writeCode(w, domain.ErrForbidden, "cannot act for another user")
+		return
+	}
+	if !h.scopePR(w, r, req.PRID) {
+		return
+	}
+	at, err := h.Svc.AcknowledgeReview(r.Context(), req.PRID, req.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"pull_request_id": req.PRID,
+		"user_id":         req.UserID,
+		"acknowledged_at": at,
+	})
+}
+
+// handlePRForUser applies change for a user on a PR, such as watching it or
+// approving it, when actFor lets the caller act for them; user_id defaults
+// to the caller.
+func (h *Handlers) handlePRForUser(change func(s *domain.Service, ctx context.Context, prID, userID string) (*domain.PullRequest, error), actFor func(*http.Request, string) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PRID   string `json:"pull_request_id"`
+			UserID string `json:"user_id"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.UserID == "" {
+			req.UserID = IdentityFrom(r.Context()).UserID
+		}
+		var v validator
+		v.id("pull_request_id", req.PRID)
+		v.id("user_id", req.UserID)
+		if !v.ok(w) {
+			return
+		}
+		if !actFor(r, req.UserID) {
+			writeCode(w, domain.ErrForbidden, "cannot act for another user")
+			return
+		}
+		if !h.scopePR(w, r, req.PRID) {
+			return
+		}
+		pr, err := change(h.Svc, r.Context(), req.PRID, req.UserID)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"pr": pr})
+	}
+}
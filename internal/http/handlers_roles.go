@@ -0,0 +1,33 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	domain "prsrv/internal/domain"
+)
+
+func (h *Handlers) handleRoleList(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.Svc.ListRoles(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"roles": roles, "known_permissions": domain.KnownPermissions})
+}
+
+func (h *Handlers) handleRoleSet(w http.ResponseWriter, r *http.Request) {
+	var req domain.RolePermissions
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	rp, err := h.Svc.SetRolePermissions(r.Context(), req.Role, req.Permissions)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if h.Auth.Permissions != nil {
+		h.Auth.Permissions.Invalidate()
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"role": rp})
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+// handleSearch backs the UI's omnibox. Team-scoped callers only find their
+// teams, and callers without pr:read
+// find no PRs.
+func (h *Handlers) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id := IdentityFrom(r.Context())
+	sq := domain.SearchQuery{Text: q.Get("q"), Teams: id.Teams}
+	if types := q.Get("types"); types != "" {
+		sq.Types = strings.Split(types, ",")
+	}
+	var v validator
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			v.add("limit", "must be a positive integer")
+		}
+		sq.Limit = n
+	}
+	if !v.ok(w) {
+		return
+	}
+	if !h.Auth.Allowed(id, domain.PermPRRead) {
+		if containsString(sq.Types, domain.SearchPR) {
+			writeCode(w, domain.ErrForbidden, "searching pull requests requires pr:read")
+			return
+		}
+		if len(sq.Types) == 0 {
+			sq.Types = []string{domain.SearchTeam, domain.SearchUser}
+		}
+	}
+	results, err := h.Svc.Search(r.Context(), sq)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"query": strings.TrimSpace(sq.Text), "results": results})
+}
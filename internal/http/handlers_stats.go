@@ -0,0 +1,361 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+func (h *Handlers) handleStatsTimeToFirstApproval(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Svc.TimeToFirstApproval(r.Context(), IdentityFrom(r.Context()).Teams)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+func (h *Handlers) handleStatsMergeTime(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since, err := timeParam(q, "since")
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	until, err := timeParam(q, "until")
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	stats, err := h.Svc.MergeTime(r.Context(), IdentityFrom(r.Context()).Teams, since, until, q.Get("group_by") == "repository")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+func (h *Handlers) handleStatsSLABreaches(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := domain.SLAFilter{SLA: h.SLA, Teams: IdentityFrom(r.Context()).Teams}
+	var err error
+	if f.Since, err = timeParam(q, "since"); err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	if f.Until, err = timeParam(q, "until"); err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Duration
+	}{{"review_sla", &f.Review}, {"merge_sla", &f.Merge}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.dst, err = time.ParseDuration(v); err != nil {
+				writeCode(w, domain.ErrInvalid, p.name+" must be a duration like 24h")
+				return
+			}
+		}
+	}
+	if v := q.Get("worst"); v != "" {
+		if f.Worst, err = strconv.Atoi(v); err != nil {
+			writeCode(w, domain.ErrInvalid, "worst must be a number")
+			return
+		}
+	}
+	report, err := h.Svc.SLABreaches(r.Context(), f)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsPRStatus(w http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Query().Get("bucket")
+	if bucket != "" && bucket != "week" {
+		writeCode(w, domain.ErrInvalid, "bucket must be week")
+		return
+	}
+	stats, err := h.Svc.PRStatusStats(r.Context(), IdentityFrom(r.Context()).Teams, bucket == "week")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+func (h *Handlers) handleStatsPRAge(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Svc.PRAge(r.Context(), IdentityFrom(r.Context()).Teams)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// handleStatsTimeseries serves daily counts for from..to (YYYY-MM-DD,
+// inclusive); the default is the last 30 days.
+func (h *Handlers) handleStatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query())
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	days, err := h.Svc.AssignmentTimeseries(r.Context(), IdentityFrom(r.Context()).Teams, from, to)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"days": days})
+}
+
+func (h *Handlers) handleStatsBurndown(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query())
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	days, err := h.Svc.OpenPRBurndown(r.Context(), IdentityFrom(r.Context()).Teams, from, to)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"days": days})
+}
+
+func (h *Handlers) handleStatsReassignments(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query())
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	report, err := h.Svc.ReassignmentChurn(r.Context(), IdentityFrom(r.Context()).Teams, from, to)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsPairings(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query())
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	report, err := h.Svc.PairingStats(r.Context(), IdentityFrom(r.Context()).Teams, from, to)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsSimulate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, err := dateRange(q)
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	report, err := h.Svc.Simulate(r.Context(), IdentityFrom(r.Context()).Teams, q.Get("strategy"), from, to)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsSnapshot(w http.ResponseWriter, r *http.Request) {
+	day := time.Now().UTC()
+	if v := r.URL.Query().Get("date"); v != "" {
+		var err error
+		if day, err = time.Parse(time.DateOnly, v); err != nil {
+			writeCode(w, domain.ErrInvalid, "date must be YYYY-MM-DD")
+			return
+		}
+	}
+	teams := IdentityFrom(r.Context()).Teams
+	if name := r.URL.Query().Get("team_name"); name != "" {
+		if !h.scopeTeam(w, r, name) {
+			return
+		}
+		teams = []string{name}
+	}
+	snap, err := h.Svc.StatsSnapshot(r.Context(), day, teams)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(snap)
+}
+
+func (h *Handlers) handleStatsSnapshotTake(w http.ResponseWriter, r *http.Request) {
+	at, err := h.Svc.TakeStatsSnapshot(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"date": at.Format(time.DateOnly), "taken_at": at})
+}
+
+func (h *Handlers) handleStatsAuthors(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, err := dateRange(q)
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	sla := h.SLA.Merge
+	if v := q.Get("merge_sla"); v != "" {
+		if sla, err = time.ParseDuration(v); err != nil {
+			writeCode(w, domain.ErrInvalid, "merge_sla must be a duration like 72h")
+			return
+		}
+	}
+	report, err := h.Svc.AuthorMergeRates(r.Context(), IdentityFrom(r.Context()).Teams, from, to, sla)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsNoCandidate(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query())
+	if err != nil {
+		writeCode(w, domain.ErrInvalid, err.Error())
+		return
+	}
+	report, err := h.Svc.NoCandidateStats(r.Context(), IdentityFrom(r.Context()).Teams, from, to)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// dateRange parses the from/to (YYYY-MM-DD) parameters of day series; the
+// default is the last 30 days.
+func dateRange(q url.Values) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -29)
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := q.Get(p.name); v != "" {
+			d, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return from, to, errors.New(p.name + " must be YYYY-MM-DD")
+			}
+			*p.dst = d
+		}
+	}
+	return from, to, nil
+}
+
+// timeParam parses an optional RFC3339 query parameter.
+func timeParam(q url.Values, name string) (*time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.New(name + " must be RFC3339")
+	}
+	return &t, nil
+}
+
+func (h *Handlers) handleStatsReviewerResponsiveness(w http.ResponseWriter, r *http.Request) {
+	sla := h.SLA.Response
+	if v := r.URL.Query().Get("response_sla"); v != "" {
+		var err error
+		if sla, err = time.ParseDuration(v); err != nil {
+			writeCode(w, domain.ErrInvalid, "response_sla must be a duration like 4h")
+			return
+		}
+	}
+	report, err := h.Svc.ReviewerResponsiveness(r.Context(), IdentityFrom(r.Context()).Teams, sla)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsIdleReviewers(w http.ResponseWriter, r *http.Request) {
+	days := h.IdleReviewerDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil {
+			writeCode(w, domain.ErrInvalid, "days must be a number")
+			return
+		}
+	}
+	report, err := h.Svc.IdleReviewers(r.Context(), IdentityFrom(r.Context()).Teams, days)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsTeams(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Svc.TeamComparison(r.Context(), IdentityFrom(r.Context()).Teams)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"teams": rows})
+}
+
+func (h *Handlers) handleStatsRebalancing(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Svc.Rebalancing(r.Context(), IdentityFrom(r.Context()).Teams)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) handleStatsRefresh(w http.ResponseWriter, r *http.Request) {
+	at, err := h.Svc.RefreshStats(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"refreshed_at": at})
+}
+
+func (h *Handlers) handleStatsQuery(w http.ResponseWriter, r *http.Request) {
+	var q domain.ReportQuery
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&q); err != nil {
+		writeCode(w, domain.ErrInvalid, "invalid query: "+err.Error())
+		return
+	}
+	if len(q.Filters.Teams) == 0 {
+		q.Filters.Teams = IdentityFrom(r.Context()).Teams
+	}
+	for _, t := range q.Filters.Teams {
+		if !h.scopeTeam(w, r, t) {
+			return
+		}
+	}
+	res, err := h.Svc.QueryReport(r.Context(), q)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
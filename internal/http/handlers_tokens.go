@@ -0,0 +1,128 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+func (h *Handlers) handleTokenIssue(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID     string   `json:"user_id"`
+		Role       string   `json:"role"`
+		Name       string   `json:"name"`
+		Teams      []string `json:"teams"`
+		TTLSeconds int64    `json:"ttl_seconds"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("user_id", req.UserID)
+	if ParseRole(req.Role) == RoleNone {
+		v.add("role", "is required")
+	}
+	v.name("name", req.Name, false)
+	if len(req.Teams) > maxBatch {
+		v.add("teams", "must have at most "+strconv.Itoa(maxBatch)+" items")
+	} else {
+		for i, t := range req.Teams {
+			v.id("teams["+strconv.Itoa(i)+"]", t)
+		}
+	}
+	if req.TTLSeconds < 0 {
+		v.add("ttl_seconds", "must not be negative")
+	}
+	if !v.ok(w) {
+		return
+	}
+	tok, err := h.Svc.IssueToken(r.Context(), req.UserID, string(ParseRole(req.Role)), req.Name, req.Teams, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"token": tok})
+}
+
+func (h *Handlers) handleTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TokenID string `json:"token_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("token_id", req.TokenID)
+	if !v.ok(w) {
+		return
+	}
+	tok, err := h.Svc.RevokeToken(r.Context(), req.TokenID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if h.Auth.Tokens != nil {
+		h.Auth.Tokens.Invalidate(tok.ID)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"token": tok})
+}
+
+func (h *Handlers) handleTokenRotate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TokenID      string `json:"token_id"`
+		TTLSeconds   int64  `json:"ttl_seconds"`
+		GraceSeconds *int64 `json:"grace_seconds"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	grace := int64(3600)
+	if req.GraceSeconds != nil {
+		grace = *req.GraceSeconds
+	}
+	if req.TokenID == "" || req.TTLSeconds < 0 || grace < 0 {
+		writeCode(w, domain.ErrInvalid, "token_id is required, ttl_seconds and grace_seconds must not be negative")
+		return
+	}
+	fresh, old, err := h.Svc.RotateToken(r.Context(), req.TokenID, time.Duration(req.TTLSeconds)*time.Second, time.Duration(grace)*time.Second)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if h.Auth.Tokens != nil {
+		h.Auth.Tokens.Invalidate(old.ID)
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"token": fresh, "previous": old})
+}
+
+func (h *Handlers) handleTokenList(w http.ResponseWriter, r *http.Request) {
+	toks, err := h.Svc.ListTokens(r.Context(), r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"tokens": toks})
+}
+
+func (h *Handlers) handleTokenUsage(w http.ResponseWriter, r *http.Request) {
+	var idleSince *time.Time
+	if v := r.URL.Query().Get("idle_since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeCode(w, domain.ErrInvalid, "idle_since must be RFC3339")
+			return
+		}
+		idleSince = &t
+	}
+	usage, err := h.Svc.ListTokenUsage(r.Context(), idleSince)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"usage": usage})
+}
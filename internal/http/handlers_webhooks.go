@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	domain "prsrv/internal/domain"
+)
+
+// handleWebhookAdd registers an outgoing webhook; the secret deliveries are
+// signed with is only in this response.
+func (h *Handlers) handleWebhookAdd(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	if req.URL == "" {
+		v.add("url", "is required")
+	}
+	if len(req.Events) > maxBatch {
+		v.add("events", "must have at most "+strconv.Itoa(maxBatch)+" items")
+	}
+	if !v.ok(w) {
+		return
+	}
+	wh, err := h.Svc.AddWebhook(r.Context(), req.URL, req.Events)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"webhook": wh})
+}
+
+func (h *Handlers) handleWebhookList(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.Svc.ListWebhooks(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if hooks == nil {
+		hooks = []domain.Webhook{}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"webhooks": hooks})
+}
+
+func (h *Handlers) handleWebhookRemove(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		WebhookID string `json:"webhook_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	v.id("webhook_id", req.WebhookID)
+	if !v.ok(w) {
+		return
+	}
+	if err := h.Svc.RemoveWebhook(r.Context(), req.WebhookID); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"webhook_id": req.WebhookID})
+}
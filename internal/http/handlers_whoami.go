@@ -0,0 +1,25 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleWhoami describes the credential the request was made with.
+func (h *Handlers) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	id := IdentityFrom(r.Context())
+	rp, _ := h.Auth.Permissions.Role(id.Role)
+	teams := id.Teams
+	if teams == nil {
+		teams = []string{} // unrestricted
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"role":        id.Role,
+		"user_id":     id.UserID,
+		"token_id":    id.TokenID,
+		"method":      id.Method,
+		"expires_at":  id.ExpiresAt,
+		"teams":       teams,
+		"permissions": rp.Permissions,
+	})
+}
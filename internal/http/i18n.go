@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+// defaultLanguage is the language handlers write error messages in.
+const defaultLanguage = "en"
+
+// errorMessages translates error responses, by language and code. The code
+// stays as is, so clients can keep matching on it; the English message of
+// the handler moves to "detail", since it is more specific than the catalog
+// entry.
+var errorMessages = map[string]map[domain.ErrorCode]string{
+	"ru": {
+		domain.ErrInvalid:            "Некорректный запрос",
+		domain.ErrTeamExists:         "Команда уже существует",
+		domain.ErrPRExists:           "PR уже существует",
+		domain.ErrPRMerged:           "PR уже смержен",
+		domain.ErrPRClosed:           "PR закрыт",
+		domain.ErrNotAssigned:        "Пользователь не назначен ревьювером этого PR",
+		domain.ErrNoCandidate:        "Нет активного кандидата для замены",
+		domain.ErrMergeBlocked:       "Мерж запрещён правилами команды",
+		domain.ErrNotApproved:        "Недостаточно одобрений ревьюверов",
+		domain.ErrPreconditionFailed: "PR изменился с момента последнего чтения",
+		domain.ErrNotFound:           "Ресурс не найден",
+		domain.ErrUnauthorized:       "Требуется аутентификация",
+		domain.ErrForbidden:          "Доступ запрещён",
+		domain.ErrRateLimited:        "Слишком много запросов",
+		domain.ErrTimeout:            "Превышено время обработки запроса",
+		domain.ErrUnavailable:        "Сервис временно недоступен",
+		domain.ErrInternal:           "Внутренняя ошибка сервера",
+	},
+}
+
+// negotiateLanguage picks the language of the Accept-Language header with
+// the highest weight that has a catalog; regional variants (ru-RU) match
+// their base language.
+func negotiateLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var cs []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if q > 0 && (base == defaultLanguage || errorMessages[base] != nil) {
+			cs = append(cs, candidate{base, q})
+		}
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].q > cs[j].q })
+	if len(cs) == 0 {
+		return defaultLanguage
+	}
+	return cs[0].lang
+}
+
+// localizeErrors translates the message of error responses to the language
+// the client accepts. Successful responses pass through unbuffered.
+func localizeErrors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+		lang := negotiateLanguage(r.Header.Get("Accept-Language"))
+		if lang == defaultLanguage {
+			next(w, r)
+			return
+		}
+		lw := &localizedWriter{ResponseWriter: w, messages: errorMessages[lang]}
+		next(lw, r)
+		if lw.buffering {
+			lw.flush(lang)
+		}
+	}
+}
+
+// localizedWriter holds back responses with an error status so that their
+// message can be rewritten.
+type localizedWriter struct {
+	http.ResponseWriter
+	messages  map[domain.ErrorCode]string
+	status    int
+	buffering bool
+	body      bytes.Buffer
+}
+
+func (w *localizedWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status >= 400 && isJSONResponse(w.Header()) {
+		w.buffering = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *localizedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *localizedWriter) flush(lang string) {
+	body := w.body.Bytes()
+	var resp map[string]json.RawMessage
+	var e map[string]any
+	if json.Unmarshal(body, &resp) == nil && json.Unmarshal(resp["error"], &e) == nil {
+		code, _ := e["code"].(string)
+		// without AUTH_STRICT_STATUS, authentication failures keep the
+		// NOT_FOUND code of the original API
+		if w.status == http.StatusUnauthorized {
+			code = string(domain.ErrUnauthorized)
+		}
+		if msg, ok := w.messages[domain.ErrorCode(code)]; ok {
+			e["detail"], e["message"] = e["message"], msg
+			resp["error"], _ = json.Marshal(e)
+			body, _ = json.Marshal(resp)
+			w.Header().Set("Content-Language", lang)
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
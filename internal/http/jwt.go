@@ -0,0 +1,244 @@
+package http
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const jwtLeeway = 30 * time.Second
+
+var (
+	errJWTMalformed = errors.New("malformed jwt")
+	errJWTSignature = errors.New("invalid jwt signature")
+	errJWTExpired   = errors.New("jwt expired")
+	errJWTClaims    = errors.New("invalid jwt claims")
+)
+
+// JWTVerifier checks HS256/RS256 bearer tokens and extracts identity claims.
+// Role is read from RoleClaim ("admin" or "user"), the user id from "sub".
+type JWTVerifier struct {
+	HMACSecret []byte
+	RSAKey     *rsa.PublicKey
+	Issuer     string
+	Audience   string
+	RoleClaim  string
+	Now        func() time.Time
+}
+
+type JWTClaims struct {
+	Subject   string
+	Role      Role
+	ExpiresAt *time.Time
+	Raw       map[string]any
+}
+
+func NewJWTVerifier(hmacSecret string, rsaPEM []byte, issuer, audience string) (*JWTVerifier, error) {
+	if hmacSecret == "" && len(rsaPEM) == 0 {
+		return nil, nil
+	}
+	v := &JWTVerifier{Issuer: issuer, Audience: audience, RoleClaim: "role"}
+	if hmacSecret != "" {
+		v.HMACSecret = []byte(hmacSecret)
+	}
+	if len(rsaPEM) > 0 {
+		key, err := parseRSAPublicKey(rsaPEM)
+		if err != nil {
+			return nil, err
+		}
+		v.RSAKey = key
+	}
+	return v, nil
+}
+
+func parseRSAPublicKey(b []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("jwt public key: no PEM block")
+	}
+	if pub, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if k, ok := pub.(*rsa.PublicKey); ok {
+			return k, nil
+		}
+		return nil, errors.New("jwt public key: not an RSA key")
+	}
+	if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwt public key: %w", err)
+	}
+	k, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("jwt public key: not an RSA key")
+	}
+	return k, nil
+}
+
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+type jwtToken struct {
+	Alg    string
+	Kid    string
+	Signed string
+	Sig    []byte
+	Claims map[string]any
+}
+
+func parseJWT(token string) (*jwtToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errJWTMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errJWTMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errJWTMalformed
+	}
+	return &jwtToken{Alg: header.Alg, Kid: header.Kid, Signed: parts[0] + "." + parts[1], Sig: sig, Claims: claims}, nil
+}
+
+func (t *jwtToken) verifyHS256(secret []byte) error {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(t.Signed))
+	if !hmac.Equal(t.Sig, mac.Sum(nil)) {
+		return errJWTSignature
+	}
+	return nil
+}
+
+func (t *jwtToken) verifyRS256(key *rsa.PublicKey) error {
+	sum := sha256.Sum256([]byte(t.Signed))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], t.Sig); err != nil {
+		return errJWTSignature
+	}
+	return nil
+}
+
+// checkRegistered validates exp/nbf/iss/aud and returns the expiry, if any.
+func checkRegistered(raw map[string]any, issuer, audience string, now time.Time) (*time.Time, error) {
+	var expiresAt *time.Time
+	if exp, ok := raw["exp"].(float64); ok {
+		t := time.Unix(int64(exp), 0).UTC()
+		if now.After(t.Add(jwtLeeway)) {
+			return nil, errJWTExpired
+		}
+		expiresAt = &t
+	}
+	if nbf, ok := raw["nbf"].(float64); ok {
+		if now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+			return nil, errJWTClaims
+		}
+	}
+	if issuer != "" {
+		if iss, _ := raw["iss"].(string); iss != issuer {
+			return nil, errJWTClaims
+		}
+	}
+	if audience != "" && !audienceContains(raw["aud"], audience) {
+		return nil, errJWTClaims
+	}
+	return expiresAt, nil
+}
+
+func (v *JWTVerifier) Verify(token string) (*JWTClaims, error) {
+	t, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t.Alg == "HS256" && v.HMACSecret != nil:
+		err = t.verifyHS256(v.HMACSecret)
+	case t.Alg == "RS256" && v.RSAKey != nil:
+		err = t.verifyRS256(v.RSAKey)
+	default:
+		err = errJWTSignature
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v.claims(t.Claims)
+}
+
+func (v *JWTVerifier) claims(raw map[string]any) (*JWTClaims, error) {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	exp, err := checkRegistered(raw, v.Issuer, v.Audience, now)
+	if err != nil {
+		return nil, err
+	}
+	out := &JWTClaims{Raw: raw, ExpiresAt: exp}
+	out.Subject, _ = raw["sub"].(string)
+
+	claim := v.RoleClaim
+	if claim == "" {
+		claim = "role"
+	}
+	// With several roles in the claim admin wins, otherwise the first one is used.
+	for _, val := range claimStrings(raw[claim]) {
+		if r := ParseRole(val); r == RoleAdmin || out.Role == RoleNone {
+			out.Role = r
+		}
+	}
+	if out.Role == RoleNone {
+		return nil, errJWTClaims
+	}
+	return out, nil
+}
+
+func claimStrings(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func audienceContains(aud any, want string) bool {
+	for _, s := range claimStrings(aud) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(part string, dst any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
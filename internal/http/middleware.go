@@ -1,23 +1,115 @@
 package http
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
 	"log"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	domain "prsrv/internal/domain"
 )
 
-type Role int
+// Role names a set of permissions stored in the roles table. The built-in
+// roles are admin and user; installations may define more.
+type Role string
 
 const (
-	RoleNone Role = iota
-	RoleUser
-	RoleAdmin
+	RoleNone  Role = ""
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
 )
 
+func ParseRole(s string) Role {
+	return Role(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// Identity is the caller resolved from request credentials.
+type Identity struct {
+	Role      Role
+	UserID    string
+	TokenID   string
+	Method    string
+	ExpiresAt *time.Time
+	// Teams restricts the caller to these teams; empty means unrestricted.
+	Teams []string
+}
+
+// Key identifies the credential for per-caller bookkeeping such as rate limits.
+func (id Identity) Key() string {
+	if id.TokenID != "" {
+		return id.TokenID
+	}
+	return id.Method + ":" + id.UserID
+}
+
+type identityKey struct{}
+
+func IdentityFrom(ctx context.Context) Identity {
+	id, _ := ctx.Value(identityKey{}).(Identity)
+	return id
+}
+
 type Auth struct {
-	AdminToken string
-	UserToken  string
+	Static      []StaticToken
+	JWT         *JWTVerifier
+	OIDC        *OIDCVerifier
+	HMAC        *HMACVerifier
+	Tokens      *TokenCache
+	Permissions *PermissionCache
+	Limiter     *RateLimiter
+
+	// AdminCIDRs, when set, restricts privileged endpoints to these networks.
+	AdminCIDRs     []*net.IPNet
+	TrustedProxies []*net.IPNet
+
+	// ClientCertRole enables mTLS mode: a verified client certificate
+	// authenticates the caller with this role (user_id is the certificate CN),
+	// and privileged endpoints refuse requests without one.
+	ClientCertRole Role
+
+	// Failures blocks sources that keep presenting bad credentials.
+	Failures *FailureTracker
+	// Events receives auth events; nil logs everything except successes.
+	Events func(domain.AuthEvent)
+
+	// Usage counts requests and errors per credential.
+	Usage *UsageTracker
+
+	// StrictStatus answers missing or invalid credentials with 401 UNAUTHORIZED
+	// and WWW-Authenticate, and insufficient permissions with 403 FORBIDDEN.
+	// Off keeps the original 401 NOT_FOUND for both.
+	StrictStatus bool
+}
+
+func (a Auth) emit(r *http.Request, typ, outcome string, id Identity) {
+	e := domain.AuthEvent{
+		Time:    time.Now().UTC(),
+		Type:    typ,
+		Outcome: outcome,
+		TokenID: id.TokenID,
+		UserID:  id.UserID,
+		Role:    string(id.Role),
+		Method:  id.Method,
+		Route:   r.URL.Path,
+	}
+	if ip := ClientIP(r, a.TrustedProxies); ip != nil {
+		e.IP = ip.String()
+	}
+	if a.Events != nil {
+		a.Events(e)
+		return
+	}
+	if outcome != domain.AuthOutcomeSuccess {
+		logSecurityEvent(e)
+	}
 }
 
 func LoggingMiddleware(next http.Handler) http.Handler {
@@ -30,35 +122,293 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 }
 
 func (a Auth) RoleFrom(r *http.Request) Role {
+	return a.Authenticate(r).Role
+}
+
+func (a Auth) Allowed(id Identity, perm domain.Permission) bool {
+	if id.Role == RoleNone {
+		return false
+	}
+	// a team-scoped credential must not be able to mint unscoped ones
+	if len(id.Teams) > 0 && perm == domain.PermAuthAdmin {
+		return false
+	}
+	if perm == domain.PermAuthenticated {
+		return true
+	}
+	return a.Permissions.Allowed(id.Role, perm)
+}
+
+func verifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// Authenticate resolves the caller's credential; failures, including an
+// unavailable token store, yield the empty identity.
+func (a Auth) Authenticate(r *http.Request) Identity {
+	id, _ := a.authenticate(r)
+	return id
+}
+
+// authenticate is Authenticate that also returns why a credential could
+// not be checked, as opposed to being invalid.
+func (a Auth) authenticate(r *http.Request) (Identity, error) {
 	auth := r.Header.Get("Authorization")
-	if strings.HasPrefix(auth, "Bearer ") {
-		t := strings.TrimPrefix(auth, "Bearer ")
-		if t == a.AdminToken && t != "" {
-			return RoleAdmin
+	if !strings.HasPrefix(auth, "Bearer ") {
+		if a.HMAC != nil && r.Header.Get(HeaderSignature) != "" {
+			key, err := a.HMAC.Verify(r)
+			if err != nil {
+				return Identity{}, nil
+			}
+			return Identity{Role: key.Role, UserID: key.ID, TokenID: "hmac-" + key.ID, Method: "hmac"}, nil
+		}
+		if cert := verifiedClientCert(r); cert != nil && a.ClientCertRole != RoleNone {
+			exp := cert.NotAfter.UTC()
+			return Identity{Role: a.ClientCertRole, UserID: cert.Subject.CommonName, TokenID: "cert-" + cert.SerialNumber.Text(16), Method: "mtls", ExpiresAt: &exp}, nil
+		}
+		return Identity{}, nil
+	}
+	t := strings.TrimPrefix(auth, "Bearer ")
+	if a.OIDC != nil && looksLikeJWT(t) && a.OIDC.Handles(t) {
+		c, err := a.OIDC.Verify(t)
+		if err != nil {
+			return Identity{}, nil
+		}
+		return Identity{Role: c.Role, UserID: c.Subject, Method: "oidc", ExpiresAt: c.ExpiresAt}, nil
+	}
+	if a.JWT != nil && looksLikeJWT(t) {
+		c, err := a.JWT.Verify(t)
+		if err != nil {
+			return Identity{}, nil
+		}
+		return Identity{Role: c.Role, UserID: c.Subject, Method: "jwt", ExpiresAt: c.ExpiresAt}, nil
+	}
+	if a.Tokens != nil && strings.HasPrefix(t, domain.TokenPrefix) {
+		tok, ok, err := a.Tokens.Get(r.Context(), t)
+		if !ok {
+			return Identity{}, err
+		}
+		return Identity{Role: Role(tok.Role), UserID: tok.UserID, TokenID: tok.ID, Method: "token", ExpiresAt: tok.ExpiresAt, Teams: tok.Teams}, nil
+	}
+	now := time.Now()
+	sum := sha256.Sum256([]byte(t))
+	for _, st := range a.Static {
+		if st.matches(sum) && st.validAt(now) {
+			return Identity{Role: st.Role, TokenID: st.ID(), Method: "static", ExpiresAt: st.ExpiresAt}, nil
+		}
+	}
+	return Identity{}, nil
+}
+
+// allowMethod answers 405 with an Allow header to requests using another
+// method than the route's (GET routes also take HEAD), and 415 to POST
+// bodies declared as anything but JSON. A missing Content-Type is read as
+// JSON.
+func allowMethod(method string, h http.HandlerFunc) http.HandlerFunc {
+	allow := method
+	if method == http.MethodGet {
+		allow += ", " + http.MethodHead
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method && !(method == http.MethodGet && r.Method == http.MethodHead) {
+			w.Header().Set("Allow", allow)
+			writeError(w, http.StatusMethodNotAllowed, string(domain.ErrInvalid), "use "+method)
+			return
 		}
-		if t == a.UserToken && t != "" {
-			return RoleUser
+		if method == http.MethodPost {
+			if ct := r.Header.Get("Content-Type"); ct != "" {
+				if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" && !isMsgpack(mt) {
+					writeError(w, http.StatusUnsupportedMediaType, string(domain.ErrInvalid), "Content-Type must be application/json or application/msgpack")
+					return
+				}
+			}
 		}
+		h(w, r)
+	}
+}
+
+// jsonContent labels responses as JSON unless the handler sets another
+// Content-Type, which most handlers have no reason to do.
+func jsonContent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		h(w, r)
 	}
-	return RoleNone
 }
 
-func Require(role Role, a Auth, h http.HandlerFunc) http.HandlerFunc {
+func Require(perm domain.Permission, a Auth, h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if role == RoleNone {
+		if perm == domain.PermPublic {
 			h(w, r)
 			return
 		}
-		if a.RoleFrom(r) < role {
-			writeError(w, http.StatusUnauthorized, "NOT_FOUND", "unauthorized")
+		if !a.checkTransport(w, r, perm) {
 			return
 		}
-		h(w, r)
+		id, ok := a.authenticateTracked(w, r)
+		if !ok {
+			return
+		}
+		if !a.Allowed(id, perm) {
+			if id.Role != RoleNone {
+				a.emit(r, "authorization", domain.AuthOutcomeDenied, id)
+			}
+			a.deny(w, r, id)
+			return
+		}
+		a.emit(r, "authentication", domain.AuthOutcomeSuccess, id)
+		if a.Limiter != nil {
+			if ok, wait := a.Limiter.Allow(id.Key(), id.Role); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeCode(w, domain.ErrRateLimited, "rate limit exceeded")
+				return
+			}
+		}
+		r = r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+		if a.Usage == nil {
+			h(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		h(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		a.Usage.Record(id, rec.status)
+	}
+}
+
+// checkTransport enforces network and client-certificate restrictions on
+// privileged endpoints before any credentials are looked at.
+func (a Auth) checkTransport(w http.ResponseWriter, r *http.Request, perm domain.Permission) bool {
+	if !perm.Privileged() {
+		return true
+	}
+	if len(a.AdminCIDRs) > 0 {
+		if ip := ClientIP(r, a.TrustedProxies); ip == nil || !cidrsContain(a.AdminCIDRs, ip) {
+			a.emit(r, "network", domain.AuthOutcomeDenied, Identity{})
+			writeCode(w, domain.ErrForbidden, "client address is not allowed")
+			return false
+		}
 	}
+	if a.ClientCertRole != RoleNone && verifiedClientCert(r) == nil {
+		a.emit(r, "client_certificate", domain.AuthOutcomeDenied, Identity{})
+		writeCode(w, domain.ErrForbidden, "client certificate required")
+		return false
+	}
+	return true
+}
+
+// authenticateTracked resolves the caller while feeding the failure tracker.
+// It returns false when the source is currently blocked.
+func (a Auth) authenticateTracked(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	source := ""
+	if a.Failures != nil {
+		if ip := ClientIP(r, a.TrustedProxies); ip != nil {
+			source = ip.String()
+		}
+		if blocked, wait := a.Failures.Blocked(source); blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeCode(w, domain.ErrRateLimited, "too many failed authentication attempts")
+			return Identity{}, false
+		}
+	}
+	id, err := a.authenticate(r)
+	if err != nil {
+		// An outage of the token store says nothing about the credential,
+		// so it is not a failed attempt.
+		log.Printf("authentication: token lookup: %v", err)
+		writeCode(w, domain.ErrUnavailable, "credential store unavailable")
+		return Identity{}, false
+	}
+	switch {
+	case id.Role == RoleNone:
+		a.emit(r, "authentication", domain.AuthOutcomeFailure, id)
+		if presentedCredentials(r) && a.Failures != nil && a.Failures.Fail(source) {
+			a.emit(r, "source_blocked", domain.AuthOutcomeDenied, id)
+		}
+	case a.Failures != nil:
+		a.Failures.Succeed(source)
+	}
+	return id, true
+}
+
+func (a Auth) deny(w http.ResponseWriter, r *http.Request, id Identity) {
+	switch {
+	case !a.StrictStatus:
+		writeLegacyDenied(w)
+	case id.Role == RoleNone:
+		challenge := `Bearer realm="prsrv"`
+		if presentedCredentials(r) {
+			challenge += `, error="invalid_token"`
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		writeCode(w, domain.ErrUnauthorized, "missing or invalid credentials")
+	default:
+		writeCode(w, domain.ErrForbidden, "insufficient permissions")
+	}
+}
+
+func presentedCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get(HeaderSignature) != ""
 }
 
 func writeError(w http.ResponseWriter, status int, code, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	_, _ = w.Write([]byte(`{"error":{"code":"` + code + `","message":"` + msg + `"}}`))
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": msg}})
+}
+
+// errorStatus is the HTTP status of each domain error code. Handlers report
+// service errors through writeDomainError, so this is the one place that
+// decides it.
+var errorStatus = map[domain.ErrorCode]int{
+	domain.ErrInvalid:            http.StatusBadRequest,
+	domain.ErrTeamExists:         http.StatusBadRequest,
+	domain.ErrPRExists:           http.StatusConflict,
+	domain.ErrPRMerged:           http.StatusConflict,
+	domain.ErrPRClosed:           http.StatusConflict,
+	domain.ErrNotAssigned:        http.StatusConflict,
+	domain.ErrNoCandidate:        http.StatusConflict,
+	domain.ErrMergeBlocked:       http.StatusConflict,
+	domain.ErrNotApproved:        http.StatusConflict,
+	domain.ErrPreconditionFailed: http.StatusPreconditionFailed,
+	domain.ErrNotFound:           http.StatusNotFound,
+	domain.ErrUnauthorized:       http.StatusUnauthorized,
+	domain.ErrForbidden:          http.StatusForbidden,
+	domain.ErrRateLimited:        http.StatusTooManyRequests,
+	domain.ErrTimeout:            http.StatusServiceUnavailable,
+	domain.ErrUnavailable:        http.StatusServiceUnavailable,
+}
+
+// writeDomainError writes err with the status of its domain code; errors
+// without one are internal and answered with 500.
+func writeDomainError(w http.ResponseWriter, err error) {
+	if e, ok := domain.AsError(err); ok {
+		if status, ok := errorStatus[e.Code]; ok {
+			writeError(w, status, string(e.Code), e.Msg)
+			return
+		}
+	}
+	if isTimeout(err) {
+		writeError(w, http.StatusServiceUnavailable, string(domain.ErrTimeout), "database query timed out")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, string(domain.ErrInternal), err.Error())
+}
+
+// writeCode writes an error the handler detected itself, with the status
+// errorStatus gives code.
+func writeCode(w http.ResponseWriter, code domain.ErrorCode, msg string) {
+	writeDomainError(w, domain.NewError(code, msg))
+}
+
+// writeLegacyDenied is the answer to a failed authentication or
+// authorization without AUTH_STRICT_STATUS: 401 with the NOT_FOUND code
+// the first clients were written against.
+func writeLegacyDenied(w http.ResponseWriter) {
+	writeError(w, http.StatusUnauthorized, string(domain.ErrNotFound), "unauthorized")
 }
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	domain "prsrv/internal/domain"
+	"prsrv/internal/msgpack"
+)
+
+const msgpackContentType = "application/msgpack"
+
+// isMsgpack matches the registered type and the older x- name many client
+// libraries still send.
+func isMsgpack(mediaType string) bool {
+	return mediaType == msgpackContentType || mediaType == "application/x-msgpack"
+}
+
+// msgpackRequest converts a MessagePack body to the JSON the handlers read.
+// It runs after authentication, so request signatures cover the bytes the
+// client sent.
+func msgpackRequest(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if !isMsgpack(mt) {
+			next(w, r)
+			return
+		}
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, http.StatusRequestEntityTooLarge, string(domain.ErrInvalid), "body is larger than "+strconv.Itoa(maxBodyBytes)+" bytes")
+			return
+		}
+		body, err := msgpack.ToJSON(raw)
+		if err != nil {
+			writeCode(w, domain.ErrInvalid, "invalid msgpack")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set("Content-Type", "application/json")
+		next(w, r)
+	}
+}
+
+// msgpackResponse answers clients that prefer MessagePack over JSON in
+// Accept with the JSON response of next converted. Other content (CSV,
+// XLSX, HTML) passes unchanged.
+func msgpackResponse(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if !prefersMsgpack(r.Header.Get("Accept")) {
+			next(w, r)
+			return
+		}
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next(rec, r)
+		if !isJSONResponse(rec.header) || rec.body.Len() == 0 {
+			rec.flush(w)
+			return
+		}
+		body, err := msgpack.FromJSON(rec.body.Bytes())
+		if err != nil {
+			rec.flush(w)
+			return
+		}
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", msgpackContentType)
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(body)
+	}
+}
+
+// prefersMsgpack reports whether Accept names MessagePack with a weight at
+// least that of JSON; wildcards alone keep JSON.
+func prefersMsgpack(accept string) bool {
+	if accept == "" {
+		return false
+	}
+	// an explicit application/json weight overrides the wildcards
+	qPack, qJSON, qAny := 0.0, -1.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case isMsgpack(mt):
+			qPack = max(qPack, q)
+		case mt == "application/json":
+			qJSON = max(qJSON, q)
+		case mt == "application/*", mt == "*/*":
+			qAny = max(qAny, q)
+		}
+	}
+	if qJSON < 0 {
+		qJSON = qAny
+	}
+	return qPack > 0 && qPack >= qJSON
+}
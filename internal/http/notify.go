@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// NewAlertNotifier returns a function that POSTs each overload alert as JSON
+// to url, e.g. a chat incoming webhook that reaches the team lead. Delivery
+// failures are logged and not retried.
+func NewAlertNotifier(url string) func(domain.OverloadAlert) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(a domain.OverloadAlert) {
+		postNotification(client, url, "alert notify", map[string]any{"event": "reviewer_overload", "alert": a})
+	}
+}
+
+// NewEscalationNotifier is NewAlertNotifier for the escalation steps taken
+// on stalled PRs.
+func NewEscalationNotifier(url string) func(domain.Escalation) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(e domain.Escalation) {
+		postNotification(client, url, "escalation notify", map[string]any{"event": "review_escalation", "escalation": e})
+	}
+}
+
+// NewDeliveryNotifier is NewAlertNotifier for assignments queued outside
+// working hours, posted when they are delivered to the reviewer.
+func NewDeliveryNotifier(url string) func(domain.DeferredReview) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(d domain.DeferredReview) {
+		postNotification(client, url, "delivery notify", map[string]any{"event": "review_assigned", "assignment": d})
+	}
+}
+
+// NewWatchNotifier is NewAlertNotifier for the events of watched PRs. They
+// happen in requests, so each is posted in the background.
+func NewWatchNotifier(url string) func(domain.WatchNotification) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(n domain.WatchNotification) {
+		go postNotification(client, url, "watch notify", map[string]any{"event": "pr_watch", "notification": n})
+	}
+}
+
+// NewCapacityNotifier is NewAlertNotifier for teams left short of
+// reviewers. They happen in requests, so each is posted in the background.
+func NewCapacityNotifier(url string) func(domain.CapacityWarning) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(w domain.CapacityWarning) {
+		go postNotification(client, url, "capacity notify", map[string]any{"event": "low_reviewer_capacity", "warning": w})
+	}
+}
+
+func postNotification(client *http.Client, url, what string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("%s: %v", what, err)
+		return
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("%s: %v", what, err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("%s: webhook returned %s", what, resp.Status)
+	}
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oidcKeysMaxAge     = time.Hour
+	oidcRefreshBackoff = time.Minute
+)
+
+// OIDCVerifier accepts access tokens issued by an OpenID Connect provider.
+// Signing keys are discovered from the issuer's JWKS and refreshed when an
+// unknown kid shows up. Group membership decides the role, UserClaim the user_id.
+type OIDCVerifier struct {
+	Issuer      string
+	Audience    string
+	UserClaim   string
+	GroupsClaim string
+	AdminGroups []string
+	UserGroups  []string
+	Client      *http.Client
+
+	mu        sync.Mutex
+	jwksURI   string
+	keys      map[string]*rsa.PublicKey
+	fetched   time.Time
+	lastTried time.Time
+}
+
+func NewOIDCVerifier(issuer, audience, userClaim, groupsClaim string, adminGroups, userGroups []string) *OIDCVerifier {
+	if issuer == "" {
+		return nil
+	}
+	if userClaim == "" {
+		userClaim = "preferred_username"
+	}
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	return &OIDCVerifier{
+		Issuer:      strings.TrimSuffix(issuer, "/"),
+		Audience:    audience,
+		UserClaim:   userClaim,
+		GroupsClaim: groupsClaim,
+		AdminGroups: adminGroups,
+		UserGroups:  userGroups,
+		Client:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Handles reports whether the token claims to come from this issuer.
+func (v *OIDCVerifier) Handles(token string) bool {
+	t, err := parseJWT(token)
+	if err != nil {
+		return false
+	}
+	iss, _ := t.Claims["iss"].(string)
+	return strings.TrimSuffix(iss, "/") == v.Issuer
+}
+
+func (v *OIDCVerifier) Verify(token string) (*JWTClaims, error) {
+	t, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	if t.Alg != "RS256" {
+		return nil, errJWTSignature
+	}
+	key, err := v.key(t.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verifyRS256(key); err != nil {
+		return nil, err
+	}
+	if iss, _ := t.Claims["iss"].(string); strings.TrimSuffix(iss, "/") != v.Issuer {
+		return nil, errJWTClaims
+	}
+	exp, err := checkRegistered(t.Claims, "", v.Audience, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	out := &JWTClaims{Raw: t.Claims, ExpiresAt: exp}
+	out.Subject, _ = t.Claims[v.UserClaim].(string)
+	for _, g := range claimStrings(t.Claims[v.GroupsClaim]) {
+		switch {
+		case containsString(v.AdminGroups, g):
+			out.Role = RoleAdmin
+		case containsString(v.UserGroups, g) && out.Role != RoleAdmin:
+			out.Role = RoleUser
+		}
+	}
+	if out.Role == RoleNone {
+		return nil, errJWTClaims
+	}
+	return out, nil
+}
+
+func (v *OIDCVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	k, ok := v.keys[kid]
+	if ok && time.Since(v.fetched) < oidcKeysMaxAge {
+		return k, nil
+	}
+	if time.Since(v.lastTried) > oidcRefreshBackoff {
+		v.lastTried = time.Now()
+		if err := v.refreshLocked(); err != nil && !ok {
+			return nil, err
+		}
+		k, ok = v.keys[kid]
+	}
+	if !ok {
+		return nil, errJWTSignature
+	}
+	return k, nil
+}
+
+func (v *OIDCVerifier) refreshLocked() error {
+	if v.jwksURI == "" {
+		var disc struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(v.Issuer+"/.well-known/openid-configuration", &disc); err != nil {
+			return fmt.Errorf("oidc discovery: %w", err)
+		}
+		if disc.JWKSURI == "" {
+			return errors.New("oidc discovery: jwks_uri missing")
+		}
+		v.jwksURI = disc.JWKSURI
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(v.jwksURI, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	v.keys = keys
+	v.fetched = time.Now()
+	return nil
+}
+
+func (v *OIDCVerifier) getJSON(url string, dst any) error {
+	resp, err := v.Client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package http
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// route is a path mounted through h.handle, recorded for the OpenAPI
+// document.
+type route struct {
+	method string
+	path   string
+	perm   domain.Permission
+}
+
+// handleOpenAPI serves the OpenAPI 3 document of every registered route,
+// built from apiDocs and the Go types the handlers encode.
+func (h *Handlers) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.openAPI())
+}
+
+// handleDocs serves Swagger UI pointed at /openapi.json.
+func (h *Handlers) handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsPage)
+}
+
+func (h *Handlers) openAPI() map[string]any {
+	g := schemaGen{defs: map[string]any{
+		"ErrorResponse": obj(map[string]any{
+			"error": obj(map[string]any{
+				"code":    map[string]any{"type": "string"},
+				"message": map[string]any{"type": "string", "description": "in the Accept-Language language (en, ru)"},
+				"detail":  map[string]any{"type": "string", "description": "the English message, when message is translated"},
+				"fields": map[string]any{
+					"type":        "array",
+					"description": "rejected fields of an INVALID_ARGUMENT error",
+					"items": obj(map[string]any{
+						"field":   map[string]any{"type": "string"},
+						"message": map[string]any{"type": "string"},
+					}, "field", "message"),
+				},
+			}, "code", "message"),
+		}, "error"),
+	}}
+	paths := map[string]any{}
+	for _, rt := range h.routes {
+		d := apiDocs[rt.path]
+		op := map[string]any{
+			"summary":      d.Summary,
+			"x-permission": rt.perm,
+			"responses":    g.responses(d),
+		}
+		if d.Tag != "" {
+			op["tags"] = []string{d.Tag}
+		}
+		if rt.perm == domain.PermPublic {
+			op["security"] = []any{}
+		}
+		params := append([]apiParam(nil), d.Query...)
+		if d.Report {
+			params = append(params, apiParam{Name: "format", Description: "json (default) or xlsx"})
+		}
+		if len(params) > 0 {
+			var ps []any
+			for _, p := range params {
+				ps = append(ps, p.spec())
+			}
+			op["parameters"] = ps
+		}
+		if d.Body != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(d.Body))}},
+			}
+		}
+		if unversioned[rt.path] {
+			op["servers"] = []any{map[string]any{"url": "/"}}
+		}
+		paths[rt.path] = map[string]any{strings.ToLower(rt.method): op}
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "PR Reviewer Assignment Service", "version": "1.0.0"},
+		"servers": []any{map[string]any{"url": currentPrefix}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.defs,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []string{}}},
+	}
+}
+
+func (g *schemaGen) responses(d apiDoc) map[string]any {
+	status := d.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	content := map[string]any{}
+	switch {
+	case d.Produces != "":
+		content[d.Produces] = map[string]any{"schema": map[string]any{"type": "string"}}
+	case d.Response != nil:
+		content["application/json"] = map[string]any{"schema": g.schema(reflect.TypeOf(d.Response))}
+	}
+	if d.Report {
+		content[xlsxContentType] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+	}
+	ok := map[string]any{"description": http.StatusText(status)}
+	if len(content) > 0 {
+		ok["content"] = content
+	}
+	out := map[string]any{
+		strconv.Itoa(status): ok,
+		"default": map[string]any{
+			"description": "error",
+			"content": map[string]any{"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"},
+			}},
+		},
+	}
+	if d.AlsoStatus != 0 {
+		also := map[string]any{"description": http.StatusText(d.AlsoStatus)}
+		if c, ok := ok["content"]; ok {
+			also["content"] = c
+		}
+		out[strconv.Itoa(d.AlsoStatus)] = also
+	}
+	return out
+}
+
+func obj(props map[string]any, required ...string) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// schemaGen derives JSON schemas from Go types the way encoding/json would
+// encode them; named structs go to defs and are referenced by name.
+type schemaGen struct {
+	defs map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if _, ok := s["$ref"]; ok {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // breaks cycles
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	g.fields(t, props, &required)
+	return obj(props, required...)
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// apiDoc describes a route for the OpenAPI document. Body and Response are
+// zero values of the types the handler decodes and encodes.
+type apiDoc struct {
+	Tag        string
+	Summary    string
+	Query      []apiParam
+	Body       any
+	Response   any
+	Status     int    // 200 when zero
+	AlsoStatus int    // another success status with the same response
+	Produces   string // content type of a non-JSON response
+	Report     bool   // wrapped with h.report: accepts format=xlsx
+}
+
+type apiParam struct {
+	In          string // query when empty, or header
+	Name        string
+	Type        string // string, integer, boolean, date, date-time or duration
+	Description string
+	Required    bool
+}
+
+func (p apiParam) spec() map[string]any {
+	s := map[string]any{"type": "string"}
+	switch p.Type {
+	case "integer", "boolean":
+		s["type"] = p.Type
+	case "date", "date-time":
+		s["format"] = p.Type
+	case "duration":
+		s["example"] = "24h"
+	}
+	in := p.In
+	if in == "" {
+		in = "query"
+	}
+	out := map[string]any{"name": p.Name, "in": in, "schema": s}
+	if p.Description != "" {
+		out["description"] = p.Description
+	}
+	if p.Required {
+		out["required"] = true
+	}
+	return out
+}
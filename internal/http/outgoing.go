@@ -0,0 +1,198 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// Headers of outgoing webhook requests. X-Signature is the hex HMAC-SHA256
+// of the body with the webhook's secret, as the hmac inbound source checks.
+const (
+	HeaderWebhookEvent    = "X-Webhook-Event"
+	HeaderWebhookDelivery = "X-Webhook-Delivery"
+	HeaderWebhookSig      = "X-Signature"
+)
+
+const (
+	webhookWorkers   = 4
+	webhookQueueSize = 1024
+)
+
+// webhookDeliveries counts outgoing deliveries by outcome: delivered,
+// retried, failed (given up) and dropped (queue full or shut down); it is
+// served with the other expvars on /debug/vars.
+var webhookDeliveries = expvar.NewMap("webhook_deliveries")
+
+type outgoingDelivery struct {
+	url, secret, event, id string
+	body                   []byte
+	attempt                int
+}
+
+// WebhookDispatcher POSTs deliveries to outgoing webhooks from background
+// goroutines. Failed attempts are retried up to maxAttempts in total, waiting
+// backoff, then twice as long each time; network errors, 429 and 5xx
+// answers are failures, other answers are final.
+type WebhookDispatcher struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	queue       chan outgoingDelivery
+	stop        chan struct{}
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+
+	mu     sync.Mutex
+	timers map[*time.Timer]struct{} // pending retries, nil once closed
+}
+
+func NewWebhookDispatcher(maxAttempts int, backoff time.Duration) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		client:      &http.Client{Timeout: 5 * time.Second},
+		maxAttempts: max(maxAttempts, 1),
+		backoff:     backoff,
+		queue:       make(chan outgoingDelivery, webhookQueueSize),
+		stop:        make(chan struct{}),
+		timers:      make(map[*time.Timer]struct{}),
+	}
+	for range webhookWorkers {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Enqueue queues a delivery without blocking; it is dropped when the queue
+// is full. Pass it to domain.Service.WithWebhookDispatcher.
+func (d *WebhookDispatcher) Enqueue(wd domain.WebhookDelivery) {
+	body, err := json.Marshal(wd.Payload)
+	if err != nil {
+		log.Printf("webhook %s: %v", wd.Webhook.ID, err)
+		return
+	}
+	d.push(outgoingDelivery{
+		url: wd.Webhook.URL, secret: wd.Webhook.Secret, event: wd.Payload.Event, id: wd.Payload.DeliveryID,
+		body: body, attempt: 1,
+	})
+}
+
+func (d *WebhookDispatcher) push(o outgoingDelivery) {
+	// A select picks among ready cases at random, so after Close a free
+	// queue slot could still win over stop.
+	select {
+	case <-d.stop:
+		webhookDeliveries.Add("dropped", 1)
+		return
+	default:
+	}
+	select {
+	case d.queue <- o:
+	default:
+		webhookDeliveries.Add("dropped", 1)
+		log.Printf("webhook delivery %s to %s: queue full, dropped", o.id, o.url)
+	}
+}
+
+// Close stops the workers after their current attempt; queued deliveries
+// and pending retries are dropped.
+func (d *WebhookDispatcher) Close() {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		d.mu.Lock()
+		for t := range d.timers {
+			if t.Stop() {
+				webhookDeliveries.Add("dropped", 1)
+			}
+		}
+		d.timers = nil
+		d.mu.Unlock()
+	})
+	d.wg.Wait()
+}
+
+// retryAfter pushes o again after wait unless the dispatcher is closed by
+// then.
+func (d *WebhookDispatcher) retryAfter(wait time.Duration, o outgoingDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timers == nil {
+		webhookDeliveries.Add("dropped", 1)
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		delete(d.timers, t)
+		d.mu.Unlock()
+		d.push(o)
+	})
+	d.timers[t] = struct{}{}
+}
+
+func (d *WebhookDispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case o := <-d.queue:
+			d.attempt(o)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) attempt(o outgoingDelivery) {
+	retry, err := d.post(o)
+	switch {
+	case err == nil:
+		webhookDeliveries.Add("delivered", 1)
+	case retry && o.attempt < d.maxAttempts:
+		webhookDeliveries.Add("retried", 1)
+		wait := d.backoff << (o.attempt - 1)
+		log.Printf("webhook delivery %s to %s, attempt %d: %v; retrying in %s", o.id, o.url, o.attempt, err, wait)
+		o.attempt++
+		d.retryAfter(wait, o)
+	default:
+		webhookDeliveries.Add("failed", 1)
+		log.Printf("webhook delivery %s to %s, attempt %d: %v; giving up", o.id, o.url, o.attempt, err)
+	}
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (d *WebhookDispatcher) post(o outgoingDelivery) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(o.body))
+	if err != nil {
+		return false, err
+	}
+	mac := hmac.New(sha256.New, []byte(o.secret))
+	mac.Write(o.body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, o.event)
+	req.Header.Set(HeaderWebhookDelivery, o.id)
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(o.attempt))
+	req.Header.Set(HeaderWebhookSig, hex.EncodeToString(mac.Sum(nil)))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, &webhookStatusError{resp.Status}
+}
+
+type webhookStatusError struct{ status string }
+
+func (e *webhookStatusError) Error() string { return "webhook returned " + e.status }
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// PermissionCache keeps the role → permissions table in memory and reloads it
+// after ttl. Until the first successful load the built-in defaults apply.
+type PermissionCache struct {
+	load func() ([]domain.RolePermissions, error)
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	roles   map[Role]domain.RolePermissions
+	fetched time.Time
+}
+
+func NewPermissionCache(load func() ([]domain.RolePermissions, error), ttl time.Duration) *PermissionCache {
+	return &PermissionCache{load: load, ttl: ttl}
+}
+
+func (c *PermissionCache) Allowed(role Role, perm domain.Permission) bool {
+	rp, ok := c.Role(role)
+	return ok && rp.Has(perm)
+}
+
+func (c *PermissionCache) Role(role Role) (domain.RolePermissions, bool) {
+	if c == nil {
+		perms, ok := domain.DefaultRolePermissions[string(role)]
+		return domain.RolePermissions{Role: string(role), Permissions: perms}, ok
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.roles == nil || time.Since(c.fetched) > c.ttl {
+		c.reloadLocked()
+	}
+	rp, ok := c.roles[role]
+	return rp, ok
+}
+
+func (c *PermissionCache) Invalidate() {
+	c.mu.Lock()
+	c.fetched = time.Time{}
+	c.mu.Unlock()
+}
+
+func (c *PermissionCache) reloadLocked() {
+	list, err := c.load()
+	if err != nil {
+		if c.roles == nil {
+			c.roles = make(map[Role]domain.RolePermissions)
+			for role, perms := range domain.DefaultRolePermissions {
+				c.roles[Role(role)] = domain.RolePermissions{Role: role, Permissions: perms}
+			}
+		}
+		// keep serving the previous table and retry on the next request after ttl
+		c.fetched = time.Now()
+		return
+	}
+	roles := make(map[Role]domain.RolePermissions, len(list))
+	for _, rp := range list {
+		roles[Role(rp.Role)] = rp
+	}
+	c.roles = roles
+	c.fetched = time.Now()
+}
+
+// ParseRoutePermissions reads "path=permission" entries separated by commas
+// or newlines. "authenticated" admits any valid credential; routes cannot be
+// made public this way.
+func ParseRoutePermissions(spec string) (map[string]domain.Permission, error) {
+	out := map[string]domain.Permission{}
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		path, perm, ok := strings.Cut(item, "=")
+		path, p := strings.TrimSpace(path), domain.Permission(strings.TrimSpace(perm))
+		if !ok || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("route permission %q: expected /path=permission", item)
+		}
+		if p != domain.PermAuthenticated && !containsPermission(domain.KnownPermissions, p) {
+			return nil, fmt.Errorf("route permission %q: unknown permission", item)
+		}
+		out[path] = p
+	}
+	return out, nil
+}
+
+func containsPermission(list []domain.Permission, p domain.Permission) bool {
+	for _, item := range list {
+		if item == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+const (
+	defaultPollWait = 25 * time.Second // below the Go client's 30s timeout
+	maxPollWait     = 60 * time.Second
+	// pollRecheck is how often a waiting poll reads the state again, to
+	// notice writes made on other replicas, which do not wake it.
+	pollRecheck = time.Second
+)
+
+// changeSignal wakes the long polls waiting on this instance. Every write
+// closes the current channel and starts a new one.
+type changeSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func (s *changeSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+func (s *changeSignal) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// handlePollAssignments holds the request until the user's open review
+// assignments differ from the cursor the client last saw, or the wait runs
+// out. Without a cursor it answers at once, which is how clients get their
+// first one.
+func (h *Handlers) handlePollAssignments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	uid := q.Get("user_id")
+	if uid == "" {
+		uid = IdentityFrom(r.Context()).UserID
+	}
+	cursor := q.Get("cursor")
+	wait := defaultPollWait
+	var v validator
+	v.id("user_id", uid)
+	if s := q.Get("timeout"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || time.Duration(n)*time.Second > maxPollWait {
+			v.add("timeout", "must be between 0 and "+strconv.Itoa(int(maxPollWait/time.Second))+" seconds")
+		}
+		wait = time.Duration(n) * time.Second
+	}
+	if !v.ok(w) {
+		return
+	}
+	if !h.canActFor(r, uid) {
+		writeCode(w, domain.ErrForbidden, "cannot access another user's data")
+		return
+	}
+	if !h.scopeUser(w, r, uid) {
+		return
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	recheck := time.NewTicker(pollRecheck)
+	defer recheck.Stop()
+	for {
+		// subscribe before reading, so a write in between is not missed
+		changed := h.changes.wait()
+		prs, next, err := h.Svc.OpenReviewsState(r.Context(), uid)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		if next != cursor {
+			writePoll(w, uid, true, next, prs)
+			return
+		}
+		select {
+		case <-changed:
+		case <-recheck.C:
+		case <-deadline.C:
+			writePoll(w, uid, false, next, prs)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writePoll(w http.ResponseWriter, uid string, changed bool, cursor string, prs []domain.PullRequestShort) {
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"user_id":       uid,
+		"changed":       changed,
+		"cursor":        cursor,
+		"pull_requests": prs,
+	})
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rateLimiterSweepEvery = 1024
+
+// sharedRetryAfter is how long the limiter stays local after the shared
+// store fails.
+const sharedRetryAfter = 5 * time.Second
+
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// SharedLimiter keeps the buckets outside the process, so that all replicas
+// enforce one limit per credential.
+type SharedLimiter interface {
+	Take(key string, lim RateLimit) (bool, time.Duration, error)
+}
+
+// RateLimiter is an in-memory token bucket per credential. Limits are looked
+// up by the caller's role, falling back to Default; a zero Rate disables limiting.
+// With Shared set the buckets live there instead; while it fails, the
+// in-memory buckets take over, so each replica limits on its own.
+type RateLimiter struct {
+	Limits  map[Role]RateLimit
+	Default RateLimit
+	Shared  SharedLimiter
+
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	calls      int
+	sharedDown time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  RateLimit
+}
+
+func NewRateLimiter(limits map[Role]RateLimit, def RateLimit) *RateLimiter {
+	return &RateLimiter{Limits: limits, Default: def, buckets: make(map[string]*bucket)}
+}
+
+// ParseRateLimits reads "role=rate:burst" pairs separated by commas; the
+// role "*" sets the default, e.g. "admin=50:100,user=10:20,*=5:10".
+func ParseRateLimits(spec string) (map[Role]RateLimit, RateLimit, error) {
+	limits := map[Role]RateLimit{}
+	var def RateLimit
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		role, val, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, def, fmt.Errorf("rate limit %q: expected role=rate:burst", item)
+		}
+		rateStr, burstStr, _ := strings.Cut(val, ":")
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 {
+			return nil, def, fmt.Errorf("rate limit %q: bad rate", item)
+		}
+		burst := int(math.Ceil(rate))
+		if burstStr != "" {
+			if burst, err = strconv.Atoi(burstStr); err != nil || burst < 1 {
+				return nil, def, fmt.Errorf("rate limit %q: bad burst", item)
+			}
+		}
+		lim := RateLimit{Rate: rate, Burst: max(burst, 1)}
+		if role == "*" {
+			def = lim
+		} else {
+			limits[ParseRole(role)] = lim
+		}
+	}
+	return limits, def, nil
+}
+
+// Allow takes one token from key's bucket and otherwise reports how long the
+// caller should wait before retrying.
+func (l *RateLimiter) Allow(key string, role Role) (bool, time.Duration) {
+	lim, ok := l.Limits[role]
+	if !ok {
+		lim = l.Default
+	}
+	if lim.Rate <= 0 {
+		return true, 0
+	}
+	if ok, wait, shared := l.takeShared(key, lim); shared {
+		return ok, wait
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	if l.calls%rateLimiterSweepEvery == 0 {
+		l.sweepLocked(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok || b.limit != lim {
+		b = &bucket{tokens: float64(lim.Burst), last: now, limit: lim}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(lim.Burst), b.tokens+now.Sub(b.last).Seconds()*lim.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / lim.Rate * float64(time.Second))
+	return false, wait
+}
+
+// takeShared takes the token from the shared store; shared is false when
+// there is none or it is down.
+func (l *RateLimiter) takeShared(key string, lim RateLimit) (ok bool, wait time.Duration, shared bool) {
+	if l.Shared == nil {
+		return false, 0, false
+	}
+	l.mu.Lock()
+	down := l.sharedDown
+	l.mu.Unlock()
+	if time.Now().Before(down) {
+		return false, 0, false
+	}
+	ok, wait, err := l.Shared.Take(key, lim)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		if l.sharedDown.IsZero() {
+			log.Printf("rate limiter: shared store failed, limiting locally: %v", err)
+		}
+		l.sharedDown = time.Now().Add(sharedRetryAfter)
+		return false, 0, false
+	}
+	if !l.sharedDown.IsZero() {
+		log.Printf("rate limiter: shared store is back")
+		l.sharedDown = time.Time{}
+	}
+	return ok, wait, true
+}
+
+// sweepLocked drops buckets that have refilled completely; they carry no state.
+func (l *RateLimiter) sweepLocked(now time.Time) {
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"prsrv/internal/redis"
+)
+
+// gcraScript applies the generic cell rate algorithm to the bucket in
+// KEYS[1], which holds the theoretical arrival time of the next request in
+// microseconds of the server clock, so replica clocks do not matter. ARGV are
+// the emission interval in microseconds and the burst. It returns whether
+// the request is allowed and otherwise the wait in microseconds.
+const gcraScript = `
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local new_tat = tat + interval
+local over = new_tat - now - burst * interval
+if over > 0 then return {0, over} end
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, 0}
+`
+
+var gcraSHA = func() string {
+	sum := sha1.Sum([]byte(gcraScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// RedisLimiter is a SharedLimiter on Redis. It keeps one key per credential
+// under Prefix, expiring once the bucket is full again.
+type RedisLimiter struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func NewRedisLimiter(c *redis.Client) *RedisLimiter {
+	return &RedisLimiter{Client: c, Prefix: "prsrv:ratelimit:"}
+}
+
+func (l *RedisLimiter) Take(key string, lim RateLimit) (bool, time.Duration, error) {
+	args := []string{"1", l.Prefix + key,
+		strconv.FormatInt(int64(float64(time.Second/time.Microsecond)/lim.Rate), 10), strconv.Itoa(lim.Burst)}
+	reply, err := l.Client.Do(append([]string{"EVALSHA", gcraSHA}, args...)...)
+	if e, ok := err.(redis.Error); ok && e.Prefix() == "NOSCRIPT" {
+		reply, err = l.Client.Do(append([]string{"EVAL", gcraScript}, args...)...)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return false, 0, fmt.Errorf("rate limiter: unexpected reply %v", reply)
+	}
+	allowed, ok1 := items[0].(int64)
+	wait, ok2 := items[1].(int64)
+	if !ok1 || !ok2 {
+		return false, 0, fmt.Errorf("rate limiter: unexpected reply %v", reply)
+	}
+	return allowed == 1, time.Duration(wait) * time.Microsecond, nil
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// untimedRoutes are left out of the request timeout: the long poll waits
+// up to maxPollWait on purpose.
+var untimedRoutes = map[string]bool{"/users/pollAssignments": true}
+
+// withTimeout answers 503 TIMEOUT when fn takes longer than d. fn runs with
+// a request context that is cancelled at the deadline, so work that honours
+// it stops there; whatever fn writes afterwards is dropped. The response is
+// buffered until fn returns.
+func withTimeout(d time.Duration, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		tw := &timeoutWriter{header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			fn(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			_, _ = w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			log.Printf("%s %s: timed out after %s", r.Method, r.URL.Path, d)
+			writeCode(w, domain.ErrTimeout, "request timed out")
+		}
+	}
+}
+
+// withDeadline runs fn with a request context cancelled after d. Unlike
+// withTimeout it does not answer for fn: fn fails on its own once the
+// database calls it makes see the context end.
+func withDeadline(d time.Duration, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		fn(w, r.WithContext(ctx))
+	}
+}
+
+// isTimeout reports whether err is the end of a request context, as the
+// database driver or the memory repo reports it, or a statement the
+// database cancelled (SQLSTATE 57014, query_canceled).
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	var s interface{ SQLState() string }
+	return errors.As(err, &s) && s.SQLState() == "57014"
+}
+
+// timeoutWriter holds the response of a handler running under withTimeout.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+const tokenCacheMaxEntries = 10000
+
+// TokenCache memoizes database token lookups (including misses) for a short
+// TTL, so authenticating a request doesn't cost a query every time.
+type TokenCache struct {
+	lookup func(context.Context, string) (*domain.APIToken, error)
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]tokenCacheEntry
+}
+
+type tokenCacheEntry struct {
+	token   *domain.APIToken
+	fetched time.Time
+}
+
+func NewTokenCache(lookup func(ctx context.Context, plain string) (*domain.APIToken, error), ttl time.Duration) *TokenCache {
+	return &TokenCache{lookup: lookup, ttl: ttl, entries: make(map[string]tokenCacheEntry)}
+}
+
+// Get returns the valid token for plain. Lookup errors other than a missing
+// token are returned and not cached.
+func (c *TokenCache) Get(ctx context.Context, plain string) (*domain.APIToken, bool, error) {
+	key := domain.HashToken(plain)
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || now.Sub(e.fetched) > c.ttl {
+		t, err := c.lookup(ctx, plain)
+		if err != nil {
+			if !errors.Is(err, domain.ErrNotFound) {
+				return nil, false, err
+			}
+			t = nil
+		}
+		e = tokenCacheEntry{token: t, fetched: now}
+		c.mu.Lock()
+		if len(c.entries) >= tokenCacheMaxEntries {
+			c.evictLocked(now)
+		}
+		c.entries[key] = e
+		c.mu.Unlock()
+	}
+	if e.token == nil || !e.token.Valid(now) {
+		return nil, false, nil
+	}
+	return e.token, true, nil
+}
+
+func (c *TokenCache) Invalidate(tokenID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if e.token != nil && e.token.ID == tokenID {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *TokenCache) evictLocked(now time.Time) {
+	for k, e := range c.entries {
+		if now.Sub(e.fetched) > c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) >= tokenCacheMaxEntries {
+		c.entries = make(map[string]tokenCacheEntry)
+	}
+}
+
+// StaticToken is a bearer token configured through the environment. Only the
+// SHA-256 of the value is kept. Several may be valid at once so clients can be
+// moved to a new value before the old one expires.
+type StaticToken struct {
+	Hash      [sha256.Size]byte
+	Role      Role
+	ExpiresAt *time.Time
+}
+
+func NewStaticToken(value string, role Role) StaticToken {
+	return StaticToken{Hash: sha256.Sum256([]byte(value)), Role: role}
+}
+
+func (t StaticToken) ID() string {
+	return "static-" + hex.EncodeToString(t.Hash[:6])
+}
+
+func (t StaticToken) matches(sum [sha256.Size]byte) bool {
+	return subtle.ConstantTimeCompare(sum[:], t.Hash[:]) == 1
+}
+
+func (t StaticToken) validAt(now time.Time) bool {
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// ParseStaticTokens parses a comma separated list of tokens, each optionally
+// suffixed with "@<RFC3339 expiry>", e.g. "new-secret,old-secret@2025-12-01T00:00:00Z".
+// With hashed set the entries are hex encoded SHA-256 digests of the tokens.
+func ParseStaticTokens(spec string, role Role, hashed bool) ([]StaticToken, error) {
+	var out []StaticToken
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		value := item
+		var expiresAt *time.Time
+		if i := strings.LastIndex(item, "@"); i >= 0 {
+			exp, err := time.Parse(time.RFC3339, item[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("token expiry %q: %w", item[i+1:], err)
+			}
+			exp = exp.UTC()
+			value, expiresAt = item[:i], &exp
+		}
+		if value == "" {
+			return nil, fmt.Errorf("empty %s token", role)
+		}
+		st := NewStaticToken(value, role)
+		if hashed {
+			b, err := hex.DecodeString(value)
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("%s token hash must be 64 hex characters", role)
+			}
+			copy(st.Hash[:], b)
+		}
+		st.ExpiresAt = expiresAt
+		out = append(out, st)
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// UsageTracker counts requests per credential in memory and periodically adds
+// the counters to storage, so tracking costs one map update per request.
+type UsageTracker struct {
+	store func(context.Context, []domain.TokenUsage) error
+
+	mu      sync.Mutex
+	pending map[string]*domain.TokenUsage
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewUsageTracker(store func(context.Context, []domain.TokenUsage) error, interval time.Duration) *UsageTracker {
+	u := &UsageTracker{
+		store:   store,
+		pending: make(map[string]*domain.TokenUsage),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go u.run(interval)
+	return u
+}
+
+// Record counts one request; responses with status >= 400 count as errors.
+func (u *UsageTracker) Record(id Identity, status int) {
+	now := time.Now().UTC()
+	key := id.Key()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	e, ok := u.pending[key]
+	if !ok {
+		e = &domain.TokenUsage{TokenID: key, Method: id.Method, UserID: id.UserID, Role: string(id.Role), FirstUsedAt: &now}
+		u.pending[key] = e
+	}
+	e.Requests++
+	if status >= 400 {
+		e.Errors++
+	}
+	e.LastUsedAt = &now
+	e.LastStatus = status
+}
+
+// Close stores the remaining counters and stops the background flush.
+func (u *UsageTracker) Close() {
+	close(u.stop)
+	<-u.done
+}
+
+func (u *UsageTracker) run(interval time.Duration) {
+	defer close(u.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.flush()
+		case <-u.stop:
+			u.flush()
+			return
+		}
+	}
+}
+
+func (u *UsageTracker) flush() {
+	u.mu.Lock()
+	batch := make([]domain.TokenUsage, 0, len(u.pending))
+	for _, e := range u.pending {
+		batch = append(batch, *e)
+	}
+	u.pending = make(map[string]*domain.TokenUsage)
+	u.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	if err := u.store(context.Background(), batch); err != nil {
+		log.Printf("token usage: store %d entries: %v", len(batch), err)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
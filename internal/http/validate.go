@@ -0,0 +1,171 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	domain "prsrv/internal/domain"
+)
+
+// Limits checked before a request reaches the service.
+const (
+	maxBodyBytes = 1 << 20
+	maxIDLen     = 64
+	maxNameLen   = 256
+	// maxTeamMembers caps /team/add members and maxBatch the id lists of
+	// bulk operations.
+	maxTeamMembers = 500
+	maxBatch       = 500
+)
+
+// fieldError is one rejected field; items of arrays are named like
+// members[2].user_id.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// decodeJSON reads the request body into dst, answering 413 for bodies over
+// maxBodyBytes and 400 for anything that is not valid JSON.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(dst)
+	if err == nil {
+		return true
+	}
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		writeError(w, http.StatusRequestEntityTooLarge, string(domain.ErrInvalid), "body is larger than "+strconv.Itoa(maxBodyBytes)+" bytes")
+		return false
+	}
+	writeCode(w, domain.ErrInvalid, "invalid json")
+	return false
+}
+
+// validator collects every field error of a request so clients can fix
+// them in one go.
+type validator struct {
+	errs []fieldError
+}
+
+func (v *validator) add(field, msg string) {
+	v.errs = append(v.errs, fieldError{Field: field, Message: msg})
+}
+
+// id checks a required identifier: team names, user, PR and token ids.
+func (v *validator) id(field, s string) {
+	switch {
+	case s == "":
+		v.add(field, "is required")
+	case len(s) > maxIDLen:
+		v.add(field, "must be at most "+strconv.Itoa(maxIDLen)+" characters")
+	case !isIDString(s):
+		v.add(field, "may contain only letters, digits and . _ - : @")
+	}
+}
+
+// optionalID is id for fields that may be left empty.
+func (v *validator) optionalID(field, s string) {
+	if s != "" {
+		v.id(field, s)
+	}
+}
+
+// name checks free text such as usernames and PR titles.
+func (v *validator) name(field, s string, required bool) {
+	switch {
+	case s == "":
+		if required {
+			v.add(field, "is required")
+		}
+	case utf8.RuneCountInString(s) > maxNameLen:
+		v.add(field, "must be at most "+strconv.Itoa(maxNameLen)+" characters")
+	case !utf8.ValidString(s) || hasControl(s):
+		v.add(field, "must be valid text without control characters")
+	}
+}
+
+// count checks the size of a required list.
+func (v *validator) count(field string, n, max int) {
+	switch {
+	case n == 0:
+		v.add(field, "must not be empty")
+	case n > max:
+		v.add(field, "must have at most "+strconv.Itoa(max)+" items")
+	}
+}
+
+// page reads the pagination parameters shared by list endpoints: limit,
+// cursor and sort. Their values are checked by the service.
+func (v *validator) page(q url.Values) domain.PageQuery {
+	p := domain.PageQuery{Sort: q.Get("sort"), Cursor: q.Get("cursor")}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			v.add("limit", "must be a number")
+		}
+		p.Limit = n
+	}
+	return p
+}
+
+// ok writes the collected errors as a 400 and reports whether there were
+// none.
+func (v *validator) ok(w http.ResponseWriter) bool {
+	if len(v.errs) == 0 {
+		return true
+	}
+	msg := v.errs[0].Field + " " + v.errs[0].Message
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"code":    domain.ErrInvalid,
+		"message": msg,
+		"fields":  v.errs,
+	}})
+	return false
+}
+
+func isIDString(s string) bool {
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == '-', c == ':', c == '@':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func hasControl(s string) bool {
+	for _, c := range s {
+		if unicode.IsControl(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTeam checks a /team/add body.
+func validateTeam(v *validator, t domain.Team) {
+	v.id("team_name", t.TeamName)
+	if len(t.Members) > maxTeamMembers {
+		v.add("members", "must have at most "+strconv.Itoa(maxTeamMembers)+" items")
+		return
+	}
+	seen := make(map[string]bool, len(t.Members))
+	for i, m := range t.Members {
+		field := "members[" + strconv.Itoa(i) + "]"
+		v.id(field+".user_id", m.UserID)
+		v.name(field+".username", m.Username, true)
+		if seen[m.UserID] {
+			v.add(field+".user_id", "is listed twice")
+		}
+		seen[m.UserID] = true
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// apiVersion is a path prefix every API route is served under, with the
+// wrapper that adapts responses to that version. A breaking change to all
+// routes (a new envelope, pagination) ships as a new entry here; the older
+// versions keep working unchanged.
+type apiVersion struct {
+	prefix string
+	wrap   func(http.HandlerFunc) http.HandlerFunc
+}
+
+var apiVersions = []apiVersion{
+	{prefix: "/api/v1"},
+	{prefix: "/api/v2", wrap: envelope},
+}
+
+// currentPrefix is the version the unprefixed legacy paths alias.
+const currentPrefix = "/api/v1"
+
+// unversioned routes are not API resources and are only served at their
+// own path.
+var unversioned = map[string]bool{"/openapi.json": true, "/docs": true, "/metrics": true, "/debug/vars": true}
+
+// mountVersions serves fn under every API version, and at the bare legacy
+// path as a deprecated alias of currentPrefix. Responses are converted to
+// MessagePack last, after any version wrapper has shaped the JSON.
+func (h *Handlers) mountVersions(mux *http.ServeMux, path string, fn http.HandlerFunc) {
+	if unversioned[path] {
+		mux.HandleFunc(path, msgpackResponse(fn))
+		return
+	}
+	for _, v := range apiVersions {
+		served := fn
+		if v.wrap != nil {
+			served = v.wrap(fn)
+		}
+		mux.HandleFunc(v.prefix+path, msgpackResponse(served))
+	}
+	mux.HandleFunc(path, deprecated(currentPrefix+path, h.LegacySunset, msgpackResponse(fn)))
+}
+
+// deprecated marks responses of a legacy path with Deprecation, Sunset
+// (when sunset is set) and a Link to its successor.
+func deprecated(successor string, sunset time.Time, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+const webhookMaxBody = 1 << 20
+
+// Webhook source kinds.
+const (
+	WebhookGitHub = "github" // X-Hub-Signature-256: sha256=<hex hmac of body>
+	WebhookGitLab = "gitlab" // X-Gitlab-Token: <secret>
+	WebhookHMAC   = "hmac"   // X-Signature: <hex hmac of body>
+)
+
+var (
+	errWebhookSignature = errors.New("invalid webhook signature")
+	errWebhookSource    = errors.New("unknown webhook source")
+
+	// webhookRejections counts rejected deliveries by "source:reason";
+	// it is served with the other expvars on /debug/vars.
+	webhookRejections = expvar.NewMap("webhook_rejections")
+)
+
+type WebhookSource struct {
+	Name   string
+	Kind   string
+	Secret []byte
+}
+
+// Verify checks the delivery signature over the raw request body.
+func (s WebhookSource) Verify(r *http.Request, body []byte) error {
+	switch s.Kind {
+	case WebhookGitHub:
+		return verifyHMACHeader(r.Header.Get("X-Hub-Signature-256"), "sha256=", s.Secret, body)
+	case WebhookGitLab:
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), s.Secret) != 1 {
+			return errWebhookSignature
+		}
+		return nil
+	case WebhookHMAC:
+		return verifyHMACHeader(r.Header.Get("X-Signature"), "", s.Secret, body)
+	}
+	return errWebhookSource
+}
+
+func verifyHMACHeader(header, prefix string, secret, body []byte) error {
+	if !strings.HasPrefix(header, prefix) {
+		return errWebhookSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(header, prefix))
+	if err != nil {
+		return errWebhookSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errWebhookSignature
+	}
+	return nil
+}
+
+// Webhooks holds the configured inbound webhook sources. Every webhook
+// endpoint is wrapped with Require so signatures are checked in one place.
+type Webhooks struct {
+	sources map[string]WebhookSource
+}
+
+func NewWebhooks(sources []WebhookSource) *Webhooks {
+	wh := &Webhooks{sources: make(map[string]WebhookSource, len(sources))}
+	for _, s := range sources {
+		wh.sources[s.Name] = s
+	}
+	return wh
+}
+
+// ParseWebhookSources reads "name=kind:secret,..." where kind is github,
+// gitlab or hmac.
+func ParseWebhookSources(spec string) ([]WebhookSource, error) {
+	var out []WebhookSource
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(item, "=")
+		kind, secret, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 || name == "" || secret == "" {
+			return nil, fmt.Errorf("invalid webhook source %q", name)
+		}
+		switch kind {
+		case WebhookGitHub, WebhookGitLab, WebhookHMAC:
+		default:
+			return nil, fmt.Errorf("webhook source %q: unknown kind %q", name, kind)
+		}
+		out = append(out, WebhookSource{Name: name, Kind: kind, Secret: []byte(secret)})
+	}
+	return out, nil
+}
+
+// Require verifies the delivery for source before calling h with the body.
+// Sources without a configured secret reject every request.
+func (wh *Webhooks) Require(source string, h func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var src WebhookSource
+		ok := false
+		if wh != nil {
+			src, ok = wh.sources[source]
+		}
+		if !ok {
+			webhookRejections.Add(source+":not_configured", 1)
+			writeCode(w, domain.ErrUnauthorized, "webhook source is not configured")
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+		if err != nil {
+			webhookRejections.Add(source+":body", 1)
+			writeError(w, http.StatusRequestEntityTooLarge, string(domain.ErrInvalid), "cannot read body")
+			return
+		}
+		if err := src.Verify(r, body); err != nil {
+			webhookRejections.Add(source+":signature", 1)
+			writeCode(w, domain.ErrUnauthorized, err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h(w, r, body)
+	}
+}
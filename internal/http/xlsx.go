@@ -0,0 +1,289 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Sheet is one worksheet; the first row is the header.
+type Sheet struct {
+	Name string
+	Rows [][]any
+}
+
+// reportFormat lets a JSON report endpoint answer ?format=xlsx with a
+// workbook converted from its JSON body: top-level scalars go to a
+// "summary" sheet and every list or object gets a sheet of its own.
+func reportFormat(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+			next(w, r)
+			return
+		case "xlsx":
+		default:
+			writeCode(w, domain.ErrInvalid, "format must be json or xlsx")
+			return
+		}
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next(rec, r)
+		if rec.status != http.StatusOK {
+			rec.flush(w)
+			return
+		}
+		sheets, err := jsonSheets(rec.body.Bytes())
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		var buf bytes.Buffer
+		if err := WriteXLSX(&buf, sheets); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", xlsxContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+path.Base(r.URL.Path)+`.xlsx"`)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// jsonSheets lays out a JSON object as worksheets, keeping field order.
+func jsonSheets(body []byte) ([]Sheet, error) {
+	keys, fields, err := orderedObject(body)
+	if err != nil {
+		return nil, err
+	}
+	summary := Sheet{Name: "summary", Rows: [][]any{{"field", "value"}}}
+	var sheets []Sheet
+	for _, k := range keys {
+		v := bytes.TrimSpace(fields[k])
+		switch {
+		case len(v) > 0 && v[0] == '[':
+			rows, err := tableRows(v)
+			if err != nil {
+				return nil, err
+			}
+			sheets = append(sheets, Sheet{Name: k, Rows: rows})
+		case len(v) > 0 && v[0] == '{':
+			okeys, ofields, err := orderedObject(v)
+			if err != nil {
+				return nil, err
+			}
+			rows := [][]any{{"key", "value"}}
+			for _, ok := range okeys {
+				rows = append(rows, []any{ok, cellValue(ofields[ok])})
+			}
+			sheets = append(sheets, Sheet{Name: k, Rows: rows})
+		default:
+			summary.Rows = append(summary.Rows, []any{k, cellValue(v)})
+		}
+	}
+	if len(summary.Rows) > 1 || len(sheets) == 0 {
+		sheets = append([]Sheet{summary}, sheets...)
+	}
+	return sheets, nil
+}
+
+// tableRows turns a list of objects into a header row plus one row per item;
+// columns follow the first appearance of each field.
+func tableRows(list []byte) ([][]any, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(list, &items); err != nil {
+		return nil, err
+	}
+	var cols []string
+	colIdx := map[string]int{}
+	objs := make([]map[string]json.RawMessage, len(items))
+	for i, it := range items {
+		if t := bytes.TrimSpace(it); len(t) == 0 || t[0] != '{' {
+			objs[i] = map[string]json.RawMessage{"value": it}
+			if _, ok := colIdx["value"]; !ok {
+				colIdx["value"] = len(cols)
+				cols = append(cols, "value")
+			}
+			continue
+		}
+		keys, fields, err := orderedObject(it)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if _, ok := colIdx[k]; !ok {
+				colIdx[k] = len(cols)
+				cols = append(cols, k)
+			}
+		}
+		objs[i] = fields
+	}
+	header := make([]any, len(cols))
+	for i, c := range cols {
+		header[i] = c
+	}
+	rows := [][]any{header}
+	for _, o := range objs {
+		row := make([]any, len(cols))
+		for k, v := range o {
+			row[colIdx[k]] = cellValue(v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func orderedObject(data []byte) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, nil, errors.New("report is not a JSON object")
+	}
+	var keys []string
+	fields := map[string]json.RawMessage{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		k, _ := t.(string)
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, k)
+		fields[k] = v
+	}
+	return keys, fields, nil
+}
+
+// cellValue maps a JSON value to a cell: numbers, booleans and strings keep
+// their type, null is empty and nested values are written as JSON text.
+func cellValue(raw json.RawMessage) any {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		_ = json.Unmarshal(raw, &s)
+		return s
+	case 't', 'f':
+		return raw[0] == 't'
+	case '{', '[':
+		return string(raw)
+	}
+	return json.Number(raw)
+}
+
+// WriteXLSX writes a minimal Office Open XML workbook with inline strings.
+func WriteXLSX(w io.Writer, sheets []Sheet) error {
+	z := zip.NewWriter(w)
+	var overrides, wbSheets, rels strings.Builder
+	used := map[string]bool{}
+	for i, s := range sheets {
+		n := strconv.Itoa(i + 1)
+		overrides.WriteString(`<Override PartName="/xl/worksheets/sheet` + n + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+		wbSheets.WriteString(`<sheet name="` + xmlEscape(sheetName(s.Name, i, used)) + `" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+		rels.WriteString(`<Relationship Id="rId` + n + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + n + `.xml"/>`)
+	}
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + wbSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+	}
+	for i, s := range sheets {
+		files = append(files, struct{ name, body string }{"xl/worksheets/sheet" + strconv.Itoa(i+1) + ".xml", sheetXML(s)})
+	}
+	for _, f := range files {
+		fw, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func sheetXML(s Sheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for ri, row := range s.Rows {
+		rn := strconv.Itoa(ri + 1)
+		b.WriteString(`<row r="` + rn + `">`)
+		for ci, v := range row {
+			ref := columnName(ci) + rn
+			switch v := v.(type) {
+			case nil:
+			case json.Number:
+				b.WriteString(`<c r="` + ref + `"><v>` + v.String() + `</v></c>`)
+			case bool:
+				x := "0"
+				if v {
+					x = "1"
+				}
+				b.WriteString(`<c r="` + ref + `" t="b"><v>` + x + `</v></c>`)
+			default:
+				b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t>` + xmlEscape(fmt.Sprint(v)) + `</t></is></c>`)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// columnName converts a zero-based index to A, B, …, Z, AA, ….
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName makes a unique Excel sheet name: at most 31 characters and
+// none of []:*?/\.
+func sheetName(name string, i int, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	if name == "" || used[strings.ToLower(name)] {
+		name = "sheet" + strconv.Itoa(i+1)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,188 @@
+// Package importer reads PR lists exported from code hosts into PRs to
+// backfill: GitHub's pulls API (https://docs.github.com/en/rest/pulls) and
+// GitLab's merge requests API
+// (https://docs.gitlab.com/ee/api/merge_requests.html), saved as a JSON
+// array.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// Export formats.
+const (
+	FormatGitHub = "github-json"
+	FormatGitLab = "gitlab-json"
+)
+
+// Parse reads an export in format. PR ids are the repository path, with
+// slashes turned into dots, a colon and the PR number, e.g. "octo.app:12".
+// Closed PRs that were never merged are left out.
+func Parse(format string, r io.Reader) ([]domain.ImportedPR, error) {
+	switch format {
+	case FormatGitHub:
+		return parseGitHub(r)
+	case FormatGitLab:
+		return parseGitLab(r)
+	}
+	return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatGitHub, FormatGitLab)
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// githubPull is a pull request of the pulls API. Reviews are not part of
+// it; an export may add them from the reviews API.
+type githubPull struct {
+	Number             int          `json:"number"`
+	Title              string       `json:"title"`
+	State              string       `json:"state"`
+	User               githubUser   `json:"user"`
+	CreatedAt          time.Time    `json:"created_at"`
+	MergedAt           *time.Time   `json:"merged_at"`
+	RequestedReviewers []githubUser `json:"requested_reviewers"`
+	Base               struct {
+		Repo struct {
+			FullName string `json:"full_name"`
+		} `json:"repo"`
+	} `json:"base"`
+	Reviews []struct {
+		User        githubUser `json:"user"`
+		State       string     `json:"state"`
+		SubmittedAt *time.Time `json:"submitted_at"`
+	} `json:"reviews"`
+}
+
+func parseGitHub(r io.Reader) ([]domain.ImportedPR, error) {
+	var pulls []githubPull
+	if err := json.NewDecoder(r).Decode(&pulls); err != nil {
+		return nil, fmt.Errorf("github export: %w", err)
+	}
+	out := make([]domain.ImportedPR, 0, len(pulls))
+	for _, p := range pulls {
+		if p.State == "closed" && p.MergedAt == nil {
+			continue
+		}
+		pr := domain.ImportedPR{
+			ID: PRID(p.Base.Repo.FullName, p.Number), Name: p.Title, AuthorID: userID(p.User.Login),
+			Repository: p.Base.Repo.FullName, CreatedAt: p.CreatedAt, MergedAt: p.MergedAt,
+		}
+		// Reviewers who submitted a review drop out of requested_reviewers.
+		for _, u := range p.RequestedReviewers {
+			pr.Reviewers = addReviewer(pr.Reviewers, pr.AuthorID, userID(u.Login), nil)
+		}
+		for _, rv := range p.Reviews {
+			var approved *time.Time
+			if rv.State == "APPROVED" {
+				approved = rv.SubmittedAt
+			}
+			pr.Reviewers = addReviewer(pr.Reviewers, pr.AuthorID, userID(rv.User.Login), approved)
+		}
+		if err := check(pr); err != nil {
+			return nil, err
+		}
+		out = append(out, pr)
+	}
+	return out, nil
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+// gitlabMR is a merge request of the merge requests API. GitLab gives no
+// approval times, so approved_by, when the export adds it, only makes the
+// approvers reviewers.
+type gitlabMR struct {
+	IID        int          `json:"iid"`
+	Title      string       `json:"title"`
+	State      string       `json:"state"`
+	Author     gitlabUser   `json:"author"`
+	CreatedAt  time.Time    `json:"created_at"`
+	MergedAt   *time.Time   `json:"merged_at"`
+	Reviewers  []gitlabUser `json:"reviewers"`
+	References struct {
+		Full string `json:"full"` // "group/project!12"
+	} `json:"references"`
+	ApprovedBy []struct {
+		User gitlabUser `json:"user"`
+	} `json:"approved_by"`
+}
+
+func parseGitLab(r io.Reader) ([]domain.ImportedPR, error) {
+	var mrs []gitlabMR
+	if err := json.NewDecoder(r).Decode(&mrs); err != nil {
+		return nil, fmt.Errorf("gitlab export: %w", err)
+	}
+	out := make([]domain.ImportedPR, 0, len(mrs))
+	for _, m := range mrs {
+		if m.State == "closed" || m.State == "locked" {
+			continue
+		}
+		repo, _, _ := strings.Cut(m.References.Full, "!")
+		pr := domain.ImportedPR{
+			ID: PRID(repo, m.IID), Name: m.Title, AuthorID: userID(m.Author.Username),
+			Repository: repo, CreatedAt: m.CreatedAt, MergedAt: m.MergedAt,
+		}
+		for _, u := range m.Reviewers {
+			pr.Reviewers = addReviewer(pr.Reviewers, pr.AuthorID, userID(u.Username), nil)
+		}
+		for _, a := range m.ApprovedBy {
+			pr.Reviewers = addReviewer(pr.Reviewers, pr.AuthorID, userID(a.User.Username), nil)
+		}
+		if err := check(pr); err != nil {
+			return nil, err
+		}
+		out = append(out, pr)
+	}
+	return out, nil
+}
+
+// PRID is the id of PR number of repo, the repository path: slashes turn
+// into dots, e.g. "octo.app:12".
+func PRID(repo string, number int) string {
+	return strings.ReplaceAll(repo, "/", ".") + ":" + strconv.Itoa(number)
+}
+
+// userID maps a login to a user id; bot accounts such as
+// "dependabot[bot]" lose the suffix.
+func userID(login string) string {
+	return strings.TrimSuffix(login, "[bot]")
+}
+
+// addReviewer adds id to reviewers unless it is the author; a reviewer
+// already there keeps the earliest approval.
+func addReviewer(reviewers []domain.ImportedReview, author, id string, approvedAt *time.Time) []domain.ImportedReview {
+	if id == "" || id == author {
+		return reviewers
+	}
+	i := slices.IndexFunc(reviewers, func(r domain.ImportedReview) bool { return r.UserID == id })
+	if i < 0 {
+		return append(reviewers, domain.ImportedReview{UserID: id, ApprovedAt: approvedAt})
+	}
+	if approvedAt != nil && (reviewers[i].ApprovedAt == nil || approvedAt.Before(*reviewers[i].ApprovedAt)) {
+		reviewers[i].ApprovedAt = approvedAt
+	}
+	return reviewers
+}
+
+func check(pr domain.ImportedPR) error {
+	switch {
+	case pr.ID == "" || strings.HasPrefix(pr.ID, ":"):
+		return fmt.Errorf("PR %q: no repository", pr.ID)
+	case pr.AuthorID == "":
+		return fmt.Errorf("PR %s: no author", pr.ID)
+	case pr.CreatedAt.IsZero():
+		return fmt.Errorf("PR %s: no created_at", pr.ID)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+// Package msgpack converts between JSON and MessagePack
+// (https://github.com/msgpack/msgpack/blob/master/spec.md), so that the JSON
+// API can be offered in MessagePack without a second set of encoders.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDepth bounds nesting, so hostile input cannot exhaust the stack.
+const maxDepth = 64
+
+// FromJSON encodes one JSON value as MessagePack, keeping the order of
+// object keys. Integers become the smallest int or uint format that fits;
+// other numbers become float64.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := fromJSON(&out, dec, 0); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("msgpack: trailing data after JSON value")
+	}
+	return out.Bytes(), nil
+}
+
+func fromJSON(dst *bytes.Buffer, dec *json.Decoder, depth int) error {
+	if depth > maxDepth {
+		return errors.New("msgpack: nesting too deep")
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		// the element count comes first, so the elements are buffered
+		var body bytes.Buffer
+		n := 0
+		for dec.More() {
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				writeString(&body, key.(string))
+			}
+			if err := fromJSON(&body, dec, depth+1); err != nil {
+				return err
+			}
+			n++
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if t == '{' {
+			writeHeader(dst, n, 0x80, 0xde)
+		} else {
+			writeHeader(dst, n, 0x90, 0xdc)
+		}
+		dst.Write(body.Bytes())
+	case string:
+		writeString(dst, t)
+	case json.Number:
+		writeNumber(dst, t)
+	case bool:
+		if t {
+			dst.WriteByte(0xc3)
+		} else {
+			dst.WriteByte(0xc2)
+		}
+	case nil:
+		dst.WriteByte(0xc0)
+	}
+	return nil
+}
+
+// writeHeader writes a map or array header: the fix format for up to 15
+// elements, else the 16- or 32-bit one (big16+1).
+func writeHeader(dst *bytes.Buffer, n int, fix, big16 byte) {
+	switch {
+	case n < 16:
+		dst.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		dst.WriteByte(big16)
+		dst.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		dst.WriteByte(big16 + 1)
+		dst.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func writeString(dst *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		dst.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		dst.Write([]byte{0xd9, byte(n)})
+	case n <= math.MaxUint16:
+		dst.WriteByte(0xda)
+		dst.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		dst.WriteByte(0xdb)
+		dst.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+	dst.WriteString(s)
+}
+
+func writeNumber(dst *bytes.Buffer, num json.Number) {
+	if i, err := strconv.ParseInt(string(num), 10, 64); err == nil {
+		switch {
+		case i >= 0:
+			writeUint(dst, uint64(i))
+		case i >= -32:
+			dst.WriteByte(byte(i))
+		case i >= math.MinInt8:
+			dst.Write([]byte{0xd0, byte(i)})
+		case i >= math.MinInt16:
+			dst.WriteByte(0xd1)
+			dst.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+		case i >= math.MinInt32:
+			dst.WriteByte(0xd2)
+			dst.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+		default:
+			dst.WriteByte(0xd3)
+			dst.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		}
+		return
+	}
+	if u, err := strconv.ParseUint(string(num), 10, 64); err == nil {
+		writeUint(dst, u)
+		return
+	}
+	f, _ := strconv.ParseFloat(string(num), 64)
+	dst.WriteByte(0xcb)
+	dst.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+func writeUint(dst *bytes.Buffer, u uint64) {
+	switch {
+	case u <= 0x7f:
+		dst.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		dst.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		dst.WriteByte(0xcd)
+		dst.Write(binary.BigEndian.AppendUint16(nil, uint16(u)))
+	case u <= math.MaxUint32:
+		dst.WriteByte(0xce)
+		dst.Write(binary.BigEndian.AppendUint32(nil, uint32(u)))
+	default:
+		dst.WriteByte(0xcf)
+		dst.Write(binary.BigEndian.AppendUint64(nil, u))
+	}
+}
+
+// ToJSON decodes one MessagePack value as JSON. Map keys must be strings;
+// binary data becomes a base64 string and timestamps an RFC 3339 string.
+// Other extension types, NaN and infinities have no JSON form and are
+// rejected.
+func ToJSON(data []byte) ([]byte, error) {
+	r := &reader{buf: data}
+	var out bytes.Buffer
+	if err := r.value(&out, 0); err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.buf) {
+		return nil, errors.New("msgpack: trailing data after value")
+	}
+	return out.Bytes(), nil
+}
+
+type reader struct {
+	buf []byte
+	pos int
+}
+
+var errShort = errors.New("msgpack: unexpected end of data")
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.buf)-r.pos < n {
+		return nil, errShort
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (r *reader) uint(size int) (uint64, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (r *reader) value(dst *bytes.Buffer, depth int) error {
+	if depth > maxDepth {
+		return errors.New("msgpack: nesting too deep")
+	}
+	b, err := r.next(1)
+	if err != nil {
+		return err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		dst.WriteString(strconv.Itoa(int(c)))
+		return nil
+	case c >= 0xe0:
+		dst.WriteString(strconv.Itoa(int(int8(c))))
+		return nil
+	case c&0xf0 == 0x80:
+		return r.mapBody(dst, int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return r.arrayBody(dst, int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return r.str(dst, int(c&0x1f))
+	}
+	switch c {
+	case 0xc0:
+		dst.WriteString("null")
+	case 0xc2:
+		dst.WriteString("false")
+	case 0xc3:
+		dst.WriteString("true")
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := r.uint(1 << (c - 0xcc))
+		if err != nil {
+			return err
+		}
+		dst.WriteString(strconv.FormatUint(u, 10))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := r.uint(size)
+		if err != nil {
+			return err
+		}
+		// sign-extend from size bytes
+		shift := 64 - 8*size
+		dst.WriteString(strconv.FormatInt(int64(u<<shift)>>shift, 10))
+	case 0xca:
+		u, err := r.uint(4)
+		if err != nil {
+			return err
+		}
+		return writeFloat(dst, float64(math.Float32frombits(uint32(u))), 32)
+	case 0xcb:
+		u, err := r.uint(8)
+		if err != nil {
+			return err
+		}
+		return writeFloat(dst, math.Float64frombits(u), 64)
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return err
+		}
+		return r.str(dst, int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		bin, err := r.next(int(n))
+		if err != nil {
+			return err
+		}
+		dst.WriteByte('"')
+		dst.WriteString(base64.StdEncoding.EncodeToString(bin))
+		dst.WriteByte('"')
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return err
+		}
+		return r.arrayBody(dst, int(n), depth)
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return err
+		}
+		return r.mapBody(dst, int(n), depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.ext(dst, 1<<(c-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.uint(1 << (c - 0xc7))
+		if err != nil {
+			return err
+		}
+		return r.ext(dst, int(n))
+	default:
+		return fmt.Errorf("msgpack: invalid format byte 0x%02x", c)
+	}
+	return nil
+}
+
+func (r *reader) str(dst *bytes.Buffer, n int) error {
+	s, err := r.next(n)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(s) {
+		return errors.New("msgpack: string is not valid UTF-8")
+	}
+	b, _ := json.Marshal(string(s))
+	dst.Write(b)
+	return nil
+}
+
+func (r *reader) arrayBody(dst *bytes.Buffer, n, depth int) error {
+	dst.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			dst.WriteByte(',')
+		}
+		if err := r.value(dst, depth+1); err != nil {
+			return err
+		}
+	}
+	dst.WriteByte(']')
+	return nil
+}
+
+func (r *reader) mapBody(dst *bytes.Buffer, n, depth int) error {
+	dst.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			dst.WriteByte(',')
+		}
+		if len(r.buf) > r.pos && !isStr(r.buf[r.pos]) {
+			return errors.New("msgpack: map keys must be strings")
+		}
+		if err := r.value(dst, depth+1); err != nil {
+			return err
+		}
+		dst.WriteByte(':')
+		if err := r.value(dst, depth+1); err != nil {
+			return err
+		}
+	}
+	dst.WriteByte('}')
+	return nil
+}
+
+func isStr(c byte) bool {
+	return c&0xe0 == 0xa0 || c == 0xd9 || c == 0xda || c == 0xdb
+}
+
+// ext decodes an extension of n data bytes; only the timestamp type (-1)
+// is supported.
+func (r *reader) ext(dst *bytes.Buffer, n int) error {
+	typ, err := r.next(1)
+	if err != nil {
+		return err
+	}
+	data, err := r.next(n)
+	if err != nil {
+		return err
+	}
+	if int8(typ[0]) != -1 {
+		return fmt.Errorf("msgpack: unsupported extension type %d", int8(typ[0]))
+	}
+	var t time.Time
+	switch n {
+	case 4:
+		t = time.Unix(int64(binary.BigEndian.Uint32(data)), 0)
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		t = time.Unix(int64(v&(1<<34-1)), int64(v>>34))
+	case 12:
+		t = time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data)))
+	default:
+		return errors.New("msgpack: invalid timestamp")
+	}
+	b, _ := json.Marshal(t.UTC().Format(time.RFC3339Nano))
+	dst.Write(b)
+	return nil
+}
+
+func writeFloat(dst *bytes.Buffer, f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return errors.New("msgpack: NaN and infinity have no JSON form")
+	}
+	dst.WriteString(strconv.FormatFloat(f, 'g', -1, bits))
+	return nil
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) InsertAuthEvents(ctx context.Context, events []domain.AuthEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString(`insert into auth_events(occurred_at, event_type, outcome, token_id, user_id, role, method, route, ip) values `)
+	args := make([]any, 0, len(events)*9)
+	for i, e := range events {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(")
+		for j := 1; j <= 9; j++ {
+			if j > 1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("$" + strconv.Itoa(i*9+j))
+		}
+		sb.WriteString(")")
+		args = append(args, e.Time, e.Type, e.Outcome, e.TokenID, e.UserID, e.Role, e.Method, e.Route, e.IP)
+	}
+	_, err := r.db.ExecContext(ctx, sb.String(), args...)
+	return err
+}
+
+func (r *PostgresRepo) ListAuthEvents(ctx context.Context, f domain.AuthEventFilter) ([]domain.AuthEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select id, occurred_at, event_type, outcome, token_id, user_id, role, method, route, ip
+		from auth_events
+		where ($1::timestamptz is null or occurred_at >= $1)
+		  and ($2::timestamptz is null or occurred_at < $2)
+		  and ($3 = '' or outcome = $3)
+		  and ($4 = '' or token_id = $4)
+		  and ($5 = '' or user_id = $5)
+		order by occurred_at desc, id desc
+		limit $6`, f.Since, f.Until, f.Outcome, f.TokenID, f.UserID, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.AuthEvent{}
+	for rows.Next() {
+		var e domain.AuthEvent
+		if err := rows.Scan(&e.ID, &e.Time, &e.Type, &e.Outcome, &e.TokenID, &e.UserID, &e.Role, &e.Method, &e.Route, &e.IP); err != nil {
+			return nil, err
+		}
+		e.Time = e.Time.UTC()
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// ErrConnDropped is what a call on a connection dropped by DropConnections
+// fails with.
+var ErrConnDropped = errors.New("chaos: database connection dropped")
+
+// DropConnections wraps c for fault injection: each call on a connection
+// fails with probability rate as if the network to the database went away.
+// The connection is closed, the call returns ErrConnDropped, a transaction
+// on it is lost and the pool opens a new connection for the next call.
+func DropConnections(c driver.Connector, rate float64) driver.Connector {
+	return &dropConnector{Connector: c, rate: rate}
+}
+
+type dropConnector struct {
+	driver.Connector
+	rate float64
+}
+
+func (c *dropConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dropConn{Conn: conn, rate: c.rate}, nil
+}
+
+type dropConn struct {
+	driver.Conn
+	rate    float64
+	dropped atomic.Bool
+}
+
+// fault drops the connection on a share of calls; once dropped, every call
+// fails.
+func (c *dropConn) fault() error {
+	if c.dropped.Load() {
+		return driver.ErrBadConn
+	}
+	if rand.Float64() < c.rate {
+		c.dropped.Store(true)
+		_ = c.Conn.Close()
+		return ErrConnDropped
+	}
+	return nil
+}
+
+func (c *dropConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *dropConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *dropConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *dropConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *dropConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	return q.QueryContext(ctx, query, args)
+}
+
+func (c *dropConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *dropConn) Ping(ctx context.Context) error {
+	if c.dropped.Load() {
+		return driver.ErrBadConn
+	}
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *dropConn) ResetSession(ctx context.Context) error {
+	if c.dropped.Load() {
+		return driver.ErrBadConn
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *dropConn) IsValid() bool {
+	if c.dropped.Load() {
+		return false
+	}
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *dropConn) Close() error {
+	if c.dropped.Load() {
+		return nil
+	}
+	return c.Conn.Close()
+}
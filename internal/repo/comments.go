@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) AddPRComment(ctx context.Context, tx *sql.Tx, c *domain.Comment) error {
+	var replyTo sql.NullInt64
+	if c.ReplyTo != nil {
+		replyTo = sql.NullInt64{Int64: *c.ReplyTo, Valid: true}
+	}
+	err := tx.QueryRowContext(ctx, `
+		insert into pr_comments(pr_id, author_id, text, reply_to, created_at)
+		values ($1, $2, $3, $4, $5)
+		returning id, created_at`,
+		c.PRID, c.AuthorID, c.Text, replyTo, r.now()).Scan(&c.ID, &c.CreatedAt)
+	c.CreatedAt = c.CreatedAt.UTC()
+	return err
+}
+
+func (r *PostgresRepo) ListPRComments(ctx context.Context, prID string) ([]domain.Comment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select id, pr_id, author_id, text, reply_to, created_at
+		from pr_comments
+		where pr_id = $1
+		order by created_at, id`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.Comment{}
+	for rows.Next() {
+		var c domain.Comment
+		var replyTo sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.PRID, &c.AuthorID, &c.Text, &replyTo, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if replyTo.Valid {
+			c.ReplyTo = &replyTo.Int64
+		}
+		c.CreatedAt = c.CreatedAt.UTC()
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const encPrefix = "enc:"
+
+var errCiphertext = errors.New("pii: malformed ciphertext")
+
+// FieldCipher encrypts PII columns with envelope encryption: every value gets
+// its own random data key, which is sealed with a key-encryption key (KEK).
+// Stored values look like "enc:<kid>:<base64>". The first KEK encrypts new
+// values; the others are kept to read data written before a rotation.
+// Values without the prefix are returned as is, so existing plaintext rows
+// stay readable and are encrypted on their next write.
+type FieldCipher struct {
+	active string
+	keks   map[string]cipher.AEAD
+}
+
+// ParseFieldKeys reads "kid=base64key,..." with 32-byte AES keys.
+func ParseFieldKeys(spec string) (*FieldCipher, error) {
+	c := &FieldCipher{keks: map[string]cipher.AEAD{}}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, raw, ok := strings.Cut(item, "=")
+		if !ok || kid == "" || strings.Contains(kid, ":") {
+			return nil, fmt.Errorf("pii key %q: expected kid=base64", kid)
+		}
+		key, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("pii key %q: must be 32 bytes, base64", kid)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		c.keks[kid] = aead
+		if c.active == "" {
+			c.active = kid
+		}
+	}
+	if c.active == "" {
+		return nil, nil
+	}
+	return c, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *FieldCipher) Encrypt(plain string) (string, error) {
+	if c == nil {
+		return plain, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	kek := c.keks[c.active]
+	wrapped, err := seal(kek, dek, []byte(c.active))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(aead, []byte(plain), wrapped)
+	if err != nil {
+		return "", err
+	}
+	return encPrefix + c.active + ":" + base64.RawStdEncoding.EncodeToString(append(wrapped, body...)), nil
+}
+
+func (c *FieldCipher) Decrypt(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encPrefix) {
+		return stored, nil
+	}
+	if c == nil {
+		return "", errors.New("pii: encrypted value but no PII key configured")
+	}
+	kid, payload, ok := strings.Cut(strings.TrimPrefix(stored, encPrefix), ":")
+	if !ok {
+		return "", errCiphertext
+	}
+	kek, ok := c.keks[kid]
+	if !ok {
+		return "", fmt.Errorf("pii: unknown key %q", kid)
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errCiphertext
+	}
+	wrappedLen := kek.NonceSize() + 32 + kek.Overhead()
+	if len(raw) < wrappedLen {
+		return "", errCiphertext
+	}
+	wrapped := raw[:wrappedLen]
+	dek, err := open(kek, wrapped, []byte(kid))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, raw[wrappedLen:], wrapped)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plain, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, ad), nil
+}
+
+func open(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errCiphertext
+	}
+	out, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, errCiphertext
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (r *PostgresRepo) AddPRDependency(ctx context.Context, tx *sql.Tx, prID, blockedBy string) error {
+	_, err := tx.ExecContext(ctx, `insert into pr_dependencies(pr_id, blocked_by) values ($1, $2) on conflict do nothing`, prID, blockedBy)
+	return err
+}
+
+func (r *PostgresRepo) DeletePRDependency(ctx context.Context, tx *sql.Tx, prID, blockedBy string) error {
+	_, err := tx.ExecContext(ctx, `delete from pr_dependencies where pr_id = $1 and blocked_by = $2`, prID, blockedBy)
+	return err
+}
+
+func (r *PostgresRepo) ListPRDependencies(ctx context.Context, prID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `select blocked_by from pr_dependencies where pr_id = $1 order by blocked_by`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
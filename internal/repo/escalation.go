@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"context"
+	"github.com/lib/pq"
+
+	domain "prsrv/internal/domain"
+)
+
+// ListStalledPRs returns the open PRs of teams with an escalation policy
+// whose reviewers have not acted yet and that no open PR blocks, oldest
+// first.
+func (r *PostgresRepo) ListStalledPRs(ctx context.Context) ([]domain.StalledPR, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select p.pr_id, p.author_id, t.team_name, p.created_at,
+		       t.escalate_notify_days, t.escalate_add_reviewer_days,
+		       array(select distinct e.reason from pr_events e
+		             where e.pr_id = p.pr_id and e.kind = 'escalated' and e.reason is not null)
+		from pull_requests p
+		join users u on u.user_id = p.author_id
+		join teams t on t.team_name = u.team_name
+		where p.status = 'OPEN'
+		  and (t.escalate_notify_days > 0 or t.escalate_add_reviewer_days > 0)
+		  and not exists (select 1 from pr_reviewers r where r.pr_id = p.pr_id and r.first_action_at is not null)
+		  and not `+prBlocked+`
+		order by p.created_at, p.pr_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.StalledPR
+	for rows.Next() {
+		var p domain.StalledPR
+		if err := rows.Scan(&p.PRID, &p.AuthorID, &p.TeamName, &p.CreatedAt,
+			&p.Policy.NotifyLeadDays, &p.Policy.AddReviewerDays, pq.Array(&p.Done)); err != nil {
+			return nil, err
+		}
+		p.CreatedAt = p.CreatedAt.UTC()
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) AddPREvents(ctx context.Context, tx *sql.Tx, events []domain.PREvent) error {
+	for _, e := range events {
+		at := e.At
+		if at.IsZero() {
+			at = r.now()
+		}
+		if _, err := tx.ExecContext(ctx, `
+			insert into pr_events(pr_id, kind, user_id, replaced_by, reason, at)
+			values ($1, $2, nullif($3, ''), nullif($4, ''), nullif($5, ''), $6)`,
+			e.PRID, e.Kind, e.UserID, e.ReplacedBy, e.Reason, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepo) ListPREvents(ctx context.Context, prID string) ([]domain.PREvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select pr_id, kind, coalesce(user_id, ''), coalesce(replaced_by, ''), coalesce(reason, ''), at
+		from pr_events
+		where pr_id = $1
+		order by at, id`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.PREvent{}
+	for rows.Next() {
+		var e domain.PREvent
+		if err := rows.Scan(&e.PRID, &e.Kind, &e.UserID, &e.ReplacedBy, &e.Reason, &e.At); err != nil {
+			return nil, err
+		}
+		e.At = e.At.UTC()
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) CreateExport(ctx context.Context, e domain.Export) error {
+	_, err := r.db.ExecContext(ctx, `insert into exports(export_id, kind, teams, status, created_by, created_at)
+		values ($1,$2,$3,$4,$5,$6)`, e.ID, e.Kind, pqStringArray(e.Teams), e.Status, e.CreatedBy, e.CreatedAt)
+	return err
+}
+
+// FinishExport stores the content, or marks the export failed when errMsg is set.
+func (r *PostgresRepo) FinishExport(ctx context.Context, id string, content []byte, errMsg string) error {
+	status := domain.ExportReady
+	if errMsg != "" {
+		status, content = domain.ExportFailed, nil
+	}
+	_, err := r.db.ExecContext(ctx, `update exports set status=$2, content=$3, error=$4, finished_at=$5
+		where export_id=$1`, id, status, content, errMsg, r.now())
+	return err
+}
+
+func (r *PostgresRepo) GetExport(ctx context.Context, id string) (*domain.Export, error) {
+	e := &domain.Export{}
+	err := r.db.QueryRowContext(ctx, `select export_id, kind, teams, status, error, created_by, created_at, finished_at
+		from exports where export_id=$1`, id).
+		Scan(&e.ID, &e.Kind, pq.Array(&e.Teams), &e.Status, &e.Error, &e.CreatedBy, &e.CreatedAt, &e.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapError(domain.ErrNotFound, "export not found", err)
+	}
+	return e, err
+}
+
+func (r *PostgresRepo) GetExportContent(ctx context.Context, id string) ([]byte, error) {
+	var b []byte
+	err := r.db.QueryRowContext(ctx, `select content from exports where export_id=$1`, id).Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapError(domain.ErrNotFound, "export not found", err)
+	}
+	return b, err
+}
@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) SetGitHubLogin(ctx context.Context, tx *sql.Tx, uID, login string) error {
+	if _, err := tx.ExecContext(ctx, `delete from github_logins where user_id = $1`, uID); err != nil || login == "" {
+		return err
+	}
+	// The user's own row is gone, so a conflict means another user holds
+	// the login; a concurrent mapping waits for this insert and lands here.
+	res, err := tx.ExecContext(ctx, `insert into github_logins(login, user_id, created_at) values ($1, $2, $3)
+		on conflict (login) do nothing`, login, uID, r.now())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.NewError(domain.ErrInvalid, "github login "+login+" is mapped to another user")
+	}
+	return nil
+}
+
+func (r *PostgresRepo) GitHubLoginUser(ctx context.Context, login string) (string, error) {
+	var id string
+	err := r.db.QueryRowContext(ctx, `select user_id from github_logins where login = $1`, login).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.NewError(domain.ErrNotFound, "github login "+login+" is not mapped to a user")
+	}
+	return id, err
+}
@@ -0,0 +1,1421 @@
+package repo
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// MemoryRepo keeps everything in process memory. It implements domain.Repo
+// with the semantics of PostgresRepo, so the full service can run in tests
+// without a database. Transactions are serialized and rolled back by
+// restoring a copy of the state; readers outside a transaction may see its
+// uncommitted writes.
+type MemoryRepo struct {
+	txMu  sync.Mutex
+	mu    sync.Mutex
+	st    memState
+	clock domain.Clock
+}
+
+type memState struct {
+	teams       map[string]string // team name to lead user id
+	manual      map[string]bool   // teams with manual assignment
+	mergeRules  map[string]domain.MergeRules
+	escalation  map[string]domain.EscalationPolicy
+	workHours   map[string]domain.WorkingHours
+	holidays    map[string][]domain.Holiday
+	users       map[string]domain.User
+	logins      map[string]string // GitHub login to user id
+	prs         map[string]memPR
+	reviewers   map[string][]memReviewer // by PR id
+	blockers    map[string][]string      // by PR id
+	watchers    map[string][]string      // by PR id
+	events      []domain.PREvent
+	comments    []domain.Comment
+	tokens      map[string]domain.APIToken
+	roles       map[string][]domain.Permission
+	authEvents  []domain.AuthEvent
+	usage       map[string]domain.TokenUsage
+	exports     map[string]memExport
+	webhooks    []domain.Webhook
+	noCandidate []domain.NoCandidateEvent
+	alerts      []domain.OverloadAlert
+	snapshots   map[string]domain.StatsSnapshot
+	refreshedAt *time.Time
+	lastID      int64
+}
+
+type memPR struct {
+	ID         string
+	Name       string
+	AuthorID   string
+	Repository string
+	Status     domain.PRStatus
+	CreatedAt  time.Time
+	MergedAt   *time.Time
+	ClosedAt   *time.Time
+}
+
+// endedAt is when the PR stopped being open, nil while it is.
+func (p memPR) endedAt() *time.Time {
+	if p.MergedAt != nil {
+		return p.MergedAt
+	}
+	return p.ClosedAt
+}
+
+type memReviewer struct {
+	UserID        string
+	AssignedAt    time.Time
+	FirstActionAt *time.Time
+	State         string
+	DecidedAt     *time.Time
+	DeliverAt     *time.Time // queued outside working hours until then
+}
+
+type memExport struct {
+	domain.Export
+	Content []byte
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	r := &MemoryRepo{clock: domain.SystemClock}
+	r.st = memState{
+		teams:      map[string]string{},
+		manual:     map[string]bool{},
+		mergeRules: map[string]domain.MergeRules{},
+		escalation: map[string]domain.EscalationPolicy{},
+		workHours:  map[string]domain.WorkingHours{},
+		holidays:   map[string][]domain.Holiday{},
+		users:      map[string]domain.User{},
+		logins:     map[string]string{},
+		prs:        map[string]memPR{},
+		reviewers:  map[string][]memReviewer{},
+		blockers:   map[string][]string{},
+		watchers:   map[string][]string{},
+		tokens:     map[string]domain.APIToken{},
+		roles:      map[string][]domain.Permission{},
+		usage:      map[string]domain.TokenUsage{},
+		exports:    map[string]memExport{},
+		snapshots:  map[string]domain.StatsSnapshot{},
+	}
+	for role, perms := range domain.DefaultRolePermissions {
+		r.st.roles[role] = slices.Clone(perms)
+	}
+	return r
+}
+
+// WithClock makes the repo stamp records with the time of c.
+func (r *MemoryRepo) WithClock(c domain.Clock) *MemoryRepo {
+	r.clock = c
+	return r
+}
+
+func (r *MemoryRepo) now() time.Time { return r.clock.Now().UTC() }
+
+func (r *MemoryRepo) nextID() int64 {
+	r.st.lastID++
+	return r.st.lastID
+}
+
+// WithTx runs fn with a nil *sql.Tx; the methods of MemoryRepo ignore it.
+func (r *MemoryRepo) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	saved := r.st.clone()
+	r.mu.Unlock()
+	// Like a database transaction, one whose context ends before it
+	// commits is rolled back.
+	if err := fn(nil); err != nil || ctx.Err() != nil {
+		if err == nil {
+			err = ctx.Err()
+		}
+		r.mu.Lock()
+		r.st = saved
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s memState) clone() memState {
+	c := s
+	c.teams = cloneMap(s.teams)
+	c.manual = cloneMap(s.manual)
+	c.mergeRules = cloneMap(s.mergeRules)
+	c.escalation = cloneMap(s.escalation)
+	c.workHours = cloneMap(s.workHours)
+	c.holidays = cloneMap(s.holidays)
+	c.users = cloneMap(s.users)
+	c.logins = cloneMap(s.logins)
+	c.prs = cloneMap(s.prs)
+	c.reviewers = make(map[string][]memReviewer, len(s.reviewers))
+	for k, v := range s.reviewers {
+		c.reviewers[k] = slices.Clone(v)
+	}
+	c.blockers = make(map[string][]string, len(s.blockers))
+	for k, v := range s.blockers {
+		c.blockers[k] = slices.Clone(v)
+	}
+	c.watchers = make(map[string][]string, len(s.watchers))
+	for k, v := range s.watchers {
+		c.watchers[k] = slices.Clone(v)
+	}
+	c.events = slices.Clone(s.events)
+	c.comments = slices.Clone(s.comments)
+	c.tokens = cloneMap(s.tokens)
+	c.roles = make(map[string][]domain.Permission, len(s.roles))
+	for k, v := range s.roles {
+		c.roles[k] = slices.Clone(v)
+	}
+	c.authEvents = slices.Clone(s.authEvents)
+	c.usage = cloneMap(s.usage)
+	c.exports = cloneMap(s.exports)
+	c.webhooks = slices.Clone(s.webhooks)
+	c.noCandidate = slices.Clone(s.noCandidate)
+	c.alerts = slices.Clone(s.alerts)
+	c.snapshots = cloneMap(s.snapshots)
+	return c
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// inTeams reports whether team passes a team filter; an empty filter passes
+// everything.
+func inTeams(teams []string, team string) bool {
+	return len(teams) == 0 || slices.Contains(teams, team)
+}
+
+func (r *MemoryRepo) CreateTeam(ctx context.Context, _ *sql.Tx, teamName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[teamName]; ok {
+		return fmt.Errorf("team %q already exists", teamName)
+	}
+	r.st.teams[teamName] = ""
+	return nil
+}
+
+func (r *MemoryRepo) TeamExists(ctx context.Context, _ *sql.Tx, teamName string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.st.teams[teamName]
+	return ok, nil
+}
+
+func (r *MemoryRepo) SetTeamManualAssignment(ctx context.Context, _ *sql.Tx, teamName string, manual bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[teamName]; ok {
+		r.st.manual[teamName] = manual
+	}
+	return nil
+}
+
+func (r *MemoryRepo) TeamManualAssignment(ctx context.Context, teamName string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.st.manual[teamName], nil
+}
+
+func (r *MemoryRepo) SetTeamMergeRules(ctx context.Context, _ *sql.Tx, teamName string, rules domain.MergeRules) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[teamName]; ok {
+		r.st.mergeRules[teamName] = rules
+	}
+	return nil
+}
+
+func (r *MemoryRepo) TeamMergeRules(ctx context.Context, teamName string) (domain.MergeRules, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.st.mergeRules[teamName], nil
+}
+
+func (r *MemoryRepo) SetTeamEscalation(ctx context.Context, _ *sql.Tx, teamName string, p domain.EscalationPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[teamName]; ok {
+		r.st.escalation[teamName] = p
+	}
+	return nil
+}
+
+func (r *MemoryRepo) TeamEscalation(ctx context.Context, teamName string) (domain.EscalationPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.st.escalation[teamName], nil
+}
+
+func (r *MemoryRepo) SetTeamWorkingHours(ctx context.Context, _ *sql.Tx, teamName string, w domain.WorkingHours) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[teamName]; ok {
+		r.st.workHours[teamName] = w
+	}
+	return nil
+}
+
+func (r *MemoryRepo) TeamWorkingHours(ctx context.Context, teamName string) (domain.WorkingHours, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.st.workHours[teamName], nil
+}
+
+func (r *MemoryRepo) SetTeamHolidays(ctx context.Context, _ *sql.Tx, teamName string, holidays []domain.Holiday) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[teamName]; ok {
+		r.st.holidays[teamName] = slices.Clone(holidays)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) ListTeamHolidays(ctx context.Context, teamName string) ([]domain.Holiday, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.Holiday{}, r.st.holidays[teamName]...), nil
+}
+
+// holidayTime is domain.HolidayTime on the calendar of team.
+func (r *MemoryRepo) holidayTime(team string, from, to time.Time) time.Duration {
+	return domain.HolidayTime(r.st.holidays[team], r.st.workHours[team].Location(), from, to)
+}
+
+func (r *MemoryRepo) DeferReview(ctx context.Context, _ *sql.Tx, prID, userID string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.reviewerIndex(prID, userID); i >= 0 {
+		r.st.reviewers[prID][i].DeliverAt = &until
+	}
+	return nil
+}
+
+func (r *MemoryRepo) ListDueDeferredReviews(ctx context.Context, now time.Time) ([]domain.DeferredReview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.DeferredReview
+	for _, pr := range r.sortedPRs() {
+		if pr.Status != domain.StatusOPEN {
+			continue
+		}
+		for _, rv := range r.st.reviewers[pr.ID] {
+			if rv.DeliverAt != nil && !rv.DeliverAt.After(now) {
+				out = append(out, domain.DeferredReview{PRID: pr.ID, UserID: rv.UserID, DeliverAt: *rv.DeliverAt})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DeliverAt.Before(out[j].DeliverAt) })
+	return out, nil
+}
+
+func (r *MemoryRepo) ClearDeferredReview(ctx context.Context, _ *sql.Tx, prID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.reviewerIndex(prID, userID); i >= 0 {
+		r.st.reviewers[prID][i].DeliverAt = nil
+	}
+	return nil
+}
+
+// delivered tells whether the assignment of userID to prID is visible to the
+// reviewer, i.e. not queued outside working hours.
+func (r *MemoryRepo) delivered(prID, userID string) bool {
+	i := r.reviewerIndex(prID, userID)
+	if i < 0 {
+		return false
+	}
+	at := r.st.reviewers[prID][i].DeliverAt
+	return at == nil || !at.After(r.now())
+}
+
+func (r *MemoryRepo) ListStalledPRs(ctx context.Context) ([]domain.StalledPR, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.StalledPR
+	for _, pr := range r.st.prs {
+		team := r.st.users[pr.AuthorID].TeamName
+		policy := r.st.escalation[team]
+		if pr.Status != domain.StatusOPEN || policy.IsZero() || r.blocked(pr) {
+			continue
+		}
+		acted := false
+		for _, rv := range r.st.reviewers[pr.ID] {
+			acted = acted || rv.FirstActionAt != nil
+		}
+		if acted {
+			continue
+		}
+		p := domain.StalledPR{PRID: pr.ID, AuthorID: pr.AuthorID, TeamName: team, CreatedAt: pr.CreatedAt, Policy: policy}
+		for _, e := range r.st.events {
+			if e.PRID == pr.ID && e.Kind == domain.PREventEscalated && !slices.Contains(p.Done, e.Reason) {
+				p.Done = append(p.Done, e.Reason)
+			}
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].PRID < out[j].PRID
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) UpsertUser(ctx context.Context, _ *sql.Tx, u domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[u.TeamName]; !ok {
+		return fmt.Errorf("team %q does not exist", u.TeamName)
+	}
+	r.st.users[u.UserID] = u
+	return nil
+}
+
+func (r *MemoryRepo) GetTeamMembers(ctx context.Context, teamName string) ([]domain.TeamMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.TeamMember
+	for _, u := range r.sortedUsers() {
+		if u.TeamName == teamName {
+			out = append(out, domain.TeamMember{UserID: u.UserID, Username: u.Username, IsActive: u.IsActive})
+		}
+	}
+	return out, nil
+}
+
+// sortedUsers lists users by id.
+func (r *MemoryRepo) sortedUsers() []domain.User {
+	out := make([]domain.User, 0, len(r.st.users))
+	for _, u := range r.st.users {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+	return out
+}
+
+func (r *MemoryRepo) SetUserActive(ctx context.Context, uID string, active bool) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.st.users[uID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user not found")
+	}
+	u.IsActive = active
+	r.st.users[uID] = u
+	return &u, nil
+}
+
+func (r *MemoryRepo) AnonymizeUser(ctx context.Context, _ *sql.Tx, uID, placeholder string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.st.users[uID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user not found")
+	}
+	u.Username, u.IsActive = placeholder, false
+	r.st.users[uID] = u
+	return nil
+}
+
+func (r *MemoryRepo) SetUsername(ctx context.Context, _ *sql.Tx, uID, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.st.users[uID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user not found")
+	}
+	u.Username = username
+	r.st.users[uID] = u
+	return nil
+}
+
+func (r *MemoryRepo) GetUser(ctx context.Context, uID string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.st.users[uID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user not found")
+	}
+	return &u, nil
+}
+
+func (r *MemoryRepo) CreatePR(ctx context.Context, _ *sql.Tx, pr domain.PullRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.prs[pr.ID]; ok {
+		return fmt.Errorf("PR %q already exists", pr.ID)
+	}
+	if _, ok := r.st.users[pr.AuthorID]; !ok {
+		return fmt.Errorf("author %q does not exist", pr.AuthorID)
+	}
+	r.st.prs[pr.ID] = memPR{
+		ID: pr.ID, Name: pr.Name, AuthorID: pr.AuthorID, Repository: pr.Repository,
+		Status: domain.StatusOPEN, CreatedAt: r.now(),
+	}
+	return nil
+}
+
+func (r *MemoryRepo) ImportPR(ctx context.Context, _ *sql.Tx, pr domain.ImportedPR) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.prs[pr.ID]; ok {
+		return fmt.Errorf("PR %q already exists", pr.ID)
+	}
+	if _, ok := r.st.users[pr.AuthorID]; !ok {
+		return fmt.Errorf("author %q does not exist", pr.AuthorID)
+	}
+	p := memPR{
+		ID: pr.ID, Name: pr.Name, AuthorID: pr.AuthorID, Repository: pr.Repository,
+		Status: domain.StatusOPEN, CreatedAt: pr.CreatedAt, MergedAt: pr.MergedAt,
+	}
+	if pr.MergedAt != nil {
+		p.Status = domain.StatusMERGED
+	}
+	r.st.prs[pr.ID] = p
+	for _, rv := range pr.Reviewers {
+		m := memReviewer{UserID: rv.UserID, AssignedAt: pr.CreatedAt, State: domain.ReviewPending}
+		if rv.ApprovedAt != nil {
+			m.State, m.DecidedAt, m.FirstActionAt = domain.ReviewApproved, rv.ApprovedAt, rv.ApprovedAt
+		}
+		r.st.reviewers[pr.ID] = append(r.st.reviewers[pr.ID], m)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.getPR(prID)
+}
+
+func (r *MemoryRepo) getPR(prID string) (*domain.PullRequest, error) {
+	p, ok := r.st.prs[prID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "PR not found")
+	}
+	created := p.CreatedAt
+	pr := &domain.PullRequest{
+		ID: p.ID, Name: p.Name, AuthorID: p.AuthorID, Repository: p.Repository, Status: p.Status,
+		AssignedReviewers: r.assigned(prID), CreatedAt: &created,
+	}
+	if p.MergedAt != nil {
+		merged := *p.MergedAt
+		pr.MergedAt = &merged
+	}
+	if p.ClosedAt != nil {
+		closed := *p.ClosedAt
+		pr.ClosedAt = &closed
+	}
+	return pr, nil
+}
+
+func (r *MemoryRepo) SetPRAuthor(ctx context.Context, _ *sql.Tx, prID, authorID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.st.prs[prID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "PR not found")
+	}
+	p.AuthorID = authorID
+	r.st.prs[prID] = p
+	return nil
+}
+
+func (r *MemoryRepo) SetPRMerged(ctx context.Context, _ *sql.Tx, prID string) (*domain.PullRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.st.prs[prID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "PR not found")
+	}
+	now := r.now()
+	p.Status, p.MergedAt = domain.StatusMERGED, &now
+	r.st.prs[prID] = p
+	return r.getPR(prID)
+}
+
+func (r *MemoryRepo) SetPRStatus(ctx context.Context, _ *sql.Tx, prID string, status domain.PRStatus) (*domain.PullRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.st.prs[prID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "PR not found")
+	}
+	p.Status, p.ClosedAt = status, nil
+	if status == domain.StatusCLOSED {
+		now := r.now()
+		p.ClosedAt = &now
+	}
+	r.st.prs[prID] = p
+	return r.getPR(prID)
+}
+
+func (r *MemoryRepo) GetAuthorTeam(ctx context.Context, authorID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.st.users[authorID]
+	if !ok {
+		return "", domain.NewError(domain.ErrNotFound, "author not found")
+	}
+	return u.TeamName, nil
+}
+
+// PickReviewersFromTeam orders candidates by md5(prID || user_id), as
+// PostgresRepo does, so picks are stable for a PR but vary across PRs.
+func (r *MemoryRepo) PickReviewersFromTeam(ctx context.Context, prID, team string, exclude []string, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type candidate struct{ id, key string }
+	var cs []candidate
+	for _, u := range r.st.users {
+		if u.TeamName == team && u.IsActive && !slices.Contains(exclude, u.UserID) {
+			sum := md5.Sum([]byte(prID + u.UserID))
+			cs = append(cs, candidate{u.UserID, hex.EncodeToString(sum[:])})
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].key < cs[j].key })
+	var out []string
+	for _, c := range cs {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, c.id)
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) ListRecentReviewers(ctx context.Context, authorID string, since time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for id, revs := range r.st.reviewers {
+		p := r.st.prs[id]
+		if p.AuthorID != authorID {
+			continue
+		}
+		for _, rv := range revs {
+			ended := rv.DecidedAt
+			if ended == nil {
+				ended = p.MergedAt
+			}
+			if ended != nil && !ended.Before(since) && !slices.Contains(out, rv.UserID) {
+				out = append(out, rv.UserID)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func (r *MemoryRepo) GetAssignedReviewers(ctx context.Context, prID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.assigned(prID)...), nil
+}
+
+func (r *MemoryRepo) GetReviewStates(ctx context.Context, prID string) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[string]string{}
+	for _, rv := range r.st.reviewers[prID] {
+		out[rv.UserID] = rv.State
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) ListReviews(ctx context.Context, prID string) ([]domain.Review, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Review
+	for _, rv := range r.st.reviewers[prID] {
+		out = append(out, domain.Review{UserID: rv.UserID, State: rv.State, DecidedAt: rv.DecidedAt})
+	}
+	slices.SortFunc(out, func(a, b domain.Review) int { return strings.Compare(a.UserID, b.UserID) })
+	return out, nil
+}
+
+func (r *MemoryRepo) DecideReview(ctx context.Context, _ *sql.Tx, prID, userID, state string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.reviewerIndex(prID, userID)
+	if i < 0 {
+		return false, domain.NewError(domain.ErrNotAssigned, "reviewer is not assigned to this PR")
+	}
+	rv := &r.st.reviewers[prID][i]
+	if rv.State == state {
+		return false, nil
+	}
+	now := r.now()
+	rv.State, rv.DecidedAt = state, &now
+	if rv.FirstActionAt == nil {
+		rv.FirstActionAt = &now
+	}
+	return true, nil
+}
+
+// assigned lists the reviewers of a PR by user id; nil when there are none.
+func (r *MemoryRepo) assigned(prID string) []string {
+	var out []string
+	for _, rv := range r.st.reviewers[prID] {
+		out = append(out, rv.UserID)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *MemoryRepo) AssignReviewers(ctx context.Context, _ *sql.Tx, prID string, userIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range userIDs {
+		r.addReviewer(prID, id)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) addReviewer(prID, userID string) {
+	if r.reviewerIndex(prID, userID) >= 0 {
+		return
+	}
+	r.st.reviewers[prID] = append(r.st.reviewers[prID], memReviewer{UserID: userID, AssignedAt: r.now(), State: "PENDING"})
+}
+
+func (r *MemoryRepo) reviewerIndex(prID, userID string) int {
+	return slices.IndexFunc(r.st.reviewers[prID], func(rv memReviewer) bool { return rv.UserID == userID })
+}
+
+func (r *MemoryRepo) ReplaceReviewer(ctx context.Context, _ *sql.Tx, prID, oldUser, newUser string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleteReviewer(prID, oldUser)
+	r.addReviewer(prID, newUser)
+	return nil
+}
+
+func (r *MemoryRepo) DeleteReviewer(ctx context.Context, _ *sql.Tx, prID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleteReviewer(prID, userID)
+	return nil
+}
+
+func (r *MemoryRepo) deleteReviewer(prID, userID string) {
+	if i := r.reviewerIndex(prID, userID); i >= 0 {
+		r.st.reviewers[prID] = slices.Delete(r.st.reviewers[prID], i, i+1)
+	}
+}
+
+func (r *MemoryRepo) AddPRDependency(ctx context.Context, _ *sql.Tx, prID, blockedBy string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.st.blockers[prID], blockedBy) {
+		r.st.blockers[prID] = append(r.st.blockers[prID], blockedBy)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) DeletePRDependency(ctx context.Context, _ *sql.Tx, prID, blockedBy string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st.blockers[prID] = slices.DeleteFunc(r.st.blockers[prID], func(id string) bool { return id == blockedBy })
+	return nil
+}
+
+func (r *MemoryRepo) ListPRDependencies(ctx context.Context, prID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := slices.Clone(r.st.blockers[prID])
+	slices.Sort(out)
+	return out, nil
+}
+
+func (r *MemoryRepo) SetGitHubLogin(ctx context.Context, _ *sql.Tx, uID, login string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if owner, ok := r.st.logins[login]; ok && owner != uID {
+		return domain.NewError(domain.ErrInvalid, "github login "+login+" is mapped to another user")
+	}
+	maps.DeleteFunc(r.st.logins, func(_, id string) bool { return id == uID })
+	if login != "" {
+		r.st.logins[login] = uID
+	}
+	return nil
+}
+
+func (r *MemoryRepo) GitHubLoginUser(ctx context.Context, login string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.st.logins[login]
+	if !ok {
+		return "", domain.NewError(domain.ErrNotFound, "github login "+login+" is not mapped to a user")
+	}
+	return id, nil
+}
+
+func (r *MemoryRepo) AddPRWatcher(ctx context.Context, _ *sql.Tx, prID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.st.watchers[prID], userID) {
+		r.st.watchers[prID] = append(r.st.watchers[prID], userID)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) DeletePRWatcher(ctx context.Context, _ *sql.Tx, prID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st.watchers[prID] = slices.DeleteFunc(r.st.watchers[prID], func(id string) bool { return id == userID })
+	return nil
+}
+
+func (r *MemoryRepo) ListPRWatchers(ctx context.Context, prID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := slices.Clone(r.st.watchers[prID])
+	slices.Sort(out)
+	return out, nil
+}
+
+func (r *MemoryRepo) AddPRComment(ctx context.Context, _ *sql.Tx, c *domain.Comment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.ID, c.CreatedAt = r.nextID(), r.now()
+	r.st.comments = append(r.st.comments, *c)
+	return nil
+}
+
+func (r *MemoryRepo) ListPRComments(ctx context.Context, prID string) ([]domain.Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []domain.Comment{}
+	for _, c := range r.st.comments {
+		if c.PRID == prID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// blocked reports whether the PR is open and depends on an unmerged PR.
+func (r *MemoryRepo) blocked(pr memPR) bool {
+	if pr.Status != domain.StatusOPEN {
+		return false
+	}
+	for _, id := range r.st.blockers[pr.ID] {
+		if r.st.prs[id].Status != domain.StatusMERGED {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryRepo) ListUserPRs(ctx context.Context, uID string, p domain.PageQuery) ([]domain.PullRequestShort, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prPage(func(pr memPR) bool { return r.delivered(pr.ID, uID) }, p, true), nil
+}
+
+func (r *MemoryRepo) ListOpenReviews(ctx context.Context, userIDs []string) (map[string][]domain.PullRequestShort, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wanted := map[string]bool{}
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	out := map[string][]domain.PullRequestShort{}
+	for _, pr := range r.sortedPRs() {
+		if pr.Status != domain.StatusOPEN {
+			continue
+		}
+		for _, rv := range r.st.reviewers[pr.ID] {
+			if wanted[rv.UserID] && r.delivered(pr.ID, rv.UserID) {
+				out[rv.UserID] = append(out[rv.UserID], domain.PullRequestShort{ID: pr.ID, Name: pr.Name, AuthorID: pr.AuthorID, Status: pr.Status, Blocked: r.blocked(pr)})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) ListPRs(ctx context.Context, f domain.PRFilter, p domain.PageQuery) ([]domain.PullRequestShort, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prPage(func(pr memPR) bool {
+		return (inTeams(f.Teams, r.st.users[pr.AuthorID].TeamName) || f.IncludeReviewing && r.reviewedByTeams(pr.ID, f.Teams)) &&
+			(f.AuthorID == "" || pr.AuthorID == f.AuthorID) &&
+			(f.Status == "" || pr.Status == f.Status)
+	}, p, false), nil
+}
+
+// reviewedByTeams reports whether a reviewer of the PR is in one of teams.
+func (r *MemoryRepo) reviewedByTeams(prID string, teams []string) bool {
+	for _, rv := range r.st.reviewers[prID] {
+		if inTeams(teams, r.st.users[rv.UserID].TeamName) {
+			return true
+		}
+	}
+	return false
+}
+
+// prPage returns up to p.Limit+1 matching PRs in the order of p.Sort,
+// blocked ones last if blockedLast, like PostgresRepo.queryPRPage.
+func (r *MemoryRepo) prPage(match func(memPR) bool, p domain.PageQuery, blockedLast bool) []domain.PullRequestShort {
+	prs := r.sortedPRs()
+	switch p.Sort {
+	case domain.PRSortCreated:
+		sort.SliceStable(prs, func(i, j int) bool { return prs[i].CreatedAt.Before(prs[j].CreatedAt) })
+	case domain.PRSortNewest:
+		sort.SliceStable(prs, func(i, j int) bool { return prs[i].CreatedAt.After(prs[j].CreatedAt) })
+	}
+	if blockedLast {
+		sort.SliceStable(prs, func(i, j int) bool { return !r.blocked(prs[i]) && r.blocked(prs[j]) })
+	}
+	out := []domain.PullRequestShort{}
+	skip := p.Offset
+	for _, pr := range prs {
+		if !match(pr) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if len(out) == p.Limit+1 {
+			break
+		}
+		out = append(out, domain.PullRequestShort{ID: pr.ID, Name: pr.Name, AuthorID: pr.AuthorID, Status: pr.Status, Blocked: r.blocked(pr)})
+	}
+	return out
+}
+
+// sortedPRs lists PRs by id.
+func (r *MemoryRepo) sortedPRs() []memPR {
+	out := make([]memPR, 0, len(r.st.prs))
+	for _, p := range r.st.prs {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (r *MemoryRepo) BulkDeactivateUsers(ctx context.Context, team string, userIDs []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	target := []string{}
+	for _, u := range r.sortedUsers() {
+		if u.TeamName == team && slices.Contains(userIDs, u.UserID) {
+			u.IsActive = false
+			r.st.users[u.UserID] = u
+			target = append(target, u.UserID)
+		}
+	}
+	return target, nil
+}
+
+func (r *MemoryRepo) ListOpenAssignmentsByUsers(ctx context.Context, userIDs []string) ([]domain.OpenAssignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.OpenAssignment
+	for _, p := range r.sortedPRs() {
+		if p.Status != domain.StatusOPEN {
+			continue
+		}
+		for _, id := range r.assigned(p.ID) {
+			if slices.Contains(userIDs, id) {
+				out = append(out, domain.OpenAssignment{PRID: p.ID, AuthorID: p.AuthorID, OldUserID: id, OldUserTeam: r.st.users[id].TeamName})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) CreateAPIToken(ctx context.Context, _ *sql.Tx, t domain.APIToken) (*domain.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.tokens[t.ID]; ok {
+		return nil, fmt.Errorf("token %q already exists", t.ID)
+	}
+	if _, ok := r.st.roles[t.Role]; !ok {
+		return nil, fmt.Errorf("role %q does not exist", t.Role)
+	}
+	t.CreatedAt, t.RevokedAt = r.now(), nil
+	t.Teams = slices.Clone(t.Teams)
+	r.st.tokens[t.ID] = t
+	return &t, nil
+}
+
+func (r *MemoryRepo) GetAPIToken(ctx context.Context, tokenID string) (*domain.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.st.tokens[tokenID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "token not found")
+	}
+	return &t, nil
+}
+
+func (r *MemoryRepo) GetAPITokenByHash(ctx context.Context, hash string) (*domain.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.st.tokens {
+		if t.Hash == hash {
+			return &t, nil
+		}
+	}
+	return nil, domain.NewError(domain.ErrNotFound, "token not found")
+}
+
+func (r *MemoryRepo) SetAPITokenExpiry(ctx context.Context, _ *sql.Tx, tokenID string, expiresAt time.Time) (*domain.APIToken, error) {
+	return r.updateToken(tokenID, func(t *domain.APIToken) {
+		exp := expiresAt.UTC()
+		t.ExpiresAt = &exp
+	})
+}
+
+func (r *MemoryRepo) RevokeAPIToken(ctx context.Context, tokenID string) (*domain.APIToken, error) {
+	return r.updateToken(tokenID, func(t *domain.APIToken) {
+		if t.RevokedAt == nil {
+			now := r.now()
+			t.RevokedAt = &now
+		}
+	})
+}
+
+func (r *MemoryRepo) updateToken(tokenID string, fn func(*domain.APIToken)) (*domain.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.st.tokens[tokenID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "token not found")
+	}
+	fn(&t)
+	r.st.tokens[tokenID] = t
+	return &t, nil
+}
+
+func (r *MemoryRepo) RevokeUserAPITokens(ctx context.Context, _ *sql.Tx, userID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := []string{}
+	now := r.now()
+	for id, t := range r.st.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			r.st.tokens[id] = t
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (r *MemoryRepo) ListAPITokens(ctx context.Context, userID string) ([]domain.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []domain.APIToken{}
+	for _, t := range r.st.tokens {
+		if userID == "" || t.UserID == userID {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) ListRolePermissions(ctx context.Context) ([]domain.RolePermissions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []domain.RolePermissions{}
+	for role, perms := range r.st.roles {
+		p := append([]domain.Permission{}, perms...)
+		slices.Sort(p)
+		out = append(out, domain.RolePermissions{Role: role, Permissions: p})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Role < out[j].Role })
+	return out, nil
+}
+
+func (r *MemoryRepo) SetRolePermissions(ctx context.Context, _ *sql.Tx, role string, perms []domain.Permission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st.roles[role] = slices.Clone(perms)
+	return nil
+}
+
+func (r *MemoryRepo) RoleExists(ctx context.Context, role string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.st.roles[role]
+	return ok, nil
+}
+
+func (r *MemoryRepo) InsertAuthEvents(ctx context.Context, events []domain.AuthEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range events {
+		e.ID = r.nextID()
+		r.st.authEvents = append(r.st.authEvents, e)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) ListAuthEvents(ctx context.Context, f domain.AuthEventFilter) ([]domain.AuthEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []domain.AuthEvent{}
+	for i := len(r.st.authEvents) - 1; i >= 0; i-- {
+		e := r.st.authEvents[i]
+		if (f.Since != nil && e.Time.Before(*f.Since)) || (f.Until != nil && !e.Time.Before(*f.Until)) ||
+			(f.Outcome != "" && e.Outcome != f.Outcome) || (f.TokenID != "" && e.TokenID != f.TokenID) ||
+			(f.UserID != "" && e.UserID != f.UserID) {
+			continue
+		}
+		e.Time = e.Time.UTC()
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) AddTokenUsage(ctx context.Context, usage []domain.TokenUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range usage {
+		cur, ok := r.st.usage[u.TokenID]
+		if ok {
+			u.Requests += cur.Requests
+			u.Errors += cur.Errors
+			u.FirstUsedAt = cur.FirstUsedAt
+			if cur.LastUsedAt != nil && (u.LastUsedAt == nil || cur.LastUsedAt.After(*u.LastUsedAt)) {
+				u.LastUsedAt = cur.LastUsedAt
+			}
+		}
+		r.st.usage[u.TokenID] = u
+	}
+	return nil
+}
+
+// ListTokenUsage includes active tokens that were never used, as
+// PostgresRepo does.
+func (r *MemoryRepo) ListTokenUsage(ctx context.Context, idleSince *time.Time) ([]domain.TokenUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := cloneMap(r.st.usage)
+	for id, t := range r.st.tokens {
+		if _, ok := all[id]; !ok && t.RevokedAt == nil {
+			all[id] = domain.TokenUsage{TokenID: id, Method: "token", UserID: t.UserID, Role: t.Role}
+		}
+	}
+	out := []domain.TokenUsage{}
+	for _, u := range all {
+		if idleSince == nil || u.LastUsedAt == nil || u.LastUsedAt.Before(*idleSince) {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].LastUsedAt, out[j].LastUsedAt
+		switch {
+		case a == nil && b == nil:
+			return out[i].TokenID < out[j].TokenID
+		case a == nil || b == nil:
+			return a == nil
+		case !a.Equal(*b):
+			return a.Before(*b)
+		}
+		return out[i].TokenID < out[j].TokenID
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) CreateWebhook(ctx context.Context, w domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.Events = slices.Clone(w.Events)
+	r.st.webhooks = append(r.st.webhooks, w)
+	return nil
+}
+
+func (r *MemoryRepo) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.st.webhooks), nil
+}
+
+func (r *MemoryRepo) DeleteWebhook(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.st.webhooks, func(w domain.Webhook) bool { return w.ID == id })
+	if i < 0 {
+		return domain.NewError(domain.ErrNotFound, "webhook not found")
+	}
+	r.st.webhooks = slices.Delete(r.st.webhooks, i, i+1)
+	return nil
+}
+
+func (r *MemoryRepo) CreateExport(ctx context.Context, e domain.Export) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st.exports[e.ID] = memExport{Export: e}
+	return nil
+}
+
+func (r *MemoryRepo) FinishExport(ctx context.Context, id string, content []byte, errMsg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.st.exports[id]
+	if !ok {
+		return nil
+	}
+	e.Status, e.Content, e.Error = domain.ExportReady, content, errMsg
+	if errMsg != "" {
+		e.Status, e.Content = domain.ExportFailed, nil
+	}
+	now := r.now()
+	e.FinishedAt = &now
+	r.st.exports[id] = e
+	return nil
+}
+
+func (r *MemoryRepo) GetExport(ctx context.Context, id string) (*domain.Export, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.st.exports[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "export not found")
+	}
+	return &e.Export, nil
+}
+
+func (r *MemoryRepo) GetExportContent(ctx context.Context, id string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.st.exports[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "export not found")
+	}
+	return e.Content, nil
+}
+
+func (r *MemoryRepo) AcknowledgeReview(ctx context.Context, prID, userID string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.reviewerIndex(prID, userID)
+	if i < 0 {
+		return time.Time{}, domain.NewError(domain.ErrNotAssigned, "reviewer is not assigned to this PR")
+	}
+	rv := &r.st.reviewers[prID][i]
+	if rv.FirstActionAt == nil {
+		now := r.now()
+		rv.FirstActionAt = &now
+		r.st.events = append(r.st.events, domain.PREvent{PRID: prID, Kind: "acknowledged", UserID: userID, At: now})
+	}
+	return *rv.FirstActionAt, nil
+}
+
+func (r *MemoryRepo) AddPREvents(ctx context.Context, _ *sql.Tx, events []domain.PREvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range events {
+		if e.At.IsZero() {
+			e.At = r.now()
+		}
+		r.st.events = append(r.st.events, e)
+	}
+	return nil
+}
+
+func (r *MemoryRepo) ListPREvents(ctx context.Context, prID string) ([]domain.PREvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []domain.PREvent{}
+	for _, e := range r.st.events {
+		if e.PRID == prID {
+			e.At = e.At.UTC()
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (r *MemoryRepo) AddNoCandidateEvent(ctx context.Context, e domain.NoCandidateEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.At = r.now()
+	r.st.noCandidate = append(r.st.noCandidate, e)
+	return nil
+}
+
+func (r *MemoryRepo) ListNoCandidateEvents(ctx context.Context, teams []string, from, until time.Time) ([]domain.NoCandidateEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.NoCandidateEvent
+	for _, e := range r.st.noCandidate {
+		if inTeams(teams, e.TeamName) && !e.At.Before(from) && e.At.Before(until) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (r *MemoryRepo) ListReviewerLoads(ctx context.Context) ([]domain.ReviewerLoad, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.ReviewerLoad
+	for _, u := range r.sortedUsers() {
+		if !u.IsActive {
+			continue
+		}
+		l := domain.ReviewerLoad{UserID: u.UserID, TeamName: u.TeamName}
+		for prID := range r.st.reviewers {
+			if r.st.prs[prID].Status == domain.StatusOPEN && r.reviewerIndex(prID, u.UserID) >= 0 {
+				l.Open++
+			}
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) SyncOverloadAlerts(ctx context.Context, current []domain.OverloadAlert) ([]domain.OverloadAlert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	active := map[string]bool{}
+	for i, a := range r.st.alerts {
+		if a.ResolvedAt != nil {
+			continue
+		}
+		if !slices.ContainsFunc(current, func(c domain.OverloadAlert) bool { return c.UserID == a.UserID }) {
+			r.st.alerts[i].ResolvedAt = &now
+			continue
+		}
+		active[a.UserID] = true
+	}
+	var opened []domain.OverloadAlert
+	for _, a := range current {
+		lead, ok := r.st.teams[a.TeamName]
+		if !ok || active[a.UserID] {
+			continue
+		}
+		a.ID, a.LeadUserID, a.CreatedAt, a.ResolvedAt = r.nextID(), lead, now, nil
+		r.st.alerts = append(r.st.alerts, a)
+		active[a.UserID] = true
+		opened = append(opened, a)
+	}
+	return opened, nil
+}
+
+func (r *MemoryRepo) ListOverloadAlerts(ctx context.Context, teams []string, includeResolved bool) ([]domain.OverloadAlert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []domain.OverloadAlert{}
+	for i := len(r.st.alerts) - 1; i >= 0; i-- {
+		a := r.st.alerts[i]
+		if inTeams(teams, a.TeamName) && (includeResolved || a.ResolvedAt == nil) {
+			out = append(out, a)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *MemoryRepo) SetTeamLead(ctx context.Context, team, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.st.teams[team]; !ok {
+		return domain.NewError(domain.ErrNotFound, "team not found")
+	}
+	r.st.teams[team] = userID
+	return nil
+}
+
+func (r *MemoryRepo) GetTeamLead(ctx context.Context, team string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.st.teams[team], nil
+}
+
+func (r *MemoryRepo) Search(ctx context.Context, q domain.SearchQuery) ([]domain.SearchResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	text := strings.ToLower(q.Text)
+	matches := func(s ...string) bool {
+		for _, v := range s {
+			if strings.Contains(strings.ToLower(v), text) {
+				return true
+			}
+		}
+		return false
+	}
+	out := []domain.SearchResult{}
+	for _, t := range q.Types {
+		var found []domain.SearchResult
+		switch t {
+		case domain.SearchTeam:
+			for name := range r.st.teams {
+				if matches(name) && inTeams(q.Teams, name) {
+					found = append(found, domain.SearchResult{Type: t, ID: name, Name: name})
+				}
+			}
+		case domain.SearchUser:
+			for _, u := range r.st.users {
+				if matches(u.UserID, u.Username) && inTeams(q.Teams, u.TeamName) {
+					found = append(found, domain.SearchResult{Type: t, ID: u.UserID, Name: u.Username, TeamName: u.TeamName})
+				}
+			}
+		case domain.SearchPR:
+			for _, pr := range r.st.prs {
+				if matches(pr.ID, pr.Name) && inTeams(q.Teams, r.st.users[pr.AuthorID].TeamName) {
+					found = append(found, domain.SearchResult{Type: t, ID: pr.ID, Name: pr.Name, Status: pr.Status})
+				}
+			}
+		}
+		sort.Slice(found, func(i, j int) bool {
+			a, b := found[i], found[j]
+			if ra, rb := domain.SearchRank(a, text), domain.SearchRank(b, text); ra != rb {
+				return ra < rb
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.ID < b.ID
+		})
+		if len(found) > q.Limit {
+			found = found[:q.Limit]
+		}
+		out = append(out, found...)
+	}
+	return out, nil
+}
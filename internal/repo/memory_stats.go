@@ -0,0 +1,860 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// percentile interpolates between the closest ranks of sorted values, like
+// percentile_cont.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo == len(sorted)-1 {
+		return sorted[lo]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// durationStats aggregates secs like durationAggregates.
+func durationStats(key string, secs []float64) domain.DurationStats {
+	s := domain.DurationStats{Key: key, Count: len(secs)}
+	if len(secs) == 0 {
+		return s
+	}
+	sorted := slices.Clone(secs)
+	slices.Sort(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	s.AvgSeconds = sum / float64(len(sorted))
+	s.MedianSeconds = percentile(sorted, 0.5)
+	s.P90Seconds = percentile(sorted, 0.9)
+	s.P99Seconds = percentile(sorted, 0.99)
+	return s
+}
+
+// groupedStats turns per-key samples into DurationStats ordered by key.
+func groupedStats(groups map[string][]float64) []domain.DurationStats {
+	out := []domain.DurationStats{}
+	for k, secs := range groups {
+		out = append(out, durationStats(k, secs))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func utcDate(t time.Time) string { return t.UTC().Format(time.DateOnly) }
+
+// dayRange lists the UTC dates from..to inclusive.
+func dayRange(from, to time.Time) []time.Time {
+	var out []time.Time
+	d := from.UTC().Truncate(24 * time.Hour)
+	for end := to.UTC().Truncate(24 * time.Hour); !d.After(end); d = d.AddDate(0, 0, 1) {
+		out = append(out, d)
+	}
+	return out
+}
+
+// inDates reports whether t falls on a UTC date in [from, to].
+func inDates(t, from, to time.Time) bool {
+	d := utcDate(t)
+	return d >= utcDate(from) && d <= utcDate(to)
+}
+
+func (r *MemoryRepo) authorTeam(p memPR) string { return r.st.users[p.AuthorID].TeamName }
+
+func (r *MemoryRepo) StatsAssignmentsByUser(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	return r.assignmentCounts(q, false, func(p memPR, rv memReviewer) (string, bool) {
+		return rv.UserID, inTeams(q.Teams, r.st.users[rv.UserID].TeamName)
+	}, func(c *domain.AssignmentCount) *string { return &c.UserID })
+}
+
+func (r *MemoryRepo) StatsAssignmentsByTeam(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	return r.assignmentCounts(q, false, func(p memPR, rv memReviewer) (string, bool) {
+		team := r.st.users[rv.UserID].TeamName
+		return team, inTeams(q.Teams, team)
+	}, func(c *domain.AssignmentCount) *string { return &c.TeamName })
+}
+
+func (r *MemoryRepo) StatsAssignmentsByPR(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	return r.assignmentCounts(q, false, func(p memPR, rv memReviewer) (string, bool) {
+		return p.ID, inTeams(q.Teams, r.authorTeam(p))
+	}, func(c *domain.AssignmentCount) *string { return &c.PRID })
+}
+
+func (r *MemoryRepo) StatsAssignmentsByRepository(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	return r.assignmentCounts(q, true, func(p memPR, rv memReviewer) (string, bool) {
+		return p.Repository, inTeams(q.Teams, r.authorTeam(p))
+	}, func(c *domain.AssignmentCount) *string { return &c.Repository })
+}
+
+// assignmentCounts counts assignments by the key group returns and pages
+// them like queryAssignmentCounts: the total is 0 past the end. With
+// needRepo, PRs without a repository are left out.
+func (r *MemoryRepo) assignmentCounts(q domain.AssignmentQuery, needRepo bool, group func(memPR, memReviewer) (string, bool),
+	key func(*domain.AssignmentCount) *string) ([]domain.AssignmentCount, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := map[string]int{}
+	for prID, rvs := range r.st.reviewers {
+		p := r.st.prs[prID]
+		if needRepo && p.Repository == "" {
+			continue
+		}
+		for _, rv := range rvs {
+			if len(q.UserIDs) > 0 && !slices.Contains(q.UserIDs, rv.UserID) {
+				continue
+			}
+			if k, ok := group(p, rv); ok {
+				counts[k]++
+			}
+		}
+	}
+	all := make([]domain.AssignmentCount, 0, len(counts))
+	for k, n := range counts {
+		c := domain.AssignmentCount{Count: n}
+		*key(&c) = k
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if q.Sort == domain.SortByCount && all[i].Count != all[j].Count {
+			return all[i].Count > all[j].Count
+		}
+		return *key(&all[i]) < *key(&all[j])
+	})
+	if q.Offset >= len(all) {
+		return []domain.AssignmentCount{}, 0, nil
+	}
+	page := all[q.Offset:]
+	if q.Limit > 0 && len(page) > q.Limit {
+		page = page[:q.Limit]
+	}
+	return page, len(all), nil
+}
+
+// firstApproval returns the earliest approval of a PR, if any.
+func (r *MemoryRepo) firstApproval(prID string) (memReviewer, bool) {
+	var first memReviewer
+	found := false
+	for _, rv := range r.st.reviewers[prID] {
+		if rv.State == "APPROVED" && rv.DecidedAt != nil && (!found || rv.DecidedAt.Before(*first.DecidedAt)) {
+			first, found = rv, true
+		}
+	}
+	return first, found
+}
+
+func (r *MemoryRepo) StatsTimeToFirstApproval(ctx context.Context, teams []string) ([]domain.DurationStats, []domain.DurationStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byTeam, byReviewer := map[string][]float64{}, map[string][]float64{}
+	for _, p := range r.st.prs {
+		team := r.authorTeam(p)
+		if !inTeams(teams, team) {
+			continue
+		}
+		if rv, ok := r.firstApproval(p.ID); ok {
+			secs := rv.DecidedAt.Sub(rv.AssignedAt).Seconds()
+			byTeam[team] = append(byTeam[team], secs)
+			byReviewer[rv.UserID] = append(byReviewer[rv.UserID], secs)
+		}
+	}
+	return groupedStats(byTeam), groupedStats(byReviewer), nil
+}
+
+func (r *MemoryRepo) ListSLABreaches(ctx context.Context, f domain.SLAFilter) ([]domain.SLABreach, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	out := []domain.SLABreach{}
+	for _, p := range r.sortedPRs() {
+		team := r.authorTeam(p)
+		if (f.Since != nil && p.CreatedAt.Before(*f.Since)) || (f.Until != nil && !p.CreatedAt.Before(*f.Until)) || !inTeams(f.Teams, team) {
+			continue
+		}
+		b := domain.SLABreach{PRID: p.ID, AuthorID: p.AuthorID, TeamName: team, Status: p.Status}
+		wait := func(end time.Time) float64 {
+			return (end.Sub(p.CreatedAt) - r.holidayTime(team, p.CreatedAt, end)).Seconds()
+		}
+		if rv, ok := r.firstApproval(p.ID); ok {
+			secs := wait(*rv.DecidedAt)
+			b.ReviewSeconds = &secs
+		} else if p.Status == domain.StatusOPEN {
+			secs := wait(now)
+			b.ReviewSeconds = &secs
+		}
+		end := now
+		if e := p.endedAt(); e != nil {
+			end = *e
+		}
+		b.MergeSeconds = wait(end)
+		b.ReviewBreach = b.ReviewSeconds != nil && *b.ReviewSeconds > f.Review.Seconds()
+		b.MergeBreach = b.MergeSeconds > f.Merge.Seconds()
+		if b.ReviewBreach || b.MergeBreach {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// mergedSecs collects merge latencies of PRs merged in [since, until) by key.
+func (r *MemoryRepo) mergedSecs(teams []string, since, until *time.Time, key func(memPR) (string, bool)) map[string][]float64 {
+	out := map[string][]float64{}
+	for _, p := range r.st.prs {
+		if p.MergedAt == nil || !inTeams(teams, r.authorTeam(p)) ||
+			(since != nil && p.MergedAt.Before(*since)) || (until != nil && !p.MergedAt.Before(*until)) {
+			continue
+		}
+		if k, ok := key(p); ok {
+			out[k] = append(out[k], p.MergedAt.Sub(p.CreatedAt).Seconds())
+		}
+	}
+	return out
+}
+
+func (r *MemoryRepo) StatsMergeTime(ctx context.Context, teams []string, since, until *time.Time) (domain.DurationStats, []domain.DurationStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byTeam := r.mergedSecs(teams, since, until, func(p memPR) (string, bool) { return r.authorTeam(p), true })
+	var all []float64
+	for _, secs := range byTeam {
+		all = append(all, secs...)
+	}
+	return durationStats("all", all), groupedStats(byTeam), nil
+}
+
+func (r *MemoryRepo) StatsMergeTimeByRepository(ctx context.Context, teams []string, since, until *time.Time) ([]domain.DurationStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return groupedStats(r.mergedSecs(teams, since, until, func(p memPR) (string, bool) {
+		return p.Repository, p.Repository != ""
+	})), nil
+}
+
+// StatsMergeTimeMaterialized has no aggregate to read and computes the
+// unfiltered merge time directly.
+func (r *MemoryRepo) StatsMergeTimeMaterialized(ctx context.Context) (domain.DurationStats, []domain.DurationStats, error) {
+	return r.StatsMergeTime(ctx, nil, nil, nil)
+}
+
+func (r *MemoryRepo) RefreshStats(ctx context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.st.refreshedAt = &now
+	return now, nil
+}
+
+func (r *MemoryRepo) StatsRefreshedAt(ctx context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.st.refreshedAt == nil {
+		return time.Time{}, fmt.Errorf("stats were never refreshed")
+	}
+	return *r.st.refreshedAt, nil
+}
+
+// weekStart truncates t to the Monday of its UTC week, like date_trunc.
+func weekStart(t time.Time) time.Time {
+	d := t.UTC().Truncate(24 * time.Hour)
+	return d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+}
+
+func (r *MemoryRepo) StatsPRStatus(ctx context.Context, teams []string, weekly bool) ([]domain.PRStatusCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type groupKey struct {
+		week   time.Time
+		team   string
+		status domain.PRStatus
+	}
+	counts := map[groupKey]int{}
+	for _, p := range r.st.prs {
+		team := r.authorTeam(p)
+		if !inTeams(teams, team) {
+			continue
+		}
+		k := groupKey{team: team, status: p.Status}
+		if weekly {
+			k.week = weekStart(p.CreatedAt)
+		}
+		counts[k]++
+	}
+	out := []domain.PRStatusCount{}
+	for k, n := range counts {
+		c := domain.PRStatusCount{TeamName: k.team, Status: k.status, Count: n}
+		if weekly {
+			w := k.week
+			c.Week = &w
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Week != nil && !a.Week.Equal(*b.Week) {
+			return a.Week.Before(*b.Week)
+		}
+		if a.TeamName != b.TeamName {
+			return a.TeamName < b.TeamName
+		}
+		return a.Status < b.Status
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsOpenPRAge(ctx context.Context, teams []string) ([]domain.PRAgeCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	type groupKey struct{ team, bucket string }
+	counts := map[groupKey]int{}
+	for _, p := range r.st.prs {
+		team := r.authorTeam(p)
+		if p.Status != domain.StatusOPEN || !inTeams(teams, team) {
+			continue
+		}
+		age := now.Sub(p.CreatedAt)
+		bucket := ">7d"
+		switch {
+		case age < 24*time.Hour:
+			bucket = "<1d"
+		case age < 3*24*time.Hour:
+			bucket = "1-3d"
+		case age < 7*24*time.Hour:
+			bucket = "3-7d"
+		}
+		counts[groupKey{team, bucket}]++
+	}
+	out := []domain.PRAgeCount{}
+	for k, n := range counts {
+		out = append(out, domain.PRAgeCount{TeamName: k.team, Bucket: k.bucket, Count: n})
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsDailyActivity(ctx context.Context, teams []string, from, to time.Time) ([]domain.DayCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assigned, merged := map[string]int{}, map[string]int{}
+	for _, p := range r.st.prs {
+		if !inTeams(teams, r.authorTeam(p)) {
+			continue
+		}
+		for _, rv := range r.st.reviewers[p.ID] {
+			assigned[utcDate(rv.AssignedAt)]++
+		}
+		if p.MergedAt != nil {
+			merged[utcDate(*p.MergedAt)]++
+		}
+	}
+	out := []domain.DayCount{}
+	for _, d := range dayRange(from, to) {
+		day := utcDate(d)
+		out = append(out, domain.DayCount{Date: day, Assignments: assigned[day], Merges: merged[day]})
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsReviewerResponsiveness(ctx context.Context, teams []string, sla time.Duration) ([]domain.ReviewerResponsiveness, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	byUser := map[string]*domain.ReviewerResponsiveness{}
+	secs := map[string][]float64{}
+	for prID, rvs := range r.st.reviewers {
+		for _, rv := range rvs {
+			team := r.st.users[rv.UserID].TeamName
+			// nobody is waiting on reviewers of a closed PR
+			if !inTeams(teams, team) || rv.FirstActionAt == nil && r.st.prs[prID].Status == domain.StatusCLOSED {
+				continue
+			}
+			s := byUser[rv.UserID]
+			if s == nil {
+				s = &domain.ReviewerResponsiveness{UserID: rv.UserID}
+				byUser[rv.UserID] = s
+			}
+			if rv.FirstActionAt == nil {
+				s.Pending++
+				if now.Sub(rv.AssignedAt)-r.holidayTime(team, rv.AssignedAt, now) > sla {
+					s.Overdue++
+				}
+				continue
+			}
+			v := (rv.FirstActionAt.Sub(rv.AssignedAt) - r.holidayTime(team, rv.AssignedAt, *rv.FirstActionAt)).Seconds()
+			secs[rv.UserID] = append(secs[rv.UserID], v)
+			s.Responded++
+			if v <= sla.Seconds() {
+				s.WithinSLA++
+			} else {
+				s.Overdue++
+			}
+		}
+	}
+	out := []domain.ReviewerResponsiveness{}
+	for id, s := range byUser {
+		d := durationStats(id, secs[id])
+		s.AvgSeconds, s.MedianSeconds, s.P90Seconds = d.AvgSeconds, d.MedianSeconds, d.P90Seconds
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+	return out, nil
+}
+
+func (r *MemoryRepo) ListIdleReviewers(ctx context.Context, teams []string, since time.Time) ([]domain.IdleReviewer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := map[string]time.Time{}
+	for _, rvs := range r.st.reviewers {
+		for _, rv := range rvs {
+			if rv.AssignedAt.After(last[rv.UserID]) {
+				last[rv.UserID] = rv.AssignedAt
+			}
+		}
+	}
+	out := []domain.IdleReviewer{}
+	for _, u := range r.sortedUsers() {
+		if !u.IsActive || !inTeams(teams, u.TeamName) {
+			continue
+		}
+		idle := domain.IdleReviewer{TeamName: u.TeamName, UserID: u.UserID, Username: u.Username}
+		if t, ok := last[u.UserID]; ok {
+			if !t.Before(since) {
+				continue
+			}
+			t = t.UTC()
+			idle.LastAssignedAt = &t
+		}
+		out = append(out, idle)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].TeamName < out[j].TeamName })
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsTeamComparison(ctx context.Context, teams []string) ([]domain.TeamComparison, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byTeam := map[string]*domain.TeamComparison{}
+	var names []string
+	for name := range r.st.teams {
+		if inTeams(teams, name) {
+			byTeam[name] = &domain.TeamComparison{TeamName: name}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, u := range r.st.users {
+		if c := byTeam[u.TeamName]; c != nil {
+			c.Members++
+		}
+	}
+	prs, reviewers, merges := map[string]int{}, map[string]int{}, map[string][]float64{}
+	perReviewer := map[string]map[string]float64{}
+	for _, p := range r.st.prs {
+		team := r.authorTeam(p)
+		prs[team]++
+		reviewers[team] += len(r.st.reviewers[p.ID])
+		if p.Status == domain.StatusOPEN {
+			if c := byTeam[team]; c != nil {
+				c.OpenPRs++
+			}
+		}
+		if p.Status == domain.StatusMERGED && p.MergedAt != nil {
+			merges[team] = append(merges[team], p.MergedAt.Sub(p.CreatedAt).Seconds())
+		}
+		for _, rv := range r.st.reviewers[p.ID] {
+			rt := r.st.users[rv.UserID].TeamName
+			if perReviewer[rt] == nil {
+				perReviewer[rt] = map[string]float64{}
+			}
+			perReviewer[rt][rv.UserID]++
+		}
+	}
+	out := []domain.TeamComparison{}
+	for _, name := range names {
+		c := byTeam[name]
+		if prs[name] > 0 {
+			c.AvgReviewersPerPR = float64(reviewers[name]) / float64(prs[name])
+		}
+		c.MedianMergeSeconds = durationStats(name, merges[name]).MedianSeconds
+		// Herfindahl index of assignments over the team's reviewers
+		var sum, squares float64
+		for _, n := range perReviewer[name] {
+			sum += n
+			squares += n * n
+		}
+		if sum > 0 {
+			c.AssignmentConcentration = squares / (sum * sum)
+		}
+		out = append(out, *c)
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsOpenPRsByDay(ctx context.Context, teams []string, from, to time.Time) ([]domain.TeamDayCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var scoped []memPR
+	teamSet := map[string]bool{}
+	for _, p := range r.st.prs {
+		if team := r.authorTeam(p); inTeams(teams, team) {
+			scoped = append(scoped, p)
+			teamSet[team] = true
+		}
+	}
+	names := make([]string, 0, len(teamSet))
+	for t := range teamSet {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	out := []domain.TeamDayCount{}
+	for _, d := range dayRange(from, to) {
+		end := d.AddDate(0, 0, 1)
+		for _, team := range names {
+			c := domain.TeamDayCount{Date: utcDate(d), TeamName: team}
+			for _, p := range scoped {
+				if r.authorTeam(p) == team && p.CreatedAt.Before(end) && (p.endedAt() == nil || !p.endedAt().Before(end)) {
+					c.Count++
+				}
+			}
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsReassignments(ctx context.Context, teams []string, from, to time.Time) (int, []domain.Reassignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scoped := map[string]bool{}
+	for _, p := range r.st.prs {
+		if inTeams(teams, r.authorTeam(p)) && inDates(p.CreatedAt, from, to) {
+			scoped[p.ID] = true
+		}
+	}
+	events := slices.Clone(r.st.events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	var out []domain.Reassignment
+	for _, e := range events {
+		if scoped[e.PRID] && (e.Kind == "replaced" || e.Kind == "removed") && e.UserID != "" {
+			out = append(out, domain.Reassignment{PRID: e.PRID, UserID: e.UserID, TeamName: r.st.users[e.UserID].TeamName, Reason: e.Reason})
+		}
+	}
+	return len(scoped), out, nil
+}
+
+// pairings calls fn with the author, reviewer and time of each assignment
+// in the event log, replacements included, like pairingEvents.
+func (r *MemoryRepo) pairings(fn func(author, reviewer string, at time.Time)) {
+	for _, e := range r.st.events {
+		reviewer := e.UserID
+		if e.Kind == domain.PREventReplaced {
+			reviewer = e.ReplacedBy
+		} else if e.Kind != domain.PREventAssigned {
+			continue
+		}
+		if p, ok := r.st.prs[e.PRID]; ok && reviewer != "" && reviewer != p.AuthorID {
+			fn(p.AuthorID, reviewer, e.At)
+		}
+	}
+}
+
+func (r *MemoryRepo) CountPairings(ctx context.Context, authorID string, since time.Time) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[string]int{}
+	r.pairings(func(author, reviewer string, at time.Time) {
+		if author == authorID && !at.Before(since) {
+			out[reviewer]++
+		}
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsPairings(ctx context.Context, teams []string, from, to time.Time) ([]domain.Pairing, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type pair struct{ author, reviewer string }
+	counts := map[pair]int{}
+	r.pairings(func(author, reviewer string, at time.Time) {
+		if inTeams(teams, r.st.users[author].TeamName) && inDates(at, from, to) {
+			counts[pair{author, reviewer}]++
+		}
+	})
+	var out []domain.Pairing
+	for p, n := range counts {
+		out = append(out, domain.Pairing{AuthorID: p.author, ReviewerID: p.reviewer, TeamName: r.st.users[p.author].TeamName, Assignments: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AuthorID != out[j].AuthorID {
+			return out[i].AuthorID < out[j].AuthorID
+		}
+		return out[i].ReviewerID < out[j].ReviewerID
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) ListPRHistory(ctx context.Context, teams []string, from, to time.Time) ([]domain.HistoricalPR, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.HistoricalPR
+	for id, p := range r.st.prs {
+		team := r.authorTeam(p)
+		if !inTeams(teams, team) || !inDates(p.CreatedAt, from, to) {
+			continue
+		}
+		h := domain.HistoricalPR{PRID: id, AuthorID: p.AuthorID, TeamName: team, CreatedAt: p.CreatedAt, MergedAt: p.MergedAt, Reviewers: []string{}}
+		for _, rv := range r.st.reviewers[id] {
+			h.Reviewers = append(h.Reviewers, rv.UserID)
+		}
+		slices.Sort(h.Reviewers)
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].PRID < out[j].PRID
+	})
+	return out, nil
+}
+
+func (r *MemoryRepo) StatsAuthorMergeRates(ctx context.Context, teams []string, from, to time.Time, mergeSLA time.Duration) ([]domain.AuthorMergeRate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	type groupKey struct{ author, team string }
+	rates := map[groupKey]*domain.AuthorMergeRate{}
+	oldest := map[groupKey]time.Time{}
+	for _, p := range r.st.prs {
+		team := r.authorTeam(p)
+		if !inTeams(teams, team) {
+			continue
+		}
+		inPeriod := inDates(p.CreatedAt, from, to)
+		open := p.Status == domain.StatusOPEN
+		if !inPeriod && !open {
+			continue
+		}
+		k := groupKey{p.AuthorID, team}
+		a := rates[k]
+		if a == nil {
+			a = &domain.AuthorMergeRate{UserID: p.AuthorID, TeamName: team}
+			rates[k] = a
+		}
+		if inPeriod {
+			a.Created++
+			if p.Status == domain.StatusMERGED {
+				a.Merged++
+			}
+		}
+		if open {
+			a.Open++
+			if p.CreatedAt.Before(now.Add(-mergeSLA)) {
+				a.LongOpen++
+			}
+			if t, ok := oldest[k]; !ok || p.CreatedAt.Before(t) {
+				oldest[k] = p.CreatedAt
+			}
+		}
+	}
+	out := []domain.AuthorMergeRate{}
+	for k, a := range rates {
+		if t, ok := oldest[k]; ok {
+			a.OldestOpenAgeSeconds = now.Sub(t).Seconds()
+		}
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+	return out, nil
+}
+
+func (r *MemoryRepo) TakeStatsSnapshot(ctx context.Context, day time.Time) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	takenAt := r.now()
+	snap := domain.StatsSnapshot{Date: utcDate(day), TakenAt: takenAt, Teams: []domain.TeamSnapshot{}}
+	idx := map[string]int{}
+	names := make([]string, 0, len(r.st.teams))
+	for name := range r.st.teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx[name] = len(snap.Teams)
+		snap.Teams = append(snap.Teams, domain.TeamSnapshot{TeamName: name, Users: []domain.UserSnapshot{}})
+	}
+	for _, u := range r.sortedUsers() {
+		us := domain.UserSnapshot{UserID: u.UserID, IsActive: u.IsActive}
+		for prID := range r.st.reviewers {
+			if r.reviewerIndex(prID, u.UserID) < 0 {
+				continue
+			}
+			us.TotalAssignments++
+			if r.st.prs[prID].Status == domain.StatusOPEN {
+				us.OpenAssignments++
+			}
+		}
+		t := &snap.Teams[idx[u.TeamName]]
+		t.Users = append(t.Users, us)
+		t.OpenAssignments += us.OpenAssignments
+		if u.IsActive {
+			t.ActiveMembers++
+		}
+	}
+	for _, p := range r.st.prs {
+		t := &snap.Teams[idx[r.authorTeam(p)]]
+		if p.Status == domain.StatusOPEN {
+			t.OpenPRs++
+		} else {
+			t.MergedPRs++
+		}
+	}
+	r.st.snapshots[snap.Date] = snap
+	return takenAt, nil
+}
+
+// GetStatsSnapshot returns nil when no snapshot was taken on day.
+func (r *MemoryRepo) GetStatsSnapshot(ctx context.Context, day time.Time, teams []string) (*domain.StatsSnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snap, ok := r.st.snapshots[utcDate(day)]
+	if !ok {
+		return nil, nil
+	}
+	out := snap
+	out.Teams = []domain.TeamSnapshot{}
+	for _, t := range snap.Teams {
+		if inTeams(teams, t.TeamName) {
+			t.Users = slices.Clone(t.Users)
+			out.Teams = append(out.Teams, t)
+		}
+	}
+	return &out, nil
+}
+
+// reportFact is one row of reportFacts.
+type reportFact struct {
+	kind, user, team, repository string
+	status                       domain.PRStatus
+	at                           time.Time
+	secs                         float64
+}
+
+func (f reportFact) dimension(name string) string {
+	switch name {
+	case "user":
+		return f.user
+	case "team":
+		return f.team
+	case "status":
+		return string(f.status)
+	case "repository":
+		return f.repository
+	case "week":
+		return weekStart(f.at).Format(time.DateOnly)
+	}
+	return ""
+}
+
+// QueryReport returns up to q.Limit+1 rows so the caller can tell whether
+// the result was cut.
+func (r *MemoryRepo) QueryReport(ctx context.Context, q domain.ReportQuery) ([]map[string]any, error) {
+	for _, d := range q.Dimensions {
+		if _, ok := reportDimensionSQL[d]; !ok {
+			return nil, fmt.Errorf("unknown dimension %q", d)
+		}
+	}
+	for _, m := range q.Measures {
+		if _, ok := reportMeasureSQL[m]; !ok {
+			return nil, fmt.Errorf("unknown measure %q", m)
+		}
+	}
+	r.mu.Lock()
+	var facts []reportFact
+	for _, p := range r.st.prs {
+		for _, rv := range r.st.reviewers[p.ID] {
+			facts = append(facts, reportFact{kind: "assignment", user: rv.UserID, team: r.st.users[rv.UserID].TeamName,
+				status: p.Status, repository: p.Repository, at: rv.AssignedAt})
+		}
+		if p.MergedAt != nil {
+			facts = append(facts, reportFact{kind: "merge", user: p.AuthorID, team: r.authorTeam(p),
+				status: p.Status, repository: p.Repository, at: *p.MergedAt, secs: p.MergedAt.Sub(p.CreatedAt).Seconds()})
+		}
+	}
+	r.mu.Unlock()
+
+	f := q.Filters
+	type group struct {
+		dims                []string
+		assignments, merges int
+		latencies           []float64
+	}
+	groups := map[string]*group{}
+	for _, fact := range facts {
+		if !inTeams(f.Teams, fact.team) || (len(f.Users) > 0 && !slices.Contains(f.Users, fact.user)) ||
+			(len(f.Status) > 0 && !slices.Contains(f.Status, fact.status)) ||
+			(f.Since != nil && fact.at.Before(*f.Since)) || (f.Until != nil && !fact.at.Before(*f.Until)) {
+			continue
+		}
+		dims := make([]string, len(q.Dimensions))
+		for i, d := range q.Dimensions {
+			dims[i] = fact.dimension(d)
+		}
+		key := strings.Join(dims, "\x00")
+		g := groups[key]
+		if g == nil {
+			g = &group{dims: dims}
+			groups[key] = g
+		}
+		if fact.kind == "assignment" {
+			g.assignments++
+		} else {
+			g.merges++
+			g.latencies = append(g.latencies, fact.secs)
+		}
+	}
+	// without dimensions the aggregate has one row even over no facts
+	if len(q.Dimensions) == 0 && len(groups) == 0 {
+		groups[""] = &group{}
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := []map[string]any{}
+	for _, k := range keys {
+		if len(out) == q.Limit+1 {
+			break
+		}
+		g := groups[k]
+		row := make(map[string]any, len(q.Dimensions)+len(q.Measures))
+		for i, d := range q.Dimensions {
+			row[d] = g.dims[i]
+		}
+		for _, m := range q.Measures {
+			switch m {
+			case "assignments":
+				row[m] = float64(g.assignments)
+			case "merges":
+				row[m] = float64(g.merges)
+			case "latency":
+				row[m] = durationStats("", g.latencies).MedianSeconds
+			}
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) AddNoCandidateEvent(ctx context.Context, e domain.NoCandidateEvent) error {
+	_, err := r.db.ExecContext(ctx, `
+		insert into no_candidate_events(op, team_name, pr_id, user_id, at)
+		values ($1, $2, nullif($3, ''), nullif($4, ''), $5)`, e.Op, e.TeamName, e.PRID, e.UserID, r.now())
+	return err
+}
+
+// ListNoCandidateEvents returns events in [from, until), oldest first.
+func (r *PostgresRepo) ListNoCandidateEvents(ctx context.Context, teams []string, from, until time.Time) ([]domain.NoCandidateEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select op, team_name, coalesce(pr_id, ''), coalesce(user_id, ''), at
+		from no_candidate_events
+		where (cardinality($1::text[]) = 0 or team_name = any($1::text[]))
+		  and at >= $2 and at < $3
+		order by at, id`, pqStringArray(teams), from, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.NoCandidateEvent
+	for rows.Next() {
+		var e domain.NoCandidateEvent
+		if err := rows.Scan(&e.Op, &e.TeamName, &e.PRID, &e.UserID, &e.At); err != nil {
+			return nil, err
+		}
+		e.At = e.At.UTC()
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
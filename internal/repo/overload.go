@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	domain "prsrv/internal/domain"
+)
+
+// ListReviewerLoads counts open PRs per active user, including users with
+// none, so team medians reflect the whole team.
+func (r *PostgresRepo) ListReviewerLoads(ctx context.Context) ([]domain.ReviewerLoad, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select u.user_id, u.team_name, count(p.pr_id)
+		from users u
+		left join pr_reviewers rv on rv.user_id = u.user_id
+		left join pull_requests p on p.pr_id = rv.pr_id and p.status = 'OPEN'
+		where u.is_active
+		group by u.user_id, u.team_name
+		order by u.user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.ReviewerLoad
+	for rows.Next() {
+		var l domain.ReviewerLoad
+		if err := rows.Scan(&l.UserID, &l.TeamName, &l.Open); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// SyncOverloadAlerts resolves active alerts of reviewers missing from current
+// and opens alerts for those in current without one. It returns the opened
+// alerts with the lead of the team filled in.
+func (r *PostgresRepo) SyncOverloadAlerts(ctx context.Context, current []domain.OverloadAlert) ([]domain.OverloadAlert, error) {
+	users := make([]string, len(current))
+	for i, a := range current {
+		users[i] = a.UserID
+	}
+	var opened []domain.OverloadAlert
+	err := r.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			update overload_alerts set resolved_at = $2
+			where resolved_at is null and not (user_id = any($1::text[]))`, pqStringArray(users), r.now()); err != nil {
+			return err
+		}
+		for _, a := range current {
+			var lead sql.NullString
+			err := tx.QueryRowContext(ctx, `
+				insert into overload_alerts(team_name, user_id, lead_user_id, reason, open_count, team_median, created_at)
+				select team_name, $2, lead_user_id, $3, $4, $5, $6 from teams where team_name = $1
+				on conflict (user_id) where resolved_at is null do nothing
+				returning id, lead_user_id, created_at`,
+				a.TeamName, a.UserID, a.Reason, a.OpenCount, a.TeamMedian, r.now()).Scan(&a.ID, &lead, &a.CreatedAt)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			a.LeadUserID, a.CreatedAt = lead.String, a.CreatedAt.UTC()
+			opened = append(opened, a)
+		}
+		return nil
+	})
+	return opened, err
+}
+
+func (r *PostgresRepo) ListOverloadAlerts(ctx context.Context, teams []string, includeResolved bool) ([]domain.OverloadAlert, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select id, team_name, user_id, coalesce(lead_user_id, ''), reason, open_count, team_median, created_at, resolved_at
+		from overload_alerts
+		where (cardinality($1::text[]) = 0 or team_name = any($1::text[]))
+		  and ($2 or resolved_at is null)
+		order by created_at desc, id desc`, pqStringArray(teams), includeResolved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.OverloadAlert{}
+	for rows.Next() {
+		var (
+			a        domain.OverloadAlert
+			resolved sql.NullTime
+		)
+		if err := rows.Scan(&a.ID, &a.TeamName, &a.UserID, &a.LeadUserID, &a.Reason, &a.OpenCount, &a.TeamMedian, &a.CreatedAt, &resolved); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = a.CreatedAt.UTC()
+		if resolved.Valid {
+			t := resolved.Time.UTC()
+			a.ResolvedAt = &t
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) SetTeamLead(ctx context.Context, team, userID string) error {
+	res, err := r.db.ExecContext(ctx, `update teams set lead_user_id = nullif($2, '') where team_name = $1`, team, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.NewError(domain.ErrNotFound, "team not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepo) GetTeamLead(ctx context.Context, team string) (string, error) {
+	var lead sql.NullString
+	err := r.db.QueryRowContext(ctx, `select lead_user_id from teams where team_name = $1`, team).Scan(&lead)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return lead.String, err
+}
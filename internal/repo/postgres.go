@@ -1,26 +1,44 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 
 	domain "prsrv/internal/domain"
 )
 
 type PostgresRepo struct {
-	db *sql.DB
+	db    *sql.DB
+	pii   *FieldCipher
+	clock domain.Clock
 }
 
-func NewPostgresRepo(db *sql.DB) *PostgresRepo { return &PostgresRepo{db: db} }
+func NewPostgresRepo(db *sql.DB) *PostgresRepo {
+	return &PostgresRepo{db: db, clock: domain.SystemClock}
+}
+
+// WithClock makes the repo stamp rows with the time of c instead of the
+// wall clock.
+func (r *PostgresRepo) WithClock(c domain.Clock) *PostgresRepo {
+	r.clock = c
+	return r
+}
+
+// now is the timestamp written to created_at, merged_at and the like;
+// queries never call the database's now().
+func (r *PostgresRepo) now() time.Time { return r.clock.Now().UTC() }
+
+// EncryptPII makes the repo store usernames encrypted with c.
+func (r *PostgresRepo) EncryptPII(c *FieldCipher) *PostgresRepo {
+	r.pii = c
+	return r
+}
 
-func (r *PostgresRepo) WithTx(fn func(tx *sql.Tx) error) error {
-	tx, err := r.db.Begin()
+func (r *PostgresRepo) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -37,31 +55,131 @@ func (r *PostgresRepo) WithTx(fn func(tx *sql.Tx) error) error {
 	return tx.Commit()
 }
 
-func (r *PostgresRepo) CreateTeam(tx *sql.Tx, teamName string) error {
-	_, err := tx.Exec(`insert into teams(team_name) values ($1)`, teamName)
+func (r *PostgresRepo) CreateTeam(ctx context.Context, tx *sql.Tx, teamName string) error {
+	_, err := tx.ExecContext(ctx, `insert into teams(team_name) values ($1)`, teamName)
+	return err
+}
+
+func (r *PostgresRepo) SetTeamManualAssignment(ctx context.Context, tx *sql.Tx, teamName string, manual bool) error {
+	_, err := tx.ExecContext(ctx, `update teams set manual_assignment = $2 where team_name = $1`, teamName, manual)
+	return err
+}
+
+func (r *PostgresRepo) TeamManualAssignment(ctx context.Context, teamName string) (bool, error) {
+	var manual bool
+	err := r.db.QueryRowContext(ctx, `select manual_assignment from teams where team_name = $1`, teamName).Scan(&manual)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return manual, err
+}
+
+func (r *PostgresRepo) SetTeamMergeRules(ctx context.Context, tx *sql.Tx, teamName string, rules domain.MergeRules) error {
+	_, err := tx.ExecContext(ctx, `
+		update teams set min_approvals = $2, block_on_changes_requested = $3,
+			require_lead_approval = $4, min_age_seconds = $5
+		where team_name = $1`,
+		teamName, rules.MinApprovals, rules.BlockOnChangesRequested, rules.RequireLeadApproval, rules.MinAgeSeconds)
+	return err
+}
+
+func (r *PostgresRepo) TeamMergeRules(ctx context.Context, teamName string) (domain.MergeRules, error) {
+	var m domain.MergeRules
+	err := r.db.QueryRowContext(ctx, `
+		select min_approvals, block_on_changes_requested, require_lead_approval, min_age_seconds
+		from teams where team_name = $1`, teamName).
+		Scan(&m.MinApprovals, &m.BlockOnChangesRequested, &m.RequireLeadApproval, &m.MinAgeSeconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.MergeRules{}, nil
+	}
+	return m, err
+}
+
+func (r *PostgresRepo) SetTeamEscalation(ctx context.Context, tx *sql.Tx, teamName string, p domain.EscalationPolicy) error {
+	_, err := tx.ExecContext(ctx, `update teams set escalate_notify_days = $2, escalate_add_reviewer_days = $3 where team_name = $1`,
+		teamName, p.NotifyLeadDays, p.AddReviewerDays)
+	return err
+}
+
+func (r *PostgresRepo) TeamEscalation(ctx context.Context, teamName string) (domain.EscalationPolicy, error) {
+	var p domain.EscalationPolicy
+	err := r.db.QueryRowContext(ctx, `select escalate_notify_days, escalate_add_reviewer_days from teams where team_name = $1`, teamName).
+		Scan(&p.NotifyLeadDays, &p.AddReviewerDays)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.EscalationPolicy{}, nil
+	}
+	return p, err
+}
+
+func (r *PostgresRepo) SetTeamHolidays(ctx context.Context, tx *sql.Tx, teamName string, holidays []domain.Holiday) error {
+	if _, err := tx.ExecContext(ctx, `delete from team_holidays where team_name = $1`, teamName); err != nil {
+		return err
+	}
+	for _, h := range holidays {
+		if _, err := tx.ExecContext(ctx, `insert into team_holidays(team_name, day, name) values ($1, $2, $3)`, teamName, h.Date, h.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepo) ListTeamHolidays(ctx context.Context, teamName string) ([]domain.Holiday, error) {
+	rows, err := r.db.QueryContext(ctx, `select to_char(day, 'YYYY-MM-DD'), name from team_holidays where team_name = $1 order by day`, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.Holiday{}
+	for rows.Next() {
+		var h domain.Holiday
+		if err := rows.Scan(&h.Date, &h.Name); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) SetTeamWorkingHours(ctx context.Context, tx *sql.Tx, teamName string, w domain.WorkingHours) error {
+	_, err := tx.ExecContext(ctx, `update teams set work_time_zone = $2, work_start_hour = $3, work_end_hour = $4 where team_name = $1`,
+		teamName, w.TimeZone, w.StartHour, w.EndHour)
 	return err
 }
 
-func (r *PostgresRepo) TeamExists(tx *sql.Tx, teamName string) (bool, error) {
+func (r *PostgresRepo) TeamWorkingHours(ctx context.Context, teamName string) (domain.WorkingHours, error) {
+	var w domain.WorkingHours
+	err := r.db.QueryRowContext(ctx, `select work_time_zone, work_start_hour, work_end_hour from teams where team_name = $1`, teamName).
+		Scan(&w.TimeZone, &w.StartHour, &w.EndHour)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.WorkingHours{}, nil
+	}
+	return w, err
+}
+
+func (r *PostgresRepo) TeamExists(ctx context.Context, tx *sql.Tx, teamName string) (bool, error) {
 	var exists bool
-	err := tx.QueryRow(`select exists(select 1 from teams where team_name=$1)`, teamName).Scan(&exists)
+	err := tx.QueryRowContext(ctx, `select exists(select 1 from teams where team_name=$1)`, teamName).Scan(&exists)
 	return exists, err
 }
 
-func (r *PostgresRepo) UpsertUser(tx *sql.Tx, u domain.User) error {
-	_, err := tx.Exec(`
+func (r *PostgresRepo) UpsertUser(ctx context.Context, tx *sql.Tx, u domain.User) error {
+	username, err := r.pii.Encrypt(u.Username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
 		insert into users(user_id, username, team_name, is_active)
 		values ($1,$2,$3,$4)
 		on conflict (user_id)
 		do update set username=excluded.username,
 		             team_name=excluded.team_name,
 		             is_active=excluded.is_active
-	`, u.UserID, u.Username, u.TeamName, u.IsActive)
+	`, u.UserID, username, u.TeamName, u.IsActive)
 	return err
 }
 
-func (r *PostgresRepo) GetTeamMembers(teamName string) ([]domain.TeamMember, error) {
-	rows, err := r.db.Query(`select user_id, username, is_active from users where team_name=$1 order by user_id`, teamName)
+func (r *PostgresRepo) GetTeamMembers(ctx context.Context, teamName string) ([]domain.TeamMember, error) {
+	rows, err := r.db.QueryContext(ctx, `select user_id, username, is_active from users where team_name=$1 order by user_id`, teamName)
 	if err != nil {
 		return nil, err
 	}
@@ -72,46 +190,106 @@ func (r *PostgresRepo) GetTeamMembers(teamName string) ([]domain.TeamMember, err
 		if err := rows.Scan(&m.UserID, &m.Username, &m.IsActive); err != nil {
 			return nil, err
 		}
+		if m.Username, err = r.pii.Decrypt(m.Username); err != nil {
+			return nil, err
+		}
 		out = append(out, m)
 	}
 	return out, nil
 }
 
-func (r *PostgresRepo) SetUserActive(uID string, active bool) (*domain.User, error) {
-	res, err := r.db.Exec(`update users set is_active=$1 where user_id=$2`, active, uID)
+func (r *PostgresRepo) SetUserActive(ctx context.Context, uID string, active bool) (*domain.User, error) {
+	res, err := r.db.ExecContext(ctx, `update users set is_active=$1 where user_id=$2`, active, uID)
 	if err != nil {
 		return nil, err
 	}
 	a, _ := res.RowsAffected()
 	if a == 0 {
-		return nil, errors.New(string(domain.ErrNotFound) + ":user not found")
+		return nil, domain.NewError(domain.ErrNotFound, "user not found")
+	}
+	return r.GetUser(ctx, uID)
+}
+
+// AnonymizeUser overwrites the username and deactivates the user; the row and
+// everything referencing user_id stay in place.
+func (r *PostgresRepo) AnonymizeUser(ctx context.Context, tx *sql.Tx, uID, placeholder string) error {
+	res, err := tx.ExecContext(ctx, `update users set username=$1, is_active=false where user_id=$2`, placeholder, uID)
+	if err != nil {
+		return err
+	}
+	a, _ := res.RowsAffected()
+	if a == 0 {
+		return domain.NewError(domain.ErrNotFound, "user not found")
 	}
-	return r.GetUser(uID)
+	return nil
 }
 
-func (r *PostgresRepo) GetUser(uID string) (*domain.User, error) {
+func (r *PostgresRepo) SetUsername(ctx context.Context, tx *sql.Tx, uID, username string) error {
+	stored, err := r.pii.Encrypt(username)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `update users set username=$1 where user_id=$2`, stored, uID)
+	if err != nil {
+		return err
+	}
+	if a, _ := res.RowsAffected(); a == 0 {
+		return domain.NewError(domain.ErrNotFound, "user not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepo) GetUser(ctx context.Context, uID string) (*domain.User, error) {
 	u := &domain.User{}
-	err := r.db.QueryRow(`select user_id, username, team_name, is_active from users where user_id=$1`, uID).
+	err := r.db.QueryRowContext(ctx, `select user_id, username, team_name, is_active from users where user_id=$1`, uID).
 		Scan(&u.UserID, &u.Username, &u.TeamName, &u.IsActive)
 	if err == sql.ErrNoRows {
-		return nil, errors.New(string(domain.ErrNotFound) + ":user not found")
+		return nil, domain.WrapError(domain.ErrNotFound, "user not found", err)
 	}
-	return u, err
+	if err != nil {
+		return nil, err
+	}
+	if u.Username, err = r.pii.Decrypt(u.Username); err != nil {
+		return nil, err
+	}
+	return u, nil
 }
 
-func (r *PostgresRepo) CreatePR(tx *sql.Tx, pr domain.PullRequest) error {
-	_, err := tx.Exec(`insert into pull_requests(pr_id, pr_name, author_id, status, created_at)
-		values ($1,$2,$3,'OPEN', now())`, pr.ID, pr.Name, pr.AuthorID)
+func (r *PostgresRepo) CreatePR(ctx context.Context, tx *sql.Tx, pr domain.PullRequest) error {
+	_, err := tx.ExecContext(ctx, `insert into pull_requests(pr_id, pr_name, author_id, repository, status, created_at)
+		values ($1,$2,$3,nullif($4,''),'OPEN', $5)`, pr.ID, pr.Name, pr.AuthorID, pr.Repository, r.now())
 	return err
 }
 
-func (r *PostgresRepo) GetPR(prID string) (*domain.PullRequest, error) {
-	row := r.db.QueryRow(`select pr_id, pr_name, author_id, status, created_at, merged_at from pull_requests where pr_id=$1`, prID)
+func (r *PostgresRepo) ImportPR(ctx context.Context, tx *sql.Tx, pr domain.ImportedPR) error {
+	status := domain.StatusOPEN
+	if pr.MergedAt != nil {
+		status = domain.StatusMERGED
+	}
+	if _, err := tx.ExecContext(ctx, `insert into pull_requests(pr_id, pr_name, author_id, repository, status, created_at, merged_at)
+		values ($1,$2,$3,nullif($4,''),$5,$6,$7)`, pr.ID, pr.Name, pr.AuthorID, pr.Repository, status, pr.CreatedAt, pr.MergedAt); err != nil {
+		return err
+	}
+	for _, rv := range pr.Reviewers {
+		state := domain.ReviewPending
+		if rv.ApprovedAt != nil {
+			state = domain.ReviewApproved
+		}
+		if _, err := tx.ExecContext(ctx, `insert into pr_reviewers(pr_id, user_id, assigned_at, state, decided_at, first_action_at)
+			values ($1,$2,$3,$4,$5,$5) on conflict do nothing`, pr.ID, rv.UserID, pr.CreatedAt, state, rv.ApprovedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepo) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	row := r.db.QueryRowContext(ctx, `select pr_id, pr_name, author_id, coalesce(repository, ''), status, created_at, merged_at, closed_at from pull_requests where pr_id=$1`, prID)
 	var pr domain.PullRequest
-	var createdAt, mergedAt sql.NullTime
-	if err := row.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &createdAt, &mergedAt); err != nil {
+	var createdAt, mergedAt, closedAt sql.NullTime
+	if err := row.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Repository, &pr.Status, &createdAt, &mergedAt, &closedAt); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New(string(domain.ErrNotFound) + ":PR not found")
+			return nil, domain.WrapError(domain.ErrNotFound, "PR not found", err)
 		}
 		return nil, err
 	}
@@ -123,29 +301,54 @@ func (r *PostgresRepo) GetPR(prID string) (*domain.PullRequest, error) {
 		t := mergedAt.Time.UTC()
 		pr.MergedAt = &t
 	}
-	rev, _ := r.GetAssignedReviewers(prID)
+	if closedAt.Valid {
+		t := closedAt.Time.UTC()
+		pr.ClosedAt = &t
+	}
+	rev, _ := r.GetAssignedReviewers(ctx, prID)
 	pr.AssignedReviewers = rev
 	return &pr, nil
 }
 
-func (r *PostgresRepo) SetPRMerged(tx *sql.Tx, prID string) (*domain.PullRequest, error) {
-	_, err := tx.Exec(`update pull_requests set status='MERGED', merged_at=now() where pr_id=$1`, prID)
+func (r *PostgresRepo) SetPRAuthor(ctx context.Context, tx *sql.Tx, prID, authorID string) error {
+	_, err := tx.ExecContext(ctx, `update pull_requests set author_id=$2 where pr_id=$1`, prID, authorID)
+	return err
+}
+
+func (r *PostgresRepo) SetPRMerged(ctx context.Context, tx *sql.Tx, prID string) (*domain.PullRequest, error) {
+	_, err := tx.ExecContext(ctx, `update pull_requests set status='MERGED', merged_at=$2 where pr_id=$1`, prID, r.now())
 	if err != nil {
 		return nil, err
 	}
-	return r.GetPR(prID)
+	return r.GetPR(ctx, prID)
 }
 
-func (r *PostgresRepo) GetAuthorTeam(authorID string) (string, error) {
+func (r *PostgresRepo) SetPRStatus(ctx context.Context, tx *sql.Tx, prID string, status domain.PRStatus) (*domain.PullRequest, error) {
+	var closedAt *time.Time
+	if status == domain.StatusCLOSED {
+		now := r.now()
+		closedAt = &now
+	}
+	res, err := tx.ExecContext(ctx, `update pull_requests set status=$2, closed_at=$3 where pr_id=$1`, prID, status, closedAt)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "PR not found")
+	}
+	return r.GetPR(ctx, prID)
+}
+
+func (r *PostgresRepo) GetAuthorTeam(ctx context.Context, authorID string) (string, error) {
 	var team string
-	err := r.db.QueryRow(`select team_name from users where user_id=$1`, authorID).Scan(&team)
+	err := r.db.QueryRowContext(ctx, `select team_name from users where user_id=$1`, authorID).Scan(&team)
 	if err == sql.ErrNoRows {
-		return "", errors.New(string(domain.ErrNotFound) + ":author not found")
+		return "", domain.WrapError(domain.ErrNotFound, "author not found", err)
 	}
 	return team, err
 }
 
-func (r *PostgresRepo) PickReviewersFromTeam(prID, team string, exclude []string, limit int) ([]string, error) {
+func (r *PostgresRepo) PickReviewersFromTeam(ctx context.Context, prID, team string, exclude []string, limit int) ([]string, error) {
 	q := `
 		select u.user_id
 		from users u
@@ -155,7 +358,7 @@ func (r *PostgresRepo) PickReviewersFromTeam(prID, team string, exclude []string
 		order by md5($3 || u.user_id)
 		limit $4
 	`
-	rows, err := r.db.Query(q, team, pqStringArray(exclude), prID, limit)
+	rows, err := r.db.QueryContext(ctx, q, team, pqStringArray(exclude), prID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -171,8 +374,13 @@ func (r *PostgresRepo) PickReviewersFromTeam(prID, team string, exclude []string
 	return out, nil
 }
 
-func (r *PostgresRepo) GetAssignedReviewers(prID string) ([]string, error) {
-	rows, err := r.db.Query(`select user_id from pr_reviewers where pr_id=$1 order by user_id`, prID)
+func (r *PostgresRepo) ListRecentReviewers(ctx context.Context, authorID string, since time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select distinct r.user_id
+		from pr_reviewers r
+		join pull_requests p on p.pr_id = r.pr_id
+		where p.author_id = $1 and coalesce(r.decided_at, p.merged_at) >= $2
+		order by r.user_id`, authorID, since)
 	if err != nil {
 		return nil, err
 	}
@@ -185,93 +393,280 @@ func (r *PostgresRepo) GetAssignedReviewers(prID string) ([]string, error) {
 		}
 		out = append(out, id)
 	}
+	return out, rows.Err()
+}
+
+// pairingEvents selects the assignments logged in pr_events with the PR
+// author: plain assignments and replacements, by reviewer. Assignments of
+// a reviewer who later became the author are left out.
+const pairingEvents = `
+	select * from (
+		select p.author_id, case when e.kind = 'replaced' then e.replaced_by else e.user_id end as reviewer_id, e.at
+		from pr_events e
+		join pull_requests p on p.pr_id = e.pr_id
+		where e.kind in ('assigned', 'replaced')
+	) pe
+	where reviewer_id is not null and reviewer_id <> author_id`
+
+func (r *PostgresRepo) CountPairings(ctx context.Context, authorID string, since time.Time) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select reviewer_id, count(*)
+		from (`+pairingEvents+`) a
+		where author_id = $1 and at >= $2
+		group by reviewer_id`, authorID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int{}
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		out[id] = n
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) GetAssignedReviewers(ctx context.Context, prID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `select user_id from pr_reviewers where pr_id=$1 order by user_id`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []string{} // a PR without reviewers has [] in JSON
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
 	return out, nil
 }
 
-func (r *PostgresRepo) AssignReviewers(tx *sql.Tx, prID string, userIDs []string) error {
+func (r *PostgresRepo) AssignReviewers(ctx context.Context, tx *sql.Tx, prID string, userIDs []string) error {
 	for _, id := range userIDs {
-		if _, err := tx.Exec(`insert into pr_reviewers(pr_id, user_id)
-			values ($1,$2) on conflict do nothing`, prID, id); err != nil {
+		if _, err := tx.ExecContext(ctx, `insert into pr_reviewers(pr_id, user_id, assigned_at)
+			values ($1,$2,$3) on conflict do nothing`, prID, id, r.now()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *PostgresRepo) ReplaceReviewer(tx *sql.Tx, prID, oldUser, newUser string) error {
-	if _, err := tx.Exec(`delete from pr_reviewers where pr_id=$1 and user_id=$2`, prID, oldUser); err != nil {
+func (r *PostgresRepo) ReplaceReviewer(ctx context.Context, tx *sql.Tx, prID, oldUser, newUser string) error {
+	if _, err := tx.ExecContext(ctx, `delete from pr_reviewers where pr_id=$1 and user_id=$2`, prID, oldUser); err != nil {
 		return err
 	}
-	_, err := tx.Exec(`insert into pr_reviewers(pr_id, user_id)
-		values ($1,$2) on conflict do nothing`, prID, newUser)
+	_, err := tx.ExecContext(ctx, `insert into pr_reviewers(pr_id, user_id, assigned_at)
+		values ($1,$2,$3) on conflict do nothing`, prID, newUser, r.now())
 	return err
 }
 
-func (r *PostgresRepo) DeleteReviewer(tx *sql.Tx, prID, userID string) error {
-	_, err := tx.Exec(`delete from pr_reviewers where pr_id=$1 and user_id=$2`, prID, userID)
+func (r *PostgresRepo) DeleteReviewer(ctx context.Context, tx *sql.Tx, prID, userID string) error {
+	_, err := tx.ExecContext(ctx, `delete from pr_reviewers where pr_id=$1 and user_id=$2`, prID, userID)
 	return err
 }
 
-func (r *PostgresRepo) ListUserPRs(uID string) ([]domain.PullRequestShort, error) {
-	rows, err := r.db.Query(`
-		select p.pr_id, p.pr_name, p.author_id, p.status
+// prBlocked is whether the PR p is open and depends on an unmerged PR; a
+// closed dependency still blocks, since it may be reopened and merged.
+const prBlocked = `(p.status = 'OPEN' and exists (
+		select 1 from pr_dependencies d
+		join pull_requests b on b.pr_id = d.blocked_by
+		where d.pr_id = p.pr_id and b.status <> 'MERGED'))`
+
+func (r *PostgresRepo) ListUserPRs(ctx context.Context, uID string, p domain.PageQuery) ([]domain.PullRequestShort, error) {
+	return r.queryPRPage(ctx, `
+		select p.pr_id, p.pr_name, p.author_id, p.status, `+prBlocked+` as blocked
 		from pull_requests p
 		join pr_reviewers r using(pr_id)
-		where r.user_id=$1
-		order by p.pr_id`, uID)
+		where r.user_id=$1 and (r.deliver_at is null or r.deliver_at <= $4)
+		order by blocked, `+prOrder(p.Sort)+`
+		limit $2 offset $3`, uID, p.Limit+1, p.Offset, r.now())
+}
+
+func (r *PostgresRepo) ListOpenReviews(ctx context.Context, userIDs []string) (map[string][]domain.PullRequestShort, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select r.user_id, p.pr_id, p.pr_name, p.author_id, p.status, `+prBlocked+`
+		from pr_reviewers r
+		join pull_requests p using(pr_id)
+		where r.user_id = any($1::text[]) and p.status='OPEN'
+		  and (r.deliver_at is null or r.deliver_at <= $2)
+		order by r.user_id, p.pr_id`, pqStringArray(userIDs), r.now())
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var out []domain.PullRequestShort
+	out := map[string][]domain.PullRequestShort{}
 	for rows.Next() {
-		var s domain.PullRequestShort
-		if err := rows.Scan(&s.ID, &s.Name, &s.AuthorID, &s.Status); err != nil {
+		var uID string
+		var pr domain.PullRequestShort
+		if err := rows.Scan(&uID, &pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.Blocked); err != nil {
 			return nil, err
 		}
-		out = append(out, s)
+		out[uID] = append(out[uID], pr)
 	}
-	return out, nil
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) ListPRs(ctx context.Context, f domain.PRFilter, p domain.PageQuery) ([]domain.PullRequestShort, error) {
+	return r.queryPRPage(ctx, `
+		select p.pr_id, p.pr_name, p.author_id, p.status, `+prBlocked+`
+		from pull_requests p
+		join users a on a.user_id = p.author_id
+		where (cardinality($1::text[]) = 0 or a.team_name = any($1::text[])
+		       or ($6 and exists (
+		           select 1 from pr_reviewers r
+		           join users u on u.user_id = r.user_id
+		           where r.pr_id = p.pr_id and u.team_name = any($1::text[]))))
+		  and ($2 = '' or p.author_id = $2)
+		  and ($3 = '' or p.status::text = $3)
+		order by `+prOrder(p.Sort)+`
+		limit $4 offset $5`, pqStringArray(f.Teams), f.AuthorID, string(f.Status), p.Limit+1, p.Offset, f.IncludeReviewing)
+}
+
+// prOrder maps a whitelisted PR sort order to an ORDER BY clause.
+func prOrder(sort string) string {
+	switch sort {
+	case domain.PRSortCreated:
+		return "p.created_at, p.pr_id"
+	case domain.PRSortNewest:
+		return "p.created_at desc, p.pr_id"
+	}
+	return "p.pr_id"
 }
 
-func (r *PostgresRepo) StatsAssignmentsByUser() (map[string]int, error) {
-	rows, err := r.db.Query(`select user_id, count(*) from pr_reviewers group by user_id order by user_id`)
+func (r *PostgresRepo) queryPRPage(ctx context.Context, query string, args ...any) ([]domain.PullRequestShort, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	out := make(map[string]int)
+	out := []domain.PullRequestShort{}
 	for rows.Next() {
-		var id string
-		var cnt int
-		if err := rows.Scan(&id, &cnt); err != nil {
+		var s domain.PullRequestShort
+		if err := rows.Scan(&s.ID, &s.Name, &s.AuthorID, &s.Status, &s.Blocked); err != nil {
 			return nil, err
 		}
-		out[id] = cnt
+		out = append(out, s)
 	}
-	return out, nil
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsAssignmentsByUser(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	key := func(c *domain.AssignmentCount) *string { return &c.UserID }
+	if q.Materialized {
+		return r.queryAssignmentCounts(ctx, `
+			select m.user_id, m.assignments as cnt, count(*) over ()
+			from mv_assignments_by_user m
+			where (cardinality($1::text[]) = 0 or m.team_name = any($1::text[]))
+			  and (cardinality($4::text[]) = 0 or m.user_id = any($4::text[]))
+			order by `+assignmentOrder(q.Sort, "m.user_id")+`
+			limit $2 offset $3`, q, key)
+	}
+	return r.queryAssignmentCounts(ctx, `
+		select r.user_id, count(*) as cnt, count(*) over ()
+		from pr_reviewers r
+		join users u using(user_id)
+		where (cardinality($1::text[]) = 0 or u.team_name = any($1::text[]))
+		  and (cardinality($4::text[]) = 0 or r.user_id = any($4::text[]))
+		group by r.user_id
+		order by `+assignmentOrder(q.Sort, "r.user_id")+`
+		limit $2 offset $3`, q, key)
 }
 
-func (r *PostgresRepo) StatsAssignmentsByPR() (map[string]int, error) {
-	rows, err := r.db.Query(`select pr_id, count(*) from pr_reviewers group by pr_id order by pr_id`)
+// StatsAssignmentsByTeam counts assignments by the reviewer's team. The
+// materialized variant sums the per-user aggregate, which is small.
+func (r *PostgresRepo) StatsAssignmentsByTeam(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	key := func(c *domain.AssignmentCount) *string { return &c.TeamName }
+	if q.Materialized {
+		return r.queryAssignmentCounts(ctx, `
+			select m.team_name, sum(m.assignments) as cnt, count(*) over ()
+			from mv_assignments_by_user m
+			where (cardinality($1::text[]) = 0 or m.team_name = any($1::text[]))
+			  and (cardinality($4::text[]) = 0 or m.user_id = any($4::text[]))
+			group by m.team_name
+			order by `+assignmentOrder(q.Sort, "m.team_name")+`
+			limit $2 offset $3`, q, key)
+	}
+	return r.queryAssignmentCounts(ctx, `
+		select u.team_name, count(*) as cnt, count(*) over ()
+		from pr_reviewers r
+		join users u using(user_id)
+		where (cardinality($1::text[]) = 0 or u.team_name = any($1::text[]))
+		  and (cardinality($4::text[]) = 0 or r.user_id = any($4::text[]))
+		group by u.team_name
+		order by `+assignmentOrder(q.Sort, "u.team_name")+`
+		limit $2 offset $3`, q, key)
+}
+
+func (r *PostgresRepo) StatsAssignmentsByPR(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	return r.queryAssignmentCounts(ctx, `
+		select r.pr_id, count(*) as cnt, count(*) over ()
+		from pr_reviewers r
+		join pull_requests p using(pr_id)
+		join users a on a.user_id = p.author_id
+		where (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+		  and (cardinality($4::text[]) = 0 or r.user_id = any($4::text[]))
+		group by r.pr_id
+		order by `+assignmentOrder(q.Sort, "r.pr_id")+`
+		limit $2 offset $3`, q, func(c *domain.AssignmentCount) *string { return &c.PRID })
+}
+
+// StatsAssignmentsByRepository counts assignments by the PR's repository;
+// PRs without one are left out. Teams filter by author team.
+func (r *PostgresRepo) StatsAssignmentsByRepository(ctx context.Context, q domain.AssignmentQuery) ([]domain.AssignmentCount, int, error) {
+	return r.queryAssignmentCounts(ctx, `
+		select p.repository, count(*) as cnt, count(*) over ()
+		from pr_reviewers r
+		join pull_requests p using(pr_id)
+		join users a on a.user_id = p.author_id
+		where p.repository is not null
+		  and (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+		  and (cardinality($4::text[]) = 0 or r.user_id = any($4::text[]))
+		group by p.repository
+		order by `+assignmentOrder(q.Sort, "p.repository")+`
+		limit $2 offset $3`, q, func(c *domain.AssignmentCount) *string { return &c.Repository })
+}
+
+// assignmentOrder maps a whitelisted sort order to an ORDER BY clause.
+func assignmentOrder(sort, idCol string) string {
+	if sort == domain.SortByCount {
+		return "cnt desc, " + idCol
+	}
+	return idCol
+}
+
+// queryAssignmentCounts scans (id, count, total) rows; id is stored in the
+// field returned by key. The total is taken from the window count, so it is
+// 0 when the page is past the end.
+func (r *PostgresRepo) queryAssignmentCounts(ctx context.Context, query string, q domain.AssignmentQuery, key func(*domain.AssignmentCount) *string) ([]domain.AssignmentCount, int, error) {
+	var limit any
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+	rows, err := r.db.QueryContext(ctx, query, pqStringArray(q.Teams), limit, q.Offset, pqStringArray(q.UserIDs))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
-	out := make(map[string]int)
+	out := []domain.AssignmentCount{}
+	total := 0
 	for rows.Next() {
-		var id string
-		var cnt int
-		if err := rows.Scan(&id, &cnt); err != nil {
-			return nil, err
+		var c domain.AssignmentCount
+		if err := rows.Scan(key(&c), &c.Count, &total); err != nil {
+			return nil, 0, err
 		}
-		out[id] = cnt
+		out = append(out, c)
 	}
-	return out, nil
+	return out, total, rows.Err()
 }
 
-func (r *PostgresRepo) BulkDeactivateUsers(team string, userIDs []string) ([]string, error) {
-	rows, err := r.db.Query(`select user_id from users where team_name=$1 and user_id = any($2::text[])`, team, pqStringArray(userIDs))
+func (r *PostgresRepo) BulkDeactivateUsers(ctx context.Context, team string, userIDs []string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `select user_id from users where team_name=$1 and user_id = any($2::text[])`, team, pqStringArray(userIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -288,14 +683,14 @@ func (r *PostgresRepo) BulkDeactivateUsers(team string, userIDs []string) ([]str
 		return []string{}, nil
 	}
 
-	_, err = r.db.Exec(`update users set is_active=false where team_name=$1 and user_id = any($2::text[])`, team, pqStringArray(target))
+	_, err = r.db.ExecContext(ctx, `update users set is_active=false where team_name=$1 and user_id = any($2::text[])`, team, pqStringArray(target))
 	if err != nil {
 		return nil, err
 	}
 	return target, nil
 }
 
-func (r *PostgresRepo) ListOpenAssignmentsByUsers(userIDs []string) ([]domain.OpenAssignment, error) {
+func (r *PostgresRepo) ListOpenAssignmentsByUsers(ctx context.Context, userIDs []string) ([]domain.OpenAssignment, error) {
 	q := `
 		select pr.pr_id, pr.author_id, u.user_id, u.team_name
 		from pr_reviewers r
@@ -305,7 +700,7 @@ func (r *PostgresRepo) ListOpenAssignmentsByUsers(userIDs []string) ([]domain.Op
 		  and r.user_id = any($1::text[])
 		order by pr.pr_id
 	`
-	rows, err := r.db.Query(q, pqStringArray(userIDs))
+	rows, err := r.db.QueryContext(ctx, q, pqStringArray(userIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -321,37 +716,6 @@ func (r *PostgresRepo) ListOpenAssignmentsByUsers(userIDs []string) ([]domain.Op
 	return out, nil
 }
 
-func RunMigrations(db *sql.DB, dir string) error {
-	files := []string{}
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		name := d.Name()
-		if strings.HasSuffix(name, ".up.sql") {
-			files = append(files, path)
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-	sort.Strings(files)
-	for _, f := range files {
-		b, err := os.ReadFile(f)
-		if err != nil {
-			return err
-		}
-		if _, err := db.Exec(string(b)); err != nil {
-			return fmt.Errorf("migration %s: %w", f, err)
-		}
-	}
-	return nil
-}
-
 func pqStringArray(a []string) string {
 	if len(a) == 0 {
 		return "{}"
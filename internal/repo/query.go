@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+// Column expressions for the whitelisted report names; nothing from the
+// request other than these keys and bound parameters reaches the SQL.
+var (
+	reportDimensionSQL = map[string]string{
+		"user":       "f.user_id",
+		"team":       "f.team_name",
+		"status":     "f.status",
+		"repository": "coalesce(f.repository, '')",
+		"week":       "to_char(date_trunc('week', f.at at time zone 'UTC'), 'YYYY-MM-DD')",
+	}
+	reportMeasureSQL = map[string]string{
+		"assignments": "count(*) filter (where f.kind = 'assignment')",
+		"merges":      "count(*) filter (where f.kind = 'merge')",
+		"latency":     "coalesce(percentile_cont(0.5) within group (order by f.secs) filter (where f.kind = 'merge'), 0)",
+	}
+)
+
+// reportFacts yields one row per assignment (reviewer side) and per merged
+// PR (author side).
+const reportFacts = `
+	with facts as (
+		select 'assignment' as kind, r.user_id, u.team_name, p.status::text as status, p.repository,
+		       r.assigned_at as at, null::float8 as secs
+		from pr_reviewers r
+		join pull_requests p using(pr_id)
+		join users u on u.user_id = r.user_id
+		union all
+		select 'merge', p.author_id, a.team_name, p.status::text, p.repository,
+		       p.merged_at, extract(epoch from p.merged_at - p.created_at)::float8
+		from pull_requests p
+		join users a on a.user_id = p.author_id
+		where p.merged_at is not null
+	)`
+
+// QueryReport returns up to q.Limit+1 rows so the caller can tell whether
+// the result was cut.
+func (r *PostgresRepo) QueryReport(ctx context.Context, q domain.ReportQuery) ([]map[string]any, error) {
+	var cols, groups []string
+	for i, d := range q.Dimensions {
+		expr, ok := reportDimensionSQL[d]
+		if !ok {
+			return nil, fmt.Errorf("unknown dimension %q", d)
+		}
+		cols = append(cols, expr)
+		groups = append(groups, fmt.Sprint(i+1))
+	}
+	for _, m := range q.Measures {
+		expr, ok := reportMeasureSQL[m]
+		if !ok {
+			return nil, fmt.Errorf("unknown measure %q", m)
+		}
+		cols = append(cols, expr)
+	}
+	statuses := make([]string, len(q.Filters.Status))
+	for i, s := range q.Filters.Status {
+		statuses[i] = string(s)
+	}
+	query := reportFacts + `
+		select ` + strings.Join(cols, ", ") + `
+		from facts f
+		where (cardinality($1::text[]) = 0 or f.team_name = any($1::text[]))
+		  and (cardinality($2::text[]) = 0 or f.user_id = any($2::text[]))
+		  and (cardinality($3::text[]) = 0 or f.status = any($3::text[]))
+		  and ($4::timestamptz is null or f.at >= $4)
+		  and ($5::timestamptz is null or f.at < $5)`
+	if len(groups) > 0 {
+		query += `
+		group by ` + strings.Join(groups, ", ") + `
+		order by ` + strings.Join(groups, ", ")
+	}
+	query += `
+		limit $6`
+	rows, err := r.db.QueryContext(ctx, query, pqStringArray(q.Filters.Teams), pqStringArray(q.Filters.Users),
+		pqStringArray(statuses), q.Filters.Since, q.Filters.Until, q.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []map[string]any{}
+	for rows.Next() {
+		dims := make([]string, len(q.Dimensions))
+		counts := make([]float64, len(q.Measures))
+		dest := make([]any, 0, len(dims)+len(counts))
+		for i := range dims {
+			dest = append(dest, &dims[i])
+		}
+		for i := range counts {
+			dest = append(dest, &counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(dest))
+		for i, d := range q.Dimensions {
+			row[d] = dims[i]
+		}
+		for i, m := range q.Measures {
+			row[m] = counts[i]
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
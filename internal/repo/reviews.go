@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// AcknowledgeReview sets the reviewer's first action time and logs an
+// "acknowledged" event, unless they have acted already.
+func (r *PostgresRepo) AcknowledgeReview(ctx context.Context, prID, userID string) (time.Time, error) {
+	var at sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		with upd as (
+			update pr_reviewers set first_action_at = $3
+			where pr_id=$1 and user_id=$2 and first_action_at is null
+			returning first_action_at
+		), ev as (
+			insert into pr_events(pr_id, kind, user_id, at)
+			select $1, 'acknowledged', $2, first_action_at from upd
+		)
+		select coalesce((select first_action_at from upd),
+		                (select first_action_at from pr_reviewers where pr_id=$1 and user_id=$2))`,
+		prID, userID, r.now()).Scan(&at)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !at.Valid {
+		return time.Time{}, domain.NewError(domain.ErrNotAssigned, "reviewer is not assigned to this PR")
+	}
+	return at.Time.UTC(), nil
+}
+
+// GetReviewStates returns the review state of each assigned reviewer.
+func (r *PostgresRepo) GetReviewStates(ctx context.Context, prID string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `select user_id, state from pr_reviewers where pr_id=$1`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var id, state string
+		if err := rows.Scan(&id, &state); err != nil {
+			return nil, err
+		}
+		out[id] = state
+	}
+	return out, rows.Err()
+}
+
+// ListReviews returns the review state of each assigned reviewer by user id.
+func (r *PostgresRepo) ListReviews(ctx context.Context, prID string) ([]domain.Review, error) {
+	rows, err := r.db.QueryContext(ctx, `select user_id, state, decided_at from pr_reviewers where pr_id=$1 order by user_id`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.Review
+	for rows.Next() {
+		var rv domain.Review
+		var at sql.NullTime
+		if err := rows.Scan(&rv.UserID, &rv.State, &at); err != nil {
+			return nil, err
+		}
+		if at.Valid {
+			t := at.Time.UTC()
+			rv.DecidedAt = &t
+		}
+		out = append(out, rv)
+	}
+	return out, rows.Err()
+}
+
+// DecideReview sets the reviewer's state and decision time, also counting
+// it as their first action.
+func (r *PostgresRepo) DecideReview(ctx context.Context, tx *sql.Tx, prID, userID, state string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+		update pr_reviewers set state=$3, decided_at=$4, first_action_at=coalesce(first_action_at, $4)
+		where pr_id=$1 and user_id=$2 and state<>$3`, prID, userID, state, r.now())
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+	var ok bool
+	if err := tx.QueryRowContext(ctx, `select exists(select 1 from pr_reviewers where pr_id=$1 and user_id=$2)`, prID, userID).Scan(&ok); err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, domain.NewError(domain.ErrNotAssigned, "reviewer is not assigned to this PR")
+	}
+	return false, nil
+}
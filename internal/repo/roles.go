@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) ListRolePermissions(ctx context.Context) ([]domain.RolePermissions, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select ro.role, rp.permission
+		from roles ro
+		left join role_permissions rp using(role)
+		order by ro.role, rp.permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.RolePermissions{}
+	for rows.Next() {
+		var role string
+		var perm sql.NullString
+		if err := rows.Scan(&role, &perm); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].Role != role {
+			out = append(out, domain.RolePermissions{Role: role, Permissions: []domain.Permission{}})
+		}
+		if perm.Valid {
+			last := &out[len(out)-1]
+			last.Permissions = append(last.Permissions, domain.Permission(perm.String))
+		}
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) SetRolePermissions(ctx context.Context, tx *sql.Tx, role string, perms []domain.Permission) error {
+	if _, err := tx.ExecContext(ctx, `insert into roles(role) values ($1) on conflict do nothing`, role); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `delete from role_permissions where role=$1`, role); err != nil {
+		return err
+	}
+	for _, p := range perms {
+		if _, err := tx.ExecContext(ctx, `insert into role_permissions(role, permission) values ($1,$2)`, role, string(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepo) RoleExists(ctx context.Context, role string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `select exists(select 1 from roles where role=$1)`, role).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"strings"
+
+	domain "prsrv/internal/domain"
+)
+
+// likeEscaper escapes the wildcards of ILIKE; backslash is its default escape.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchRank orders the matches of a type like domain.SearchRank, with $1
+// the escaped text: exact match, then prefix, then substring.
+func searchRank(id, name string) string {
+	return `case when lower(` + id + `) = lower($2) or lower(` + name + `) = lower($2) then 0
+		when ` + id + ` ilike $1 || '%' or ` + name + ` ilike $1 || '%' then 1 else 2 end`
+}
+
+// Search returns up to q.Limit matches of each type in q.Types. Encrypted
+// usernames cannot be matched in SQL, so with PII_KEYS users are found by
+// user_id only.
+func (r *PostgresRepo) Search(ctx context.Context, q domain.SearchQuery) ([]domain.SearchResult, error) {
+	text := likeEscaper.Replace(q.Text)
+	teams := pqStringArray(q.Teams)
+	out := []domain.SearchResult{}
+	for _, t := range q.Types {
+		var query string
+		args := []any{text, q.Text, teams, q.Limit}
+		switch t {
+		case domain.SearchTeam:
+			query = `
+				select t.team_name, t.team_name, '', ''
+				from teams t
+				where t.team_name ilike '%' || $1 || '%'
+				  and (cardinality($3::text[]) = 0 or t.team_name = any($3::text[]))
+				order by ` + searchRank("t.team_name", "t.team_name") + `, t.team_name
+				limit $4`
+		case domain.SearchUser:
+			query = `
+				select u.user_id, u.username, u.team_name, ''
+				from users u
+				where (u.user_id ilike '%' || $1 || '%' or (not $5 and u.username ilike '%' || $1 || '%'))
+				  and (cardinality($3::text[]) = 0 or u.team_name = any($3::text[]))
+				order by ` + searchRank("u.user_id", "u.username") + `, u.username, u.user_id
+				limit $4`
+			args = append(args, r.pii != nil)
+		case domain.SearchPR:
+			query = `
+				select p.pr_id, p.pr_name, '', p.status::text
+				from pull_requests p
+				join users a on a.user_id = p.author_id
+				where (p.pr_id ilike '%' || $1 || '%' or p.pr_name ilike '%' || $1 || '%')
+				  and (cardinality($3::text[]) = 0 or a.team_name = any($3::text[]))
+				order by ` + searchRank("p.pr_id", "p.pr_name") + `, p.pr_name, p.pr_id
+				limit $4`
+		default:
+			continue
+		}
+		found, err := r.querySearch(ctx, t, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, found...)
+	}
+	return out, nil
+}
+
+func (r *PostgresRepo) querySearch(ctx context.Context, typ, query string, args ...any) ([]domain.SearchResult, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.SearchResult
+	for rows.Next() {
+		res := domain.SearchResult{Type: typ}
+		var status string
+		if err := rows.Scan(&res.ID, &res.Name, &res.TeamName, &status); err != nil {
+			return nil, err
+		}
+		res.Status = domain.PRStatus(status)
+		if typ == domain.SearchUser {
+			if res.Name, err = r.pii.Decrypt(res.Name); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// TakeStatsSnapshot replaces the snapshot of day with the current state.
+func (r *PostgresRepo) TakeStatsSnapshot(ctx context.Context, day time.Time) (time.Time, error) {
+	takenAt := r.now()
+	d := day.Format(time.DateOnly)
+	err := r.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `delete from stats_snapshot_users where day = $1`, d); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `delete from stats_snapshot_teams where day = $1`, d); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			insert into stats_snapshot_users(day, user_id, team_name, is_active, open_assignments, total_assignments)
+			select $1, u.user_id, u.team_name, u.is_active,
+			       count(*) filter (where p.status = 'OPEN'), count(p.pr_id)
+			from users u
+			left join pr_reviewers rv on rv.user_id = u.user_id
+			left join pull_requests p on p.pr_id = rv.pr_id
+			group by u.user_id, u.team_name, u.is_active`, d); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `
+			insert into stats_snapshot_teams(day, team_name, active_members, open_prs, merged_prs, open_assignments, taken_at)
+			select $1, t.team_name,
+			       coalesce(m.active, 0), coalesce(pr.open, 0), coalesce(pr.merged, 0), coalesce(m.open_assignments, 0), $2
+			from teams t
+			left join (
+				select team_name, count(*) filter (where is_active) as active, sum(open_assignments) as open_assignments
+				from stats_snapshot_users where day = $1
+				group by team_name
+			) m on m.team_name = t.team_name
+			left join (
+				select a.team_name, count(*) filter (where p.status = 'OPEN') as open,
+				       count(*) filter (where p.status = 'MERGED') as merged
+				from pull_requests p
+				join users a on a.user_id = p.author_id
+				group by a.team_name
+			) pr on pr.team_name = t.team_name`, d, takenAt)
+		return err
+	})
+	return takenAt, err
+}
+
+// GetStatsSnapshot returns nil when no snapshot was taken on day.
+func (r *PostgresRepo) GetStatsSnapshot(ctx context.Context, day time.Time, teams []string) (*domain.StatsSnapshot, error) {
+	d := day.Format(time.DateOnly)
+	var takenAt sql.NullTime
+	if err := r.db.QueryRowContext(ctx, `select max(taken_at) from stats_snapshot_teams where day = $1`, d).Scan(&takenAt); err != nil || !takenAt.Valid {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		select team_name, active_members, open_prs, merged_prs, open_assignments
+		from stats_snapshot_teams
+		where day = $1 and (cardinality($2::text[]) = 0 or team_name = any($2::text[]))
+		order by team_name`, d, pqStringArray(teams))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snap := &domain.StatsSnapshot{Date: d, TakenAt: takenAt.Time.UTC(), Teams: []domain.TeamSnapshot{}}
+	idx := map[string]int{}
+	for rows.Next() {
+		t := domain.TeamSnapshot{Users: []domain.UserSnapshot{}}
+		if err := rows.Scan(&t.TeamName, &t.ActiveMembers, &t.OpenPRs, &t.MergedPRs, &t.OpenAssignments); err != nil {
+			return nil, err
+		}
+		idx[t.TeamName] = len(snap.Teams)
+		snap.Teams = append(snap.Teams, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	urows, err := r.db.QueryContext(ctx, `
+		select team_name, user_id, is_active, open_assignments, total_assignments
+		from stats_snapshot_users
+		where day = $1 and (cardinality($2::text[]) = 0 or team_name = any($2::text[]))
+		order by team_name, user_id`, d, pqStringArray(teams))
+	if err != nil {
+		return nil, err
+	}
+	defer urows.Close()
+	for urows.Next() {
+		var (
+			team string
+			u    domain.UserSnapshot
+		)
+		if err := urows.Scan(&team, &u.UserID, &u.IsActive, &u.OpenAssignments, &u.TotalAssignments); err != nil {
+			return nil, err
+		}
+		if i, ok := idx[team]; ok {
+			snap.Teams[i].Users = append(snap.Teams[i].Users, u)
+		}
+	}
+	return snap, urows.Err()
+}
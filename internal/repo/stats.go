@@ -0,0 +1,580 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	domain "prsrv/internal/domain"
+)
+
+// firstApprovals yields one row per PR: the earliest approval, who gave it,
+// how long after their assignment, and the author's team. $1 limits teams.
+const firstApprovals = `
+	with first as (
+		select distinct on (r.pr_id)
+		       r.pr_id, r.user_id, a.team_name,
+		       extract(epoch from r.decided_at - r.assigned_at) as secs
+		from pr_reviewers r
+		join pull_requests p on p.pr_id = r.pr_id
+		join users a on a.user_id = p.author_id
+		where r.state = 'APPROVED' and r.decided_at is not null
+		  and (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+		order by r.pr_id, r.decided_at
+	)`
+
+func (r *PostgresRepo) StatsTimeToFirstApproval(ctx context.Context, teams []string) ([]domain.DurationStats, []domain.DurationStats, error) {
+	byTeam, err := queryDurationStats(ctx, r.db, firstApprovals+`
+		select team_name, `+durationAggregates+`
+		from first group by team_name order by team_name`, pqStringArray(teams))
+	if err != nil {
+		return nil, nil, err
+	}
+	byReviewer, err := queryDurationStats(ctx, r.db, firstApprovals+`
+		select user_id, `+durationAggregates+`
+		from first group by user_id order by user_id`, pqStringArray(teams))
+	if err != nil {
+		return nil, nil, err
+	}
+	return byTeam, byReviewer, nil
+}
+
+// durationAggregates computes the DurationStats columns over a "secs" column.
+const durationAggregates = `count(*), coalesce(avg(secs), 0),
+	coalesce(percentile_cont(0.5) within group (order by secs), 0),
+	coalesce(percentile_cont(0.9) within group (order by secs), 0),
+	coalesce(percentile_cont(0.99) within group (order by secs), 0)`
+
+func (r *PostgresRepo) StatsMergeTime(ctx context.Context, teams []string, since, until *time.Time) (domain.DurationStats, []domain.DurationStats, error) {
+	const merged = `
+	with merged as (
+		select a.team_name, extract(epoch from p.merged_at - p.created_at) as secs
+		from pull_requests p
+		join users a on a.user_id = p.author_id
+		where p.merged_at is not null
+		  and (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+		  and ($2::timestamptz is null or p.merged_at >= $2)
+		  and ($3::timestamptz is null or p.merged_at < $3)
+	)`
+	args := []any{pqStringArray(teams), since, until}
+	overall, err := queryDurationStats(ctx, r.db, merged+`select 'all', `+durationAggregates+` from merged`, args...)
+	if err != nil {
+		return domain.DurationStats{}, nil, err
+	}
+	byTeam, err := queryDurationStats(ctx, r.db, merged+`
+		select team_name, `+durationAggregates+`
+		from merged group by team_name order by team_name`, args...)
+	if err != nil {
+		return domain.DurationStats{}, nil, err
+	}
+	return overall[0], byTeam, nil
+}
+
+func (r *PostgresRepo) StatsMergeTimeByRepository(ctx context.Context, teams []string, since, until *time.Time) ([]domain.DurationStats, error) {
+	return queryDurationStats(ctx, r.db, `
+		with merged as (
+			select p.repository, extract(epoch from p.merged_at - p.created_at) as secs
+			from pull_requests p
+			join users a on a.user_id = p.author_id
+			where p.merged_at is not null and p.repository is not null
+			  and (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+			  and ($2::timestamptz is null or p.merged_at >= $2)
+			  and ($3::timestamptz is null or p.merged_at < $3)
+		)
+		select repository, `+durationAggregates+`
+		from merged group by repository order by repository`, pqStringArray(teams), since, until)
+}
+
+// queryDurationStats scans rows of (key, durationAggregates...).
+func queryDurationStats(ctx context.Context, db *sql.DB, q string, args ...any) ([]domain.DurationStats, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.DurationStats{}
+	for rows.Next() {
+		var s domain.DurationStats
+		if err := rows.Scan(&s.Key, &s.Count, &s.AvgSeconds, &s.MedianSeconds, &s.P90Seconds, &s.P99Seconds); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) ListSLABreaches(ctx context.Context, f domain.SLAFilter) ([]domain.SLABreach, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		with ends as (
+			select p.pr_id, p.author_id, a.team_name, p.status, p.created_at,
+			       coalesce(
+			           (select min(r.decided_at) from pr_reviewers r where r.pr_id = p.pr_id and r.state = 'APPROVED'),
+			           case when p.status = 'OPEN' then $6::timestamptz end) as reviewed_at,
+			       coalesce(p.merged_at, p.closed_at, $6::timestamptz) as ended_at
+			from pull_requests p
+			join users a on a.user_id = p.author_id
+			where ($1::timestamptz is null or p.created_at >= $1)
+			  and ($2::timestamptz is null or p.created_at < $2)
+			  and (cardinality($3::text[]) = 0 or a.team_name = any($3::text[]))
+		), waits as (
+			-- team holidays do not count
+			select pr_id, author_id, team_name, status,
+			       extract(epoch from reviewed_at - created_at) - holiday_seconds(team_name, created_at, reviewed_at) as review_secs,
+			       extract(epoch from ended_at - created_at) - holiday_seconds(team_name, created_at, ended_at) as merge_secs
+			from ends
+		)
+		select pr_id, author_id, team_name, status, review_secs, merge_secs,
+		       coalesce(review_secs > $4, false), merge_secs > $5
+		from waits
+		where review_secs > $4 or merge_secs > $5`,
+		f.Since, f.Until, pqStringArray(f.Teams), f.Review.Seconds(), f.Merge.Seconds(), r.now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.SLABreach{}
+	for rows.Next() {
+		var b domain.SLABreach
+		if err := rows.Scan(&b.PRID, &b.AuthorID, &b.TeamName, &b.Status, &b.ReviewSeconds, &b.MergeSeconds, &b.ReviewBreach, &b.MergeBreach); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsPRStatus(ctx context.Context, teams []string, weekly bool) ([]domain.PRStatusCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select case when $2 then date_trunc('week', p.created_at at time zone 'UTC') end as week,
+		       a.team_name, p.status::text, count(*)
+		from pull_requests p
+		join users a on a.user_id = p.author_id
+		where cardinality($1::text[]) = 0 or a.team_name = any($1::text[])
+		group by 1, 2, 3
+		order by 1, 2, 3`, pqStringArray(teams), weekly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.PRStatusCount{}
+	for rows.Next() {
+		var c domain.PRStatusCount
+		var week sql.NullTime
+		if err := rows.Scan(&week, &c.TeamName, &c.Status, &c.Count); err != nil {
+			return nil, err
+		}
+		if week.Valid {
+			t := week.Time.UTC()
+			c.Week = &t
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsOpenPRAge(ctx context.Context, teams []string) ([]domain.PRAgeCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select a.team_name,
+		       case when $2::timestamptz - p.created_at < interval '1 day' then '<1d'
+		            when $2::timestamptz - p.created_at < interval '3 days' then '1-3d'
+		            when $2::timestamptz - p.created_at < interval '7 days' then '3-7d'
+		            else '>7d' end,
+		       count(*)
+		from pull_requests p
+		join users a on a.user_id = p.author_id
+		where p.status = 'OPEN'
+		  and (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+		group by 1, 2`, pqStringArray(teams), r.now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.PRAgeCount{}
+	for rows.Next() {
+		var c domain.PRAgeCount
+		if err := rows.Scan(&c.TeamName, &c.Bucket, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsDailyActivity(ctx context.Context, teams []string, from, to time.Time) ([]domain.DayCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		with days as (
+			select d::date as day from generate_series($2::date, $3::date, interval '1 day') d
+		),
+		scoped as (
+			select p.pr_id, p.merged_at
+			from pull_requests p
+			join users a on a.user_id = p.author_id
+			where cardinality($1::text[]) = 0 or a.team_name = any($1::text[])
+		),
+		assigned as (
+			select (r.assigned_at at time zone 'UTC')::date as day, count(*) as n
+			from pr_reviewers r join scoped s using(pr_id)
+			group by 1
+		),
+		merged as (
+			select (merged_at at time zone 'UTC')::date as day, count(*) as n
+			from scoped where merged_at is not null
+			group by 1
+		)
+		select to_char(d.day, 'YYYY-MM-DD'), coalesce(a.n, 0), coalesce(m.n, 0)
+		from days d
+		left join assigned a using(day)
+		left join merged m using(day)
+		order by d.day`, pqStringArray(teams), from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.DayCount{}
+	for rows.Next() {
+		var d domain.DayCount
+		if err := rows.Scan(&d.Date, &d.Assignments, &d.Merges); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsReviewerResponsiveness(ctx context.Context, teams []string, sla time.Duration) ([]domain.ReviewerResponsiveness, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		with acts as (
+			select r.user_id,
+			       extract(epoch from r.first_action_at - r.assigned_at)
+			           - holiday_seconds(u.team_name, r.assigned_at, r.first_action_at) as secs,
+			       r.first_action_at is null and extract(epoch from $3::timestamptz - r.assigned_at)
+			           - holiday_seconds(u.team_name, r.assigned_at, $3::timestamptz) > $2 as late
+			from pr_reviewers r
+			join users u using(user_id)
+			join pull_requests p on p.pr_id = r.pr_id
+			where (cardinality($1::text[]) = 0 or u.team_name = any($1::text[]))
+			  -- nobody is waiting on reviewers of a closed PR
+			  and (r.first_action_at is not null or p.status <> 'CLOSED')
+		)
+		select user_id,
+		       count(secs), count(*) - count(secs),
+		       coalesce(avg(secs), 0),
+		       coalesce(percentile_cont(0.5) within group (order by secs), 0),
+		       coalesce(percentile_cont(0.9) within group (order by secs), 0),
+		       count(*) filter (where secs <= $2),
+		       count(*) filter (where secs > $2 or late)
+		from acts
+		group by user_id
+		order by user_id`, pqStringArray(teams), sla.Seconds(), r.now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.ReviewerResponsiveness{}
+	for rows.Next() {
+		var s domain.ReviewerResponsiveness
+		if err := rows.Scan(&s.UserID, &s.Responded, &s.Pending, &s.AvgSeconds, &s.MedianSeconds,
+			&s.P90Seconds, &s.WithinSLA, &s.Overdue); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// ListIdleReviewers returns active users with no assignment since the given
+// time, ordered by team and user.
+func (r *PostgresRepo) ListIdleReviewers(ctx context.Context, teams []string, since time.Time) ([]domain.IdleReviewer, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select u.team_name, u.user_id, u.username, max(pr.assigned_at)
+		from users u
+		left join pr_reviewers pr on pr.user_id = u.user_id
+		where u.is_active
+		  and (cardinality($1::text[]) = 0 or u.team_name = any($1::text[]))
+		group by u.team_name, u.user_id, u.username
+		having coalesce(max(pr.assigned_at) < $2, true)
+		order by u.team_name, u.user_id`, pqStringArray(teams), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.IdleReviewer{}
+	for rows.Next() {
+		var u domain.IdleReviewer
+		var last sql.NullTime
+		if err := rows.Scan(&u.TeamName, &u.UserID, &u.Username, &last); err != nil {
+			return nil, err
+		}
+		if u.Username, err = r.pii.Decrypt(u.Username); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			t := last.Time.UTC()
+			u.LastAssignedAt = &t
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsTeamComparison(ctx context.Context, teams []string) ([]domain.TeamComparison, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		with prs as (
+			select a.team_name, p.status, extract(epoch from p.merged_at - p.created_at) as merge_secs,
+			       (select count(*) from pr_reviewers r where r.pr_id = p.pr_id) as reviewers
+			from pull_requests p
+			join users a on a.user_id = p.author_id
+		), pr_stats as (
+			select team_name,
+			       count(*) filter (where status = 'OPEN') as open_prs,
+			       avg(reviewers) as avg_reviewers,
+			       percentile_cont(0.5) within group (order by merge_secs) filter (where status = 'MERGED') as median_merge
+			from prs group by team_name
+		), per_reviewer as (
+			select u.team_name, count(*)::float8 as n
+			from pr_reviewers r
+			join users u using(user_id)
+			group by u.team_name, r.user_id
+		), concentration as (
+			select team_name, sum(n * n) / (sum(n) * sum(n)) as hhi
+			from per_reviewer group by team_name
+		), members as (
+			select team_name, count(*) as members from users group by team_name
+		)
+		select t.team_name, coalesce(m.members, 0), coalesce(s.open_prs, 0), coalesce(s.avg_reviewers, 0),
+		       coalesce(s.median_merge, 0), coalesce(c.hhi, 0)
+		from teams t
+		left join members m using(team_name)
+		left join pr_stats s using(team_name)
+		left join concentration c using(team_name)
+		where cardinality($1::text[]) = 0 or t.team_name = any($1::text[])
+		order by t.team_name`, pqStringArray(teams))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.TeamComparison{}
+	for rows.Next() {
+		var c domain.TeamComparison
+		if err := rows.Scan(&c.TeamName, &c.Members, &c.OpenPRs, &c.AvgReviewersPerPR,
+			&c.MedianMergeSeconds, &c.AssignmentConcentration); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) StatsMergeTimeMaterialized(ctx context.Context) (domain.DurationStats, []domain.DurationStats, error) {
+	rows, err := queryDurationStats(ctx, r.db, `
+		select team_name, merged, avg_secs, p50_secs, p90_secs, p99_secs
+		from mv_merge_latency order by team_name`)
+	if err != nil {
+		return domain.DurationStats{}, nil, err
+	}
+	overall := domain.DurationStats{Key: "all"}
+	byTeam := []domain.DurationStats{}
+	for _, s := range rows {
+		if s.Key == "" {
+			overall = s
+			overall.Key = "all"
+			continue
+		}
+		byTeam = append(byTeam, s)
+	}
+	return overall, byTeam, nil
+}
+
+// RefreshStats refreshes the materialized aggregates without blocking their
+// readers and records the time the refresh started.
+func (r *PostgresRepo) RefreshStats(ctx context.Context) (time.Time, error) {
+	at := r.now()
+	for _, view := range []string{"mv_assignments_by_user", "mv_merge_latency"} {
+		if _, err := r.db.ExecContext(ctx, `refresh materialized view concurrently `+view); err != nil {
+			return time.Time{}, fmt.Errorf("refresh %s: %w", view, err)
+		}
+	}
+	_, err := r.db.ExecContext(ctx, `
+		insert into stats_refresh(name, refreshed_at) values ('stats', $1)
+		on conflict (name) do update set refreshed_at = excluded.refreshed_at`, at)
+	return at, err
+}
+
+func (r *PostgresRepo) StatsRefreshedAt(ctx context.Context) (time.Time, error) {
+	var at time.Time
+	err := r.db.QueryRowContext(ctx, `select refreshed_at from stats_refresh where name = 'stats'`).Scan(&at)
+	return at.UTC(), err
+}
+
+// StatsOpenPRsByDay counts PRs open at the end of each UTC day, per author
+// team. Teams without PRs in scope produce no rows.
+func (r *PostgresRepo) StatsOpenPRsByDay(ctx context.Context, teams []string, from, to time.Time) ([]domain.TeamDayCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		with days as (
+			select d::date as day, (d::date + 1)::timestamp at time zone 'UTC' as day_end
+			from generate_series($2::date, $3::date, interval '1 day') d
+		),
+		scoped as (
+			select a.team_name, p.created_at, coalesce(p.merged_at, p.closed_at) as ended_at
+			from pull_requests p
+			join users a on a.user_id = p.author_id
+			where cardinality($1::text[]) = 0 or a.team_name = any($1::text[])
+		),
+		team_list as (
+			select distinct team_name from scoped
+		)
+		select to_char(d.day, 'YYYY-MM-DD'), t.team_name, count(s.created_at)
+		from days d
+		cross join team_list t
+		left join scoped s on s.team_name = t.team_name
+		                  and s.created_at < d.day_end
+		                  and (s.ended_at is null or s.ended_at >= d.day_end)
+		group by d.day, t.team_name
+		order by d.day, t.team_name`, pqStringArray(teams), from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.TeamDayCount{}
+	for rows.Next() {
+		var c domain.TeamDayCount
+		if err := rows.Scan(&c.Date, &c.TeamName, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// StatsReassignments returns the number of PRs created in [from, to] (UTC
+// dates) by authors of teams, and every replacement or removal of a reviewer
+// on those PRs with the reviewer's team.
+func (r *PostgresRepo) StatsReassignments(ctx context.Context, teams []string, from, to time.Time) (int, []domain.Reassignment, error) {
+	const scoped = `
+		from pull_requests p
+		join users a on a.user_id = p.author_id
+		where (cardinality($1::text[]) = 0 or a.team_name = any($1::text[]))
+		  and p.created_at >= $2::date::timestamp at time zone 'UTC'
+		  and p.created_at < ($3::date + 1)::timestamp at time zone 'UTC'`
+	args := []any{pqStringArray(teams), from.Format(time.DateOnly), to.Format(time.DateOnly)}
+	var prs int
+	if err := r.db.QueryRowContext(ctx, `select count(*)`+scoped, args...).Scan(&prs); err != nil {
+		return 0, nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		select e.pr_id, e.user_id, coalesce(u.team_name, ''), coalesce(e.reason, '')
+		from pr_events e
+		join (select p.pr_id`+scoped+`) s on s.pr_id = e.pr_id
+		left join users u on u.user_id = e.user_id
+		where e.kind in ('replaced', 'removed') and e.user_id is not null
+		order by e.at, e.id`, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	var out []domain.Reassignment
+	for rows.Next() {
+		var m domain.Reassignment
+		if err := rows.Scan(&m.PRID, &m.UserID, &m.TeamName, &m.Reason); err != nil {
+			return 0, nil, err
+		}
+		out = append(out, m)
+	}
+	return prs, out, rows.Err()
+}
+
+// StatsPairings counts the assignments made in [from, to] (UTC dates) by
+// author and reviewer.
+func (r *PostgresRepo) StatsPairings(ctx context.Context, teams []string, from, to time.Time) ([]domain.Pairing, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select a.author_id, a.reviewer_id, u.team_name, count(*)
+		from (`+pairingEvents+`) a
+		join users u on u.user_id = a.author_id
+		where (cardinality($1::text[]) = 0 or u.team_name = any($1::text[]))
+		  and a.at >= $2::date::timestamp at time zone 'UTC'
+		  and a.at < ($3::date + 1)::timestamp at time zone 'UTC'
+		group by a.author_id, a.reviewer_id, u.team_name
+		order by a.author_id, a.reviewer_id`,
+		pqStringArray(teams), from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.Pairing
+	for rows.Next() {
+		var p domain.Pairing
+		if err := rows.Scan(&p.AuthorID, &p.ReviewerID, &p.TeamName, &p.Assignments); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) ListPRHistory(ctx context.Context, teams []string, from, to time.Time) ([]domain.HistoricalPR, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select p.pr_id, p.author_id, u.team_name, p.created_at, p.merged_at,
+		       coalesce(array_agg(rv.user_id order by rv.user_id) filter (where rv.user_id is not null), '{}')
+		from pull_requests p
+		join users u on u.user_id = p.author_id
+		left join pr_reviewers rv on rv.pr_id = p.pr_id
+		where (cardinality($1::text[]) = 0 or u.team_name = any($1::text[]))
+		  and p.created_at >= $2::date::timestamp at time zone 'UTC'
+		  and p.created_at < ($3::date + 1)::timestamp at time zone 'UTC'
+		group by p.pr_id, u.team_name
+		order by p.created_at, p.pr_id`,
+		pqStringArray(teams), from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.HistoricalPR
+	for rows.Next() {
+		var h domain.HistoricalPR
+		if err := rows.Scan(&h.PRID, &h.AuthorID, &h.TeamName, &h.CreatedAt, &h.MergedAt, pq.Array(&h.Reviewers)); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// StatsAuthorMergeRates lists authors with PRs created in [from, to] (UTC
+// dates) or open now.
+func (r *PostgresRepo) StatsAuthorMergeRates(ctx context.Context, teams []string, from, to time.Time, mergeSLA time.Duration) ([]domain.AuthorMergeRate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		with scoped as (
+			select p.author_id, a.team_name, p.status, p.created_at, p.merged_at,
+			       p.created_at >= $2::date::timestamp at time zone 'UTC'
+			       and p.created_at < ($3::date + 1)::timestamp at time zone 'UTC' as in_period
+			from pull_requests p
+			join users a on a.user_id = p.author_id
+			where cardinality($1::text[]) = 0 or a.team_name = any($1::text[])
+		)
+		select author_id, team_name,
+		       count(*) filter (where in_period),
+		       count(*) filter (where in_period and status = 'MERGED'),
+		       count(*) filter (where status = 'OPEN'),
+		       count(*) filter (where status = 'OPEN' and created_at < $5::timestamptz - make_interval(secs => $4)),
+		       coalesce(extract(epoch from $5::timestamptz - min(created_at) filter (where status = 'OPEN')), 0)::float8
+		from scoped
+		group by author_id, team_name
+		having count(*) filter (where in_period or status = 'OPEN') > 0
+		order by author_id`, pqStringArray(teams), from.Format(time.DateOnly), to.Format(time.DateOnly), mergeSLA.Seconds(), r.now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.AuthorMergeRate{}
+	for rows.Next() {
+		var a domain.AuthorMergeRate
+		if err := rows.Scan(&a.UserID, &a.TeamName, &a.Created, &a.Merged, &a.Open, &a.LongOpen, &a.OldestOpenAgeSeconds); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
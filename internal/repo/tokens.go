@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	domain "prsrv/internal/domain"
+)
+
+const apiTokenColumns = `token_id, user_id, role, name, teams, token_hash, created_at, expires_at, revoked_at`
+
+func scanAPIToken(row interface{ Scan(...any) error }) (*domain.APIToken, error) {
+	var t domain.APIToken
+	var expiresAt, revokedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Role, &t.Name, pq.Array(&t.Teams), &t.Hash, &t.CreatedAt, &expiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	t.CreatedAt = t.CreatedAt.UTC()
+	if expiresAt.Valid {
+		v := expiresAt.Time.UTC()
+		t.ExpiresAt = &v
+	}
+	if revokedAt.Valid {
+		v := revokedAt.Time.UTC()
+		t.RevokedAt = &v
+	}
+	return &t, nil
+}
+
+func (r *PostgresRepo) CreateAPIToken(ctx context.Context, tx *sql.Tx, t domain.APIToken) (*domain.APIToken, error) {
+	row := tx.QueryRowContext(ctx, `
+		insert into api_tokens(token_id, user_id, role, name, teams, token_hash, expires_at, created_at)
+		values ($1,$2,$3,$4,$5,$6,$7,$8)
+		returning `+apiTokenColumns, t.ID, t.UserID, t.Role, t.Name, pqStringArray(t.Teams), t.Hash, t.ExpiresAt, r.now())
+	return scanAPIToken(row)
+}
+
+func (r *PostgresRepo) GetAPIToken(ctx context.Context, tokenID string) (*domain.APIToken, error) {
+	t, err := scanAPIToken(r.db.QueryRowContext(ctx, `select `+apiTokenColumns+` from api_tokens where token_id=$1`, tokenID))
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapError(domain.ErrNotFound, "token not found", err)
+	}
+	return t, err
+}
+
+func (r *PostgresRepo) SetAPITokenExpiry(ctx context.Context, tx *sql.Tx, tokenID string, expiresAt time.Time) (*domain.APIToken, error) {
+	t, err := scanAPIToken(tx.QueryRowContext(ctx, `
+		update api_tokens set expires_at=$2
+		where token_id=$1
+		returning `+apiTokenColumns, tokenID, expiresAt))
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapError(domain.ErrNotFound, "token not found", err)
+	}
+	return t, err
+}
+
+func (r *PostgresRepo) GetAPITokenByHash(ctx context.Context, hash string) (*domain.APIToken, error) {
+	t, err := scanAPIToken(r.db.QueryRowContext(ctx, `select `+apiTokenColumns+` from api_tokens where token_hash=$1`, hash))
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapError(domain.ErrNotFound, "token not found", err)
+	}
+	return t, err
+}
+
+func (r *PostgresRepo) RevokeAPIToken(ctx context.Context, tokenID string) (*domain.APIToken, error) {
+	t, err := scanAPIToken(r.db.QueryRowContext(ctx, `
+		update api_tokens set revoked_at=coalesce(revoked_at, $2)
+		where token_id=$1
+		returning `+apiTokenColumns, tokenID, r.now()))
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapError(domain.ErrNotFound, "token not found", err)
+	}
+	return t, err
+}
+
+// RevokeUserAPITokens revokes every active token of the user and returns their ids.
+func (r *PostgresRepo) RevokeUserAPITokens(ctx context.Context, tx *sql.Tx, userID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `update api_tokens set revoked_at=$2
+		where user_id=$1 and revoked_at is null
+		returning token_id`, userID, r.now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *PostgresRepo) ListAPITokens(ctx context.Context, userID string) ([]domain.APIToken, error) {
+	rows, err := r.db.QueryContext(ctx, `select `+apiTokenColumns+` from api_tokens
+		where ($1 = '' or user_id=$1)
+		order by created_at, token_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *t)
+	}
+	return out, rows.Err()
+}
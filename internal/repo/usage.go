@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// AddTokenUsage adds the batch counters to the stored totals. Token ids must
+// be unique within a batch.
+func (r *PostgresRepo) AddTokenUsage(ctx context.Context, usage []domain.TokenUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString(`insert into token_usage(token_id, method, user_id, role, requests, errors, first_used_at, last_used_at, last_status) values `)
+	args := make([]any, 0, len(usage)*9)
+	for i, u := range usage {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(")
+		for j := 1; j <= 9; j++ {
+			if j > 1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("$" + strconv.Itoa(i*9+j))
+		}
+		sb.WriteString(")")
+		args = append(args, u.TokenID, u.Method, u.UserID, u.Role, u.Requests, u.Errors, u.FirstUsedAt, u.LastUsedAt, u.LastStatus)
+	}
+	sb.WriteString(` on conflict (token_id) do update set
+		method = excluded.method,
+		user_id = excluded.user_id,
+		role = excluded.role,
+		requests = token_usage.requests + excluded.requests,
+		errors = token_usage.errors + excluded.errors,
+		last_used_at = greatest(token_usage.last_used_at, excluded.last_used_at),
+		last_status = excluded.last_status`)
+	_, err := r.db.ExecContext(ctx, sb.String(), args...)
+	return err
+}
+
+func (r *PostgresRepo) ListTokenUsage(ctx context.Context, idleSince *time.Time) ([]domain.TokenUsage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select coalesce(u.token_id, t.token_id), coalesce(u.method, 'token'),
+		       coalesce(u.user_id, t.user_id), coalesce(u.role, t.role),
+		       coalesce(u.requests, 0), coalesce(u.errors, 0),
+		       u.first_used_at, u.last_used_at, coalesce(u.last_status, 0)
+		from token_usage u
+		full join (select token_id, user_id, role from api_tokens where revoked_at is null) t
+		  on t.token_id = u.token_id
+		where $1::timestamptz is null or u.last_used_at is null or u.last_used_at < $1
+		order by u.last_used_at nulls first, 1`, idleSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []domain.TokenUsage{}
+	for rows.Next() {
+		var u domain.TokenUsage
+		if err := rows.Scan(&u.TokenID, &u.Method, &u.UserID, &u.Role, &u.Requests, &u.Errors, &u.FirstUsedAt, &u.LastUsedAt, &u.LastStatus); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (r *PostgresRepo) AddPRWatcher(ctx context.Context, tx *sql.Tx, prID, userID string) error {
+	_, err := tx.ExecContext(ctx, `insert into pr_watchers(pr_id, user_id, created_at) values ($1, $2, $3) on conflict do nothing`, prID, userID, r.now())
+	return err
+}
+
+func (r *PostgresRepo) DeletePRWatcher(ctx context.Context, tx *sql.Tx, prID, userID string) error {
+	_, err := tx.ExecContext(ctx, `delete from pr_watchers where pr_id = $1 and user_id = $2`, prID, userID)
+	return err
+}
+
+func (r *PostgresRepo) ListPRWatchers(ctx context.Context, prID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `select user_id from pr_watchers where pr_id = $1 order by user_id`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	domain "prsrv/internal/domain"
+)
+
+// CreateWebhook stores the webhook; its secret is encrypted like other
+// personal data when PII keys are set.
+func (r *PostgresRepo) CreateWebhook(ctx context.Context, w domain.Webhook) error {
+	secret, err := r.pii.Encrypt(w.Secret)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `insert into webhooks(webhook_id, url, events, secret, created_at) values ($1,$2,$3,$4,$5)`,
+		w.ID, w.URL, pqStringArray(w.Events), secret, w.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepo) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, `select webhook_id, url, events, secret, created_at from webhooks order by created_at, webhook_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.Webhook
+	for rows.Next() {
+		var w domain.Webhook
+		if err := rows.Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Secret, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		if w.Secret, err = r.pii.Decrypt(w.Secret); err != nil {
+			return nil, err
+		}
+		w.CreatedAt = w.CreatedAt.UTC()
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `delete from webhooks where webhook_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.NewError(domain.ErrNotFound, "webhook not found")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+func (r *PostgresRepo) DeferReview(ctx context.Context, tx *sql.Tx, prID, userID string, until time.Time) error {
+	_, err := tx.ExecContext(ctx, `update pr_reviewers set deliver_at = $3 where pr_id = $1 and user_id = $2`, prID, userID, until)
+	return err
+}
+
+// ListDueDeferredReviews returns the queued assignments to open PRs whose
+// delivery time has come, oldest first.
+func (r *PostgresRepo) ListDueDeferredReviews(ctx context.Context, now time.Time) ([]domain.DeferredReview, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		select r.pr_id, r.user_id, r.deliver_at
+		from pr_reviewers r
+		join pull_requests p using(pr_id)
+		where r.deliver_at <= $1 and p.status = 'OPEN'
+		order by r.deliver_at, r.pr_id, r.user_id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.DeferredReview
+	for rows.Next() {
+		var d domain.DeferredReview
+		if err := rows.Scan(&d.PRID, &d.UserID, &d.DeliverAt); err != nil {
+			return nil, err
+		}
+		d.DeliverAt = d.DeliverAt.UTC()
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresRepo) ClearDeferredReview(ctx context.Context, tx *sql.Tx, prID, userID string) error {
+	_, err := tx.ExecContext(ctx, `update pr_reviewers set deliver_at = null where pr_id = $1 and user_id = $2`, prID, userID)
+	return err
+}
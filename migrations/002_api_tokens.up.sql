@@ -0,0 +1,12 @@
+create table if not exists api_tokens (
+    token_id   text primary key,
+    user_id    text not null references users(user_id) on delete cascade,
+    role       text not null check (role in ('admin','user')),
+    name       text not null default '',
+    token_hash text not null unique,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz,
+    revoked_at timestamptz
+);
+
+create index if not exists idx_api_tokens_user on api_tokens(user_id);
@@ -0,0 +1,5 @@
+alter table api_tokens drop constraint if exists api_tokens_role_fkey;
+delete from api_tokens where role not in ('admin', 'user');
+alter table api_tokens add constraint api_tokens_role_check check (role in ('admin','user'));
+drop table if exists role_permissions;
+drop table if exists roles;
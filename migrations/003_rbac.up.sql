@@ -0,0 +1,22 @@
+create table if not exists roles (
+    role text primary key
+);
+
+create table if not exists role_permissions (
+    role       text not null references roles(role) on delete cascade,
+    permission text not null,
+    primary key (role, permission)
+);
+
+insert into roles(role) values ('admin'), ('user') on conflict do nothing;
+
+insert into role_permissions(role, permission) values
+    ('admin', '*'),
+    ('user', 'team:read'),
+    ('user', 'pr:read'),
+    ('user', 'stats:read')
+on conflict do nothing;
+
+alter table api_tokens drop constraint if exists api_tokens_role_check;
+alter table api_tokens drop constraint if exists api_tokens_role_fkey;
+alter table api_tokens add constraint api_tokens_role_fkey foreign key (role) references roles(role) on delete cascade;
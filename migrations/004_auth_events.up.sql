@@ -0,0 +1,15 @@
+create table if not exists auth_events (
+    id          bigserial primary key,
+    occurred_at timestamptz not null default now(),
+    event_type  text not null,
+    outcome     text not null,
+    token_id    text not null default '',
+    user_id     text not null default '',
+    role        text not null default '',
+    method      text not null default '',
+    route       text not null default '',
+    ip          text not null default ''
+);
+
+create index if not exists idx_auth_events_time on auth_events(occurred_at);
+create index if not exists idx_auth_events_token on auth_events(token_id, occurred_at);
@@ -0,0 +1 @@
+alter table api_tokens drop column if exists teams;
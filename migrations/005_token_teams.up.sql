@@ -0,0 +1 @@
+alter table api_tokens add column if not exists teams text[] not null default '{}';
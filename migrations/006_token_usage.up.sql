@@ -0,0 +1,13 @@
+create table if not exists token_usage (
+    token_id      text primary key,
+    method        text not null default '',
+    user_id       text not null default '',
+    role          text not null default '',
+    requests      bigint not null default 0,
+    errors        bigint not null default 0,
+    first_used_at timestamptz not null,
+    last_used_at  timestamptz not null,
+    last_status   int not null default 0
+);
+
+create index if not exists idx_token_usage_last_used on token_usage(last_used_at);
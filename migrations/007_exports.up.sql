@@ -0,0 +1,13 @@
+create table if not exists exports (
+    export_id   text primary key,
+    kind        text not null,
+    teams       text[] not null default '{}',
+    status      text not null,
+    error       text not null default '',
+    content     bytea,
+    created_by  text not null default '',
+    created_at  timestamptz not null default now(),
+    finished_at timestamptz
+);
+
+create index if not exists idx_exports_created on exports(created_at);
@@ -0,0 +1,4 @@
+drop index if exists idx_pr_reviewers_state;
+alter table pr_reviewers drop column if exists decided_at;
+alter table pr_reviewers drop column if exists state;
+alter table pr_reviewers drop column if exists assigned_at;
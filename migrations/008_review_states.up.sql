@@ -0,0 +1,10 @@
+alter table pr_reviewers add column if not exists assigned_at timestamptz not null default now();
+alter table pr_reviewers add column if not exists state text not null default 'PENDING';
+alter table pr_reviewers add column if not exists decided_at timestamptz;
+
+-- rows that predate the column were assigned when their PR was created
+update pr_reviewers r set assigned_at = p.created_at
+from pull_requests p
+where p.pr_id = r.pr_id and r.assigned_at > p.created_at;
+
+create index if not exists idx_pr_reviewers_state on pr_reviewers(state, decided_at);
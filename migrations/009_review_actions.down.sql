@@ -0,0 +1,3 @@
+delete from role_permissions where role = 'user' and permission = 'pr:review';
+
+alter table pr_reviewers drop column if exists first_action_at;
@@ -0,0 +1,8 @@
+alter table pr_reviewers add column if not exists first_action_at timestamptz;
+
+-- a decision is also the reviewer's first action
+update pr_reviewers set first_action_at = decided_at
+where first_action_at is null and decided_at is not null;
+
+insert into role_permissions(role, permission) values ('user', 'pr:review')
+on conflict do nothing;
@@ -0,0 +1,3 @@
+drop table if exists stats_refresh;
+drop materialized view if exists mv_merge_latency;
+drop materialized view if exists mv_assignments_by_user;
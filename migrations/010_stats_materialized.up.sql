@@ -0,0 +1,34 @@
+create materialized view if not exists mv_assignments_by_user as
+select r.user_id, u.team_name, count(*)::int as assignments
+from pr_reviewers r
+join users u using(user_id)
+group by r.user_id, u.team_name;
+
+create unique index if not exists mv_assignments_by_user_pk on mv_assignments_by_user(user_id);
+create index if not exists mv_assignments_by_user_team on mv_assignments_by_user(team_name);
+
+-- one row per author team plus the overall row with an empty team_name
+create materialized view if not exists mv_merge_latency as
+with merged as (
+    select a.team_name, extract(epoch from p.merged_at - p.created_at) as secs
+    from pull_requests p
+    join users a on a.user_id = p.author_id
+    where p.merged_at is not null
+)
+select coalesce(team_name, '') as team_name,
+       count(*)::int as merged,
+       coalesce(avg(secs), 0)::float8 as avg_secs,
+       coalesce(percentile_cont(0.5) within group (order by secs), 0)::float8 as p50_secs,
+       coalesce(percentile_cont(0.9) within group (order by secs), 0)::float8 as p90_secs,
+       coalesce(percentile_cont(0.99) within group (order by secs), 0)::float8 as p99_secs
+from merged
+group by grouping sets ((team_name), ());
+
+create unique index if not exists mv_merge_latency_pk on mv_merge_latency(team_name);
+
+create table if not exists stats_refresh (
+    name         text primary key,
+    refreshed_at timestamptz not null
+);
+
+insert into stats_refresh(name, refreshed_at) values ('stats', now()) on conflict do nothing;
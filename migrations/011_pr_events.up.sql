@@ -0,0 +1,31 @@
+create table if not exists pr_events (
+    id          bigserial primary key,
+    pr_id       text not null references pull_requests(pr_id) on delete cascade,
+    kind        text not null,
+    user_id     text,
+    replaced_by text,
+    reason      text,
+    at          timestamptz not null default now()
+);
+
+create index if not exists idx_pr_events_pr on pr_events(pr_id, at, id);
+
+-- rebuild what the current tables still know about existing PRs; earlier
+-- replacements were not recorded and cannot be recovered
+insert into pr_events(pr_id, kind, user_id, at)
+select pr_id, kind, user_id, at from (
+    select pr_id, 'created' as kind, author_id as user_id, created_at as at, 0 as seq from pull_requests
+    union all
+    select pr_id, 'assigned', user_id, assigned_at, 1 from pr_reviewers
+    union all
+    select pr_id, 'acknowledged', user_id, first_action_at, 2 from pr_reviewers
+    where first_action_at is not null and first_action_at is distinct from decided_at
+    union all
+    select pr_id, 'approved', user_id, decided_at, 3 from pr_reviewers
+    where state = 'APPROVED' and decided_at is not null
+    union all
+    select pr_id, 'merged', null, merged_at, 4 from pull_requests
+    where merged_at is not null
+) history
+where not exists (select 1 from pr_events)
+order by at, seq;
@@ -0,0 +1,2 @@
+drop table if exists overload_alerts;
+alter table teams drop column if exists lead_user_id;
@@ -0,0 +1,17 @@
+alter table teams add column if not exists lead_user_id text references users(user_id) on delete set null;
+
+create table if not exists overload_alerts (
+    id           bigserial primary key,
+    team_name    text not null references teams(team_name) on delete cascade,
+    user_id      text not null references users(user_id) on delete cascade,
+    lead_user_id text,
+    reason       text not null,
+    open_count   int not null,
+    team_median  float8 not null,
+    created_at   timestamptz not null default now(),
+    resolved_at  timestamptz
+);
+
+-- one active alert per reviewer; it is resolved once their load is back to normal
+create unique index if not exists idx_overload_alerts_active on overload_alerts(user_id) where resolved_at is null;
+create index if not exists idx_overload_alerts_team on overload_alerts(team_name, created_at);
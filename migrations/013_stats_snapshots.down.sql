@@ -0,0 +1,2 @@
+drop table if exists stats_snapshot_users;
+drop table if exists stats_snapshot_teams;
@@ -0,0 +1,24 @@
+-- daily snapshots of workload aggregates; the day's snapshot is overwritten
+-- until the day ends, so it reflects the last state of that day
+create table if not exists stats_snapshot_teams (
+    day              date not null,
+    team_name        text not null,
+    active_members   int not null,
+    open_prs         int not null,
+    merged_prs       int not null,
+    open_assignments int not null,
+    taken_at         timestamptz not null,
+    primary key (day, team_name)
+);
+
+create table if not exists stats_snapshot_users (
+    day               date not null,
+    user_id           text not null,
+    team_name         text not null,
+    is_active         boolean not null,
+    open_assignments  int not null,
+    total_assignments int not null,
+    primary key (day, user_id)
+);
+
+create index if not exists idx_stats_snapshot_users_team on stats_snapshot_users(day, team_name);
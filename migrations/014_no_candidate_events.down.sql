@@ -0,0 +1 @@
+drop table if exists no_candidate_events;
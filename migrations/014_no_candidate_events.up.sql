@@ -0,0 +1,10 @@
+create table if not exists no_candidate_events (
+    id        bigserial primary key,
+    op        text not null,
+    team_name text not null references teams(team_name) on delete cascade,
+    pr_id     text,
+    user_id   text,
+    at        timestamptz not null default now()
+);
+
+create index if not exists idx_no_candidate_events_at on no_candidate_events(at);
@@ -0,0 +1,2 @@
+drop index if exists idx_pr_repository;
+alter table pull_requests drop column if exists repository;
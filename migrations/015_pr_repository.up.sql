@@ -0,0 +1,3 @@
+alter table pull_requests add column if not exists repository text;
+
+create index if not exists idx_pr_repository on pull_requests(repository) where repository is not null;
@@ -0,0 +1,5 @@
+drop index if exists idx_pr_name_trgm;
+drop index if exists idx_pr_id_trgm;
+drop index if exists idx_users_name_trgm;
+drop index if exists idx_users_id_trgm;
+drop index if exists idx_teams_name_trgm;
@@ -0,0 +1,8 @@
+-- trigram indexes make the ILIKE '%...%' lookups of /search use an index
+create extension if not exists pg_trgm;
+
+create index if not exists idx_teams_name_trgm on teams using gin (team_name gin_trgm_ops);
+create index if not exists idx_users_id_trgm on users using gin (user_id gin_trgm_ops);
+create index if not exists idx_users_name_trgm on users using gin (username gin_trgm_ops);
+create index if not exists idx_pr_id_trgm on pull_requests using gin (pr_id gin_trgm_ops);
+create index if not exists idx_pr_name_trgm on pull_requests using gin (pr_name gin_trgm_ops);
@@ -0,0 +1 @@
+alter table teams add column if not exists manual_assignment boolean not null default false;
@@ -0,0 +1,4 @@
+alter table teams drop column if exists min_age_seconds;
+alter table teams drop column if exists require_lead_approval;
+alter table teams drop column if exists block_on_changes_requested;
+alter table teams drop column if exists min_approvals;
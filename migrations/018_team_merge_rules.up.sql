@@ -0,0 +1,4 @@
+alter table teams add column if not exists min_approvals int not null default 0;
+alter table teams add column if not exists block_on_changes_requested boolean not null default false;
+alter table teams add column if not exists require_lead_approval boolean not null default false;
+alter table teams add column if not exists min_age_seconds bigint not null default 0;
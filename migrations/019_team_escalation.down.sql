@@ -0,0 +1,2 @@
+alter table teams drop column if exists escalate_add_reviewer_days;
+alter table teams drop column if exists escalate_notify_days;
@@ -0,0 +1,2 @@
+alter table teams add column if not exists escalate_notify_days int not null default 0;
+alter table teams add column if not exists escalate_add_reviewer_days int not null default 0;
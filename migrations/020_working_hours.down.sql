@@ -0,0 +1,7 @@
+drop index if exists idx_pr_reviewers_deliver_at;
+
+alter table pr_reviewers drop column if exists deliver_at;
+
+alter table teams drop column if exists work_end_hour;
+alter table teams drop column if exists work_start_hour;
+alter table teams drop column if exists work_time_zone;
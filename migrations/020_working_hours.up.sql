@@ -0,0 +1,7 @@
+alter table teams add column if not exists work_time_zone text not null default '';
+alter table teams add column if not exists work_start_hour int not null default 0;
+alter table teams add column if not exists work_end_hour int not null default 0;
+
+alter table pr_reviewers add column if not exists deliver_at timestamptz;
+
+create index if not exists idx_pr_reviewers_deliver_at on pr_reviewers(deliver_at) where deliver_at is not null;
@@ -0,0 +1,2 @@
+drop function if exists holiday_seconds(text, timestamptz, timestamptz);
+drop table if exists team_holidays;
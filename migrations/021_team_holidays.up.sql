@@ -0,0 +1,21 @@
+create table if not exists team_holidays (
+    team_name text not null references teams(team_name) on delete cascade,
+    day       date not null,
+    name      text not null default '',
+    primary key (team_name, day)
+);
+
+-- holiday_seconds is how much of [p_from, p_to) falls on holidays of the
+-- team, whole days in the time zone of its working hours (UTC without them).
+create or replace function holiday_seconds(p_team text, p_from timestamptz, p_to timestamptz)
+returns double precision language sql stable as $$
+    select coalesce(sum(extract(epoch from
+               least(p_to, (h.day + 1)::timestamp at time zone z.tz)
+               - greatest(p_from, h.day::timestamp at time zone z.tz))), 0)::double precision
+    from team_holidays h
+    cross join (select coalesce(nullif(t.work_time_zone, ''), 'UTC') as tz
+                from teams t where t.team_name = p_team) z
+    where h.team_name = p_team
+      and h.day::timestamp at time zone z.tz < p_to
+      and (h.day + 1)::timestamp at time zone z.tz > p_from
+$$;
@@ -0,0 +1,8 @@
+create table if not exists pr_dependencies (
+    pr_id      text not null references pull_requests(pr_id) on delete cascade,
+    blocked_by text not null references pull_requests(pr_id) on delete cascade,
+    primary key (pr_id, blocked_by),
+    check (pr_id <> blocked_by)
+);
+
+create index if not exists idx_pr_dependencies_blocked_by on pr_dependencies(blocked_by);
@@ -0,0 +1,6 @@
+create table if not exists pr_watchers (
+    pr_id      text not null references pull_requests(pr_id) on delete cascade,
+    user_id    text not null references users(user_id) on delete cascade,
+    created_at timestamptz not null,
+    primary key (pr_id, user_id)
+);
@@ -0,0 +1,10 @@
+create table if not exists pr_comments (
+    id         bigserial primary key,
+    pr_id      text not null references pull_requests(pr_id) on delete cascade,
+    author_id  text not null references users(user_id),
+    text       text not null,
+    reply_to   bigint references pr_comments(id),
+    created_at timestamptz not null
+);
+
+create index if not exists idx_pr_comments_pr on pr_comments(pr_id, created_at, id);
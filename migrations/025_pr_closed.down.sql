@@ -0,0 +1,3 @@
+-- Enum values cannot be dropped; closed PRs go back to OPEN.
+update pull_requests set status = 'OPEN' where status::text = 'CLOSED';
+alter table pull_requests drop column if exists closed_at;
@@ -0,0 +1,2 @@
+alter type pr_status add value if not exists 'CLOSED';
+alter table pull_requests add column if not exists closed_at timestamptz;
@@ -0,0 +1 @@
+drop table if exists github_logins;
@@ -0,0 +1,5 @@
+create table if not exists github_logins (
+    login      text primary key,
+    user_id    text not null unique references users(user_id) on delete cascade,
+    created_at timestamptz not null
+);
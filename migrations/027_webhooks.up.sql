@@ -0,0 +1,7 @@
+create table if not exists webhooks (
+    webhook_id text primary key,
+    url        text not null,
+    events     text[] not null,
+    secret     text not null,
+    created_at timestamptz not null
+);
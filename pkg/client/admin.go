@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// OverloadAlerts lists active overload alerts, of one team when teamName is
+// set, and resolved ones too with includeResolved.
+func (c *Client) OverloadAlerts(ctx context.Context, teamName string, includeResolved bool) ([]domain.OverloadAlert, error) {
+	q := url.Values{}
+	if teamName != "" {
+		q.Set("team_name", teamName)
+	}
+	if includeResolved {
+		q.Set("include_resolved", "true")
+	}
+	var out struct {
+		Alerts []domain.OverloadAlert `json:"alerts"`
+	}
+	return out.Alerts, c.get(ctx, "/alerts/overload", q, &out)
+}
+
+// CheckOverload runs the overload check now and returns the opened alerts.
+func (c *Client) CheckOverload(ctx context.Context) ([]domain.OverloadAlert, error) {
+	var out struct {
+		Opened []domain.OverloadAlert `json:"opened"`
+	}
+	return out.Opened, c.post(ctx, "/alerts/overload/check", nil, &out)
+}
+
+// RunEscalations takes the due escalation steps on stalled PRs now and
+// returns them.
+func (c *Client) RunEscalations(ctx context.Context) ([]domain.Escalation, error) {
+	var out struct {
+		Escalations []domain.Escalation `json:"escalations"`
+	}
+	return out.Escalations, c.post(ctx, "/alerts/escalations/run", nil, &out)
+}
+
+func (c *Client) CreateExport(ctx context.Context, kind string) (*domain.Export, error) {
+	var out struct {
+		Export *domain.Export `json:"export"`
+	}
+	return out.Export, c.post(ctx, "/exports/create", map[string]string{"kind": kind}, &out)
+}
+
+// ExportStatus is an export with its signed download link once it is ready.
+type ExportStatus struct {
+	Export            *domain.Export `json:"export"`
+	DownloadURL       string         `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time     `json:"download_expires_at,omitempty"`
+}
+
+func (c *Client) GetExport(ctx context.Context, exportID string) (*ExportStatus, error) {
+	var out ExportStatus
+	if err := c.get(ctx, "/exports/get", url.Values{"export_id": {exportID}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadExport fetches the CSV behind a signed download URL.
+func (c *Client) DownloadExport(ctx context.Context, downloadURL string) ([]byte, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	return out, c.get(ctx, u.Path, u.Query(), &out)
+}
+
+// Whoami describes the credential the client uses.
+type Whoami struct {
+	Role        string              `json:"role"`
+	UserID      string              `json:"user_id"`
+	TokenID     string              `json:"token_id"`
+	Method      string              `json:"method"`
+	ExpiresAt   *time.Time          `json:"expires_at"`
+	Teams       []string            `json:"teams"`
+	Permissions []domain.Permission `json:"permissions"`
+}
+
+func (c *Client) Whoami(ctx context.Context) (*Whoami, error) {
+	var out Whoami
+	if err := c.get(ctx, "/auth/whoami", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type IssueTokenRequest struct {
+	UserID string   `json:"user_id"`
+	Role   string   `json:"role"`
+	Name   string   `json:"name,omitempty"`
+	Teams  []string `json:"teams,omitempty"`
+	// TTL zero issues a token without expiry.
+	TTL time.Duration `json:"-"`
+}
+
+// IssueToken creates an API token; the returned Token is shown only once.
+func (c *Client) IssueToken(ctx context.Context, req IssueTokenRequest) (*domain.IssuedToken, error) {
+	in := struct {
+		IssueTokenRequest
+		TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+	}{req, int64(req.TTL / time.Second)}
+	var out struct {
+		Token *domain.IssuedToken `json:"token"`
+	}
+	return out.Token, c.post(ctx, "/auth/tokens/issue", in, &out)
+}
+
+func (c *Client) RevokeToken(ctx context.Context, tokenID string) (*domain.APIToken, error) {
+	var out struct {
+		Token *domain.APIToken `json:"token"`
+	}
+	return out.Token, c.post(ctx, "/auth/tokens/revoke", map[string]string{"token_id": tokenID}, &out)
+}
+
+// RotateToken issues a replacement for tokenID; the old token keeps working
+// for grace, or the service default when grace is nil.
+func (c *Client) RotateToken(ctx context.Context, tokenID string, ttl time.Duration, grace *time.Duration) (*domain.IssuedToken, *domain.APIToken, error) {
+	in := map[string]any{"token_id": tokenID}
+	if ttl > 0 {
+		in["ttl_seconds"] = int64(ttl / time.Second)
+	}
+	if grace != nil {
+		in["grace_seconds"] = int64(*grace / time.Second)
+	}
+	var out struct {
+		Token    *domain.IssuedToken `json:"token"`
+		Previous *domain.APIToken    `json:"previous"`
+	}
+	err := c.post(ctx, "/auth/tokens/rotate", in, &out)
+	return out.Token, out.Previous, err
+}
+
+// ListTokens lists API tokens, of one user when userID is set.
+func (c *Client) ListTokens(ctx context.Context, userID string) ([]domain.APIToken, error) {
+	q := url.Values{}
+	if userID != "" {
+		q.Set("user_id", userID)
+	}
+	var out struct {
+		Tokens []domain.APIToken `json:"tokens"`
+	}
+	return out.Tokens, c.get(ctx, "/auth/tokens/list", q, &out)
+}
+
+// TokenUsage lists token usage, only of tokens unused since idleSince when
+// set.
+func (c *Client) TokenUsage(ctx context.Context, idleSince *time.Time) ([]domain.TokenUsage, error) {
+	q := url.Values{}
+	setTime(q, "idle_since", idleSince)
+	var out struct {
+		Usage []domain.TokenUsage `json:"usage"`
+	}
+	return out.Usage, c.get(ctx, "/auth/tokens/usage", q, &out)
+}
+
+// ListRoles returns the roles with their permissions and every permission
+// the service knows.
+func (c *Client) ListRoles(ctx context.Context) ([]domain.RolePermissions, []domain.Permission, error) {
+	var out struct {
+		Roles []domain.RolePermissions `json:"roles"`
+		Known []domain.Permission      `json:"known_permissions"`
+	}
+	err := c.get(ctx, "/auth/roles/list", nil, &out)
+	return out.Roles, out.Known, err
+}
+
+func (c *Client) SetRolePermissions(ctx context.Context, role string, perms []domain.Permission) (*domain.RolePermissions, error) {
+	var out struct {
+		Role *domain.RolePermissions `json:"role"`
+	}
+	in := domain.RolePermissions{Role: role, Permissions: perms}
+	return out.Role, c.post(ctx, "/auth/roles/set", in, &out)
+}
+
+func (c *Client) AuthEvents(ctx context.Context, f domain.AuthEventFilter) ([]domain.AuthEvent, error) {
+	q := url.Values{}
+	setTime(q, "since", f.Since)
+	setTime(q, "until", f.Until)
+	for k, v := range map[string]string{"outcome": f.Outcome, "token_id": f.TokenID, "user_id": f.UserID} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	var out struct {
+		Events []domain.AuthEvent `json:"events"`
+	}
+	return out.Events, c.get(ctx, "/auth/events", q, &out)
+}
+
+// AddWebhook registers url for outgoing webhook events, all of them when
+// events is empty; the returned Secret is shown only once.
+func (c *Client) AddWebhook(ctx context.Context, url string, events []string) (*domain.AddedWebhook, error) {
+	in := struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events,omitempty"`
+	}{url, events}
+	var out struct {
+		Webhook *domain.AddedWebhook `json:"webhook"`
+	}
+	return out.Webhook, c.post(ctx, "/webhooks/add", in, &out)
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	var out struct {
+		Webhooks []domain.Webhook `json:"webhooks"`
+	}
+	return out.Webhooks, c.get(ctx, "/webhooks/list", nil, &out)
+}
+
+func (c *Client) RemoveWebhook(ctx context.Context, webhookID string) error {
+	return c.post(ctx, "/webhooks/remove", map[string]string{"webhook_id": webhookID}, nil)
+}
@@ -0,0 +1,300 @@
+// Package client is the Go client of the PR reviewer assignment service.
+// Request and response types are those of the service itself, so the client
+// stays in sync with the API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// TokenSource returns the bearer token for a request; it is called before
+// every attempt, so short-lived tokens can be refreshed.
+type TokenSource func(ctx context.Context) (string, error)
+
+type Client struct {
+	baseURL    string
+	http       *http.Client
+	token      TokenSource
+	maxRetries int
+	backoff    time.Duration
+	userAgent  string
+	language   string
+}
+
+type Option func(*Client)
+
+// WithToken authenticates requests with a static bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) { c.token = ts }
+}
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries sets how many times a failed request is retried and the first
+// backoff, which doubles on every retry up to maxBackoff. Zero retries
+// disables retrying.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.maxRetries, c.backoff = n, backoff }
+}
+
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithLanguage asks for error messages in lang (en or ru) via
+// Accept-Language; error codes do not change.
+func WithLanguage(lang string) Option {
+	return func(c *Client) { c.language = lang }
+}
+
+const maxBackoff = 5 * time.Second
+
+// apiPrefix is the API version the client speaks; paths the server hands out
+// (download links) already carry it.
+const apiPrefix = "/api/v1"
+
+// New returns a client of the service at baseURL, e.g. http://prsrv:8080.
+// By default it retries twice starting at 200ms.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		http:       &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 2,
+		backoff:    200 * time.Millisecond,
+		userAgent:  "prsrv-client",
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Error is an error response of the service. Code is the service error code.
+// Without AUTH_STRICT_STATUS the server answers failed authentication and
+// authorization with 401 NOT_FOUND, so ErrUnauthorized matches on the status
+// only.
+type Error struct {
+	StatusCode int
+	Code       domain.ErrorCode
+	Message    string
+	// Detail is the English message when Message is translated, see
+	// WithLanguage.
+	Detail string
+	// Fields lists the rejected fields of an INVALID_ARGUMENT response.
+	Fields []FieldError
+	// RetryAfter is the server's Retry-After hint, if any.
+	RetryAfter time.Duration
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("prsrv: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is matches the sentinel errors: every non-zero field of target must equal
+// that of e.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return (t.StatusCode == 0 || t.StatusCode == e.StatusCode) && (t.Code == "" || t.Code == e.Code)
+}
+
+// Sentinels for errors.Is.
+var (
+	ErrBadRequest   = &Error{StatusCode: http.StatusBadRequest}
+	ErrUnauthorized = &Error{StatusCode: http.StatusUnauthorized}
+	ErrForbidden    = &Error{StatusCode: http.StatusForbidden}
+	ErrNotFound     = &Error{StatusCode: http.StatusNotFound}
+	ErrRateLimited  = &Error{StatusCode: http.StatusTooManyRequests}
+	// ErrTimeout: the server gave up on the request; it may still have
+	// been applied.
+	ErrTimeout = &Error{Code: domain.ErrTimeout}
+	// ErrUnavailable: the service could not check the credentials, e.g.
+	// while its database is down; retry later.
+	ErrUnavailable = &Error{Code: domain.ErrUnavailable}
+
+	ErrTeamExists = &Error{Code: domain.ErrTeamExists}
+	ErrPRExists   = &Error{Code: domain.ErrPRExists}
+	ErrPRMerged   = &Error{Code: domain.ErrPRMerged}
+	// ErrPRClosed: the PR is closed; reopen it first.
+	ErrPRClosed    = &Error{Code: domain.ErrPRClosed}
+	ErrNotAssigned = &Error{Code: domain.ErrNotAssigned}
+	ErrNoCandidate = &Error{Code: domain.ErrNoCandidate}
+	// ErrPreconditionFailed: the PR changed since the ETag passed to an
+	// IfMatch method was read.
+	ErrPreconditionFailed = &Error{Code: domain.ErrPreconditionFailed}
+	// ErrMergeBlocked: the PR breaks a merge rule of its team; the message
+	// starts with the rule's name.
+	ErrMergeBlocked = &Error{Code: domain.ErrMergeBlocked}
+	// ErrNotApproved: the PR has fewer approvals than its team's
+	// min_approvals merge rule requires.
+	ErrNotApproved = &Error{Code: domain.ErrNotApproved}
+	ErrInvalid     = &Error{Code: domain.ErrInvalid}
+)
+
+// get and post decode the JSON response into out unless it is nil.
+func (c *Client) get(ctx context.Context, path string, q url.Values, out any) error {
+	return c.do(ctx, http.MethodGet, path, q, nil, nil, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out any) error {
+	return c.do(ctx, http.MethodPost, path, nil, nil, in, out)
+}
+
+// postIfMatch is post with an If-Match header, unless etag is empty.
+func (c *Client) postIfMatch(ctx context.Context, path, etag string, in, out any) error {
+	var hdr http.Header
+	if etag != "" {
+		hdr = http.Header{"If-Match": {etag}}
+	}
+	return c.do(ctx, http.MethodPost, path, nil, hdr, in, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, hdr http.Header, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	if !strings.HasPrefix(path, "/api/") {
+		path = apiPrefix + path
+	}
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		tok := ""
+		if c.token != nil {
+			var err error
+			if tok, err = c.token(ctx); err != nil {
+				return fmt.Errorf("prsrv: token: %w", err)
+			}
+		}
+		resp, err := c.send(ctx, method, u, tok, hdr, body)
+		if err == nil {
+			err = decode(resp, out)
+		}
+		if attempt >= c.maxRetries || !retryable(method, err) {
+			return err
+		}
+		wait := backoff
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		backoff = min(2*backoff, maxBackoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u, token string, hdr http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.language != "" {
+		req.Header.Set("Accept-Language", c.language)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.http.Do(req)
+}
+
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var payload struct {
+			Error struct {
+				Code    domain.ErrorCode `json:"code"`
+				Message string           `json:"message"`
+				Detail  string           `json:"detail"`
+				Fields  []FieldError     `json:"fields"`
+			} `json:"error"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(raw, &payload) == nil && payload.Error.Code != "" {
+			apiErr.Code, apiErr.Message, apiErr.Fields = payload.Error.Code, payload.Error.Message, payload.Error.Fields
+			apiErr.Detail = payload.Error.Detail
+		} else {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(s) * time.Second
+		}
+		return apiErr
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if b, ok := out.(*[]byte); ok {
+		var err error
+		*b, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// retryable retries rate-limited requests, which the service rejects before
+// doing anything, and idempotent requests that hit a transport error or an
+// unavailable server.
+func retryable(method string, err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return method == http.MethodGet
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+
+	domain "prsrv/internal/domain"
+)
+
+// Page selects a page of a list endpoint; zero fields use the server's
+// defaults. Cursor is the NextCursor of the previous page.
+type Page struct {
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+func (p Page) values(q url.Values) url.Values {
+	if q == nil {
+		q = url.Values{}
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// PageInfo is the page of a list response; NextCursor is empty on the last
+// one.
+type PageInfo = domain.PageInfo
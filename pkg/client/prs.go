@@ -0,0 +1,569 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+func (c *Client) Health(ctx context.Context) error {
+	return c.get(ctx, "/health", nil, nil)
+}
+
+func (c *Client) AddTeam(ctx context.Context, team domain.Team) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	return out.Team, c.post(ctx, "/team/add", team, &out)
+}
+
+// UpsertTeam creates the team or merges the members into the existing one;
+// members not listed stay in it.
+func (c *Client) UpsertTeam(ctx context.Context, team domain.Team) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	return out.Team, c.post(ctx, "/team/upsert", team, &out)
+}
+
+// GetTeam returns the team with all its members, following the member pages.
+func (c *Client) GetTeam(ctx context.Context, teamName string) (*domain.Team, error) {
+	var team *domain.Team
+	var p Page
+	for {
+		page, info, err := c.GetTeamPage(ctx, teamName, p)
+		if err != nil {
+			return nil, err
+		}
+		if team == nil {
+			team = page
+		} else {
+			team.Members = append(team.Members, page.Members...)
+		}
+		if info.NextCursor == "" {
+			return team, nil
+		}
+		p.Cursor = info.NextCursor
+	}
+}
+
+// GetTeamPage returns the team with one page of its members.
+func (c *Client) GetTeamPage(ctx context.Context, teamName string, p Page) (*domain.Team, PageInfo, error) {
+	var out struct {
+		domain.Team
+		Page PageInfo `json:"page"`
+	}
+	if err := c.get(ctx, "/team/get", p.values(url.Values{"team_name": {teamName}}), &out); err != nil {
+		return nil, PageInfo{}, err
+	}
+	return &out.Team, out.Page, nil
+}
+
+// SetTeamLead makes userID the team lead; an empty userID clears it.
+func (c *Client) SetTeamLead(ctx context.Context, teamName, userID string) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	in := map[string]string{"team_name": teamName, "user_id": userID}
+	return out.Team, c.post(ctx, "/team/setLead", in, &out)
+}
+
+// SetTeamManualAssignment turns automatic reviewer selection of the team
+// off (manual) or back on.
+func (c *Client) SetTeamManualAssignment(ctx context.Context, teamName string, manual bool) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	in := map[string]any{"team_name": teamName, "manual_assignment": manual}
+	return out.Team, c.post(ctx, "/team/setManualAssignment", in, &out)
+}
+
+// SetTeamMergeRules replaces the preconditions for merging PRs of the team;
+// zero rules turn them off. MergePR fails with ErrMergeBlocked for PRs that
+// break them.
+func (c *Client) SetTeamMergeRules(ctx context.Context, teamName string, rules domain.MergeRules) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	in := struct {
+		TeamName string `json:"team_name"`
+		domain.MergeRules
+	}{teamName, rules}
+	return out.Team, c.post(ctx, "/team/setMergeRules", in, &out)
+}
+
+// SetTeamEscalation replaces the escalation policy of the team; a zero
+// policy turns escalation off.
+func (c *Client) SetTeamEscalation(ctx context.Context, teamName string, p domain.EscalationPolicy) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	in := struct {
+		TeamName string `json:"team_name"`
+		domain.EscalationPolicy
+	}{teamName, p}
+	return out.Team, c.post(ctx, "/team/setEscalation", in, &out)
+}
+
+// SetTeamWorkingHours sets the working hours of the team; zero hours turn
+// queuing of assignments outside them off.
+func (c *Client) SetTeamWorkingHours(ctx context.Context, teamName string, w domain.WorkingHours) (*domain.Team, error) {
+	var out struct {
+		Team *domain.Team `json:"team"`
+	}
+	in := struct {
+		TeamName string `json:"team_name"`
+		domain.WorkingHours
+	}{teamName, w}
+	return out.Team, c.post(ctx, "/team/setWorkingHours", in, &out)
+}
+
+// SetTeamHolidays replaces the holiday calendar of the team and returns it
+// by date; an empty list clears it.
+func (c *Client) SetTeamHolidays(ctx context.Context, teamName string, holidays []domain.Holiday) ([]domain.Holiday, error) {
+	var out struct {
+		Holidays []domain.Holiday `json:"holidays"`
+	}
+	in := struct {
+		TeamName string           `json:"team_name"`
+		Holidays []domain.Holiday `json:"holidays"`
+	}{teamName, holidays}
+	return out.Holidays, c.post(ctx, "/team/setHolidays", in, &out)
+}
+
+// TeamHolidays returns the holiday calendar of the team by date.
+func (c *Client) TeamHolidays(ctx context.Context, teamName string) ([]domain.Holiday, error) {
+	var out struct {
+		Holidays []domain.Holiday `json:"holidays"`
+	}
+	return out.Holidays, c.get(ctx, "/team/holidays", url.Values{"team_name": {teamName}}, &out)
+}
+
+func (c *Client) SetUserActive(ctx context.Context, userID string, active bool) (*domain.User, error) {
+	var out struct {
+		User *domain.User `json:"user"`
+	}
+	in := map[string]any{"user_id": userID, "is_active": active}
+	return out.User, c.post(ctx, "/users/setIsActive", in, &out)
+}
+
+// UpdateUser changes the non-nil fields of upd on the user.
+func (c *Client) UpdateUser(ctx context.Context, userID string, upd domain.UserUpdate) (*domain.User, error) {
+	var out struct {
+		User *domain.User `json:"user"`
+	}
+	in := struct {
+		UserID string `json:"user_id"`
+		domain.UserUpdate
+	}{userID, upd}
+	return out.User, c.post(ctx, "/users/update", in, &out)
+}
+
+// UserReviews lists all PRs userID is assigned to review; an empty userID
+// means the user the token belongs to.
+func (c *Client) UserReviews(ctx context.Context, userID string) ([]domain.PullRequestShort, error) {
+	var all []domain.PullRequestShort
+	var p Page
+	for {
+		prs, info, err := c.UserReviewsPage(ctx, userID, p)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, prs...)
+		if info.NextCursor == "" {
+			return all, nil
+		}
+		p.Cursor = info.NextCursor
+	}
+}
+
+// UserReviewsPage returns one page of UserReviews.
+func (c *Client) UserReviewsPage(ctx context.Context, userID string, p Page) ([]domain.PullRequestShort, PageInfo, error) {
+	q := url.Values{}
+	if userID != "" {
+		q.Set("user_id", userID)
+	}
+	var out struct {
+		PRs  []domain.PullRequestShort `json:"pull_requests"`
+		Page PageInfo                  `json:"page"`
+	}
+	err := c.get(ctx, "/users/getReview", p.values(q), &out)
+	return out.PRs, out.Page, err
+}
+
+// OpenReviews returns the open PRs each of the users reviews, in one
+// request; users without any map to an empty list.
+func (c *Client) OpenReviews(ctx context.Context, userIDs []string) (map[string][]domain.PullRequestShort, error) {
+	var out struct {
+		Reviews map[string][]domain.PullRequestShort `json:"reviews"`
+	}
+	return out.Reviews, c.get(ctx, "/users/getReviewBatch", url.Values{"user_ids": {strings.Join(userIDs, ",")}}, &out)
+}
+
+// AssignmentPoll is a response of PollAssignments.
+type AssignmentPoll struct {
+	UserID  string                    `json:"user_id"`
+	Changed bool                      `json:"changed"`
+	Cursor  string                    `json:"cursor"`
+	PRs     []domain.PullRequestShort `json:"pull_requests"`
+}
+
+// PollAssignments waits up to wait until the open PRs the user reviews
+// differ from those of cursor, and returns them with the new cursor. An
+// empty cursor returns at once. Keep wait below the HTTP client's timeout,
+// 30s by default.
+func (c *Client) PollAssignments(ctx context.Context, userID, cursor string, wait time.Duration) (*AssignmentPoll, error) {
+	q := url.Values{"timeout": {strconv.Itoa(int(wait / time.Second))}}
+	if userID != "" {
+		q.Set("user_id", userID)
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	var out AssignmentPoll
+	if err := c.get(ctx, "/users/pollAssignments", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) BulkDeactivate(ctx context.Context, teamName string, userIDs []string) (*domain.BulkDeactivateResult, error) {
+	return c.bulkDeactivate(ctx, teamName, userIDs, false)
+}
+
+// PlanBulkDeactivate returns what BulkDeactivate would do without changing
+// anything.
+func (c *Client) PlanBulkDeactivate(ctx context.Context, teamName string, userIDs []string) (*domain.BulkDeactivateResult, error) {
+	return c.bulkDeactivate(ctx, teamName, userIDs, true)
+}
+
+func (c *Client) bulkDeactivate(ctx context.Context, teamName string, userIDs []string, dryRun bool) (*domain.BulkDeactivateResult, error) {
+	var out domain.BulkDeactivateResult
+	in := map[string]any{"team_name": teamName, "user_ids": userIDs, "dry_run": dryRun}
+	if err := c.post(ctx, "/users/bulkDeactivate", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AnonymizeUser erases the user's personal data and returns the ids of the
+// revoked tokens.
+func (c *Client) AnonymizeUser(ctx context.Context, userID string) (*domain.User, []string, error) {
+	var out struct {
+		User    *domain.User `json:"user"`
+		Revoked []string     `json:"revoked_tokens"`
+	}
+	err := c.post(ctx, "/users/anonymize", map[string]string{"user_id": userID}, &out)
+	return out.User, out.Revoked, err
+}
+
+// SetGitHubLogin maps a GitHub login to userID for the GitHub webhook; an
+// empty login removes the mapping. A login mapped to another user fails
+// with ErrInvalid.
+func (c *Client) SetGitHubLogin(ctx context.Context, userID, login string) (*domain.GitHubLogin, error) {
+	var out domain.GitHubLogin
+	if err := c.post(ctx, "/integrations/github/setLogin", domain.GitHubLogin{UserID: userID, Login: login}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePRRequest describes a new PR; leave ID empty to have the server
+// generate one and read it from the returned PR.
+type CreatePRRequest struct {
+	ID         string `json:"pull_request_id,omitempty"`
+	Name       string `json:"pull_request_name"`
+	AuthorID   string `json:"author_id"`
+	Repository string `json:"repository,omitempty"`
+	// PreferredReviewers are assigned before automatic picks when they are
+	// active members of the author's team.
+	PreferredReviewers []string `json:"preferred_reviewers,omitempty"`
+}
+
+func (c *Client) CreatePR(ctx context.Context, req CreatePRRequest) (*domain.PullRequest, error) {
+	pr, _, err := c.CreatePRPreferring(ctx, req)
+	return pr, err
+}
+
+// CreatePRPreferring is CreatePR that also returns the preferred reviewers
+// the server rejected and why.
+func (c *Client) CreatePRPreferring(ctx context.Context, req CreatePRRequest) (*domain.PullRequest, []domain.RejectedReviewer, error) {
+	res, err := c.CreatePRDetailed(ctx, req)
+	return res.PR, res.Rejected, err
+}
+
+// CreatePRResult is the full answer to a PR create.
+type CreatePRResult struct {
+	PR       *domain.PullRequest       `json:"pr"`
+	Rejected []domain.RejectedReviewer `json:"rejected_reviewers"`
+	// CapacityWarning is set when the author's team is short of reviewers.
+	CapacityWarning *domain.CapacityWarning `json:"capacity_warning"`
+}
+
+// CreatePRDetailed is CreatePR that returns everything the server said
+// about the new PR.
+func (c *Client) CreatePRDetailed(ctx context.Context, req CreatePRRequest) (CreatePRResult, error) {
+	var out CreatePRResult
+	err := c.post(ctx, "/pullRequest/create", req, &out)
+	return out, err
+}
+
+// GetPR returns the PR with its reviewers. pr.ETag() is the tag to pass to
+// MergePRIfMatch and ReassignIfMatch.
+func (c *Client) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	return out.PR, c.get(ctx, "/pullRequest/get", url.Values{"pull_request_id": {prID}}, &out)
+}
+
+// MergePR merges the PR; it fails with ErrMergeBlocked when the PR breaks a
+// merge rule of its author's team.
+func (c *Client) MergePR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	return c.MergePRIfMatch(ctx, prID, "")
+}
+
+// MergePRIfMatch merges the PR only if it still has etag, and fails with
+// ErrPreconditionFailed otherwise. An empty etag merges unconditionally.
+func (c *Client) MergePRIfMatch(ctx context.Context, prID, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/merge", etag, map[string]string{"pull_request_id": prID}, &out)
+}
+
+// ClosePR closes the open PR without merging it; a non-empty etag guards it
+// like MergePRIfMatch. Closing a merged PR fails with ErrPRMerged.
+func (c *Client) ClosePR(ctx context.Context, prID, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/close", etag, map[string]string{"pull_request_id": prID}, &out)
+}
+
+// ReopenPR opens the closed PR again, replacing or removing reviewers
+// deactivated meanwhile; a non-empty etag guards it like MergePRIfMatch.
+func (c *Client) ReopenPR(ctx context.Context, prID, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/reopen", etag, map[string]string{"pull_request_id": prID}, &out)
+}
+
+// Reassign replaces oldUserID on the PR and returns the PR and the new
+// reviewer.
+func (c *Client) Reassign(ctx context.Context, prID, oldUserID string) (*domain.PullRequest, string, error) {
+	return c.ReassignIfMatch(ctx, prID, oldUserID, "")
+}
+
+// ReassignIfMatch is Reassign guarded by etag like MergePRIfMatch.
+func (c *Client) ReassignIfMatch(ctx context.Context, prID, oldUserID, etag string) (*domain.PullRequest, string, error) {
+	var out struct {
+		PR         *domain.PullRequest `json:"pr"`
+		ReplacedBy string              `json:"replaced_by"`
+	}
+	in := map[string]string{"pull_request_id": prID, "old_user_id": oldUserID}
+	err := c.postIfMatch(ctx, "/pullRequest/reassign", etag, in, &out)
+	return out.PR, out.ReplacedBy, err
+}
+
+// AddReviewer attaches userID to the PR as a reviewer; a non-empty etag
+// guards it like MergePRIfMatch.
+func (c *Client) AddReviewer(ctx context.Context, prID, userID, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "user_id": userID}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/addReviewer", etag, in, &out)
+}
+
+// TransferAuthor makes authorID the author of the open PR; a non-empty etag
+// guards it like MergePRIfMatch.
+func (c *Client) TransferAuthor(ctx context.Context, prID, authorID, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "author_id": authorID}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/transferAuthor", etag, in, &out)
+}
+
+// AddPRDependency marks prID as blocked by blockedBy until that PR is
+// merged; a non-empty etag guards it like MergePRIfMatch.
+func (c *Client) AddPRDependency(ctx context.Context, prID, blockedBy, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "blocked_by": blockedBy}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/addDependency", etag, in, &out)
+}
+
+// RemovePRDependency drops the blocked_by link from prID to blockedBy.
+func (c *Client) RemovePRDependency(ctx context.Context, prID, blockedBy, etag string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "blocked_by": blockedBy}
+	return out.PR, c.postIfMatch(ctx, "/pullRequest/removeDependency", etag, in, &out)
+}
+
+// PRFilter selects PRs for ListPRs; zero fields match everything.
+type PRFilter struct {
+	TeamName string // the author's team
+	AuthorID string
+	Status   domain.PRStatus
+}
+
+// ListPRs returns one page of the PRs matching f.
+func (c *Client) ListPRs(ctx context.Context, f PRFilter, p Page) ([]domain.PullRequestShort, PageInfo, error) {
+	q := url.Values{}
+	if f.TeamName != "" {
+		q.Set("team_name", f.TeamName)
+	}
+	if f.AuthorID != "" {
+		q.Set("author_id", f.AuthorID)
+	}
+	if f.Status != "" {
+		q.Set("status", string(f.Status))
+	}
+	var out struct {
+		PRs  []domain.PullRequestShort `json:"pull_requests"`
+		Page PageInfo                  `json:"page"`
+	}
+	err := c.get(ctx, "/pullRequest/list", p.values(q), &out)
+	return out.PRs, out.Page, err
+}
+
+// Search finds teams, users and PRs whose id or name contains text, best
+// matches first. Empty types searches all of them; limit 0 uses the server's
+// default.
+func (c *Client) Search(ctx context.Context, text string, types []string, limit int) ([]domain.SearchResult, error) {
+	q := url.Values{"q": {text}}
+	if len(types) > 0 {
+		q.Set("types", strings.Join(types, ","))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out struct {
+		Results []domain.SearchResult `json:"results"`
+	}
+	return out.Results, c.get(ctx, "/search", q, &out)
+}
+
+// ListTeamPRs returns one page of the PRs authored by the team's members;
+// with includeReviewing also the PRs they review. An empty status matches
+// both.
+func (c *Client) ListTeamPRs(ctx context.Context, team string, includeReviewing bool, status domain.PRStatus, p Page) ([]domain.PullRequestShort, PageInfo, error) {
+	q := url.Values{"team_name": {team}}
+	if includeReviewing {
+		q.Set("include_reviewing", "true")
+	}
+	if status != "" {
+		q.Set("status", string(status))
+	}
+	var out struct {
+		PRs  []domain.PullRequestShort `json:"pull_requests"`
+		Page PageInfo                  `json:"page"`
+	}
+	err := c.get(ctx, "/pullRequest/listByTeam", p.values(q), &out)
+	return out.PRs, out.Page, err
+}
+
+func (c *Client) PRTimeline(ctx context.Context, prID string) (*domain.PRTimeline, error) {
+	var out domain.PRTimeline
+	if err := c.get(ctx, "/pullRequest/timeline", url.Values{"pull_request_id": {prID}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddComment leaves a comment on the PR by authorID (the token's user when
+// empty); replyTo, when not nil, is the comment it answers.
+func (c *Client) AddComment(ctx context.Context, prID, authorID, text string, replyTo *int64) (*domain.Comment, error) {
+	body := map[string]any{"pull_request_id": prID, "text": text}
+	if authorID != "" {
+		body["author_id"] = authorID
+	}
+	if replyTo != nil {
+		body["reply_to"] = *replyTo
+	}
+	var out struct {
+		Comment *domain.Comment `json:"comment"`
+	}
+	if err := c.post(ctx, "/pullRequest/comment", body, &out); err != nil {
+		return nil, err
+	}
+	return out.Comment, nil
+}
+
+// Comments returns one page of the comments on the PR.
+func (c *Client) Comments(ctx context.Context, prID string, p Page) ([]domain.Comment, PageInfo, error) {
+	var out struct {
+		Comments []domain.Comment `json:"comments"`
+		Page     PageInfo         `json:"page"`
+	}
+	err := c.get(ctx, "/pullRequest/comments", p.values(url.Values{"pull_request_id": {prID}}), &out)
+	return out.Comments, out.Page, err
+}
+
+// PRActivity returns one page of the PR's activity feed.
+func (c *Client) PRActivity(ctx context.Context, prID string, p Page) (*domain.PRActivity, error) {
+	var out domain.PRActivity
+	if err := c.get(ctx, "/pullRequest/activity", p.values(url.Values{"pull_request_id": {prID}}), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AcknowledgeReview marks that userID (the token's user when empty) started
+// reviewing the PR.
+func (c *Client) AcknowledgeReview(ctx context.Context, prID, userID string) (time.Time, error) {
+	var out struct {
+		At time.Time `json:"acknowledged_at"`
+	}
+	in := map[string]string{"pull_request_id": prID, "user_id": userID}
+	return out.At, c.post(ctx, "/pullRequest/acknowledge", in, &out)
+}
+
+// ApproveReview approves the PR as userID (the token's user when empty),
+// one of its reviewers.
+func (c *Client) ApproveReview(ctx context.Context, prID, userID string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "user_id": userID}
+	return out.PR, c.post(ctx, "/pullRequest/approve", in, &out)
+}
+
+// RequestChanges requests changes on the PR as userID (the token's user
+// when empty), withdrawing their approval.
+func (c *Client) RequestChanges(ctx context.Context, prID, userID string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "user_id": userID}
+	return out.PR, c.post(ctx, "/pullRequest/requestChanges", in, &out)
+}
+
+// WatchPR subscribes userID (the token's user when empty) to the reviewer
+// changes and merge of a PR they do not review.
+func (c *Client) WatchPR(ctx context.Context, prID, userID string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "user_id": userID}
+	return out.PR, c.post(ctx, "/pullRequest/watch", in, &out)
+}
+
+// UnwatchPR drops the subscription of userID (the token's user when empty).
+func (c *Client) UnwatchPR(ctx context.Context, prID, userID string) (*domain.PullRequest, error) {
+	var out struct {
+		PR *domain.PullRequest `json:"pr"`
+	}
+	in := map[string]string{"pull_request_id": prID, "user_id": userID}
+	return out.PR, c.post(ctx, "/pullRequest/unwatch", in, &out)
+}
@@ -0,0 +1,297 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// DateRange selects UTC days From..To inclusive; zero values take the
+// service default (the last 30 days).
+type DateRange struct {
+	From, To time.Time
+}
+
+func (r DateRange) values() url.Values {
+	q := url.Values{}
+	if !r.From.IsZero() {
+		q.Set("from", r.From.Format(time.DateOnly))
+	}
+	if !r.To.IsZero() {
+		q.Set("to", r.To.Format(time.DateOnly))
+	}
+	return q
+}
+
+func setTime(q url.Values, name string, t *time.Time) {
+	if t != nil {
+		q.Set(name, t.Format(time.RFC3339))
+	}
+}
+
+func setDuration(q url.Values, name string, d time.Duration) {
+	if d > 0 {
+		q.Set(name, d.String())
+	}
+}
+
+type AssignmentStatsParams struct {
+	GroupBy  string // user, pr, team or repository; empty for user and pr together
+	TeamName string
+	UserIDs  []string
+	Sort     string // domain.SortByCount or domain.SortByID
+	Limit    int
+	// Cursor is Page.NextCursor of the previous result; it replaces Offset.
+	Cursor string
+	Offset int
+}
+
+func (c *Client) AssignmentStats(ctx context.Context, p AssignmentStatsParams) (*domain.AssignmentStats, error) {
+	q := url.Values{}
+	for k, v := range map[string]string{"group_by": p.GroupBy, "team_name": p.TeamName, "sort": p.Sort, "cursor": p.Cursor} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if len(p.UserIDs) > 0 {
+		q.Set("user_ids", strings.Join(p.UserIDs, ","))
+	}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Offset > 0 {
+		q.Set("offset", strconv.Itoa(p.Offset))
+	}
+	var out domain.AssignmentStats
+	if err := c.get(ctx, "/stats/assignments", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) AssignmentTimeseries(ctx context.Context, r DateRange) ([]domain.DayCount, error) {
+	var out struct {
+		Days []domain.DayCount `json:"days"`
+	}
+	return out.Days, c.get(ctx, "/stats/assignments/timeseries", r.values(), &out)
+}
+
+func (c *Client) PRBurndown(ctx context.Context, r DateRange) ([]domain.BurndownDay, error) {
+	var out struct {
+		Days []domain.BurndownDay `json:"days"`
+	}
+	return out.Days, c.get(ctx, "/stats/prBurndown", r.values(), &out)
+}
+
+func (c *Client) TimeToFirstApproval(ctx context.Context) (*domain.ApprovalStats, error) {
+	var out domain.ApprovalStats
+	if err := c.get(ctx, "/stats/timeToFirstApproval", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type MergeTimeParams struct {
+	Since, Until *time.Time
+	ByRepository bool
+}
+
+func (c *Client) MergeTime(ctx context.Context, p MergeTimeParams) (*domain.MergeTimeStats, error) {
+	q := url.Values{}
+	setTime(q, "since", p.Since)
+	setTime(q, "until", p.Until)
+	if p.ByRepository {
+		q.Set("group_by", "repository")
+	}
+	var out domain.MergeTimeStats
+	if err := c.get(ctx, "/stats/mergeTime", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SLABreachParams override the service's default SLAs when non-zero.
+type SLABreachParams struct {
+	Since, Until *time.Time
+	ReviewSLA    time.Duration
+	MergeSLA     time.Duration
+	Worst        int
+}
+
+func (c *Client) SLABreaches(ctx context.Context, p SLABreachParams) (*domain.SLAReport, error) {
+	q := url.Values{}
+	setTime(q, "since", p.Since)
+	setTime(q, "until", p.Until)
+	setDuration(q, "review_sla", p.ReviewSLA)
+	setDuration(q, "merge_sla", p.MergeSLA)
+	if p.Worst > 0 {
+		q.Set("worst", strconv.Itoa(p.Worst))
+	}
+	var out domain.SLAReport
+	if err := c.get(ctx, "/stats/slaBreaches", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) PRStatus(ctx context.Context, weekly bool) (*domain.PRStatusStats, error) {
+	q := url.Values{}
+	if weekly {
+		q.Set("bucket", "week")
+	}
+	var out domain.PRStatusStats
+	if err := c.get(ctx, "/stats/prStatus", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) PRAge(ctx context.Context) (*domain.PRAgeHistogram, error) {
+	var out domain.PRAgeHistogram
+	if err := c.get(ctx, "/stats/prAge", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthorMergeRates uses the service's merge SLA when mergeSLA is zero.
+func (c *Client) AuthorMergeRates(ctx context.Context, r DateRange, mergeSLA time.Duration) (*domain.AuthorMergeReport, error) {
+	q := r.values()
+	setDuration(q, "merge_sla", mergeSLA)
+	var out domain.AuthorMergeReport
+	if err := c.get(ctx, "/stats/authors", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) NoCandidateStats(ctx context.Context, r DateRange) (*domain.NoCandidateReport, error) {
+	var out domain.NoCandidateReport
+	if err := c.get(ctx, "/stats/noCandidate", r.values(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ReassignmentChurn(ctx context.Context, r DateRange) (*domain.ChurnReport, error) {
+	var out domain.ChurnReport
+	if err := c.get(ctx, "/stats/reassignments", r.values(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) PairingStats(ctx context.Context, r DateRange) (*domain.PairingReport, error) {
+	var out domain.PairingReport
+	if err := c.get(ctx, "/stats/pairings", r.values(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Simulate replays the PRs created in r against a reviewer selection
+// strategy, e.g. domain.StrategyLeastLoaded; it needs an admin token.
+func (c *Client) Simulate(ctx context.Context, strategy string, r DateRange) (*domain.SimulationReport, error) {
+	q := r.values()
+	q.Set("strategy", strategy)
+	var out domain.SimulationReport
+	if err := c.get(ctx, "/stats/simulate", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) TeamComparison(ctx context.Context) ([]domain.TeamComparison, error) {
+	var out struct {
+		Teams []domain.TeamComparison `json:"teams"`
+	}
+	return out.Teams, c.get(ctx, "/stats/teams", nil, &out)
+}
+
+// Rebalancing returns the suggested team changes that would even out
+// review load; it changes nothing.
+func (c *Client) Rebalancing(ctx context.Context) (*domain.RebalanceReport, error) {
+	var out domain.RebalanceReport
+	if err := c.get(ctx, "/stats/rebalancing", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// IdleReviewers uses the service's default window when days is zero.
+func (c *Client) IdleReviewers(ctx context.Context, days int) (*domain.IdleReviewersReport, error) {
+	q := url.Values{}
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+	var out domain.IdleReviewersReport
+	if err := c.get(ctx, "/stats/idleReviewers", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewerResponsiveness uses the service's response SLA when sla is zero.
+func (c *Client) ReviewerResponsiveness(ctx context.Context, sla time.Duration) (*domain.ResponsivenessReport, error) {
+	q := url.Values{}
+	setDuration(q, "response_sla", sla)
+	var out domain.ResponsivenessReport
+	if err := c.get(ctx, "/stats/reviewerResponsiveness", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StatsSnapshot returns the workload snapshot of a day (today when zero),
+// limited to teamName when set.
+func (c *Client) StatsSnapshot(ctx context.Context, day time.Time, teamName string) (*domain.StatsSnapshot, error) {
+	q := url.Values{}
+	if !day.IsZero() {
+		q.Set("date", day.Format(time.DateOnly))
+	}
+	if teamName != "" {
+		q.Set("team_name", teamName)
+	}
+	var out domain.StatsSnapshot
+	if err := c.get(ctx, "/stats/snapshot", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) TakeStatsSnapshot(ctx context.Context) (time.Time, error) {
+	var out struct {
+		TakenAt time.Time `json:"taken_at"`
+	}
+	return out.TakenAt, c.post(ctx, "/stats/snapshot/take", nil, &out)
+}
+
+func (c *Client) QueryReport(ctx context.Context, q domain.ReportQuery) (*domain.ReportResult, error) {
+	var out domain.ReportResult
+	if err := c.post(ctx, "/stats/query", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) RefreshStats(ctx context.Context) (time.Time, error) {
+	var out struct {
+		RefreshedAt time.Time `json:"refreshed_at"`
+	}
+	return out.RefreshedAt, c.post(ctx, "/stats/refresh", nil, &out)
+}
+
+// StatsXLSX downloads a /stats/* report (e.g. "/stats/teams") as an XLSX
+// workbook; q holds the report's own parameters.
+func (c *Client) StatsXLSX(ctx context.Context, path string, q url.Values) ([]byte, error) {
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("format", "xlsx")
+	var out []byte
+	return out, c.get(ctx, path, q, &out)
+}
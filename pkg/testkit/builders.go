@@ -0,0 +1,73 @@
+package testkit
+
+import (
+	"time"
+
+	domain "prsrv/internal/domain"
+)
+
+// TeamBuilder describes a team for Server.AddTeam.
+type TeamBuilder struct {
+	team domain.Team
+}
+
+func NewTeam(name string) *TeamBuilder {
+	return &TeamBuilder{team: domain.Team{TeamName: name, Members: []domain.TeamMember{}}}
+}
+
+// Member adds an active member.
+func (b *TeamBuilder) Member(userID, username string) *TeamBuilder {
+	b.team.Members = append(b.team.Members, domain.TeamMember{UserID: userID, Username: username, IsActive: true})
+	return b
+}
+
+// Inactive adds a member who is never picked as a reviewer.
+func (b *TeamBuilder) Inactive(userID, username string) *TeamBuilder {
+	b.team.Members = append(b.team.Members, domain.TeamMember{UserID: userID, Username: username})
+	return b
+}
+
+// Lead makes a member the team lead.
+func (b *TeamBuilder) Lead(userID string) *TeamBuilder {
+	b.team.LeadUserID = userID
+	return b
+}
+
+// ManualAssignment turns off automatic reviewer selection for the team.
+func (b *TeamBuilder) ManualAssignment() *TeamBuilder {
+	b.team.ManualAssignment = true
+	return b
+}
+
+// Build returns the team as /team/add takes it.
+func (b *TeamBuilder) Build() domain.Team { return b.team }
+
+// PRBuilder describes a PR for Server.CreatePR.
+type PRBuilder struct {
+	id, name, authorID, repository string
+	mergeAfter                     time.Duration
+}
+
+// NewPR describes an open PR; an empty id lets the service generate one.
+func NewPR(id, authorID string) *PRBuilder {
+	return &PRBuilder{id: id, name: "Test PR", authorID: authorID, mergeAfter: -1}
+}
+
+func (b *PRBuilder) Name(name string) *PRBuilder {
+	b.name = name
+	return b
+}
+
+func (b *PRBuilder) Repository(repo string) *PRBuilder {
+	b.repository = repo
+	return b
+}
+
+// Merged merges the PR right after creation.
+func (b *PRBuilder) Merged() *PRBuilder { return b.MergedAfter(0) }
+
+// MergedAfter merges the PR once the server clock has moved on by d.
+func (b *PRBuilder) MergedAfter(d time.Duration) *PRBuilder {
+	b.mergeAfter = d
+	return b
+}
@@ -0,0 +1,137 @@
+// Package testkit runs the complete service in process, on an in-memory
+// store unless WithRepo says otherwise, for integration tests of code that
+// talks to it. No database or container is needed:
+//
+//	srv := testkit.Start(t)
+//	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob"))
+//	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+//	c := srv.Client() // or point your own code at srv.URL
+package testkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "prsrv/internal/domain"
+	httppkg "prsrv/internal/http"
+	"prsrv/internal/repo"
+	"prsrv/pkg/client"
+)
+
+// Static bearer tokens the server accepts.
+const (
+	AdminToken = "testkit-admin"
+	UserToken  = "testkit-user"
+)
+
+// Server is a running service. It is shut down when the test ends.
+type Server struct {
+	// URL is the base URL of the service, e.g. http://127.0.0.1:38211.
+	URL string
+	// Clock is the time of the service; move it to test deadlines and SLAs.
+	Clock *domain.ManualClock
+	// Service and Handlers allow configuration beyond the options, before
+	// the first request.
+	Service  *domain.Service
+	Handlers *httppkg.Handlers
+}
+
+type config struct {
+	now       time.Time
+	repo      func(domain.Clock) domain.Repo
+	configure []func(*httppkg.Handlers)
+}
+
+type Option func(*config)
+
+// WithTime starts the clock of the service at t instead of the current time.
+func WithTime(t time.Time) Option {
+	return func(c *config) { c.now = t }
+}
+
+// WithRepo keeps the state in the repo fn returns, e.g. a PostgresRepo over
+// a test database, instead of in memory. fn gets the clock of the service
+// for the repo to stamp rows with; the repo should start out empty.
+func WithRepo(fn func(domain.Clock) domain.Repo) Option {
+	return func(c *config) { c.repo = fn }
+}
+
+// WithHandlers adjusts the HTTP layer before the routes are registered,
+// e.g. to set SLAs or route permissions.
+func WithHandlers(fn func(*httppkg.Handlers)) Option {
+	return func(c *config) { c.configure = append(c.configure, fn) }
+}
+
+// Start runs a fresh service with empty state.
+func Start(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	cfg := config{now: time.Now().UTC(), repo: func(c domain.Clock) domain.Repo {
+		return repo.NewMemoryRepo().WithClock(c)
+	}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	clock := domain.NewManualClock(cfg.now)
+	svc := domain.NewService(cfg.repo(clock)).WithClock(clock)
+	h := httppkg.NewHandlers(svc, AdminToken, UserToken)
+	signer, err := httppkg.NewURLSigner("", time.Hour)
+	if err != nil {
+		t.Fatalf("testkit: %v", err)
+	}
+	h.Exports = signer
+	for _, fn := range cfg.configure {
+		fn(h)
+	}
+	mux := http.NewServeMux()
+	h.Register(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return &Server{URL: ts.URL, Clock: clock, Service: svc, Handlers: h}
+}
+
+// Client returns a client of the server authenticated as admin.
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	return client.New(s.URL, append([]client.Option{client.WithToken(AdminToken)}, opts...)...)
+}
+
+// UserClient returns a client with the read-only user role.
+func (s *Server) UserClient(opts ...client.Option) *client.Client {
+	return client.New(s.URL, append([]client.Option{client.WithToken(UserToken)}, opts...)...)
+}
+
+// AddTeam stores the team directly, bypassing HTTP, and fails the test on
+// error.
+func (s *Server) AddTeam(t testing.TB, b *TeamBuilder) *domain.Team {
+	t.Helper()
+	team, err := s.Service.AddTeam(context.Background(), b.Build())
+	if err != nil {
+		t.Fatalf("testkit: add team %s: %v", b.team.TeamName, err)
+	}
+	if b.team.LeadUserID != "" {
+		if err := s.Service.SetTeamLead(context.Background(), b.team.TeamName, b.team.LeadUserID); err != nil {
+			t.Fatalf("testkit: set lead of %s: %v", b.team.TeamName, err)
+		}
+		team.LeadUserID = b.team.LeadUserID
+	}
+	return team
+}
+
+// CreatePR creates the PR, which assigns reviewers as usual, and merges it
+// when the builder says so. It fails the test on error.
+func (s *Server) CreatePR(t testing.TB, b *PRBuilder) *domain.PullRequest {
+	t.Helper()
+	pr, err := s.Service.CreatePR(context.Background(), b.id, b.name, b.authorID, b.repository)
+	if err != nil {
+		t.Fatalf("testkit: create PR %s: %v", b.id, err)
+	}
+	if b.mergeAfter >= 0 {
+		s.Clock.Advance(b.mergeAfter)
+		if pr, err = s.Service.MergePR(context.Background(), pr.ID); err != nil {
+			t.Fatalf("testkit: merge PR %s: %v", pr.ID, err)
+		}
+	}
+	return pr
+}
@@ -0,0 +1,523 @@
+package e2e
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	httppkg "prsrv/internal/http"
+)
+
+const jwtTestSecret = "e2e-secret"
+
+func signHS256(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(jwtTestSecret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func withJWT(t *testing.T) func(*httppkg.Handlers) {
+	return func(h *httppkg.Handlers) {
+		v, err := httppkg.NewJWTVerifier(jwtTestSecret, nil, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Auth.JWT = v
+	}
+}
+
+func TestE2E_JWT_RoleClaims(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db, withJWT(t))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	admin := signHS256(t, map[string]any{"sub": "ops", "role": "admin", "exp": exp})
+	user := signHS256(t, map[string]any{"sub": "u1", "role": "user", "exp": exp})
+	expired := signHS256(t, map[string]any{"sub": "ops", "role": "admin", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	cases := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"admin", admin, 201},
+		{"user role", user, 401},
+		{"expired", expired, 401},
+		{"tampered", admin[:len(admin)-2] + "xx", 401},
+	}
+	for i, c := range cases {
+		body := `{"team_name":"jwt-` + string(rune('a'+i)) + `","members":[]}`
+		req, _ := http.NewRequest("POST", srv.URL+"/team/add", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Fatalf("%s: status=%d want=%d", c.name, resp.StatusCode, c.want)
+		}
+	}
+}
+
+func doJSON(t *testing.T, method, url, token, body string) (int, map[string]any) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out := map[string]any{}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestE2E_APITokens_IssueUseRevoke(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db)
+
+	status, _ := doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[
+		{"user_id":"u1","username":"Alice","is_active":true}
+	]}`)
+	if status != 201 {
+		t.Fatalf("team/add status=%d", status)
+	}
+
+	status, body := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", "admin", `{"user_id":"u1","role":"user","name":"ci"}`)
+	if status != 201 {
+		t.Fatalf("issue status=%d", status)
+	}
+	tok := body["token"].(map[string]any)
+	plain, _ := tok["token"].(string)
+	id, _ := tok["token_id"].(string)
+	if plain == "" || id == "" {
+		t.Fatalf("issue response missing token: %v", body)
+	}
+
+	if status, _ := doJSON(t, "GET", srv.URL+"/team/get?team_name=backend", plain, ""); status != 200 {
+		t.Fatalf("team/get with issued token status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/create", plain, `{"pull_request_id":"pr-x","pull_request_name":"x","author_id":"u1"}`); status != 401 {
+		t.Fatalf("user token on admin route status=%d", status)
+	}
+
+	if status, _ := doJSON(t, "POST", srv.URL+"/auth/tokens/revoke", "admin", `{"token_id":"`+id+`"}`); status != 200 {
+		t.Fatalf("revoke status=%d", status)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/team/get?team_name=backend", plain, ""); status != 401 {
+		t.Fatalf("revoked token status=%d", status)
+	}
+}
+
+func TestE2E_OIDC_GroupsToRoles(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": idp.URL, "jwks_uri": idp.URL + "/jwks"})
+		case "/jwks":
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "RSA", "kid": "k1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(idp.Close)
+
+	sign := func(claims map[string]any) string {
+		enc := func(v any) string {
+			b, _ := json.Marshal(v)
+			return base64.RawURLEncoding.EncodeToString(b)
+		}
+		signed := enc(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + enc(claims)
+		sum := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+
+	db := openTestDB(t)
+	srv := makeServer(t, db, func(h *httppkg.Handlers) {
+		h.Auth.OIDC = httppkg.NewOIDCVerifier(idp.URL, "prsrv", "", "", []string{"platform-admins"}, []string{"engineers"})
+	})
+
+	exp := time.Now().Add(time.Hour).Unix()
+	admin := sign(map[string]any{"iss": idp.URL, "aud": "prsrv", "preferred_username": "u1", "groups": []string{"platform-admins"}, "exp": exp})
+	engineer := sign(map[string]any{"iss": idp.URL, "aud": "prsrv", "preferred_username": "u2", "groups": []string{"engineers"}, "exp": exp})
+	wrongAud := sign(map[string]any{"iss": idp.URL, "aud": "other", "groups": []string{"platform-admins"}, "exp": exp})
+
+	if status, _ := doJSON(t, "POST", srv.URL+"/team/add", admin, `{"team_name":"sso","members":[]}`); status != 201 {
+		t.Fatalf("admin group status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/team/add", engineer, `{"team_name":"sso2","members":[]}`); status != 401 {
+		t.Fatalf("user group on admin route status=%d", status)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/stats/assignments", engineer, ""); status != 200 {
+		t.Fatalf("user group on user route status=%d", status)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/stats/assignments", wrongAud, ""); status != 401 {
+		t.Fatalf("wrong audience status=%d", status)
+	}
+}
+
+func TestE2E_RBAC_CustomRoleMergeOnly(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db)
+
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[
+		{"user_id":"u1","username":"Alice","is_active":true},
+		{"user_id":"u2","username":"Bob","is_active":true}
+	]}`)
+	doJSON(t, "POST", srv.URL+"/pullRequest/create", "admin", `{"pull_request_id":"pr-rm","pull_request_name":"x","author_id":"u1"}`)
+
+	if status, _ := doJSON(t, "POST", srv.URL+"/auth/roles/set", "admin", `{"role":"release-manager","permissions":["pr:merge","pr:read"]}`); status != 200 {
+		t.Fatalf("roles/set status=%d", status)
+	}
+	_, body := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", "admin", `{"user_id":"u2","role":"release-manager"}`)
+	plain, _ := body["token"].(map[string]any)["token"].(string)
+
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/create", plain, `{"pull_request_id":"pr-rm2","pull_request_name":"x","author_id":"u1"}`); status != 401 {
+		t.Fatalf("create with merge-only role status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/merge", plain, `{"pull_request_id":"pr-rm"}`); status != 200 {
+		t.Fatalf("merge with merge-only role status=%d", status)
+	}
+}
+
+func TestE2E_RateLimit_PerToken(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db, func(h *httppkg.Handlers) {
+		h.Auth.Limiter = httppkg.NewRateLimiter(map[httppkg.Role]httppkg.RateLimit{
+			httppkg.RoleUser: {Rate: 0.01, Burst: 2},
+		}, httppkg.RateLimit{})
+	})
+
+	for i := 0; i < 2; i++ {
+		if status, _ := doJSON(t, "GET", srv.URL+"/stats/assignments", "user", ""); status != 200 {
+			t.Fatalf("request %d status=%d", i, status)
+		}
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/stats/assignments", nil)
+	req.Header.Set("Authorization", "Bearer user")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 429 || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("over limit status=%d retry-after=%q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/stats/assignments", "admin", ""); status != 200 {
+		t.Fatalf("admin is not limited, status=%d", status)
+	}
+}
+
+func TestE2E_AdminCIDRAllowlist(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db, func(h *httppkg.Handlers) {
+		h.Auth.AdminCIDRs, _ = httppkg.ParseCIDRs("10.0.0.0/8")
+		h.Auth.TrustedProxies, _ = httppkg.ParseCIDRs("127.0.0.1,::1")
+	})
+
+	post := func(xff string) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/team/add", strings.NewReader(`{"team_name":"cidr-`+xff+`","members":[]}`))
+		req.Header.Set("Authorization", "Bearer admin")
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := post(""); status != 403 {
+		t.Fatalf("direct loopback status=%d", status)
+	}
+	if status := post("192.168.1.1"); status != 403 {
+		t.Fatalf("outside range status=%d", status)
+	}
+	if status := post("10.1.2.3"); status != 201 {
+		t.Fatalf("allowed range via trusted proxy status=%d", status)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/stats/assignments", "user", ""); status != 200 {
+		t.Fatalf("read endpoints are not restricted, status=%d", status)
+	}
+}
+
+func TestE2E_SelfScopedGetReview(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db)
+
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[
+		{"user_id":"u1","username":"Alice","is_active":true},
+		{"user_id":"u2","username":"Bob","is_active":true}
+	]}`)
+	_, body := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", "admin", `{"user_id":"u1","role":"user"}`)
+	own, _ := body["token"].(map[string]any)["token"].(string)
+
+	if status, body := doJSON(t, "GET", srv.URL+"/users/getReview", own, ""); status != 200 || body["user_id"] != "u1" {
+		t.Fatalf("own reviews status=%d body=%v", status, body)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/users/getReview?user_id=u2", own, ""); status != 403 {
+		t.Fatalf("foreign reviews status=%d", status)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/users/getReview?user_id=u2", "admin", ""); status != 200 {
+		t.Fatalf("admin reviews status=%d", status)
+	}
+}
+
+func TestE2E_TeamScopedToken(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db)
+
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[
+		{"user_id":"u1","username":"Alice","is_active":true},
+		{"user_id":"u2","username":"Bob","is_active":true}
+	]}`)
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"payments","members":[
+		{"user_id":"u3","username":"Carol","is_active":true},
+		{"user_id":"u4","username":"Dan","is_active":true}
+	]}`)
+	_, body := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", "admin", `{"user_id":"u1","role":"admin","teams":["backend"]}`)
+	scoped, _ := body["token"].(map[string]any)["token"].(string)
+
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/create", scoped, `{"pull_request_id":"pr-in","pull_request_name":"x","author_id":"u1"}`); status != 201 {
+		t.Fatalf("create in scope status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/create", scoped, `{"pull_request_id":"pr-out","pull_request_name":"x","author_id":"u3"}`); status != 403 {
+		t.Fatalf("create out of scope status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/users/setIsActive", scoped, `{"user_id":"u4","is_active":false}`); status != 403 {
+		t.Fatalf("setIsActive out of scope status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", scoped, `{"user_id":"u1","role":"admin"}`); status != 401 {
+		t.Fatalf("scoped token issuing tokens status=%d", status)
+	}
+	_, stats := doJSON(t, "GET", srv.URL+"/stats/assignments?group_by=user", scoped, "")
+	byUser, _ := stats["by_user"].([]any)
+	for _, row := range byUser {
+		if row.(map[string]any)["user_id"] == "u4" {
+			t.Fatalf("stats include out of scope user: %v", byUser)
+		}
+	}
+}
+
+func TestE2E_WebhookSignatures(t *testing.T) {
+	sources, err := httppkg.ParseWebhookSources("gh=github:s3cret,gl=gitlab:tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wh := httppkg.NewWebhooks(sources)
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request, body []byte) { w.WriteHeader(http.StatusNoContent) }
+	mux.HandleFunc("/hooks/github", wh.Require("gh", ok))
+	mux.HandleFunc("/hooks/gitlab", wh.Require("gl", ok))
+	mux.HandleFunc("/hooks/other", wh.Require("other", ok))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	payload := `{"action":"opened"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(payload))
+	good := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	cases := []struct {
+		name, path, header, value string
+		want                      int
+	}{
+		{"github valid", "/hooks/github", "X-Hub-Signature-256", good, 204},
+		{"github tampered", "/hooks/github", "X-Hub-Signature-256", "sha256=00", 401},
+		{"github missing", "/hooks/github", "", "", 401},
+		{"gitlab valid", "/hooks/gitlab", "X-Gitlab-Token", "tok", 204},
+		{"gitlab wrong", "/hooks/gitlab", "X-Gitlab-Token", "nope", 401},
+		{"unconfigured", "/hooks/other", "X-Signature", "00", 401},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("POST", srv.URL+tc.path, strings.NewReader(payload))
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status=%d want %d", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestE2E_HMACSignedRequests(t *testing.T) {
+	db := openTestDB(t)
+	keys, err := httppkg.ParseSigningKeys("ci=admin:k3y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := makeServer(t, db, func(h *httppkg.Handlers) {
+		h.Auth.HMAC = httppkg.NewHMACVerifier(keys, time.Minute)
+	})
+
+	send := func(ts int64, body, sig string) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/team/add", strings.NewReader(body))
+		req.Header.Set(httppkg.HeaderSignKey, "ci")
+		req.Header.Set(httppkg.HeaderSignTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(httppkg.HeaderSignature, sig)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	body := `{"team_name":"signed","members":[]}`
+	now := time.Now().Unix()
+	sig := httppkg.RequestSignature([]byte("k3y"), "POST", "/team/add", now, []byte(body))
+
+	if status := send(now, body, sig); status != 201 {
+		t.Fatalf("signed request status=%d", status)
+	}
+	if status := send(now, body, sig); status != 401 {
+		t.Fatalf("replayed request status=%d", status)
+	}
+	if status := send(now, `{"team_name":"other","members":[]}`, sig); status != 401 {
+		t.Fatalf("tampered body status=%d", status)
+	}
+	old := now - 600
+	if status := send(old, body, httppkg.RequestSignature([]byte("k3y"), "POST", "/team/add", old, []byte(body))); status != 401 {
+		t.Fatalf("stale timestamp status=%d", status)
+	}
+}
+
+func TestE2E_StrictAuthStatus(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db, func(h *httppkg.Handlers) { h.Auth.StrictStatus = true })
+
+	req, _ := http.NewRequest("POST", srv.URL+"/team/add", strings.NewReader(`{"team_name":"x","members":[]}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 || !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Bearer") {
+		t.Fatalf("missing token status=%d www-authenticate=%q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+	if status, body := doJSON(t, "POST", srv.URL+"/team/add", "bogus", `{"team_name":"x","members":[]}`); status != 401 || body["error"].(map[string]any)["code"] != "UNAUTHORIZED" {
+		t.Fatalf("invalid token status=%d body=%v", status, body)
+	}
+	if status, body := doJSON(t, "POST", srv.URL+"/team/add", "user", `{"team_name":"x","members":[]}`); status != 403 || body["error"].(map[string]any)["code"] != "FORBIDDEN" {
+		t.Fatalf("insufficient role status=%d body=%v", status, body)
+	}
+}
+
+func TestE2E_Whoami(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db)
+
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[{"user_id":"u1","username":"Alice","is_active":true}]}`)
+	_, body := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", "admin", `{"user_id":"u1","role":"user","teams":["backend"],"ttl_seconds":3600}`)
+	plain, _ := body["token"].(map[string]any)["token"].(string)
+
+	status, me := doJSON(t, "GET", srv.URL+"/auth/whoami", plain, "")
+	if status != 200 || me["role"] != "user" || me["user_id"] != "u1" || me["method"] != "token" || me["expires_at"] == nil {
+		t.Fatalf("whoami status=%d body=%v", status, me)
+	}
+	if teams, _ := me["teams"].([]any); len(teams) != 1 || teams[0] != "backend" {
+		t.Fatalf("whoami teams=%v", me["teams"])
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/auth/whoami", "bogus", ""); status != 401 {
+		t.Fatalf("whoami with bad token status=%d", status)
+	}
+}
+
+func TestE2E_RoutePermissionOverride(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db, func(h *httppkg.Handlers) {
+		var err error
+		if h.RoutePermissions, err = httppkg.ParseRoutePermissions("/pullRequest/create=pr:read"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[
+		{"user_id":"u1","username":"Alice","is_active":true},
+		{"user_id":"u2","username":"Bob","is_active":true}
+	]}`)
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/create", "user", `{"pull_request_id":"pr-o","pull_request_name":"x","author_id":"u1"}`); status != 201 {
+		t.Fatalf("create with user role status=%d", status)
+	}
+	if status, _ := doJSON(t, "POST", srv.URL+"/pullRequest/merge", "user", `{"pull_request_id":"pr-o"}`); status != 401 {
+		t.Fatalf("merge is still restricted, status=%d", status)
+	}
+	if _, err := httppkg.ParseRoutePermissions("/pullRequest/create=everything"); err == nil {
+		t.Fatal("unknown permission accepted")
+	}
+}
+
+func TestE2E_TokenUsage(t *testing.T) {
+	db := openTestDB(t)
+	var usage *httppkg.UsageTracker
+	srv := makeServer(t, db, func(h *httppkg.Handlers) {
+		usage = httppkg.NewUsageTracker(h.Svc.RecordTokenUsage, time.Hour)
+		h.Auth.Usage = usage
+	})
+
+	doJSON(t, "POST", srv.URL+"/team/add", "admin", `{"team_name":"backend","members":[{"user_id":"u1","username":"Alice","is_active":true}]}`)
+	_, body := doJSON(t, "POST", srv.URL+"/auth/tokens/issue", "admin", `{"user_id":"u1","role":"user","name":"idle"}`)
+	idleID, _ := body["token"].(map[string]any)["token_id"].(string)
+	doJSON(t, "GET", srv.URL+"/team/get?team_name=backend", "user", "")
+	doJSON(t, "GET", srv.URL+"/team/get?team_name=missing", "user", "")
+	usage.Close()
+
+	_, body = doJSON(t, "GET", srv.URL+"/auth/tokens/usage", "admin", "")
+	var sawIdle, sawUser bool
+	for _, item := range body["usage"].([]any) {
+		u := item.(map[string]any)
+		switch {
+		case u["token_id"] == idleID:
+			sawIdle = u["requests"].(float64) == 0
+		case u["role"] == "user" && u["method"] == "static":
+			sawUser = u["requests"].(float64) == 2 && u["errors"].(float64) == 1
+		}
+	}
+	if !sawIdle || !sawUser {
+		t.Fatalf("usage=%v", body["usage"])
+	}
+}
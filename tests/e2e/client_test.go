@@ -0,0 +1,107 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domain "prsrv/internal/domain"
+	"prsrv/pkg/client"
+)
+
+func TestClient_RetriesAndErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin" {
+			t.Errorf("authorization header = %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/api/v1/team/get":
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"error":{"code":"RATE_LIMITED","message":"slow down"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"team_name":"backend","members":[]}`))
+		case "/api/v1/pullRequest/merge":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"code":"PR_MERGED","message":"already merged"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"NOT_FOUND","message":"no such pr"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithToken("admin"), client.WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+
+	team, err := c.GetTeam(ctx, "backend")
+	if err != nil || team.TeamName != "backend" {
+		t.Fatalf("get team after 429: %+v, %v", team, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected one retry, got %d calls", n)
+	}
+
+	_, err = c.MergePR(ctx, "pr-1")
+	if !errors.Is(err, client.ErrPRMerged) {
+		t.Fatalf("expected ErrPRMerged, got %v", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || apiErr.Message != "already merged" {
+		t.Fatalf("unexpected error details: %+v", apiErr)
+	}
+
+	if _, err := c.PRTimeline(ctx, "pr-404"); !errors.Is(err, client.ErrNotFound) || errors.Is(err, client.ErrPRMerged) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestE2E_Client(t *testing.T) {
+	db := openTestDB(t)
+	srv := makeServer(t, db)
+	c := client.New(srv.URL, client.WithToken("admin"))
+	ctx := context.Background()
+
+	_, err := c.AddTeam(ctx, domain.Team{TeamName: "sdk", Members: []domain.TeamMember{
+		{UserID: "s1", Username: "A", IsActive: true},
+		{UserID: "s2", Username: "B", IsActive: true},
+		{UserID: "s3", Username: "C", IsActive: true},
+	}})
+	if err != nil {
+		t.Fatalf("add team: %v", err)
+	}
+	if _, err := c.AddTeam(ctx, domain.Team{TeamName: "sdk"}); !errors.Is(err, client.ErrTeamExists) {
+		t.Fatalf("expected ErrTeamExists, got %v", err)
+	}
+
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "sdk-1", Name: "client", AuthorID: "s1", Repository: "api"})
+	if err != nil {
+		t.Fatalf("create pr: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 2 || pr.Repository != "api" {
+		t.Fatalf("unexpected pr: %+v", pr)
+	}
+	if _, err := c.MergePR(ctx, "sdk-1"); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if _, _, err := c.Reassign(ctx, "sdk-1", pr.AssignedReviewers[0]); !errors.Is(err, client.ErrPRMerged) {
+		t.Fatalf("expected ErrPRMerged, got %v", err)
+	}
+
+	stats, err := c.AssignmentStats(ctx, client.AssignmentStatsParams{GroupBy: "repository"})
+	if err != nil || len(stats.ByRepo) != 1 || stats.ByRepo[0].Count != 2 {
+		t.Fatalf("assignment stats: %+v, %v", stats, err)
+	}
+
+	user := client.New(srv.URL, client.WithToken("user"))
+	if _, err := user.IssueToken(ctx, client.IssueTokenRequest{UserID: "s1", Role: "user"}); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
@@ -0,0 +1,459 @@
+package e2e
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"prsrv/pkg/testkit"
+)
+
+// TestContract_ResponsesMatchOpenAPI calls every documented operation and
+// checks each response against /openapi.json: the status must be documented,
+// the content type must match, and a JSON body must satisfy the schema,
+// with no undocumented fields. Request bodies are checked against the
+// schema too, so the spec describes what the handlers accept. A route
+// added without a call here fails the test.
+func TestContract_ResponsesMatchOpenAPI(t *testing.T) {
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	srv := testkit.Start(t, testkit.WithTime(start))
+	srv.AddTeam(t, testkit.NewTeam("backend").
+		Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave").Lead("u1"))
+	srv.CreatePR(t, testkit.NewPR("pr-old", "u1").Repository("api").MergedAfter(3*time.Hour))
+
+	c := newContract(t, srv)
+
+	c.call("GET", "/health", "", "", 200)
+	c.call("GET", "/openapi.json", "", "", 200)
+	c.call("GET", "/docs", "", "", 200)
+	c.call("GET", "/debug/vars", "", "", 200)
+	c.call("GET", "/metrics", "", "", 200)
+
+	c.call("POST", "/team/add", "", `{"team_name":"frontend","members":[
+		{"user_id":"f1","username":"Eve","is_active":true},
+		{"user_id":"f2","username":"Frank","is_active":true}]}`, 201)
+	c.call("POST", "/team/add", "", `{"team_name":"frontend","members":[]}`, 400)
+	c.call("POST", "/team/upsert", "", `{"team_name":"frontend","members":[{"user_id":"f3","username":"Gus","is_active":true}]}`, 200)
+	c.call("POST", "/team/upsert", "", `{"team_name":"design","members":[]}`, 201)
+	c.call("GET", "/team/get", "team_name=backend&limit=2", "", 200)
+	c.call("GET", "/team/get", "team_name=nope", "", 404)
+	c.call("POST", "/team/setLead", "", `{"team_name":"frontend","user_id":"f1"}`, 200)
+	c.call("POST", "/team/setManualAssignment", "", `{"team_name":"nope","manual_assignment":true}`, 404)
+
+	pr := c.call("POST", "/pullRequest/create", "", `{"pull_request_id":"pr-1","pull_request_name":"Add search","author_id":"u1","repository":"api"}`, 201)
+	reviewers := pr["pr"].(map[string]any)["assigned_reviewers"].([]any)
+	c.call("POST", "/pullRequest/create", "", `{"pull_request_name":"Generated id","author_id":"f1"}`, 201)
+	c.call("POST", "/pullRequest/create", "", `{"pull_request_id":"pr-preferred","pull_request_name":"Preferred","author_id":"f1","preferred_reviewers":["f2","u2"]}`, 201)
+	c.call("POST", "/pullRequest/create", "", `{"pull_request_id":"pr-1","pull_request_name":"again","author_id":"u1"}`, 409)
+	c.call("POST", "/pullRequest/acknowledge", "", fmt.Sprintf(`{"pull_request_id":"pr-1","user_id":%q}`, reviewers[0]), 200)
+	c.call("POST", "/pullRequest/requestChanges", "", fmt.Sprintf(`{"pull_request_id":"pr-1","user_id":%q}`, reviewers[0]), 200)
+	c.call("POST", "/pullRequest/approve", "", fmt.Sprintf(`{"pull_request_id":"pr-1","user_id":%q}`, reviewers[0]), 200)
+	c.call("POST", "/pullRequest/approve", "", `{"pull_request_id":"pr-1","user_id":"u1"}`, 409)
+	c.call("POST", "/pullRequest/requestChanges", "", `{"pull_request_id":"missing","user_id":"u1"}`, 404)
+	c.call("POST", "/pullRequest/reassign", "", fmt.Sprintf(`{"pull_request_id":"pr-1","old_user_id":%q}`, reviewers[1]), 200)
+	c.call("GET", "/pullRequest/list", "status=OPEN&sort=-created_at", "", 200)
+	c.call("GET", "/pullRequest/listByTeam", "team_name=frontend&include_reviewing=true&limit=1", "", 200)
+	c.call("GET", "/pullRequest/listByTeam", "team_name=nope", "", 404)
+	c.call("GET", "/pullRequest/timeline", "pull_request_id=pr-1", "", 200)
+	c.call("GET", "/pullRequest/activity", "pull_request_id=pr-1", "", 200)
+	c.call("POST", "/pullRequest/comment", "", `{"pull_request_id":"pr-1","author_id":"u2","text":"LGTM"}`, 201)
+	c.call("GET", "/pullRequest/comments", "pull_request_id=pr-1", "", 200)
+	c.call("GET", "/pullRequest/get", "pull_request_id=pr-1", "", 200)
+	c.call("GET", "/users/getReview", "user_id="+reviewers[0].(string), "", 200)
+	c.call("POST", "/pullRequest/addReviewer", "", fmt.Sprintf(`{"pull_request_id":"pr-1","user_id":%q}`, reviewers[0]), 400)
+	c.call("POST", "/team/setManualAssignment", "", `{"team_name":"frontend","manual_assignment":true}`, 200)
+	c.call("POST", "/pullRequest/create", "", `{"pull_request_id":"pr-manual","pull_request_name":"Manual","author_id":"f2"}`, 201)
+	c.call("POST", "/pullRequest/addReviewer", "", `{"pull_request_id":"pr-manual","user_id":"f1"}`, 200)
+	c.call("POST", "/pullRequest/transferAuthor", "", `{"pull_request_id":"pr-manual","author_id":"f1"}`, 200)
+	c.call("POST", "/team/setManualAssignment", "", `{"team_name":"frontend","manual_assignment":false}`, 200)
+	c.call("POST", "/team/setMergeRules", "", `{"team_name":"frontend","min_approvals":3}`, 400)
+	c.call("POST", "/team/setMergeRules", "", `{"team_name":"frontend","min_approvals":1,"block_on_changes_requested":true}`, 200)
+	c.call("POST", "/pullRequest/merge", "", `{"pull_request_id":"pr-manual"}`, 409)
+	c.call("POST", "/team/setMergeRules", "", `{"team_name":"frontend"}`, 200)
+	c.call("POST", "/team/setWorkingHours", "", `{"team_name":"frontend","time_zone":"Mars/Olympus","start_hour":9,"end_hour":18}`, 400)
+	c.call("POST", "/team/setWorkingHours", "", `{"team_name":"frontend","time_zone":"Europe/Moscow","start_hour":9,"end_hour":18}`, 200)
+	c.call("POST", "/team/setWorkingHours", "", `{"team_name":"frontend"}`, 200)
+	c.call("POST", "/team/setHolidays", "", `{"team_name":"frontend","holidays":[{"date":"2025-13-01"}]}`, 400)
+	c.call("POST", "/team/setHolidays", "", `{"team_name":"nope","holidays":[]}`, 404)
+	c.call("POST", "/team/setHolidays", "", `{"team_name":"frontend","holidays":[{"date":"2025-05-01","name":"Labour Day"}]}`, 200)
+	c.call("GET", "/team/holidays", "team_name=frontend", "", 200)
+	c.call("GET", "/team/holidays", "team_name=nope", "", 404)
+	c.call("POST", "/team/setHolidays", "", `{"team_name":"frontend","holidays":[]}`, 200)
+	c.call("POST", "/pullRequest/addDependency", "", `{"pull_request_id":"pr-manual","blocked_by":"pr-1"}`, 200)
+	c.call("POST", "/pullRequest/addDependency", "", `{"pull_request_id":"pr-1","blocked_by":"pr-manual"}`, 400)
+	c.call("POST", "/pullRequest/addDependency", "", `{"pull_request_id":"pr-manual","blocked_by":"missing"}`, 404)
+	c.call("POST", "/pullRequest/removeDependency", "", `{"pull_request_id":"pr-manual","blocked_by":"pr-1"}`, 200)
+	c.call("POST", "/pullRequest/removeDependency", "", `{"pull_request_id":"pr-manual","blocked_by":"pr-1"}`, 404)
+	c.call("POST", "/pullRequest/watch", "", `{"pull_request_id":"pr-manual","user_id":"u1"}`, 200)
+	c.call("POST", "/pullRequest/watch", "", `{"pull_request_id":"missing","user_id":"u1"}`, 404)
+	c.call("POST", "/pullRequest/unwatch", "", `{"pull_request_id":"pr-manual","user_id":"u1"}`, 200)
+	c.call("POST", "/pullRequest/close", "", `{"pull_request_id":"pr-manual"}`, 200)
+	c.call("POST", "/pullRequest/merge", "", `{"pull_request_id":"pr-manual"}`, 409)
+	c.call("POST", "/pullRequest/reopen", "", `{"pull_request_id":"pr-manual"}`, 200)
+	c.call("POST", "/pullRequest/close", "", `{"pull_request_id":"missing"}`, 404)
+	c.call("POST", "/pullRequest/reopen", "", `{"pull_request_id":"missing"}`, 404)
+	c.call("GET", "/users/getReviewBatch", "user_ids=u2,u3,u4", "", 200)
+	c.call("GET", "/users/pollAssignments", "user_id=u2", "", 200)
+	c.call("GET", "/users/pollAssignments", "user_id=u2&timeout=61", "", 400)
+	c.call("GET", "/users/getReviewBatch", "", "", 400)
+	c.call("GET", "/users/getReview", "", "", 400)
+	srv.Clock.Advance(5 * time.Hour)
+	c.call("POST", "/pullRequest/merge", "", `{"pull_request_id":"pr-1"}`, 200)
+	c.call("POST", "/pullRequest/merge", "", `{"pull_request_id":"missing"}`, 404)
+
+	c.call("POST", "/users/setIsActive", "", `{"user_id":"f2","is_active":false}`, 200)
+	c.call("POST", "/users/bulkDeactivate", "", `{"team_name":"backend","user_ids":["u4"],"dry_run":true}`, 200)
+	c.call("POST", "/users/bulkDeactivate", "", `{"team_name":"backend","user_ids":["u4"]}`, 200)
+	c.call("POST", "/users/update", "", `{"user_id":"u3","username":"Carol B."}`, 200)
+	c.call("POST", "/users/update", "", `{"user_id":"u3"}`, 400)
+	c.call("POST", "/users/update", "", `{"user_id":"nobody","username":"X"}`, 404)
+	c.call("POST", "/integrations/github/setLogin", "", `{"user_id":"u3","github_login":"Carol-B"}`, 200)
+	c.call("POST", "/integrations/github/setLogin", "", `{"user_id":"nobody","github_login":"ghost"}`, 404)
+	c.call("POST", "/integrations/github/webhook", "", `{"action":"opened","number":7,"pull_request":{"title":"Fix","merged":false,"user":{"login":"carol-b"}},"repository":{"full_name":"octo/app"}}`, 401)
+	c.call("POST", "/users/anonymize", "", `{"user_id":"u4"}`, 200)
+
+	c.call("POST", "/stats/refresh", "", "", 200)
+	c.call("POST", "/stats/snapshot/take", "", "", 200)
+	c.call("GET", "/stats/assignments", "group_by=team", "", 200)
+	c.call("GET", "/stats/assignments", "", "", 200)
+	c.call("GET", "/stats/assignments/timeseries", "from=2025-03-01&to=2025-03-04", "", 200)
+	c.call("GET", "/stats/prBurndown", "from=2025-03-01&to=2025-03-04", "", 200)
+	c.call("GET", "/stats/timeToFirstApproval", "", "", 200)
+	c.call("GET", "/stats/mergeTime", "group_by=repository", "", 200)
+	c.call("GET", "/stats/slaBreaches", "review_sla=1h&worst=5", "", 200)
+	c.call("GET", "/stats/prStatus", "bucket=week", "", 200)
+	c.call("GET", "/stats/prAge", "", "", 200)
+	c.call("GET", "/stats/authors", "merge_sla=2h", "", 200)
+	c.call("GET", "/stats/noCandidate", "", "", 200)
+	c.call("GET", "/stats/reassignments", "", "", 200)
+	c.call("GET", "/stats/pairings", "", "", 200)
+	c.call("GET", "/stats/teams", "", "", 200)
+	c.call("GET", "/stats/rebalancing", "", "", 200)
+	c.call("GET", "/stats/simulate", "strategy=least_loaded", "", 200)
+	c.call("GET", "/stats/idleReviewers", "days=1", "", 200)
+	c.call("GET", "/stats/snapshot", "date=2025-03-03", "", 200)
+	c.call("GET", "/stats/reviewerResponsiveness", "", "", 200)
+	c.call("GET", "/stats/prAge", "format=xlsx", "", 200)
+	c.call("POST", "/stats/query", "", `{"dimensions":["team","status"],"measures":["assignments","merges","latency"],"filters":{},"limit":10}`, 200)
+	c.call("POST", "/stats/query", "", `{"dimensions":["planet"],"measures":["assignments"],"filters":{},"limit":0}`, 400)
+
+	c.call("POST", "/alerts/overload/check", "", "", 200)
+	c.call("POST", "/team/setEscalation", "", `{"team_name":"frontend","notify_lead_after_days":2,"add_reviewer_after_days":1}`, 400)
+	c.call("POST", "/team/setEscalation", "", `{"team_name":"nope","notify_lead_after_days":1}`, 404)
+	c.call("POST", "/team/setEscalation", "", `{"team_name":"frontend","notify_lead_after_days":1,"add_reviewer_after_days":2}`, 200)
+	c.call("POST", "/alerts/escalations/run", "", "", 200)
+	c.call("GET", "/search", "q=u&types=user,team&limit=5", "", 200)
+	c.call("GET", "/search", "q=", "", 400)
+	c.call("GET", "/alerts/overload", "include_resolved=true", "", 200)
+
+	export := c.call("POST", "/exports/create", "", `{"kind":"assignments_by_user"}`, 202)
+	exportID := export["export"].(map[string]any)["export_id"].(string)
+	var link string
+	for deadline := time.Now().Add(5 * time.Second); link == "" && time.Now().Before(deadline); {
+		got := c.call("GET", "/exports/get", "export_id="+exportID, "", 200)
+		link, _ = got["download_url"].(string)
+		if link == "" {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if link == "" {
+		t.Fatalf("export %s never became ready", exportID)
+	}
+	path, query, _ := strings.Cut(strings.TrimPrefix(link, "/api/v1"), "?")
+	c.call("GET", path, query, "", 200)
+	c.call("GET", path, "id="+exportID+"&expires=1&signature=bogus", "", 403)
+
+	issued := c.call("POST", "/auth/tokens/issue", "", `{"user_id":"u1","role":"user","name":"ci","teams":["backend"],"ttl_seconds":3600}`, 201)
+	tokenID := issued["token"].(map[string]any)["token_id"].(string)
+	c.call("GET", "/auth/whoami", "", "", 200)
+	c.call("GET", "/auth/tokens/list", "user_id=u1", "", 200)
+	c.call("GET", "/auth/tokens/usage", "", "", 200)
+	rotated := c.call("POST", "/auth/tokens/rotate", "", fmt.Sprintf(`{"token_id":%q,"grace_seconds":0}`, tokenID), 201)
+	c.call("POST", "/auth/tokens/revoke", "", fmt.Sprintf(`{"token_id":%q}`, rotated["token"].(map[string]any)["token_id"]), 200)
+	c.call("POST", "/auth/tokens/revoke", "", `{"token_id":""}`, 400)
+	c.call("GET", "/auth/roles/list", "", "", 200)
+	c.call("POST", "/auth/roles/set", "", `{"role":"auditor","permissions":["stats:read"]}`, 200)
+	c.call("GET", "/auth/events", "limit=10", "", 200)
+
+	hook := c.call("POST", "/webhooks/add", "", `{"url":"http://127.0.0.1:1/hook","events":["pr_merged"]}`, 201)
+	c.call("POST", "/webhooks/add", "", `{"url":"ftp://example.com/hook"}`, 400)
+	c.call("POST", "/webhooks/add", "", `{"url":"http://127.0.0.1:1/hook","events":["pr_opened"]}`, 400)
+	c.call("GET", "/webhooks/list", "", "", 200)
+	c.call("POST", "/webhooks/remove", "", fmt.Sprintf(`{"webhook_id":%q}`, hook["webhook"].(map[string]any)["webhook_id"]), 200)
+	c.call("POST", "/webhooks/remove", "", `{"webhook_id":"wh_missing"}`, 404)
+
+	c.unauthenticated("GET", "/team/get", "team_name=backend", 401)
+
+	c.checkCoverage()
+}
+
+// contract validates responses of one server against its own OpenAPI
+// document.
+type contract struct {
+	t       *testing.T
+	srv     *testkit.Server
+	spec    map[string]any
+	covered map[string]bool
+}
+
+func newContract(t *testing.T, srv *testkit.Server) *contract {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	c := &contract{t: t, srv: srv, covered: map[string]bool{}}
+	if err := json.NewDecoder(resp.Body).Decode(&c.spec); err != nil {
+		t.Fatalf("decode openapi.json: %v", err)
+	}
+	return c
+}
+
+// operation returns the documented operation and the base URL it is
+// served under.
+func (c *contract) operation(method, path string) (map[string]any, string) {
+	c.t.Helper()
+	item, _ := c.spec["paths"].(map[string]any)[path].(map[string]any)
+	op, _ := item[strings.ToLower(method)].(map[string]any)
+	if op == nil {
+		c.t.Fatalf("%s %s is not in the OpenAPI document", method, path)
+	}
+	servers, _ := op["servers"].([]any)
+	if servers == nil {
+		servers = c.spec["servers"].([]any)
+	}
+	base := strings.TrimSuffix(servers[0].(map[string]any)["url"].(string), "/")
+	return op, base
+}
+
+func (c *contract) call(method, path, query, body string, status int) map[string]any {
+	c.t.Helper()
+	return c.do(method, path, query, body, testkit.AdminToken, status)
+}
+
+func (c *contract) unauthenticated(method, path, query string, status int) {
+	c.t.Helper()
+	c.do(method, path, query, "", "", status)
+}
+
+func (c *contract) do(method, path, query, body, token string, status int) map[string]any {
+	c.t.Helper()
+	op, base := c.operation(method, path)
+	name := method + " " + path
+	c.covered[name] = true
+
+	if body != "" {
+		schema := op["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+		var v any
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			c.t.Fatalf("%s: request body: %v", name, err)
+		}
+		for _, p := range c.validate(schema, v, "request") {
+			c.t.Errorf("%s: %s", name, p)
+		}
+	}
+	c.checkParams(name, op, query)
+
+	url := c.srv.URL + base + path
+	if query != "" {
+		url += "?" + query
+	}
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != status {
+		c.t.Fatalf("%s?%s: status %d, want %d: %s", name, query, resp.StatusCode, status, raw)
+	}
+
+	responses := op["responses"].(map[string]any)
+	documented, ok := responses[strconv.Itoa(resp.StatusCode)].(map[string]any)
+	if !ok {
+		if resp.StatusCode < 400 {
+			c.t.Errorf("%s: status %d is not documented", name, resp.StatusCode)
+			return nil
+		}
+		documented = responses["default"].(map[string]any)
+	}
+	content, _ := documented["content"].(map[string]any)
+	if len(content) == 0 {
+		if len(raw) > 0 {
+			c.t.Errorf("%s: undocumented body %q", name, raw)
+		}
+		return nil
+	}
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	media, ok := content[ct].(map[string]any)
+	if !ok {
+		c.t.Errorf("%s: content type %q, documented %v", name, ct, keys(content))
+		return nil
+	}
+	if ct != "application/json" {
+		if len(raw) == 0 {
+			c.t.Errorf("%s: empty %s body", name, ct)
+		}
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		c.t.Fatalf("%s: response is not JSON: %v", name, err)
+	}
+	for _, p := range c.validate(media["schema"].(map[string]any), v, "response") {
+		c.t.Errorf("%s: %s", name, p)
+	}
+	out, _ := v.(map[string]any)
+	return out
+}
+
+// checkParams fails on query parameters the operation does not document.
+func (c *contract) checkParams(name string, op map[string]any, query string) {
+	c.t.Helper()
+	known := map[string]bool{}
+	params, _ := op["parameters"].([]any)
+	for _, p := range params {
+		known[p.(map[string]any)["name"].(string)] = true
+	}
+	for _, kv := range strings.Split(query, "&") {
+		if k, _, _ := strings.Cut(kv, "="); k != "" && !known[k] {
+			c.t.Errorf("%s: query parameter %q is not documented", name, k)
+		}
+	}
+}
+
+// checkCoverage fails for documented operations no call exercised.
+func (c *contract) checkCoverage() {
+	c.t.Helper()
+	for path, item := range c.spec["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			if name := strings.ToUpper(method) + " " + path; !c.covered[name] {
+				c.t.Errorf("%s has no contract call", name)
+			}
+		}
+	}
+}
+
+// validate returns where v does not satisfy the schema. It understands the
+// subset of OpenAPI 3.0 that /openapi.json uses.
+func (c *contract) validate(schema map[string]any, v any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		def, ok := c.spec["components"].(map[string]any)["schemas"].(map[string]any)[name].(map[string]any)
+		if !ok {
+			return []string{at + ": unresolved " + ref}
+		}
+		return c.validate(def, v, at)
+	}
+	if v == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": null is not nullable"}
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		var problems []string
+		for _, s := range all {
+			problems = append(problems, c.validate(s.(map[string]any), v, at)...)
+		}
+		return problems
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T, want object", at, v)}
+		}
+		var problems []string
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			if _, ok := obj[r.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: required field %q missing", at, r))
+			}
+		}
+		extra, _ := schema["additionalProperties"].(map[string]any)
+		for _, k := range keys(obj) {
+			switch p, ok := props[k].(map[string]any); {
+			case ok:
+				problems = append(problems, c.validate(p, obj[k], at+"."+k)...)
+			case extra != nil:
+				problems = append(problems, c.validate(extra, obj[k], at+"."+k)...)
+			case props != nil:
+				problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, k))
+			}
+		}
+		return problems
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T, want array", at, v)}
+		}
+		items, _ := schema["items"].(map[string]any)
+		var problems []string
+		for i, e := range arr {
+			problems = append(problems, c.validate(items, e, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T, want string", at, v)}
+		}
+		var err error
+		switch schema["format"] {
+		case "date-time":
+			_, err = time.Parse(time.RFC3339Nano, s)
+		case "date":
+			_, err = time.Parse(time.DateOnly, s)
+		case "byte":
+			_, err = base64.StdEncoding.DecodeString(s)
+		}
+		if err != nil {
+			return []string{fmt.Sprintf("%s: %q is not a %s", at, s, schema["format"])}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: %v, want integer", at, v)}
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return []string{fmt.Sprintf("%s: %T, want number", at, v)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %T, want boolean", at, v)}
+		}
+	}
+	return nil
+}
+
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
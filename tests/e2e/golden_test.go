@@ -0,0 +1,230 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"prsrv/pkg/testkit"
+)
+
+// Golden files hold the canonical JSON of responses. After an intended
+// change to a response, rewrite them with
+//
+//	go test ./tests/e2e -run TestGolden -update
+//
+// and review the diff of testdata/golden like any other code.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of TestGolden_*")
+
+// volatileKeys are fields whose values are random on every run.
+var volatileKeys = map[string]bool{"token_id": true, "token": true, "export_id": true, "webhook_id": true, "secret": true}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// assertGolden compares the canonical form of a JSON body with
+// testdata/golden/<name>.json.
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	got, err := canonicalJSON(body)
+	if err != nil {
+		t.Fatalf("%s: %v: %s", name, err, body)
+	}
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s: %v (run with -update to record it)", name, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s (run with -update if intended):\n%s", name, path, lineDiff(string(want), string(got)))
+	}
+}
+
+// canonicalJSON indents the body with sorted keys and masks volatile values.
+func canonicalJSON(body []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(mask(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func mask(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok && volatileKeys[k] && s != "" {
+				v[k] = "<" + k + ">"
+				continue
+			}
+			v[k] = mask(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = mask(e)
+		}
+	case string:
+		if uuidRe.MatchString(v) {
+			return "<uuid>"
+		}
+	}
+	return v
+}
+
+// lineDiff lists the lines that differ, with their line numbers.
+func lineDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			fmt.Fprintf(&b, "%4d - %s\n%4d + %s\n", i+1, wl, i+1, gl)
+		}
+	}
+	return b.String()
+}
+
+// TestGolden_Responses records one response per endpoint on a fixed clock
+// and data set, so a change to any response shape shows up as a diff.
+func TestGolden_Responses(t *testing.T) {
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	srv := testkit.Start(t, testkit.WithTime(start))
+	srv.AddTeam(t, testkit.NewTeam("backend").
+		Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave").Lead("u1"))
+	srv.AddTeam(t, testkit.NewTeam("frontend").Member("f1", "Eve").Member("f2", "Frank"))
+	srv.CreatePR(t, testkit.NewPR("pr-old", "u1").Repository("api").MergedAfter(3*time.Hour))
+
+	steps := []struct {
+		name, method, path, body string
+		advance                  time.Duration // moves the clock before the call
+	}{
+		{name: "health", method: "GET", path: "/health"},
+		{name: "team_add", method: "POST", path: "/team/add", body: `{"team_name":"mobile","members":[{"user_id":"m1","username":"Gina","is_active":true}]}`},
+		{name: "team_upsert", method: "POST", path: "/team/upsert", body: `{"team_name":"mobile","members":[{"user_id":"m1","username":"G.","is_active":false},{"user_id":"m1","username":"Gina","is_active":true}]}`},
+		{name: "team_add_invalid", method: "POST", path: "/team/add", body: `{"team_name":"","members":[]}`},
+		{name: "team_get", method: "GET", path: "/team/get?team_name=backend&limit=2"},
+		{name: "team_get_not_found", method: "GET", path: "/team/get?team_name=nope"},
+		{name: "team_set_lead", method: "POST", path: "/team/setLead", body: `{"team_name":"frontend","user_id":"f1"}`},
+		{name: "team_set_escalation", method: "POST", path: "/team/setEscalation", body: `{"team_name":"frontend","notify_lead_after_days":1,"add_reviewer_after_days":3}`},
+		{name: "pr_create", method: "POST", path: "/pullRequest/create", body: `{"pull_request_id":"pr-1","pull_request_name":"Add search","author_id":"u1","repository":"api"}`, advance: time.Hour},
+		{name: "pr_create_generated_id", method: "POST", path: "/pullRequest/create", body: `{"pull_request_name":"Generated id","author_id":"f1"}`},
+		{name: "pr_create_exists", method: "POST", path: "/pullRequest/create", body: `{"pull_request_id":"pr-1","pull_request_name":"again","author_id":"u1"}`},
+		{name: "team_set_manual_assignment", method: "POST", path: "/team/setManualAssignment", body: `{"team_name":"mobile","manual_assignment":true}`},
+		{name: "pr_create_manual", method: "POST", path: "/pullRequest/create", body: `{"pull_request_id":"pr-m","pull_request_name":"Manual","author_id":"m1","preferred_reviewers":["m1","u2"]}`},
+		{name: "pr_add_reviewer", method: "POST", path: "/pullRequest/addReviewer", body: `{"pull_request_id":"pr-m","user_id":"u2"}`},
+		{name: "team_set_merge_rules", method: "POST", path: "/team/setMergeRules", body: `{"team_name":"mobile","min_approvals":1,"min_age_seconds":3600}`},
+		{name: "team_set_working_hours", method: "POST", path: "/team/setWorkingHours", body: `{"team_name":"mobile","time_zone":"Europe/Moscow","start_hour":9,"end_hour":18}`},
+		{name: "team_set_holidays", method: "POST", path: "/team/setHolidays", body: `{"team_name":"mobile","holidays":[{"date":"2025-05-09","name":"Victory Day"},{"date":"2025-05-01","name":"Labour Day"}]}`},
+		{name: "team_holidays", method: "GET", path: "/team/holidays?team_name=mobile"},
+		{name: "pr_merge_blocked", method: "POST", path: "/pullRequest/merge", body: `{"pull_request_id":"pr-m"}`},
+		{name: "pr_transfer_author", method: "POST", path: "/pullRequest/transferAuthor", body: `{"pull_request_id":"pr-m","author_id":"u2"}`},
+		{name: "pr_add_dependency", method: "POST", path: "/pullRequest/addDependency", body: `{"pull_request_id":"pr-m","blocked_by":"pr-1"}`},
+		{name: "pr_add_dependency_cycle", method: "POST", path: "/pullRequest/addDependency", body: `{"pull_request_id":"pr-1","blocked_by":"pr-m"}`},
+		{name: "pr_remove_dependency", method: "POST", path: "/pullRequest/removeDependency", body: `{"pull_request_id":"pr-m","blocked_by":"pr-1"}`},
+		{name: "pr_watch", method: "POST", path: "/pullRequest/watch", body: `{"pull_request_id":"pr-m","user_id":"u1"}`},
+		{name: "pr_watch_reviewer", method: "POST", path: "/pullRequest/watch", body: `{"pull_request_id":"pr-m","user_id":"u3"}`},
+		{name: "pr_unwatch", method: "POST", path: "/pullRequest/unwatch", body: `{"pull_request_id":"pr-m","user_id":"u1"}`},
+		{name: "pr_close", method: "POST", path: "/pullRequest/close", body: `{"pull_request_id":"pr-m"}`},
+		{name: "pr_reopen", method: "POST", path: "/pullRequest/reopen", body: `{"pull_request_id":"pr-m"}`},
+		{name: "pr_acknowledge", method: "POST", path: "/pullRequest/acknowledge", body: `{"pull_request_id":"pr-1","user_id":"u3"}`, advance: 30 * time.Minute},
+		{name: "pr_request_changes", method: "POST", path: "/pullRequest/requestChanges", body: `{"pull_request_id":"pr-1","user_id":"u3"}`},
+		{name: "pr_approve", method: "POST", path: "/pullRequest/approve", body: `{"pull_request_id":"pr-1","user_id":"u3"}`},
+		{name: "pr_approve_not_assigned", method: "POST", path: "/pullRequest/approve", body: `{"pull_request_id":"pr-1","user_id":"u1"}`},
+		{name: "pr_reassign", method: "POST", path: "/pullRequest/reassign", body: `{"pull_request_id":"pr-1","old_user_id":"u3"}`},
+		{name: "pr_list", method: "GET", path: "/pullRequest/list?sort=created_at"},
+		{name: "pr_list_by_team", method: "GET", path: "/pullRequest/listByTeam?team_name=backend&include_reviewing=true&status=OPEN"},
+		{name: "pr_get", method: "GET", path: "/pullRequest/get?pull_request_id=pr-1"},
+		{name: "user_reviews", method: "GET", path: "/users/getReview?user_id=u3"},
+		{name: "user_poll_assignments", method: "GET", path: "/users/pollAssignments?user_id=u3"},
+		{name: "user_reviews_batch", method: "GET", path: "/users/getReviewBatch?user_ids=u2,u3&user_ids=u4"},
+		{name: "pr_merge", method: "POST", path: "/pullRequest/merge", body: `{"pull_request_id":"pr-1"}`, advance: 5 * time.Hour},
+		{name: "pr_timeline", method: "GET", path: "/pullRequest/timeline?pull_request_id=pr-1"},
+		{name: "pr_comment", method: "POST", path: "/pullRequest/comment", body: `{"pull_request_id":"pr-1","author_id":"u2","text":"Reviewed offline, LGTM"}`},
+		{name: "pr_comments", method: "GET", path: "/pullRequest/comments?pull_request_id=pr-1"},
+		{name: "pr_activity", method: "GET", path: "/pullRequest/activity?pull_request_id=pr-1&sort=-at&limit=2"},
+		{name: "user_update", method: "POST", path: "/users/update", body: `{"user_id":"f1","username":"Eve Adams"}`},
+		{name: "github_set_login", method: "POST", path: "/integrations/github/setLogin", body: `{"user_id":"f1","github_login":"Eve-Adams"}`},
+		{name: "user_set_active", method: "POST", path: "/users/setIsActive", body: `{"user_id":"f2","is_active":false}`},
+		{name: "users_bulk_deactivate_dry_run", method: "POST", path: "/users/bulkDeactivate", body: `{"team_name":"backend","user_ids":["u4"],"dry_run":true}`},
+		{name: "users_bulk_deactivate", method: "POST", path: "/users/bulkDeactivate", body: `{"team_name":"backend","user_ids":["u4"]}`},
+		{name: "stats_assignments", method: "GET", path: "/stats/assignments"},
+		{name: "stats_assignments_by_team", method: "GET", path: "/stats/assignments?group_by=team"},
+		{name: "stats_timeseries", method: "GET", path: "/stats/assignments/timeseries?from=2025-03-02&to=2025-03-04"},
+		{name: "stats_burndown", method: "GET", path: "/stats/prBurndown?from=2025-03-02&to=2025-03-04"},
+		{name: "stats_time_to_first_approval", method: "GET", path: "/stats/timeToFirstApproval"},
+		{name: "stats_merge_time", method: "GET", path: "/stats/mergeTime?group_by=repository"},
+		{name: "stats_sla_breaches", method: "GET", path: "/stats/slaBreaches?review_sla=1h&merge_sla=4h&worst=5"},
+		{name: "stats_pr_status", method: "GET", path: "/stats/prStatus?bucket=week"},
+		{name: "stats_pr_age", method: "GET", path: "/stats/prAge"},
+		{name: "stats_authors", method: "GET", path: "/stats/authors?merge_sla=4h"},
+		{name: "stats_no_candidate", method: "GET", path: "/stats/noCandidate?from=2025-03-02&to=2025-03-04"},
+		{name: "stats_reassignments", method: "GET", path: "/stats/reassignments?from=2025-03-02&to=2025-03-04"},
+		{name: "stats_pairings", method: "GET", path: "/stats/pairings?from=2025-03-02&to=2025-03-04"},
+		{name: "stats_teams", method: "GET", path: "/stats/teams"},
+		{name: "stats_rebalancing", method: "GET", path: "/stats/rebalancing"},
+		{name: "stats_simulate", method: "GET", path: "/stats/simulate?strategy=round_robin&from=2025-03-02&to=2025-03-04"},
+		{name: "stats_idle_reviewers", method: "GET", path: "/stats/idleReviewers?days=1"},
+		{name: "stats_reviewer_responsiveness", method: "GET", path: "/stats/reviewerResponsiveness"},
+		{name: "stats_query", method: "POST", path: "/stats/query", body: `{"dimensions":["team","status"],"measures":["assignments","merges"],"limit":10}`},
+		{name: "stats_snapshot_take", method: "POST", path: "/stats/snapshot/take"},
+		{name: "stats_snapshot", method: "GET", path: "/stats/snapshot?date=2025-03-03"},
+		{name: "search", method: "GET", path: "/search?q=a"},
+		{name: "search_prs", method: "GET", path: "/search?q=PR-&types=pull_request&limit=2"},
+		{name: "alerts_overload", method: "GET", path: "/alerts/overload"},
+		{name: "alerts_escalations_run", method: "POST", path: "/alerts/escalations/run", advance: 24 * time.Hour},
+		{name: "auth_token_issue", method: "POST", path: "/auth/tokens/issue", body: `{"user_id":"u1","role":"user","name":"ci","teams":["backend"],"ttl_seconds":3600}`},
+		{name: "auth_tokens_list", method: "GET", path: "/auth/tokens/list?user_id=u1"},
+		{name: "webhook_add", method: "POST", path: "/webhooks/add", body: `{"url":"https://hooks.example.com/prsrv","events":["reviewer_assigned","pr_merged"]}`},
+		{name: "webhooks_list", method: "GET", path: "/webhooks/list"},
+		{name: "auth_roles_list", method: "GET", path: "/auth/roles/list"},
+		{name: "auth_whoami", method: "GET", path: "/auth/whoami"},
+		{name: "v2_team_get", method: "GET", path: "/team/get?team_name=frontend"},
+	}
+	for _, s := range steps {
+		srv.Clock.Advance(s.advance)
+		prefix := "/api/v1"
+		if strings.HasPrefix(s.name, "v2_") {
+			prefix = "/api/v2"
+		}
+		req, _ := http.NewRequest(s.method, srv.URL+prefix+s.path, strings.NewReader(s.body))
+		req.Header.Set("Authorization", "Bearer "+testkit.AdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// the status is part of the snapshot
+		assertGolden(t, s.name, []byte(fmt.Sprintf(`{"status":%d,"body":%s}`, resp.StatusCode, body)))
+	}
+}
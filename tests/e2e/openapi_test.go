@@ -0,0 +1,277 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domain "prsrv/internal/domain"
+	httppkg "prsrv/internal/http"
+	"prsrv/internal/msgpack"
+)
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	h := httppkg.NewHandlers(domain.NewService(nil), "admin", "user")
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("openapi.json status %d", resp.StatusCode)
+	}
+	var spec struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("openapi version %q", spec.OpenAPI)
+	}
+	for _, p := range []string{"/team/add", "/pullRequest/create", "/stats/query", "/auth/tokens/issue", "/openapi.json"} {
+		if spec.Paths[p] == nil {
+			t.Errorf("%s missing from the document", p)
+		}
+	}
+	for path, ops := range spec.Paths {
+		for method, op := range ops {
+			if op["summary"] == "" {
+				t.Errorf("%s %s has no entry in apiDocs", method, path)
+			}
+		}
+	}
+	if spec.Paths["/pullRequest/create"]["post"] == nil || spec.Paths["/stats/teams"]["get"] == nil {
+		t.Errorf("unexpected methods: %v, %v", spec.Paths["/pullRequest/create"], spec.Paths["/stats/teams"])
+	}
+	for _, name := range []string{"PullRequest", "Team", "ReportQuery", "ErrorResponse"} {
+		if spec.Components.Schemas[name] == nil {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "/openapi.json") {
+		t.Fatalf("docs: status %d", resp.StatusCode)
+	}
+}
+
+func TestMethodAndContentType(t *testing.T) {
+	h := httppkg.NewHandlers(domain.NewService(nil), "admin", "user")
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/team/add", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "POST" {
+		t.Fatalf("GET /team/add: status=%d allow=%q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+
+	resp, err = http.Post(srv.URL+"/openapi.json", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+		t.Fatalf("POST /openapi.json: status=%d allow=%q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+
+	resp, err = http.Post(srv.URL+"/team/add", "application/x-www-form-urlencoded", strings.NewReader("team_name=x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("form body: status=%d", resp.StatusCode)
+	}
+}
+
+func TestV2Envelope_HealthAndErrors(t *testing.T) {
+	h := httppkg.NewHandlers(domain.NewService(nil), "admin", "user")
+	mux := http.NewServeMux()
+	h.Auth.Permissions, h.Auth.Tokens = nil, nil
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	status, body := doJSON(t, "GET", srv.URL+"/api/v2/health", "", "")
+	if status != 200 || body["data"].(map[string]any)["status"] != "ok" || body["meta"].(map[string]any)["api_version"].(float64) != 2 {
+		t.Fatalf("health: status=%d body=%v", status, body)
+	}
+	status, body = doJSON(t, "GET", srv.URL+"/api/v2/team/add", "admin", "")
+	if status != http.StatusMethodNotAllowed || body["error"].(map[string]any)["code"] != "INVALID_ARGUMENT" || body["data"] != nil {
+		t.Fatalf("405: status=%d body=%v", status, body)
+	}
+	status, body = doJSON(t, "GET", srv.URL+"/api/v2/pullRequest/list?cursor=bogus", "admin", "")
+	if status != http.StatusBadRequest || body["error"].(map[string]any)["message"] != "invalid cursor" {
+		t.Fatalf("bad cursor: status=%d body=%v", status, body)
+	}
+	if status, _ := doJSON(t, "GET", srv.URL+"/api/v2/openapi.json", "", ""); status != http.StatusNotFound {
+		t.Fatalf("openapi.json is not versioned: status=%d", status)
+	}
+}
+
+func TestV1Prefix_AndLegacyDeprecation(t *testing.T) {
+	h := httppkg.NewHandlers(domain.NewService(nil), "admin", "user")
+	h.LegacySunset = time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Deprecation") != "" {
+		t.Fatalf("/api/v1/health: status=%d deprecation=%q", resp.StatusCode, resp.Header.Get("Deprecation"))
+	}
+
+	resp, err = http.Get(srv.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Deprecation") != "true" ||
+		resp.Header.Get("Sunset") != "Sun, 31 Jan 2027 00:00:00 GMT" ||
+		resp.Header.Get("Link") != `</api/v1/health>; rel="successor-version"` {
+		t.Fatalf("/health: status=%d headers=%v", resp.StatusCode, resp.Header)
+	}
+
+	resp, err = http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Deprecation") != "" {
+		t.Fatal("unversioned routes are not deprecated")
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	h := httppkg.NewHandlers(domain.NewService(nil), "admin", "user")
+	h.Auth.Permissions, h.Auth.Tokens = nil, nil
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path, token, lang string) (*http.Response, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("Accept-Language", lang)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body := map[string]any{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp, body
+	}
+
+	resp, body := get("/team/add", "admin", "ru-RU,ru;q=0.9,en;q=0.8")
+	e := body["error"].(map[string]any)
+	if resp.StatusCode != http.StatusMethodNotAllowed || e["code"] != "INVALID_ARGUMENT" ||
+		e["message"] != "Некорректный запрос" || e["detail"] != "use POST" || resp.Header.Get("Content-Language") != "ru" {
+		t.Fatalf("ru: status=%d body=%v", resp.StatusCode, body)
+	}
+
+	resp, body = get("/team/get?team_name=x", "", "ru")
+	if e := body["error"].(map[string]any); resp.StatusCode != http.StatusUnauthorized || e["message"] != "Требуется аутентификация" {
+		t.Fatalf("ru 401: status=%d body=%v", resp.StatusCode, body)
+	}
+
+	resp, body = get("/team/add", "admin", "de, en;q=0.5, ru;q=0.1")
+	if e := body["error"].(map[string]any); e["message"] != "use POST" || e["detail"] != nil {
+		t.Fatalf("en: status=%d body=%v", resp.StatusCode, body)
+	}
+
+	resp, body = get("/api/v2/team/add", "admin", "ru")
+	if e := body["error"].(map[string]any); e["message"] != "Некорректный запрос" || body["meta"] == nil {
+		t.Fatalf("v2 ru: status=%d body=%v", resp.StatusCode, body)
+	}
+
+	resp, _ = get("/health", "", "ru")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Language") != "" {
+		t.Fatalf("health: status=%d", resp.StatusCode)
+	}
+}
+
+func TestMsgpackNegotiation(t *testing.T) {
+	h := httppkg.NewHandlers(domain.NewService(nil), "admin", "user")
+	h.Auth.Permissions, h.Auth.Tokens = nil, nil
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	in := []byte(`{"team_name":"","members":[{"user_id":"u1","username":"Алиса","n":-200,"big":4294967296,"f":1.5,"ok":true,"x":null}]}`)
+	packed, err := msgpack.FromJSON(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := msgpack.ToJSON(packed)
+	if err != nil || string(back) != string(in) {
+		t.Fatalf("round trip: %s, %v", back, err)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/api/v1/team/add", bytes.NewReader(packed))
+	req.Header.Set("Authorization", "Bearer admin")
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/msgpack, application/json;q=0.5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("Content-Type") != "application/msgpack" {
+		t.Fatalf("team/add: status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, err := msgpack.ToJSON(raw)
+	if err != nil || !strings.Contains(string(body), `"field":"team_name"`) {
+		t.Fatalf("team/add body: %s, %v", body, err)
+	}
+
+	req, _ = http.NewRequest("GET", srv.URL+"/health", nil)
+	req.Header.Set("Accept", "*/*")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct == "application/msgpack" {
+		t.Fatal("wildcard Accept must keep JSON")
+	}
+}
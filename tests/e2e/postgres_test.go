@@ -0,0 +1,223 @@
+package e2e
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "prsrv/internal/domain"
+	repo "prsrv/internal/repo"
+	"prsrv/pkg/testkit"
+)
+
+// The e2e tests run against TEST_DATABASE_URL when it is set. Otherwise the
+// first test that needs a database starts a disposable Postgres container,
+// which all tests of the package share and TestMain removes at the end.
+// The container is driven through the docker CLI rather than
+// testcontainers-go, which would pull the Docker SDK into go.mod for three
+// commands. With TEST_REQUIRE_POSTGRES set, a missing database fails the
+// tests instead of skipping them.
+
+const (
+	defaultPostgresImage = "postgres:16"
+	postgresStartTimeout = time.Minute
+)
+
+var errNoDocker = errors.New("docker not found")
+
+var testPostgres struct {
+	once      sync.Once
+	dsn       string
+	container string
+	err       error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if id := testPostgres.container; id != "" {
+		_ = exec.Command("docker", "rm", "-f", "-v", id).Run()
+	}
+	os.Exit(code)
+}
+
+// testDSN returns the connection string of the test database, starting the
+// container on first use.
+func testDSN() (string, error) {
+	testPostgres.once.Do(func() {
+		if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+			testPostgres.dsn = dsn
+			return
+		}
+		testPostgres.dsn, testPostgres.container, testPostgres.err = startPostgres(mustEnv("TEST_POSTGRES_IMAGE", defaultPostgresImage))
+	})
+	return testPostgres.dsn, testPostgres.err
+}
+
+// startPostgres runs the image on a random local port and waits until it
+// accepts connections.
+func startPostgres(image string) (dsn, container string, err error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", "", errNoDocker
+	}
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_USER=postgres",
+		"-e", "POSTGRES_PASSWORD=postgres",
+		"-e", "POSTGRES_DB=prsrv",
+		"-p", "127.0.0.1::5432",
+		image).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("docker run %s: %w", image, commandError(err))
+	}
+	container = strings.TrimSpace(string(out))
+
+	out, err = exec.Command("docker", "port", container, "5432/tcp").Output()
+	if err != nil {
+		_ = exec.Command("docker", "rm", "-f", "-v", container).Run()
+		return "", "", fmt.Errorf("docker port: %w", commandError(err))
+	}
+	// docker port may list an IPv6 binding as well; the first line is ours
+	addr := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	dsn = fmt.Sprintf("postgres://postgres:postgres@%s/prsrv?sslmode=disable", addr)
+
+	if err := waitPostgres(dsn, postgresStartTimeout); err != nil {
+		_ = exec.Command("docker", "rm", "-f", "-v", container).Run()
+		return "", "", err
+	}
+	return dsn, container, nil
+}
+
+// waitPostgres pings until the server is up. While the image initializes the
+// database it listens on the unix socket only, so a successful TCP ping
+// means the final server is ready.
+func waitPostgres(dsn string, timeout time.Duration) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	deadline := time.Now().Add(timeout)
+	for {
+		err := db.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("postgres not ready after %s: %w", timeout, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func commandError(err error) error {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn, err := testDSN()
+	if errors.Is(err, errNoDocker) {
+		if os.Getenv("TEST_REQUIRE_POSTGRES") != "" {
+			t.Fatal("TEST_REQUIRE_POSTGRES is set, but there is no TEST_DATABASE_URL and no docker to start postgres")
+		}
+		t.Skip("no TEST_DATABASE_URL and no docker to start postgres")
+	}
+	if err != nil {
+		t.Fatalf("test postgres: %v", err)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping db: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+// resetDB migrates db and empties it: every table but the migration log,
+// the built-in roles and the stats refresh marker is truncated, and the
+// stats views are refreshed to match.
+func resetDB(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if err := repo.RunMigrations(db, migrationsPath(t)); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	tables, err := names(db, `select tablename from pg_tables where schemaname = current_schema()
+		and tablename not in ('app_migrations', 'roles', 'role_permissions', 'stats_refresh')`)
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	if len(tables) > 0 {
+		if _, err := db.Exec(`truncate table ` + strings.Join(tables, ", ") + ` cascade`); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+	}
+	if _, err := db.Exec(`delete from roles where role not in ('admin', 'user')`); err != nil {
+		t.Fatalf("delete roles: %v", err)
+	}
+	views, err := names(db, `select matviewname from pg_matviews where schemaname = current_schema()`)
+	if err != nil {
+		t.Fatalf("list views: %v", err)
+	}
+	for _, v := range views {
+		if _, err := db.Exec(`refresh materialized view ` + v); err != nil {
+			t.Fatalf("refresh %s: %v", v, err)
+		}
+	}
+}
+
+func names(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// stores are the repos the testkit tests run against. Postgres is skipped
+// when there is neither TEST_DATABASE_URL nor docker.
+var stores = []struct {
+	name string
+	repo func(t *testing.T) testkit.Option
+}{
+	{"memory", func(*testing.T) testkit.Option {
+		return testkit.WithRepo(func(c domain.Clock) domain.Repo { return repo.NewMemoryRepo().WithClock(c) })
+	}},
+	{"postgres", func(t *testing.T) testkit.Option {
+		db := openTestDB(t)
+		resetDB(t, db)
+		return testkit.WithRepo(func(c domain.Clock) domain.Repo { return repo.NewPostgresRepo(db).WithClock(c) })
+	}},
+}
+
+// forEachStore runs fn as a subtest per store, so the memory and Postgres
+// repos answer the same tests.
+func forEachStore(t *testing.T, fn func(t *testing.T, store testkit.Option)) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) { fn(t, s.repo(t)) })
+	}
+}
@@ -0,0 +1,2358 @@
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domain "prsrv/internal/domain"
+	httppkg "prsrv/internal/http"
+	"prsrv/internal/importer"
+	"prsrv/internal/redis"
+	"prsrv/internal/repo"
+	"prsrv/pkg/client"
+	"prsrv/pkg/testkit"
+)
+
+func TestTestkit_ClientFlow(t *testing.T) {
+	forEachStore(t, testTestkit_ClientFlow)
+}
+
+func testTestkit_ClientFlow(t *testing.T, store testkit.Option) {
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	srv := testkit.Start(t, store, testkit.WithTime(start))
+	srv.AddTeam(t, testkit.NewTeam("backend").
+		Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Inactive("u4", "Dave").Lead("u1"))
+	srv.CreatePR(t, testkit.NewPR("pr-old", "u1").MergedAfter(2*time.Hour))
+
+	c := srv.Client()
+	ctx := context.Background()
+	team, err := c.GetTeam(ctx, "backend")
+	if err != nil || len(team.Members) != 4 || team.LeadUserID != "u1" {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Add search", AuthorID: "u1"})
+	if err != nil || len(pr.AssignedReviewers) != 2 || !pr.CreatedAt.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("create: pr=%+v err=%v", pr, err)
+	}
+	for _, id := range pr.AssignedReviewers {
+		if id == "u1" || id == "u4" {
+			t.Fatalf("author or inactive member assigned: %v", pr.AssignedReviewers)
+		}
+	}
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "again", AuthorID: "u1"}); !errors.Is(err, client.ErrPRExists) {
+		t.Fatalf("duplicate PR: %v", err)
+	}
+
+	reviews, err := c.UserReviews(ctx, pr.AssignedReviewers[0])
+	if err != nil || len(reviews) == 0 {
+		t.Fatalf("reviews=%v err=%v", reviews, err)
+	}
+	prs, _, err := c.ListPRs(ctx, client.PRFilter{Status: "MERGED"}, client.Page{})
+	if err != nil || len(prs) != 1 || prs[0].ID != "pr-old" {
+		t.Fatalf("merged PRs=%v err=%v", prs, err)
+	}
+
+	srv.Clock.Advance(4 * 24 * time.Hour)
+	age, err := c.PRAge(ctx)
+	if err != nil || age.Overall["3-7d"] != 1 {
+		t.Fatalf("age=%+v err=%v", age, err)
+	}
+	merged, err := c.MergePR(ctx, "pr-1")
+	if err != nil || merged.MergedAt.Sub(*merged.CreatedAt) != 96*time.Hour {
+		t.Fatalf("merge: pr=%+v err=%v", merged, err)
+	}
+	mt, err := c.MergeTime(ctx, client.MergeTimeParams{})
+	if err != nil || mt.Overall.Count != 2 {
+		t.Fatalf("merge time=%+v err=%v", mt, err)
+	}
+
+	// without AUTH_STRICT_STATUS a missing permission is a 401
+	if _, err := srv.UserClient().MergePR(ctx, "pr-1"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("user merge: %v", err)
+	}
+}
+
+func TestConditionalPRUpdates(t *testing.T) {
+	forEachStore(t, testConditionalPRUpdates)
+}
+
+func testConditionalPRUpdates(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	seen, err := c.GetPR(ctx, "pr-1")
+	if err != nil || len(seen.AssignedReviewers) != 2 {
+		t.Fatalf("get: pr=%+v err=%v", seen, err)
+	}
+	etag := seen.ETag()
+
+	// a second admin reassigns first, with the same view of the PR
+	other, _, err := c.ReassignIfMatch(ctx, "pr-1", seen.AssignedReviewers[0], etag)
+	if err != nil {
+		t.Fatalf("first reassign: %v", err)
+	}
+	if _, err := c.MergePRIfMatch(ctx, "pr-1", etag); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Fatalf("stale merge: %v", err)
+	}
+	if _, _, err := c.ReassignIfMatch(ctx, "pr-1", seen.AssignedReviewers[1], etag); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Fatalf("stale reassign: %v", err)
+	}
+	if pr, _ := c.GetPR(ctx, "pr-1"); pr.Status != "OPEN" || pr.ETag() != other.ETag() {
+		t.Fatalf("the stale requests changed the PR: %+v", pr)
+	}
+
+	merged, err := c.MergePRIfMatch(ctx, "pr-1", other.ETag())
+	if err != nil || merged.Status != "MERGED" {
+		t.Fatalf("merge: pr=%+v err=%v", merged, err)
+	}
+	// without If-Match the caller accepts whatever state the PR is in
+	if _, err := c.MergePR(ctx, "pr-1"); err != nil {
+		t.Fatalf("unconditional merge: %v", err)
+	}
+
+	get := func(header, value string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+"/api/v1/pullRequest/get?pull_request_id=pr-1", nil)
+		req.Header.Set("Authorization", "Bearer "+testkit.AdminToken)
+		req.Header.Set(header, value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	resp := get("If-None-Match", merged.ETag())
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != merged.ETag() {
+		t.Fatalf("If-None-Match: status=%d etag=%q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if resp := get("If-None-Match", etag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("stale If-None-Match: status=%d", resp.StatusCode)
+	}
+}
+
+func TestBulkDeactivate_DryRun(t *testing.T) {
+	forEachStore(t, testBulkDeactivate_DryRun)
+}
+
+func testBulkDeactivate_DryRun(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	for _, id := range []string{"pr-1", "pr-2", "pr-3"} {
+		srv.CreatePR(t, testkit.NewPR(id, "u1"))
+	}
+	c := srv.Client()
+	ctx := context.Background()
+
+	plan, err := c.PlanBulkDeactivate(ctx, "backend", []string{"u2", "u3", "nobody"})
+	if err != nil || !plan.DryRun || len(plan.Deactivated) != 2 || len(plan.Reassignments) == 0 {
+		t.Fatalf("plan=%+v err=%v", plan, err)
+	}
+	team, _ := c.GetTeam(ctx, "backend")
+	for _, m := range team.Members {
+		if !m.IsActive {
+			t.Fatalf("dry run deactivated %s", m.UserID)
+		}
+	}
+	if tl, _ := c.PRTimeline(ctx, "pr-1"); len(tl.Events) != 3 {
+		t.Fatalf("dry run recorded events: %+v", tl.Events)
+	}
+
+	res, err := c.BulkDeactivate(ctx, "backend", []string{"u2", "u3", "nobody"})
+	if err != nil || res.DryRun {
+		t.Fatalf("res=%+v err=%v", res, err)
+	}
+	res.DryRun = true
+	if !reflect.DeepEqual(plan, res) {
+		t.Fatalf("the run differs from its plan:\nplan %+v\nrun  %+v", plan, res)
+	}
+}
+
+func TestOpenReviews_Batch(t *testing.T) {
+	forEachStore(t, testOpenReviews_Batch)
+}
+
+func testOpenReviews_Batch(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	srv.CreatePR(t, testkit.NewPR("pr-2", "u1").MergedAfter(time.Hour))
+	c := srv.Client()
+	ctx := context.Background()
+
+	got, err := c.OpenReviews(ctx, []string{"u1", "u2", "u3", "nobody"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"u1": {}, "u2": {"pr-1"}, "u3": {"pr-1"}, "nobody": {}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for uid, ids := range want {
+		prs, ok := got[uid]
+		if !ok || len(prs) != len(ids) {
+			t.Fatalf("%s: got %+v, want %v", uid, prs, ids)
+		}
+		for i, pr := range prs {
+			if pr.ID != ids[i] || pr.Status != domain.StatusOPEN {
+				t.Fatalf("%s: got %+v, want %v", uid, prs, ids)
+			}
+		}
+	}
+}
+
+func TestListTeamPRs(t *testing.T) {
+	forEachStore(t, testListTeamPRs)
+}
+
+func testListTeamPRs(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	srv.CreatePR(t, testkit.NewPR("pr-2", "u2").MergedAfter(time.Hour))
+	// Carol moves to platform and keeps reviewing pr-1 and pr-2
+	srv.AddTeam(t, testkit.NewTeam("platform").Member("u3", "Carol").Member("p1", "Paul"))
+	srv.CreatePR(t, testkit.NewPR("pr-3", "p1"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	ids := func(prs []domain.PullRequestShort) []string {
+		out := []string{}
+		for _, pr := range prs {
+			out = append(out, pr.ID)
+		}
+		return out
+	}
+	cases := []struct {
+		team      string
+		reviewing bool
+		status    domain.PRStatus
+		want      []string
+	}{
+		{team: "backend", want: []string{"pr-1", "pr-2"}},
+		{team: "backend", status: domain.StatusOPEN, want: []string{"pr-1"}},
+		{team: "platform", want: []string{"pr-3"}},
+		{team: "platform", reviewing: true, want: []string{"pr-1", "pr-2", "pr-3"}},
+		{team: "platform", reviewing: true, status: domain.StatusMERGED, want: []string{"pr-2"}},
+	}
+	for _, tc := range cases {
+		prs, _, err := c.ListTeamPRs(ctx, tc.team, tc.reviewing, tc.status, client.Page{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(prs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s reviewing=%v status=%q: got %v, want %v", tc.team, tc.reviewing, tc.status, got, tc.want)
+		}
+	}
+	if _, _, err := c.ListTeamPRs(ctx, "nope", false, "", client.Page{}); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("unknown team: %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	forEachStore(t, testSearch)
+}
+
+func testSearch(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("search").Member("u1", "Sam").Member("u2", "Bob"))
+	srv.AddTeam(t, testkit.NewTeam("frontend").Member("f1", "Research bot").Member("f2", "Eve"))
+	srv.CreatePR(t, testkit.NewPR("pr-1", "u1").Name("Add search"))
+	srv.CreatePR(t, testkit.NewPR("pr-2", "f1").Name("Searchable 50%_off"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	type hit struct{ Type, ID string }
+	hits := func(res []domain.SearchResult) []hit {
+		out := []hit{}
+		for _, r := range res {
+			out = append(out, hit{r.Type, r.ID})
+		}
+		return out
+	}
+	cases := []struct {
+		text  string
+		types []string
+		limit int
+		want  []hit
+	}{
+		// exact match, then prefixes, then substrings; by type and name
+		{text: "SEARCH", want: []hit{{"team", "search"}, {"pull_request", "pr-2"}, {"user", "f1"}, {"pull_request", "pr-1"}}},
+		{text: "search", types: []string{"user", "pull_request"}, limit: 2, want: []hit{{"pull_request", "pr-2"}, {"user", "f1"}}},
+		{text: "u1", want: []hit{{"user", "u1"}}},
+		// LIKE wildcards are matched literally
+		{text: "%_", want: []hit{{"pull_request", "pr-2"}}},
+		{text: "nothing", want: []hit{}},
+	}
+	for _, tc := range cases {
+		res, err := c.Search(ctx, tc.text, tc.types, tc.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hits(res); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q %v: got %v, want %v", tc.text, tc.types, got, tc.want)
+		}
+	}
+	if _, err := c.Search(ctx, "x", []string{"repo"}, 0); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("unknown type: %v", err)
+	}
+
+	// a team-scoped token finds nothing of other teams
+	tok, err := c.IssueToken(ctx, client.IssueTokenRequest{UserID: "u1", Role: "user", Teams: []string{"search"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.New(srv.URL, client.WithToken(tok.Token)).Search(ctx, "search", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hits(res), []hit{{"team", "search"}, {"pull_request", "pr-1"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("scoped: got %v, want %v", got, want)
+	}
+}
+
+func TestPollAssignments(t *testing.T) {
+	forEachStore(t, testPollAssignments)
+}
+
+func testPollAssignments(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	first, err := c.PollAssignments(ctx, "u2", "", time.Minute)
+	if err != nil || !first.Changed || len(first.PRs) != 0 || first.Cursor == "" {
+		t.Fatalf("first=%+v err=%v", first, err)
+	}
+
+	// a write on the instance wakes the waiting poll long before its timeout
+	done := make(chan *client.AssignmentPoll)
+	go func() {
+		p, err := c.PollAssignments(ctx, "u2", first.Cursor, 20*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- p
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Add search", AuthorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	p := <-done
+	if p == nil || !p.Changed || len(p.PRs) != 1 || p.PRs[0].ID != "pr-1" || p.Cursor == first.Cursor {
+		t.Fatalf("woken poll=%+v", p)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Fatalf("poll returned after %s", waited)
+	}
+
+	// nothing changes: the poll times out with the same cursor
+	same, err := c.PollAssignments(ctx, "u2", p.Cursor, time.Second)
+	if err != nil || same.Changed || same.Cursor != p.Cursor || len(same.PRs) != 1 {
+		t.Fatalf("same=%+v err=%v", same, err)
+	}
+}
+
+func TestManualAssignment(t *testing.T) {
+	forEachStore(t, testManualAssignment)
+}
+
+func testManualAssignment(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").ManualAssignment())
+	srv.AddTeam(t, testkit.NewTeam("frontend").Member("f1", "Eve").Inactive("f2", "Frank"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	team, err := c.GetTeam(ctx, "backend")
+	if err != nil || !team.ManualAssignment {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Add search", AuthorID: "u1"})
+	if err != nil || pr.AssignedReviewers == nil || len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("create on a manual team: pr=%+v err=%v", pr, err)
+	}
+
+	for _, tc := range []struct {
+		userID string
+		want   error
+	}{
+		{"u1", client.ErrInvalid}, // the author
+		{"f2", client.ErrInvalid}, // inactive
+		{"nobody", client.ErrNotFound},
+		{"u2", nil},
+		{"u2", client.ErrInvalid}, // already assigned
+		{"f1", nil},               // any team
+		{"u3", client.ErrInvalid}, // two reviewers already
+	} {
+		if _, err := c.AddReviewer(ctx, "pr-1", tc.userID, ""); !errors.Is(err, tc.want) {
+			t.Fatalf("add %s: %v, want %v", tc.userID, err, tc.want)
+		}
+	}
+	tl, err := c.PRTimeline(ctx, "pr-1")
+	if err != nil || len(tl.Events) != 3 || tl.Events[1].Kind != "assigned" || tl.Events[1].Reason != "manual" {
+		t.Fatalf("timeline=%+v err=%v", tl, err)
+	}
+
+	// deactivation removes the assignment and picks no replacement
+	res, err := c.BulkDeactivate(ctx, "backend", []string{"u2"})
+	if err != nil || len(res.Reassignments) != 1 || res.Reassignments[0].Action != "removed" {
+		t.Fatalf("deactivate=%+v err=%v", res, err)
+	}
+	if nc, err := c.NoCandidateStats(ctx, client.DateRange{}); err != nil || nc.Total != 0 {
+		t.Fatalf("no candidate=%+v err=%v", nc, err)
+	}
+
+	// back to automatic selection
+	if _, err := c.SetTeamManualAssignment(ctx, "backend", false); err != nil {
+		t.Fatal(err)
+	}
+	pr, err = c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "Add filters", AuthorID: "u1"})
+	if err != nil || len(pr.AssignedReviewers) != 1 || pr.AssignedReviewers[0] != "u3" {
+		t.Fatalf("create after turning manual off: pr=%+v err=%v", pr, err)
+	}
+}
+
+func TestTransferAuthor(t *testing.T) {
+	forEachStore(t, testTransferAuthor)
+}
+
+func testTransferAuthor(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	srv.AddTeam(t, testkit.NewTeam("ops").Member("o1", "Olga").Member("o2", "Oleg").ManualAssignment())
+	srv.AddTeam(t, testkit.NewTeam("frontend").Inactive("f1", "Eve"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	reviewer := pr.AssignedReviewers[0]
+	moved, err := c.TransferAuthor(ctx, "pr-1", reviewer, pr.ETag())
+	if err != nil || moved.AuthorID != reviewer || len(moved.AssignedReviewers) != 2 {
+		t.Fatalf("transfer: pr=%+v err=%v", moved, err)
+	}
+	for _, id := range moved.AssignedReviewers {
+		if id == reviewer || id == "u1" {
+			t.Fatalf("an author reviews the PR: %v", moved.AssignedReviewers)
+		}
+	}
+	tl, _ := c.PRTimeline(ctx, "pr-1")
+	if n := len(tl.Events); n < 2 || tl.Events[n-2].Kind != "author_changed" || tl.Events[n-2].ReplacedBy != reviewer ||
+		tl.Events[n-1].Kind != "replaced" || tl.Events[n-1].Reason != "author_transfer" {
+		t.Fatalf("timeline=%+v", tl.Events)
+	}
+
+	// on a manual team the reviewer who becomes the author is only removed
+	srv.CreatePR(t, testkit.NewPR("pr-2", "o1"))
+	if _, err := c.AddReviewer(ctx, "pr-2", "o2", ""); err != nil {
+		t.Fatal(err)
+	}
+	moved, err = c.TransferAuthor(ctx, "pr-2", "o2", "")
+	if err != nil || moved.AuthorID != "o2" || len(moved.AssignedReviewers) != 0 {
+		t.Fatalf("manual team: pr=%+v err=%v", moved, err)
+	}
+
+	for _, tc := range []struct {
+		pr, author, etag string
+		want             error
+	}{
+		{"pr-1", "f1", "", client.ErrInvalid}, // inactive
+		{"pr-1", "nobody", "", client.ErrNotFound},
+		{"pr-1", "u4", pr.ETag(), client.ErrPreconditionFailed},
+	} {
+		if _, err := c.TransferAuthor(ctx, tc.pr, tc.author, tc.etag); !errors.Is(err, tc.want) {
+			t.Fatalf("transfer %s to %s: %v, want %v", tc.pr, tc.author, err, tc.want)
+		}
+	}
+	if _, err := c.MergePR(ctx, "pr-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.TransferAuthor(ctx, "pr-1", "u4", ""); !errors.Is(err, client.ErrPRMerged) {
+		t.Fatalf("merged: %v", err)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	forEachStore(t, testUpdateUser)
+}
+
+func testUpdateUser(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Inactive("u2", "Bob"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	name := "Robert"
+	u, err := c.UpdateUser(ctx, "u2", domain.UserUpdate{Username: &name})
+	if err != nil || *u != (domain.User{UserID: "u2", Username: "Robert", TeamName: "backend"}) {
+		t.Fatalf("user=%+v err=%v", u, err)
+	}
+	team, _ := c.GetTeam(ctx, "backend")
+	if m := team.Members[1]; m.Username != "Robert" || m.IsActive {
+		t.Fatalf("member=%+v", m)
+	}
+	if _, err := c.UpdateUser(ctx, "u1", domain.UserUpdate{}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("empty update: %v", err)
+	}
+	if _, err := c.UpdateUser(ctx, "nobody", domain.UserUpdate{Username: &name}); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("unknown user: %v", err)
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	forEachStore(t, testMergeRules)
+}
+
+func testMergeRules(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	if _, err := c.SetTeamMergeRules(ctx, "backend", domain.MergeRules{MinApprovals: 3}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("3 approvals: %v", err)
+	}
+	if _, err := c.SetTeamMergeRules(ctx, "nope", domain.MergeRules{}); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("unknown team: %v", err)
+	}
+	rules := domain.MergeRules{MinApprovals: 1, BlockOnChangesRequested: true, MinAgeSeconds: 3600}
+	team, err := c.SetTeamMergeRules(ctx, "backend", rules)
+	if err != nil || team.MergeRules == nil || *team.MergeRules != rules {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Add search", AuthorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockedBy := func(code *client.Error, rule string) {
+		t.Helper()
+		_, err := c.MergePR(ctx, "pr-1")
+		var e *client.Error
+		if !errors.Is(err, code) || !errors.As(err, &e) || !strings.HasPrefix(e.Message, rule+": ") {
+			t.Fatalf("merge: %v, want %s by %s", err, code.Code, rule)
+		}
+	}
+	blockedBy(client.ErrMergeBlocked, domain.RuleMinAge)
+	srv.Clock.Advance(time.Hour)
+	blockedBy(client.ErrNotApproved, domain.RuleMinApprovals)
+
+	// Decisions count towards the rules: shared tokens without a user cannot
+	// make them, personal ones only for their own user.
+	reviewer := pr.AssignedReviewers[0]
+	if _, err := srv.UserClient().ApproveReview(ctx, "pr-1", reviewer); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("shared user token approves: %v", err)
+	}
+	tok, err := c.IssueToken(ctx, client.IssueTokenRequest{UserID: reviewer, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := client.New(srv.URL, client.WithToken(tok.Token))
+	if _, err := own.ApproveReview(ctx, "pr-1", pr.AssignedReviewers[1]); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("approve for another reviewer: %v", err)
+	}
+	if _, err := own.RequestChanges(ctx, "pr-1", reviewer); err != nil {
+		t.Fatal(err)
+	}
+	blockedBy(client.ErrMergeBlocked, domain.RuleNoChangesRequested)
+	approved, err := c.ApproveReview(ctx, "pr-1", reviewer)
+	if err != nil || len(approved.Reviews) != 2 {
+		t.Fatalf("approve: pr=%+v err=%v", approved, err)
+	}
+	for _, rv := range approved.Reviews {
+		if want := rv.UserID == reviewer; (rv.State == domain.ReviewApproved) != want || (rv.DecidedAt != nil) != want {
+			t.Fatalf("reviews=%+v, want only %s approved", approved.Reviews, reviewer)
+		}
+	}
+	if _, err := c.ApproveReview(ctx, "pr-1", "u1"); !errors.Is(err, client.ErrNotAssigned) {
+		t.Fatalf("author approves: %v", err)
+	}
+
+	if _, err := c.SetTeamMergeRules(ctx, "backend", domain.MergeRules{MinApprovals: 1, RequireLeadApproval: true}); err != nil {
+		t.Fatal(err)
+	}
+	blockedBy(client.ErrMergeBlocked, domain.RuleLeadApproval) // the team has no lead
+
+	team, err = c.SetTeamMergeRules(ctx, "backend", domain.MergeRules{})
+	if err != nil || team.MergeRules != nil {
+		t.Fatalf("cleared: team=%+v err=%v", team, err)
+	}
+	if pr, err := c.MergePR(ctx, "pr-1"); err != nil || pr.Status != domain.StatusMERGED {
+		t.Fatalf("merge without rules: pr=%+v err=%v", pr, err)
+	}
+}
+
+func TestEscalations(t *testing.T) {
+	forEachStore(t, testEscalations)
+}
+
+func testEscalations(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").
+		Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave").Lead("u1"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	if _, err := c.SetTeamEscalation(ctx, "backend", domain.EscalationPolicy{NotifyLeadDays: 3, AddReviewerDays: 1}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("add before notify: %v", err)
+	}
+	policy := domain.EscalationPolicy{NotifyLeadDays: 1, AddReviewerDays: 3}
+	team, err := c.SetTeamEscalation(ctx, "backend", policy)
+	if err != nil || team.Escalation == nil || *team.Escalation != policy {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Stalled", AuthorID: "u2"})
+	if err != nil || len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+	acked, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "Acknowledged", AuthorID: "u2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AcknowledgeReview(ctx, "pr-2", acked.AssignedReviewers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(want ...string) []domain.Escalation {
+		t.Helper()
+		taken, err := c.RunEscalations(ctx)
+		if err != nil || len(taken) != len(want) {
+			t.Fatalf("escalations=%+v err=%v, want %v", taken, err, want)
+		}
+		for i, e := range taken {
+			if e.Step != want[i] || e.PRID != "pr-1" {
+				t.Fatalf("escalation %d = %+v, want %s on pr-1", i, e, want[i])
+			}
+		}
+		return taken
+	}
+	run()
+	srv.Clock.Advance(24 * time.Hour)
+	if e := run(domain.EscalationNotifyLead); e[0].LeadUserID != "u1" {
+		t.Fatalf("notified %+v", e[0])
+	}
+	run() // each step once
+	srv.Clock.Advance(48 * time.Hour)
+	e := run(domain.EscalationAddReviewer)
+	if e[0].ReviewerID == "" || slices.Contains(pr.AssignedReviewers, e[0].ReviewerID) || e[0].ReviewerID == "u2" {
+		t.Fatalf("added %+v to %v", e[0], pr.AssignedReviewers)
+	}
+	if pr, err = c.GetPR(ctx, "pr-1"); err != nil || len(pr.AssignedReviewers) != 3 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+
+	tl, err := c.PRTimeline(ctx, "pr-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var escalated []string
+	for _, ev := range tl.Events {
+		if ev.Kind == domain.PREventEscalated {
+			escalated = append(escalated, ev.Reason+":"+ev.UserID)
+		}
+	}
+	if want := []string{"notify_lead:u1", "add_reviewer:" + e[0].ReviewerID}; !reflect.DeepEqual(escalated, want) {
+		t.Fatalf("escalated events %v, want %v", escalated, want)
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	forEachStore(t, testWorkingHours)
+}
+
+func testWorkingHours(t *testing.T, store testkit.Option) {
+	friday := time.Date(2025, 3, 7, 16, 0, 0, 0, time.UTC) // 19:00 in Moscow
+	srv := testkit.Start(t, store, testkit.WithTime(friday))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	if _, err := c.SetTeamWorkingHours(ctx, "backend", domain.WorkingHours{TimeZone: "Europe/Moscow", StartHour: 18, EndHour: 9}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("inverted hours: %v", err)
+	}
+	hours := domain.WorkingHours{TimeZone: "Europe/Moscow", StartHour: 9, EndHour: 18}
+	team, err := c.SetTeamWorkingHours(ctx, "backend", hours)
+	if err != nil || team.WorkingHours == nil || *team.WorkingHours != hours {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+
+	// after hours the reviewers are assigned but do not see the PR yet
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Friday evening", AuthorID: "u1"})
+	if err != nil || len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+	if got, err := c.OpenReviews(ctx, []string{"u2", "u3"}); err != nil || len(got["u2"]) != 0 || len(got["u3"]) != 0 {
+		t.Fatalf("reviews before delivery=%+v err=%v", got, err)
+	}
+	if delivered, err := srv.Service.DeliverDeferredReviews(context.Background()); err != nil || len(delivered) != 0 {
+		t.Fatalf("delivered on friday=%+v err=%v", delivered, err)
+	}
+
+	monday := time.Date(2025, 3, 10, 6, 0, 0, 0, time.UTC) // 09:00 in Moscow
+	srv.Clock.Set(monday)
+	if got, err := c.OpenReviews(ctx, []string{"u2"}); err != nil || len(got["u2"]) != 1 {
+		t.Fatalf("reviews on monday=%+v err=%v", got, err)
+	}
+	delivered, err := srv.Service.DeliverDeferredReviews(context.Background())
+	if err != nil || len(delivered) != 2 || !delivered[0].DeliverAt.Equal(monday) {
+		t.Fatalf("delivered=%+v err=%v", delivered, err)
+	}
+	tl, err := c.PRTimeline(ctx, "pr-1")
+	if err != nil || tl.Events[len(tl.Events)-1].Kind != domain.PREventDelivered {
+		t.Fatalf("timeline=%+v err=%v", tl, err)
+	}
+
+	// within working hours assignments are delivered at once
+	srv.Clock.Advance(time.Hour)
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "Monday morning", AuthorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.OpenReviews(ctx, []string{"u2"}); err != nil || len(got["u2"]) != 2 {
+		t.Fatalf("reviews=%+v err=%v", got, err)
+	}
+	if delivered, err := srv.Service.DeliverDeferredReviews(context.Background()); err != nil || len(delivered) != 0 {
+		t.Fatalf("delivered=%+v err=%v", delivered, err)
+	}
+}
+
+func TestHolidays(t *testing.T) {
+	forEachStore(t, testHolidays)
+}
+
+func testHolidays(t *testing.T, store testkit.Option) {
+	thursday := time.Date(2025, 5, 8, 10, 0, 0, 0, time.UTC)
+	srv := testkit.Start(t, store, testkit.WithTime(thursday))
+	srv.AddTeam(t, testkit.NewTeam("backend").
+		Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave").Lead("u1"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	victory := domain.Holiday{Date: "2025-05-09", Name: "Victory Day"}
+	if _, err := c.SetTeamHolidays(ctx, "backend", []domain.Holiday{victory, victory}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("duplicate holiday: %v", err)
+	}
+	if _, err := c.SetTeamHolidays(ctx, "backend", []domain.Holiday{victory}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.TeamHolidays(ctx, "backend"); err != nil || len(got) != 1 || got[0] != victory {
+		t.Fatalf("holidays=%+v err=%v", got, err)
+	}
+	if _, err := c.SetTeamEscalation(ctx, "backend", domain.EscalationPolicy{NotifyLeadDays: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Before the holiday", AuthorID: "u2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// assignments made on the holiday are queued until the day after
+	srv.Clock.Set(time.Date(2025, 5, 9, 12, 0, 0, 0, time.UTC))
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "On the holiday", AuthorID: "u2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the holiday does not count towards SLA waits and escalation deadlines
+	srv.Clock.Set(time.Date(2025, 5, 9, 23, 0, 0, 0, time.UTC))
+	report, err := c.SLABreaches(ctx, client.SLABreachParams{ReviewSLA: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, team := range report.Teams {
+		if team.ReviewBreaches != 0 {
+			t.Fatalf("breaches over the holiday: %+v", team)
+		}
+	}
+	if taken, err := c.RunEscalations(ctx); err != nil || len(taken) != 0 {
+		t.Fatalf("escalations over the holiday=%+v err=%v", taken, err)
+	}
+
+	saturday := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+	srv.Clock.Set(saturday)
+	delivered, err := srv.Service.DeliverDeferredReviews(context.Background())
+	if err != nil || len(delivered) != len(pr.AssignedReviewers) || !delivered[0].DeliverAt.Equal(saturday) {
+		t.Fatalf("delivered=%+v err=%v", delivered, err)
+	}
+	srv.Clock.Set(saturday.Add(10 * time.Hour))
+	taken, err := c.RunEscalations(ctx)
+	if err != nil || len(taken) != 1 || taken[0].PRID != "pr-1" || taken[0].Step != domain.EscalationNotifyLead {
+		t.Fatalf("escalations=%+v err=%v", taken, err)
+	}
+}
+
+func TestPRDependencies(t *testing.T) {
+	forEachStore(t, testPRDependencies)
+}
+
+func testPRDependencies(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Lead("u1"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	for _, id := range []string{"pr-1", "pr-2"} {
+		if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: id, Name: id, AuthorID: "u2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.AddPRDependency(ctx, "pr-1", "pr-1", ""); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("self dependency: %v", err)
+	}
+	pr, err := c.AddPRDependency(ctx, "pr-1", "pr-2", "")
+	if err != nil || !slices.Equal(pr.BlockedBy, []string{"pr-2"}) {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+	if _, err := c.AddPRDependency(ctx, "pr-2", "pr-1", ""); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("cycle: %v", err)
+	}
+
+	// the blocked PR comes last for its reviewers and is not escalated
+	order := func(prs []domain.PullRequestShort) string {
+		var ids []string
+		for _, p := range prs {
+			id := p.ID
+			if p.Blocked {
+				id += "(blocked)"
+			}
+			ids = append(ids, id)
+		}
+		return strings.Join(ids, ",")
+	}
+	if got, err := c.OpenReviews(ctx, []string{"u1"}); err != nil || order(got["u1"]) != "pr-2,pr-1(blocked)" {
+		t.Fatalf("open reviews=%v err=%v", order(got["u1"]), err)
+	}
+	if got, _, err := c.UserReviewsPage(ctx, "u3", client.Page{}); err != nil || order(got) != "pr-2,pr-1(blocked)" {
+		t.Fatalf("reviews=%v err=%v", order(got), err)
+	}
+	if _, err := c.SetTeamEscalation(ctx, "backend", domain.EscalationPolicy{NotifyLeadDays: 1}); err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock.Advance(25 * time.Hour)
+	if taken, err := c.RunEscalations(ctx); err != nil || len(taken) != 1 || taken[0].PRID != "pr-2" {
+		t.Fatalf("escalations=%+v err=%v", taken, err)
+	}
+
+	// a closed dependency still blocks: it may be reopened and merged
+	if _, err := c.ClosePR(ctx, "pr-2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := c.UserReviewsPage(ctx, "u3", client.Page{}); err != nil || !strings.Contains(order(got), "pr-1(blocked)") {
+		t.Fatalf("reviews after close=%v err=%v", order(got), err)
+	}
+	if taken, err := c.RunEscalations(ctx); err != nil || len(taken) != 0 {
+		t.Fatalf("escalations after close=%+v err=%v", taken, err)
+	}
+	if _, err := c.ReopenPR(ctx, "pr-2", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// merging the dependency unblocks the PR
+	if _, err := c.MergePR(ctx, "pr-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.OpenReviews(ctx, []string{"u1"}); err != nil || order(got["u1"]) != "pr-1" {
+		t.Fatalf("open reviews=%v err=%v", order(got["u1"]), err)
+	}
+	if taken, err := c.RunEscalations(ctx); err != nil || len(taken) != 1 || taken[0].PRID != "pr-1" {
+		t.Fatalf("escalations=%+v err=%v", taken, err)
+	}
+	if pr, err := c.RemovePRDependency(ctx, "pr-1", "pr-2", ""); err != nil || len(pr.BlockedBy) != 0 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+	if _, err := c.RemovePRDependency(ctx, "pr-1", "pr-2", ""); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("removed twice: %v", err)
+	}
+}
+
+func TestPRWatchers(t *testing.T) {
+	forEachStore(t, testPRWatchers)
+}
+
+func testPRWatchers(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	srv.AddTeam(t, testkit.NewTeam("frontend").Member("f1", "Frank"))
+	var mu sync.Mutex
+	var got []domain.WatchNotification
+	srv.Service.WithWatchNotifier(func(n domain.WatchNotification) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, n)
+	})
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Watched", AuthorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "Unwatched", AuthorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.WatchPR(ctx, "pr-1", pr.AssignedReviewers[0]); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("reviewer watching: %v", err)
+	}
+	for range 2 {
+		if pr, err = c.WatchPR(ctx, "pr-1", "f1"); err != nil || !slices.Equal(pr.Watchers, []string{"f1"}) {
+			t.Fatalf("pr=%+v err=%v", pr, err)
+		}
+	}
+
+	old := pr.AssignedReviewers[0]
+	_, replacedBy, err := c.Reassign(ctx, "pr-1", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr2, err := c.GetPR(ctx, "pr-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Reassign(ctx, "pr-2", pr2.AssignedReviewers[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MergePR(ctx, "pr-1"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("notifications=%+v", got)
+	}
+	if n := got[0]; n.PRID != "pr-1" || n.Event.Kind != domain.PREventReplaced || n.Event.UserID != old ||
+		n.Event.ReplacedBy != replacedBy || !slices.Equal(n.Watchers, []string{"f1"}) {
+		t.Fatalf("reassignment notification=%+v", n)
+	}
+	if n := got[1]; n.PRID != "pr-1" || n.Event.Kind != domain.PREventMerged || n.Event.At.IsZero() {
+		t.Fatalf("merge notification=%+v", n)
+	}
+	if pr, err := c.UnwatchPR(ctx, "pr-1", "f1"); err != nil || len(pr.Watchers) != 0 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+}
+
+func TestPRActivity(t *testing.T) {
+	forEachStore(t, testPRActivity)
+}
+
+func testPRActivity(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Feed", AuthorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock.Advance(time.Hour)
+	if _, err := c.AcknowledgeReview(ctx, "pr-1", pr.AssignedReviewers[0]); err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock.Advance(time.Hour)
+	if _, err := c.MergePR(ctx, "pr-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds, types []string
+	p := client.Page{Sort: "-at", Limit: 2}
+	for pages := 0; ; pages++ {
+		a, err := c.PRActivity(ctx, "pr-1", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Status != domain.StatusMERGED || len(a.Items) > 2 || pages > 3 {
+			t.Fatalf("page %d: %+v", pages, a)
+		}
+		for _, it := range a.Items {
+			kinds, types = append(kinds, it.Kind), append(types, it.Type)
+		}
+		if p.Cursor = a.Page.NextCursor; p.Cursor == "" {
+			break
+		}
+	}
+	wantKinds := []string{"merged", "acknowledged", "assigned", "assigned", "created"}
+	wantTypes := []string{"status", "approvals", "reviewers", "reviewers", "status"}
+	if !slices.Equal(kinds, wantKinds) || !slices.Equal(types, wantTypes) {
+		t.Fatalf("kinds=%v types=%v", kinds, types)
+	}
+	if _, err := c.PRActivity(ctx, "pr-1", client.Page{Sort: "at", Cursor: p.Cursor + "x"}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("bad cursor: %v", err)
+	}
+	if _, err := c.PRActivity(ctx, "nope", client.Page{}); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("unknown PR: %v", err)
+	}
+}
+
+func TestPRComments(t *testing.T) {
+	forEachStore(t, testPRComments)
+}
+
+func testPRComments(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Commented", AuthorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "Other", AuthorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock.Advance(time.Hour)
+	first, err := c.AddComment(ctx, "pr-1", "u2", "Reviewed offline, LGTM", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock.Advance(time.Minute)
+	reply, err := c.AddComment(ctx, "pr-1", "u1", "Thanks", &first.ID)
+	if err != nil || reply.ReplyTo == nil || *reply.ReplyTo != first.ID || reply.AuthorID != "u1" {
+		t.Fatalf("reply=%+v err=%v", reply, err)
+	}
+	if _, err := c.AddComment(ctx, "pr-2", "u2", "Wrong PR", &first.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("reply across PRs: %v", err)
+	}
+	if _, err := c.AddComment(ctx, "pr-1", "u2", "  ", nil); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("blank comment: %v", err)
+	}
+	if _, err := c.AddComment(ctx, "nope", "u2", "Hi", nil); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("unknown PR: %v", err)
+	}
+
+	comments, page, err := c.Comments(ctx, "pr-1", client.Page{Sort: "-created_at", Limit: 1})
+	if err != nil || len(comments) != 1 || comments[0].ID != reply.ID || page.NextCursor == "" {
+		t.Fatalf("comments=%+v page=%+v err=%v", comments, page, err)
+	}
+	comments, page, err = c.Comments(ctx, "pr-1", client.Page{Sort: "-created_at", Limit: 1, Cursor: page.NextCursor})
+	if err != nil || len(comments) != 1 || comments[0].Text != "Reviewed offline, LGTM" || page.NextCursor != "" {
+		t.Fatalf("comments=%+v page=%+v err=%v", comments, page, err)
+	}
+
+	a, err := c.PRActivity(ctx, "pr-1", client.Page{Sort: "-at", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Items) != 2 || a.Items[0].Type != domain.ActivityComments || a.Items[0].Comment == nil ||
+		a.Items[0].Comment.ID != reply.ID || a.Items[1].UserID != "u2" {
+		t.Fatalf("activity=%+v", a.Items)
+	}
+}
+
+func TestPreferredReviewers(t *testing.T) {
+	forEachStore(t, testPreferredReviewers)
+}
+
+func testPreferredReviewers(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").
+		Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Inactive("u4", "Dave").Member("u5", "Erin"))
+	srv.AddTeam(t, testkit.NewTeam("frontend").Member("f1", "Frank"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr, rejected, err := c.CreatePRPreferring(ctx, client.CreatePRRequest{
+		ID: "pr-1", Name: "Suggested", AuthorID: "u1",
+		PreferredReviewers: []string{"u4", "f1", "u3", "u3", "ghost", "u1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pr.AssignedReviewers) != 2 || pr.AssignedReviewers[0] != "u3" && pr.AssignedReviewers[1] != "u3" {
+		t.Fatalf("reviewers=%v", pr.AssignedReviewers)
+	}
+	want := []domain.RejectedReviewer{
+		{UserID: "u4", Reason: domain.RejectInactive},
+		{UserID: "f1", Reason: domain.RejectOtherTeam},
+		{UserID: "u3", Reason: domain.RejectDuplicate},
+		{UserID: "ghost", Reason: domain.RejectNotFound},
+		{UserID: "u1", Reason: domain.RejectAuthor},
+	}
+	if !slices.Equal(rejected, want) {
+		t.Fatalf("rejected=%+v", rejected)
+	}
+
+	pr, rejected, err = c.CreatePRPreferring(ctx, client.CreatePRRequest{
+		ID: "pr-2", Name: "Full", AuthorID: "u1", PreferredReviewers: []string{"u5", "u2", "u3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(pr.AssignedReviewers)
+	if !slices.Equal(pr.AssignedReviewers, []string{"u2", "u5"}) ||
+		!slices.Equal(rejected, []domain.RejectedReviewer{{UserID: "u3", Reason: domain.RejectNoSlot}}) {
+		t.Fatalf("reviewers=%v rejected=%+v", pr.AssignedReviewers, rejected)
+	}
+	tl, err := c.PRTimeline(ctx, "pr-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range tl.Events {
+		if e.Kind == domain.PREventAssigned && e.Reason != domain.ReasonPreferred {
+			t.Fatalf("event=%+v", e)
+		}
+	}
+
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{
+		ID: "pr-3", Name: "Too many", AuthorID: "u1", PreferredReviewers: make([]string, 11),
+	}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("too many suggestions: %v", err)
+	}
+}
+
+func TestReviewCooldown(t *testing.T) {
+	forEachStore(t, testReviewCooldown)
+}
+
+func testReviewCooldown(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.Service.WithReviewCooldown(48 * time.Hour)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").
+		Member("u4", "Dave").Member("u5", "Erin").Member("u6", "Fay").Member("u7", "Gus"))
+	ctx := context.Background()
+
+	seen := map[string]bool{}
+	for _, id := range []string{"pr-1", "pr-2", "pr-3"} {
+		pr := srv.CreatePR(t, testkit.NewPR(id, "u1").Merged())
+		for _, r := range pr.AssignedReviewers {
+			if seen[r] {
+				t.Fatalf("%s: %s reviewed for u1 during the cooldown", id, r)
+			}
+			seen[r] = true
+		}
+		srv.Clock.Advance(time.Hour)
+	}
+
+	// Every candidate is in cooldown now, and they are picked anyway.
+	pr, err := srv.Client().CreatePR(ctx, client.CreatePRRequest{ID: "pr-4", Name: "Fourth", AuthorID: "u1"})
+	if err != nil || len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+}
+
+func TestPairingLimit(t *testing.T) {
+	forEachStore(t, testPairingLimit)
+}
+
+func testPairingLimit(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.Service.WithPairingLimit(1, 24*time.Hour)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").
+		Member("u4", "Dave").Member("u5", "Erin").Member("u6", "Fay").Member("u7", "Gus"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	seen := map[string]bool{}
+	for _, id := range []string{"pr-1", "pr-2", "pr-3"} {
+		pr := srv.CreatePR(t, testkit.NewPR(id, "u1"))
+		for _, r := range pr.AssignedReviewers {
+			if seen[r] {
+				t.Fatalf("%s: u1 and %s paired again within the window", id, r)
+			}
+			seen[r] = true
+		}
+	}
+	// Everyone has reached the limit, which then gives way.
+	if pr := srv.CreatePR(t, testkit.NewPR("pr-4", "u1")); len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("pr=%+v", pr)
+	}
+
+	rep, err := c.PairingStats(ctx, client.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Assignments != 8 || rep.MaxRepeats != 1 || len(rep.ByAuthor) != 1 || rep.ByAuthor[0].Reviewers != 6 ||
+		rep.TopPairs[0].Assignments != 2 || rep.ByAuthor[0].TopShare != 0.25 {
+		t.Fatalf("report=%+v", rep)
+	}
+}
+
+func TestCapacityWarnings(t *testing.T) {
+	forEachStore(t, testCapacityWarnings)
+}
+
+func testCapacityWarnings(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	var (
+		mu       sync.Mutex
+		warnings []domain.CapacityWarning
+	)
+	srv.Service.WithCapacityNotifier(func(w domain.CapacityWarning) {
+		mu.Lock()
+		defer mu.Unlock()
+		warnings = append(warnings, w)
+	})
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	srv.AddTeam(t, testkit.NewTeam("manual").ManualAssignment().Member("m1", "Mia"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	team, err := c.GetTeam(ctx, "backend")
+	if err != nil || team.CapacityWarning != nil {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+	if _, err := c.SetUserActive(ctx, "u3", false); err != nil {
+		t.Fatal(err)
+	}
+	want := domain.CapacityWarning{TeamName: "backend", ActiveMembers: 2, Required: 3}
+	if team, err = c.GetTeam(ctx, "backend"); err != nil || team.CapacityWarning == nil || *team.CapacityWarning != want {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+	res, err := c.CreatePRDetailed(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Fix", AuthorID: "u1"})
+	if err != nil || res.CapacityWarning == nil || *res.CapacityWarning != want {
+		t.Fatalf("res=%+v err=%v", res, err)
+	}
+	// Deactivating an inactive user again is not news.
+	if _, err := c.SetUserActive(ctx, "u3", false); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	got := slices.Clone(warnings)
+	mu.Unlock()
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("warnings=%+v", got)
+	}
+
+	// Teams with manual assignment need no reviewers to pick from.
+	if team, err = c.GetTeam(ctx, "manual"); err != nil || team.CapacityWarning != nil {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+}
+
+func TestRebalancing(t *testing.T) {
+	forEachStore(t, testRebalancing)
+}
+
+func testRebalancing(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("busy").Member("b1", "Ann").Member("b2", "Ben").Member("b3", "Cid"))
+	srv.AddTeam(t, testkit.NewTeam("calm").Member("c1", "Dan").Member("c2", "Eve").Member("c3", "Fox").
+		Member("c4", "Gil").Member("c5", "Hal"))
+	srv.AddTeam(t, testkit.NewTeam("tiny").Member("t1", "Ida").Member("t2", "Jon"))
+	for _, id := range []string{"pr-1", "pr-2", "pr-3", "pr-4"} {
+		srv.CreatePR(t, testkit.NewPR(id, "b1"))
+	}
+
+	rep, err := srv.Client().Rebalancing(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.AverageLoad != 0.8 || len(rep.Teams) != 3 || rep.Teams[0].OpenReviews != 8 {
+		t.Fatalf("report=%+v", rep)
+	}
+	want := []domain.RebalanceSuggestion{
+		{Rank: 1, Kind: domain.RebalanceMove, Reason: domain.RebalanceCapacity, ToTeam: "tiny", FromTeam: "calm", UserID: "c1",
+			Count: 1, LoadBefore: 0, LoadAfter: 0},
+		{Rank: 2, Kind: domain.RebalanceAdd, Reason: domain.RebalanceLoad, ToTeam: "busy",
+			Count: 7, LoadBefore: 8.0 / 3, LoadAfter: 0.8},
+	}
+	if !reflect.DeepEqual(rep.Suggestions, want) {
+		t.Fatalf("suggestions=%+v", rep.Suggestions)
+	}
+	// Only suggestions: nobody was moved.
+	if team, err := srv.Client().GetTeam(context.Background(), "calm"); err != nil || len(team.Members) != 5 {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	forEachStore(t, testSimulate)
+}
+
+func testSimulate(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	srv.AddTeam(t, testkit.NewTeam("manual").ManualAssignment().Member("m1", "Mia").Member("m2", "Max"))
+	c := srv.Client()
+	ctx := context.Background()
+	var first *domain.PullRequest
+	for i := 1; i <= 6; i++ {
+		pr := srv.CreatePR(t, testkit.NewPR("pr-"+strconv.Itoa(i), "u1"))
+		if first == nil {
+			first = pr
+		}
+		srv.Clock.Advance(time.Minute)
+	}
+	srv.CreatePR(t, testkit.NewPR("pr-m", "m1"))
+	srv.Clock.Advance(time.Hour)
+	if _, err := c.AcknowledgeReview(ctx, first.ID, first.AssignedReviewers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := c.Simulate(ctx, domain.StrategyRoundRobin, client.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.PRs != 6 || rep.Skipped != 1 || rep.Actual.Assignments != 12 || rep.Simulated.Assignments != 12 {
+		t.Fatalf("report=%+v", rep)
+	}
+	// Round robin over u2..u4 hands everyone the same share.
+	sim := rep.Simulated
+	if sim.Reviewers != 3 || sim.MaxAssignments != 4 || sim.PeakOpen != 4 || math.Abs(sim.Concentration-1.0/3) > 1e-9 {
+		t.Fatalf("simulated=%+v", sim)
+	}
+	if rep.Actual.MedianResponseSeconds == nil || sim.MedianResponseSeconds == nil {
+		t.Fatalf("report=%+v", rep)
+	}
+	if _, err := c.Simulate(ctx, "random", client.DateRange{}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestImportPRs(t *testing.T) {
+	forEachStore(t, testImportPRs)
+}
+
+func testImportPRs(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("bob", "Bob"))
+	github := `[
+		{"number": 12, "title": "Add cache", "state": "closed", "user": {"login": "alice"},
+		 "created_at": "2025-01-10T09:00:00Z", "merged_at": "2025-01-11T15:00:00Z",
+		 "base": {"repo": {"full_name": "octo/app"}}, "requested_reviewers": [{"login": "carol"}],
+		 "reviews": [{"user": {"login": "bob"}, "state": "APPROVED", "submitted_at": "2025-01-10T13:00:00Z"},
+		             {"user": {"login": "alice"}, "state": "COMMENTED", "submitted_at": "2025-01-10T14:00:00Z"}]},
+		{"number": 13, "title": "Abandoned", "state": "closed", "user": {"login": "alice"},
+		 "created_at": "2025-01-12T09:00:00Z", "merged_at": null, "base": {"repo": {"full_name": "octo/app"}}},
+		{"number": 14, "title": "Bump deps", "state": "open", "user": {"login": "dependabot[bot]"},
+		 "created_at": "2025-01-13T09:00:00Z", "merged_at": null, "base": {"repo": {"full_name": "octo/app"}},
+		 "requested_reviewers": [{"login": "bob"}]}
+	]`
+	prs, err := importer.Parse(importer.FormatGitHub, strings.NewReader(github))
+	if err != nil || len(prs) != 2 {
+		t.Fatalf("prs=%+v err=%v", prs, err)
+	}
+	res, err := srv.Service.ImportPRs(context.Background(), "imported", prs)
+	if err != nil || *res != (domain.ImportResult{Teams: 1, Users: 3, PRs: 2}) {
+		t.Fatalf("res=%+v err=%v", res, err)
+	}
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr, err := c.GetPR(ctx, "octo.app:12")
+	if err != nil || pr.Status != domain.StatusMERGED || pr.Repository != "octo/app" ||
+		!pr.CreatedAt.Equal(time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)) || !reflect.DeepEqual(pr.AssignedReviewers, []string{"bob", "carol"}) {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+	tl, err := c.PRTimeline(ctx, "octo.app:12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range tl.Events {
+		kinds = append(kinds, e.Kind)
+	}
+	if !reflect.DeepEqual(kinds, []string{"created", "assigned", "assigned", "approved", "merged"}) ||
+		tl.Events[1].Reason != domain.ReasonImport || !tl.Events[4].At.Equal(*pr.MergedAt) {
+		t.Fatalf("timeline=%+v", tl.Events)
+	}
+	approvals, err := c.TimeToFirstApproval(ctx)
+	if err != nil || len(approvals.ByReviewer) != 1 || approvals.ByReviewer[0].MedianSeconds != 4*3600 {
+		t.Fatalf("approvals=%+v err=%v", approvals, err)
+	}
+	// Existing users keep their team; new ones join the import team.
+	team, err := c.GetTeam(ctx, "imported")
+	if err != nil || len(team.Members) != 3 || team.Members[2].UserID != "dependabot" {
+		t.Fatalf("team=%+v err=%v", team, err)
+	}
+
+	// Importing again changes nothing.
+	if res, err = srv.Service.ImportPRs(context.Background(), "imported", prs); err != nil || *res != (domain.ImportResult{Skipped: 2}) {
+		t.Fatalf("res=%+v err=%v", res, err)
+	}
+
+	gitlab := `[{"iid": 7, "title": "Fix login", "state": "opened", "author": {"username": "bob"},
+		"created_at": "2025-02-01T10:00:00Z", "merged_at": null, "references": {"full": "group/web!7"},
+		"reviewers": [{"username": "dave"}], "approved_by": [{"user": {"username": "erin"}}]}]`
+	if prs, err = importer.Parse(importer.FormatGitLab, strings.NewReader(gitlab)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = srv.Service.ImportPRs(context.Background(), "imported", prs); err != nil {
+		t.Fatal(err)
+	}
+	if pr, err = c.GetPR(ctx, "group.web:7"); err != nil || pr.Status != domain.StatusOPEN || len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("pr=%+v err=%v", pr, err)
+	}
+	if _, err := importer.Parse("bitbucket-json", strings.NewReader("[]")); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.RequestTimeout = 100 * time.Millisecond
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	// The capacity warning of the deactivation holds the request up.
+	release := make(chan struct{})
+	srv.Service.WithCapacityNotifier(func(domain.CapacityWarning) { <-release })
+	_, err := c.SetUserActive(ctx, "u3", false)
+	close(release)
+	if !errors.Is(err, client.ErrTimeout) {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err := c.GetTeam(ctx, "backend"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The long poll may wait longer than the timeout.
+	poll, err := c.PollAssignments(ctx, "u1", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll, err = c.PollAssignments(ctx, "u1", poll.Cursor, time.Second); err != nil || poll.Changed {
+		t.Fatalf("poll=%+v err=%v", poll, err)
+	}
+}
+
+func TestDBTimeout(t *testing.T) {
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.DBTimeout = time.Nanosecond
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob"))
+	c := srv.Client(client.WithRetries(0, 0))
+	ctx := context.Background()
+
+	// The deadline has passed by the time the transaction starts.
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "First", AuthorID: "u1"}); !errors.Is(err, client.ErrTimeout) {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err := srv.Service.GetPR(context.Background(), "pr-1"); err == nil {
+		t.Fatal("timed out PR stored")
+	}
+}
+
+// fakeRedis answers the rate limiter's scripts: the first allow calls are
+// let through, the rest are told to wait 1.5s.
+func fakeRedis(t *testing.T, allow int) (addr string, keys func() []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	serve := func(c net.Conn) {
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			var args []string
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			for i := 0; i < n; i++ {
+				head, _ := r.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(head[1:]))
+				arg := make([]byte, size+2)
+				if _, err := io.ReadFull(r, arg); err != nil {
+					return
+				}
+				args = append(args, string(arg[:size]))
+			}
+			if args[0] == "EVALSHA" {
+				io.WriteString(c, "-NOSCRIPT No matching script.\r\n")
+				continue
+			}
+			mu.Lock()
+			seen = append(seen, args[3])
+			ok := len(seen) <= allow
+			mu.Unlock()
+			if ok {
+				io.WriteString(c, "*2\r\n:1\r\n:0\r\n")
+			} else {
+				io.WriteString(c, "*2\r\n:0\r\n:1500000\r\n")
+			}
+		}
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c)
+		}
+	}()
+	return ln.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(seen)
+	}
+}
+
+func TestRateLimit_Redis(t *testing.T) {
+	addr, keys := fakeRedis(t, 2)
+	rc, err := redis.ParseURL("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.Auth.Limiter = httppkg.NewRateLimiter(map[httppkg.Role]httppkg.RateLimit{
+			httppkg.RoleAdmin: {Rate: 100, Burst: 100},
+		}, httppkg.RateLimit{})
+		h.Auth.Limiter.Shared = httppkg.NewRedisLimiter(rc)
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").ManualAssignment())
+	c := srv.Client(client.WithRetries(0, 0))
+	ctx := context.Background()
+
+	// The local limit is far off; Redis decides.
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetTeam(ctx, "backend"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	_, err = c.GetTeam(ctx, "backend")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrRateLimited) || apiErr.RetryAfter != 2*time.Second {
+		t.Fatalf("err=%v", err)
+	}
+	got := keys()
+	if len(got) != 3 || !strings.HasPrefix(got[0], "prsrv:ratelimit:") || got[2] != got[0] {
+		t.Fatalf("keys=%q", got)
+	}
+}
+
+func TestRateLimit_RedisDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	rc, err := redis.ParseURL("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.Auth.Limiter = httppkg.NewRateLimiter(map[httppkg.Role]httppkg.RateLimit{
+			httppkg.RoleAdmin: {Rate: 0.01, Burst: 2},
+		}, httppkg.RateLimit{})
+		h.Auth.Limiter.Shared = httppkg.NewRedisLimiter(rc)
+	}))
+	c := srv.Client(client.WithRetries(0, 0))
+	ctx := context.Background()
+
+	// Without Redis each replica still limits on its own.
+	for i := 0; i < 2; i++ {
+		if _, err := c.Rebalancing(ctx); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if _, err := c.Rebalancing(ctx); !errors.Is(err, client.ErrRateLimited) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestUpsertTeam(t *testing.T) {
+	forEachStore(t, testUpsertTeam)
+}
+
+func testUpsertTeam(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").ManualAssignment().Member("u1", "Alice").Member("u2", "Bob"))
+	srv.AddTeam(t, testkit.NewTeam("frontend").Member("f1", "Eve"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	team, err := c.UpsertTeam(ctx, domain.Team{TeamName: "backend", Members: []domain.TeamMember{
+		{UserID: "u2", Username: "Bobby", IsActive: true},
+		{UserID: "f1", Username: "Eve", IsActive: true},
+		{UserID: "u3", Username: "C.", IsActive: false},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.TeamMember{
+		{UserID: "f1", Username: "Eve", IsActive: true},
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bobby", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	}
+	if !reflect.DeepEqual(team.Members, want) || !team.ManualAssignment {
+		t.Fatalf("team=%+v", team)
+	}
+	// Sending the same definition again changes nothing.
+	again, err := c.UpsertTeam(ctx, domain.Team{TeamName: "backend", Members: want})
+	if err != nil || !reflect.DeepEqual(again.Members, want) {
+		t.Fatalf("team=%+v err=%v", again, err)
+	}
+	if _, err := c.GetTeam(ctx, "frontend"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("f1 did not move: %v", err)
+	}
+
+	created, err := c.UpsertTeam(ctx, domain.Team{TeamName: "mobile", Members: []domain.TeamMember{{UserID: "m1", Username: "Gina", IsActive: true}}})
+	if err != nil || len(created.Members) != 1 || created.CapacityWarning == nil {
+		t.Fatalf("team=%+v err=%v", created, err)
+	}
+	if _, err := c.AddTeam(ctx, domain.Team{TeamName: "mobile"}); !errors.Is(err, client.ErrTeamExists) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		rolls []float64
+	)
+	// next hands out the queued rolls, then ones that inject nothing.
+	next := func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(rolls) == 0 {
+			return 1
+		}
+		r := rolls[0]
+		rolls = rolls[1:]
+		return r
+	}
+	queue := func(r ...float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		rolls = append(rolls, r...)
+	}
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.Chaos = &httppkg.Chaos{Latency: time.Second, LatencyRate: 0.1, ErrorRate: 0.1, Rand: next}
+		h.RequestTimeout = 200 * time.Millisecond
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client(client.WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+
+	// A failed read is retried; a failed write is left to the caller.
+	queue(1, 0)
+	if _, err := c.GetTeam(ctx, "backend"); err != nil {
+		t.Fatal(err)
+	}
+	queue(1, 0)
+	_, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-1", Name: "Fix", AuthorID: "u1"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err := c.GetPR(ctx, "pr-1"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("the failed create ran: %v", err)
+	}
+
+	// Injected latency runs into the request timeout.
+	queue(0)
+	if _, err := c.CreatePR(ctx, client.CreatePRRequest{ID: "pr-2", Name: "Fix", AuthorID: "u1"}); !errors.Is(err, client.ErrTimeout) {
+		t.Fatalf("err=%v", err)
+	}
+
+	// Health checks are never hit.
+	queue(0, 0)
+	if err := c.Health(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	left := len(rolls)
+	mu.Unlock()
+	if left != 2 {
+		t.Fatalf("health consumed rolls, %d left", left)
+	}
+}
+
+// fakeConnector hands out connections that accept any statement.
+type fakeConnector struct{ closed atomic.Int32 }
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ c *fakeConnector }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { c.c.closed.Add(1); return nil }
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func TestDropConnections(t *testing.T) {
+	fc := &fakeConnector{}
+	db := sql.OpenDB(repo.DropConnections(fc, 1))
+	defer db.Close()
+	if _, err := db.Exec("UPDATE prs SET name = 'x'"); !errors.Is(err, repo.ErrConnDropped) {
+		t.Fatalf("err=%v", err)
+	}
+	if fc.closed.Load() != 1 {
+		t.Fatalf("closed=%d", fc.closed.Load())
+	}
+
+	fc = &fakeConnector{}
+	db = sql.OpenDB(repo.DropConnections(fc, 0))
+	defer db.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("UPDATE prs SET name = 'x'"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fc.closed.Load() != 0 {
+		t.Fatalf("closed=%d", fc.closed.Load())
+	}
+}
+
+func TestCloseReopenPR(t *testing.T) {
+	forEachStore(t, testCloseReopenPR)
+}
+
+func testCloseReopenPR(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	srv.AddTeam(t, testkit.NewTeam("ops").Member("o1", "Olga").Member("o2", "Oleg").ManualAssignment())
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	gone, kept := pr.AssignedReviewers[0], pr.AssignedReviewers[1]
+	closed, err := c.ClosePR(ctx, "pr-1", pr.ETag())
+	if err != nil || closed.Status != domain.StatusCLOSED || closed.ClosedAt == nil || len(closed.AssignedReviewers) != 2 {
+		t.Fatalf("close: pr=%+v err=%v", closed, err)
+	}
+	if again, err := c.ClosePR(ctx, "pr-1", ""); err != nil || !again.ClosedAt.Equal(*closed.ClosedAt) {
+		t.Fatalf("close again: pr=%+v err=%v", again, err)
+	}
+	// A closed PR is out of its reviewers' open load.
+	if open, err := c.OpenReviews(ctx, []string{kept}); err != nil || len(open[kept]) != 0 {
+		t.Fatalf("open reviews=%+v err=%v", open, err)
+	}
+	if _, err := c.MergePR(ctx, "pr-1"); !errors.Is(err, client.ErrPRClosed) {
+		t.Fatalf("merge: err=%v", err)
+	}
+	if _, _, err := c.Reassign(ctx, "pr-1", kept); !errors.Is(err, client.ErrPRClosed) {
+		t.Fatalf("reassign: err=%v", err)
+	}
+
+	// Reopening replaces the reviewer deactivated meanwhile.
+	if _, err := c.SetUserActive(ctx, gone, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReopenPR(ctx, "pr-1", pr.ETag()); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Fatalf("stale etag: err=%v", err)
+	}
+	reopened, err := c.ReopenPR(ctx, "pr-1", "")
+	if err != nil || reopened.Status != domain.StatusOPEN || reopened.ClosedAt != nil {
+		t.Fatalf("reopen: pr=%+v err=%v", reopened, err)
+	}
+	if revs := reopened.AssignedReviewers; len(revs) != 2 || slices.Contains(revs, gone) || !slices.Contains(revs, kept) || slices.Contains(revs, "u1") {
+		t.Fatalf("reviewers=%v", revs)
+	}
+	tl, _ := c.PRTimeline(ctx, "pr-1")
+	if n := len(tl.Events); n < 3 || tl.Events[n-3].Kind != "closed" || tl.Events[n-2].Kind != "reopened" ||
+		tl.Events[n-1].Kind != "replaced" || tl.Events[n-1].UserID != gone || tl.Events[n-1].Reason != "reopen" {
+		t.Fatalf("timeline=%+v", tl.Events)
+	}
+	if open, err := c.OpenReviews(ctx, []string{kept}); err != nil || len(open[kept]) != 1 {
+		t.Fatalf("open reviews=%+v err=%v", open, err)
+	}
+
+	// On a manual team the deactivated reviewer is only removed.
+	srv.CreatePR(t, testkit.NewPR("pr-2", "o1"))
+	if _, err := c.AddReviewer(ctx, "pr-2", "o2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ClosePR(ctx, "pr-2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUserActive(ctx, "o2", false); err != nil {
+		t.Fatal(err)
+	}
+	if reopened, err = c.ReopenPR(ctx, "pr-2", ""); err != nil || len(reopened.AssignedReviewers) != 0 {
+		t.Fatalf("manual team: pr=%+v err=%v", reopened, err)
+	}
+
+	if _, err := c.MergePR(ctx, "pr-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range []func(context.Context, string, string) (*domain.PullRequest, error){c.ClosePR, c.ReopenPR} {
+		if _, err := change(ctx, "pr-1", ""); !errors.Is(err, client.ErrPRMerged) {
+			t.Fatalf("merged PR: err=%v", err)
+		}
+	}
+	if prs, _, err := c.ListPRs(ctx, client.PRFilter{Status: domain.StatusCLOSED}, client.Page{}); err != nil || len(prs) != 0 {
+		t.Fatalf("closed PRs=%+v err=%v", prs, err)
+	}
+}
+
+func TestClosedPRStats(t *testing.T) {
+	forEachStore(t, testClosedPRStats)
+}
+
+func testClosedPRStats(t *testing.T, store testkit.Option) {
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	srv := testkit.Start(t, store, testkit.WithTime(start))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	if _, err := c.ClosePR(ctx, "pr-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock.Advance(30 * 24 * time.Hour)
+
+	// A closed PR is neither open nor waiting on anyone.
+	days, err := c.PRBurndown(ctx, client.DateRange{From: start, To: start.AddDate(0, 0, 30)})
+	if err != nil || len(days) != 31 {
+		t.Fatalf("burndown=%+v err=%v", days, err)
+	}
+	for _, d := range days {
+		if d.Open != 0 {
+			t.Fatalf("burndown day %+v", d)
+		}
+	}
+	if rep, err := c.SLABreaches(ctx, client.SLABreachParams{}); err != nil || len(rep.Teams) != 0 {
+		t.Fatalf("sla breaches=%+v err=%v", rep, err)
+	}
+	rep, err := c.ReviewerResponsiveness(ctx, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rep.Reviewers {
+		if slices.Contains(pr.AssignedReviewers, r.UserID) && (r.Pending != 0 || r.Overdue != 0) {
+			t.Fatalf("responsiveness=%+v", rep.Reviewers)
+		}
+	}
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	forEachStore(t, testGitHubWebhook)
+}
+
+func testGitHubWebhook(t *testing.T, store testkit.Option) {
+	sources, err := httppkg.ParseWebhookSources("github=github:s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := testkit.Start(t, store, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.Webhooks = httppkg.NewWebhooks(sources)
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	if m, err := c.SetGitHubLogin(ctx, "u1", "Alice-GH"); err != nil || m.Login != "alice-gh" {
+		t.Fatalf("set login: m=%+v err=%v", m, err)
+	}
+	if _, err := c.SetGitHubLogin(ctx, "u2", "alice-gh"); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("login of another user: %v", err)
+	}
+	// Racing mappings of one login: exactly one user gets it.
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, u := range []string{"u2", "u3"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = c.SetGitHubLogin(ctx, u, "shared-gh")
+		}()
+	}
+	wg.Wait()
+	if (errs[0] == nil) == (errs[1] == nil) || !errors.Is(errors.Join(errs...), client.ErrInvalid) {
+		t.Fatalf("racing logins: %v", errs)
+	}
+	if _, err := c.SetGitHubLogin(ctx, "u2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetGitHubLogin(ctx, "u3", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deliver := func(event, payload string, signed bool) (int, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+"/api/v1/integrations/github/webhook", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", event)
+		if signed {
+			mac := hmac.New(sha256.New, []byte("s3cret"))
+			mac.Write([]byte(payload))
+			req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	pull := func(action string, number int, login string, merged bool) string {
+		return fmt.Sprintf(`{"action":%q,"number":%d,"pull_request":{"title":"Add search","merged":%t,"user":{"login":%q}},"repository":{"full_name":"octo/app"}}`,
+			action, number, merged, login)
+	}
+	expect := func(status int, out map[string]any, action, prStatus string) {
+		t.Helper()
+		pr, _ := out["pr"].(map[string]any)
+		if status != 200 || out["action"] != action || pr == nil || pr["status"] != prStatus {
+			t.Fatalf("status=%d out=%v, want %s with PR %s", status, out, action, prStatus)
+		}
+	}
+
+	if status, _ := deliver("pull_request", pull("opened", 12, "alice-gh", false), false); status != 401 {
+		t.Fatalf("unsigned: status=%d", status)
+	}
+	if status, out := deliver("ping", `{"zen":"Keep it logically awesome."}`, true); status != 200 || out["action"] != "ignored" {
+		t.Fatalf("ping: status=%d out=%v", status, out)
+	}
+	status, out := deliver("pull_request", pull("opened", 12, "Alice-GH", false), true)
+	expect(status, out, "created", "OPEN")
+	pr, err := c.GetPR(ctx, "octo.app:12")
+	if err != nil || pr.AuthorID != "u1" || pr.Repository != "octo/app" || len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("created pr=%+v err=%v", pr, err)
+	}
+	status, out = deliver("pull_request", pull("opened", 12, "alice-gh", false), true)
+	expect(status, out, "ignored", "OPEN") // redelivered
+	if status, _ := deliver("pull_request", pull("opened", 14, "stranger", false), true); status != 404 {
+		t.Fatalf("unmapped author: status=%d", status)
+	}
+	if status, out := deliver("pull_request", pull("labeled", 12, "alice-gh", false), true); status != 200 || out["action"] != "ignored" {
+		t.Fatalf("labeled: status=%d out=%v", status, out)
+	}
+	// GitHub merged it already, so the team's merge rules do not apply.
+	if _, err := c.SetTeamMergeRules(ctx, "backend", domain.MergeRules{MinApprovals: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MergePR(ctx, "octo.app:12"); !errors.Is(err, client.ErrNotApproved) {
+		t.Fatalf("local merge: err=%v", err)
+	}
+	status, out = deliver("pull_request", pull("closed", 12, "alice-gh", true), true)
+	expect(status, out, "merged", "MERGED")
+
+	status, out = deliver("pull_request", pull("opened", 13, "alice-gh", false), true)
+	expect(status, out, "created", "OPEN")
+	status, out = deliver("pull_request", pull("closed", 13, "alice-gh", false), true)
+	expect(status, out, "closed", "CLOSED")
+	status, out = deliver("pull_request", pull("reopened", 13, "alice-gh", false), true)
+	expect(status, out, "reopened", "OPEN")
+
+	if _, _, err := c.AnonymizeUser(ctx, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := deliver("pull_request", pull("opened", 15, "alice-gh", false), true); status != 404 {
+		t.Fatalf("login of an anonymized user: status=%d", status)
+	}
+}
+
+func TestOutgoingWebhooks(t *testing.T) {
+	forEachStore(t, testOutgoingWebhooks)
+}
+
+func testOutgoingWebhooks(t *testing.T, store testkit.Option) {
+	type received struct {
+		path, event, signature string
+		body                   []byte
+		payload                domain.WebhookPayload
+	}
+	var calls atomic.Int32
+	var failedID atomic.Value
+	got := make(chan received, 16)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			// The first attempt fails and is retried with the same delivery id.
+			failedID.Store(r.Header.Get(httppkg.HeaderWebhookDelivery))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var p domain.WebhookPayload
+		_ = json.Unmarshal(body, &p)
+		got <- received{r.URL.Path, r.Header.Get(httppkg.HeaderWebhookEvent), r.Header.Get(httppkg.HeaderWebhookSig), body, p}
+	}))
+	t.Cleanup(receiver.Close)
+
+	srv := testkit.Start(t, store)
+	dispatcher := httppkg.NewWebhookDispatcher(3, 10*time.Millisecond)
+	t.Cleanup(dispatcher.Close)
+	srv.Service.WithWebhookDispatcher(dispatcher.Enqueue)
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	all, err := c.AddWebhook(ctx, receiver.URL+"/all", nil)
+	if err != nil || len(all.Events) != 3 || all.Secret == "" {
+		t.Fatalf("add: hook=%+v err=%v", all, err)
+	}
+	merged, err := c.AddWebhook(ctx, receiver.URL+"/merged", []string{domain.WebhookPRMerged, domain.WebhookPRMerged})
+	if err != nil || !slices.Equal(merged.Events, []string{domain.WebhookPRMerged}) {
+		t.Fatalf("add merged: hook=%+v err=%v", merged, err)
+	}
+	if _, err := c.AddWebhook(ctx, receiver.URL, []string{"pr_opened"}); !errors.Is(err, client.ErrInvalid) {
+		t.Fatalf("unknown event: err=%v", err)
+	}
+	secrets := map[string]string{"/all": all.Secret, "/merged": merged.Secret}
+
+	receive := func(n int) []received {
+		t.Helper()
+		var out []received
+		for range n {
+			select {
+			case r := <-got:
+				mac := hmac.New(sha256.New, []byte(secrets[r.path]))
+				mac.Write(r.body)
+				if !hmac.Equal([]byte(r.signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+					t.Fatalf("bad signature on %s: %s", r.path, r.body)
+				}
+				if r.event != r.payload.Event {
+					t.Fatalf("header event %q, payload %s", r.event, r.body)
+				}
+				out = append(out, r)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("got %d of %d deliveries", len(out), n)
+			}
+		}
+		return out
+	}
+
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	var assigned, ids []string
+	for _, r := range receive(2) {
+		if r.path != "/all" || r.payload.Event != domain.WebhookReviewerAssigned || r.payload.PRID != "pr-1" {
+			t.Fatalf("assigned delivery: %s %s", r.path, r.body)
+		}
+		assigned = append(assigned, r.payload.PREvent.UserID)
+		ids = append(ids, r.payload.DeliveryID)
+	}
+	slices.Sort(assigned)
+	want := slices.Clone(pr.AssignedReviewers)
+	slices.Sort(want)
+	if !slices.Equal(assigned, want) {
+		t.Fatalf("assigned %v, want %v", assigned, want)
+	}
+	if calls.Load() != 3 || !slices.Contains(ids, failedID.Load().(string)) {
+		t.Fatalf("calls=%d ids=%v failed=%v", calls.Load(), ids, failedID.Load())
+	}
+
+	old := pr.AssignedReviewers[0]
+	_, next, err := c.Reassign(ctx, "pr-1", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := receive(1)[0]; r.payload.Event != domain.WebhookReviewerReplaced || r.payload.PREvent.UserID != old || r.payload.PREvent.ReplacedBy != next {
+		t.Fatalf("replaced delivery: %s", r.body)
+	}
+
+	if _, err := c.MergePR(ctx, "pr-1"); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range receive(2) {
+		if r.payload.Event != domain.WebhookPRMerged {
+			t.Fatalf("merged delivery: %s", r.body)
+		}
+		paths = append(paths, r.path)
+	}
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"/all", "/merged"}) {
+		t.Fatalf("merged deliveries went to %v", paths)
+	}
+
+	if err := c.RemoveWebhook(ctx, all.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveWebhook(ctx, all.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("remove again: err=%v", err)
+	}
+	if hooks, err := c.ListWebhooks(ctx); err != nil || len(hooks) != 1 || hooks[0].ID != merged.ID {
+		t.Fatalf("list: hooks=%+v err=%v", hooks, err)
+	}
+	srv.CreatePR(t, testkit.NewPR("pr-2", "u1"))
+	select {
+	case r := <-got:
+		t.Fatalf("delivery after removal: %s %s", r.path, r.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStrictAuthStatus(t *testing.T) {
+	ctx := context.Background()
+	team := domain.Team{TeamName: "x", Members: []domain.TeamMember{}}
+
+	legacy := testkit.Start(t)
+	if _, err := client.New(legacy.URL).AddTeam(ctx, team); !errors.Is(err, &client.Error{StatusCode: 401, Code: domain.ErrNotFound}) {
+		t.Fatalf("legacy missing token: err=%v", err)
+	}
+
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) { h.Auth.StrictStatus = true }))
+	resp, err := http.Post(srv.URL+"/api/v1/team/add", "application/json", strings.NewReader(`{"team_name":"x","members":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 || resp.Header.Get("WWW-Authenticate") != `Bearer realm="prsrv"` {
+		t.Fatalf("missing token: status=%d www-authenticate=%q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+	if _, err := client.New(srv.URL, client.WithToken("bogus")).AddTeam(ctx, team); !errors.Is(err, &client.Error{StatusCode: 401, Code: domain.ErrUnauthorized}) {
+		t.Fatalf("invalid token: err=%v", err)
+	}
+	if _, err := srv.UserClient().AddTeam(ctx, team); !errors.Is(err, &client.Error{StatusCode: 403, Code: domain.ErrForbidden}) {
+		t.Fatalf("user token on admin route: err=%v", err)
+	}
+	if _, err := srv.Client().AddTeam(ctx, team); err != nil {
+		t.Fatalf("admin: %v", err)
+	}
+}
+
+func TestTokenUsage(t *testing.T) {
+	forEachStore(t, testTokenUsage)
+}
+
+func testTokenUsage(t *testing.T, store testkit.Option) {
+	var usage *httppkg.UsageTracker
+	srv := testkit.Start(t, store, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		usage = httppkg.NewUsageTracker(h.Svc.RecordTokenUsage, time.Hour)
+		h.Auth.Usage = usage
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice"))
+	ctx := context.Background()
+	user := srv.UserClient()
+	if _, err := user.GetTeam(ctx, "backend"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := user.GetTeam(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("missing team: err=%v", err)
+	}
+	usage.Close()
+
+	all, err := srv.Client().TokenUsage(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(all, func(u domain.TokenUsage) bool { return u.Role == "user" && u.Method == "static" })
+	if i < 0 || all[i].Requests != 2 || all[i].Errors != 1 || all[i].LastStatus != 404 {
+		t.Fatalf("usage=%+v", all)
+	}
+}
+
+func TestAdminCIDRs_ReviewerActions(t *testing.T) {
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.Auth.AdminCIDRs, _ = httppkg.ParseCIDRs("10.0.0.0/8")
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol"))
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	ctx := context.Background()
+	reviewer := pr.AssignedReviewers[0]
+	tok, err := srv.Service.IssueToken(ctx, reviewer, "user", "laptop", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test client connects from loopback, outside the allowed network.
+	if _, err := srv.Client().AddTeam(ctx, domain.Team{TeamName: "x", Members: []domain.TeamMember{}}); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("admin route: err=%v", err)
+	}
+	own := client.New(srv.URL, client.WithToken(tok.Token))
+	if _, err := own.AcknowledgeReview(ctx, "pr-1", reviewer); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	if _, err := own.RequestChanges(ctx, "pr-1", reviewer); err != nil {
+		t.Fatalf("request changes: %v", err)
+	}
+}
+
+func TestTokenStoreUnavailable(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	srv := testkit.Start(t, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		lookup := h.Svc.LookupToken
+		h.Auth.Tokens = httppkg.NewTokenCache(func(ctx context.Context, plain string) (*domain.APIToken, error) {
+			if down.Load() {
+				return nil, errors.New("connection refused")
+			}
+			return lookup(ctx, plain)
+		}, time.Minute)
+		h.Auth.Failures = httppkg.NewFailureTracker(2, time.Minute, time.Hour)
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice"))
+	ctx := context.Background()
+	tok, err := srv.Service.IssueToken(ctx, "u1", "user", "laptop", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.New(srv.URL, client.WithToken(tok.Token), client.WithRetries(0, 0))
+
+	// An outage is neither an invalid token nor a failed attempt.
+	for range 3 {
+		if _, err := c.GetTeam(ctx, "backend"); !errors.Is(err, &client.Error{StatusCode: 503, Code: domain.ErrUnavailable}) {
+			t.Fatalf("store down: err=%v", err)
+		}
+	}
+	down.Store(false)
+	if _, err := c.GetTeam(ctx, "backend"); err != nil {
+		t.Fatalf("store back: %v", err)
+	}
+}
+
+func TestSharedUserToken_AttributedWrites(t *testing.T) {
+	srv := testkit.Start(t)
+	ctx := context.Background()
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	reviewer := pr.AssignedReviewers[0]
+	watcher := ""
+	for _, u := range []string{"u2", "u3", "u4"} {
+		if !slices.Contains(pr.AssignedReviewers, u) {
+			watcher = u
+		}
+	}
+
+	// Acknowledgements, comments and watches are recorded under the named
+	// user, so a shared token without a user may not pick one.
+	user := srv.UserClient()
+	if _, err := user.AcknowledgeReview(ctx, "pr-1", reviewer); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	if _, err := user.AddComment(ctx, "pr-1", reviewer, "LGTM", nil); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("comment: %v", err)
+	}
+	if _, err := user.WatchPR(ctx, "pr-1", watcher); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("watch: %v", err)
+	}
+	if _, err := user.UnwatchPR(ctx, "pr-1", watcher); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("unwatch: %v", err)
+	}
+
+	// Reads stay open to it.
+	if _, err := user.UserReviews(ctx, reviewer); err != nil {
+		t.Fatalf("user reviews: %v", err)
+	}
+	// The admin token grants user:any.
+	if _, err := srv.Client().AcknowledgeReview(ctx, "pr-1", reviewer); err != nil {
+		t.Fatalf("admin acknowledge: %v", err)
+	}
+}
+
+func TestWebhookDispatcher_CloseDropsRetries(t *testing.T) {
+	attempted := make(chan struct{}, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+	}))
+	t.Cleanup(receiver.Close)
+	dropped := func() int64 {
+		if v, ok := expvar.Get("webhook_deliveries").(*expvar.Map).Get("dropped").(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+
+	dispatcher := httppkg.NewWebhookDispatcher(3, time.Minute)
+	before := dropped()
+	dispatcher.Enqueue(domain.WebhookDelivery{
+		Webhook: domain.Webhook{ID: "wh-1", URL: receiver.URL, Secret: "s3cret"},
+		Payload: domain.WebhookPayload{DeliveryID: "d-1", Event: domain.WebhookPRMerged, PRID: "pr-1"},
+	})
+	<-attempted
+	// The failed attempt schedules a retry a minute out; Close waits for the
+	// attempt and drops the retry instead of leaving a timer to push into
+	// the stopped dispatcher.
+	dispatcher.Close()
+	if n := dropped() - before; n != 1 {
+		t.Fatalf("dropped %d deliveries on close, want 1", n)
+	}
+}
+
+func TestReadCache_InvalidatedByWrites(t *testing.T) {
+	forEachStore(t, testReadCache_InvalidatedByWrites)
+}
+
+func testReadCache_InvalidatedByWrites(t *testing.T, store testkit.Option) {
+	srv := testkit.Start(t, store, testkit.WithHandlers(func(h *httppkg.Handlers) {
+		h.StatsCache = httppkg.NewResponseCache(time.Hour)
+		h.ReadCache = httppkg.NewResponseCache(time.Hour)
+	}))
+	srv.AddTeam(t, testkit.NewTeam("backend").Member("u1", "Alice").Member("u2", "Bob").Member("u3", "Carol").Member("u4", "Dave"))
+	pr := srv.CreatePR(t, testkit.NewPR("pr-1", "u1"))
+	c := srv.Client()
+	ctx := context.Background()
+
+	get := func(path, etag string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+"/api/v1"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+testkit.AdminToken)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	for _, tc := range []struct {
+		path  string
+		write func() error
+	}{
+		{"/team/get?team_name=backend", func() error {
+			_, err := c.SetUserActive(ctx, "u4", false)
+			return err
+		}},
+		{"/pullRequest/get?pull_request_id=pr-1", func() error {
+			_, _, err := c.Reassign(ctx, "pr-1", pr.AssignedReviewers[0])
+			return err
+		}},
+		{"/stats/prStatus", func() error {
+			_, err := c.MergePR(ctx, "pr-1")
+			return err
+		}},
+	} {
+		first := get(tc.path, "")
+		etag := first.Header.Get("ETag")
+		if first.StatusCode != 200 || etag == "" || first.Header.Get("Last-Modified") == "" ||
+			!strings.HasPrefix(first.Header.Get("Cache-Control"), "private, max-age=") {
+			t.Fatalf("%s: status=%d headers=%v", tc.path, first.StatusCode, first.Header)
+		}
+		if resp := get(tc.path, etag); resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: unchanged status=%d", tc.path, resp.StatusCode)
+		}
+		if err := tc.write(); err != nil {
+			t.Fatal(err)
+		}
+		if resp := get(tc.path, etag); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+			t.Fatalf("%s: after a write status=%d etag=%q", tc.path, resp.StatusCode, resp.Header.Get("ETag"))
+		}
+	}
+	// the PR's own tag survives caching, so it still works in If-Match
+	if resp := get("/pullRequest/get?pull_request_id=pr-1", ""); resp.Header.Get("ETag") == "" {
+		t.Fatal("no ETag")
+	} else if got, _ := c.GetPR(ctx, "pr-1"); got.ETag() != resp.Header.Get("ETag") {
+		t.Fatalf("cached ETag %s, PR %s", resp.Header.Get("ETag"), got.ETag())
+	}
+}